@@ -0,0 +1,251 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"slices"
+	"time"
+
+	"github.com/anastreamer/ana/internal/audio"
+	"github.com/anastreamer/ana/internal/config"
+	"github.com/anastreamer/ana/internal/executor"
+	"github.com/anastreamer/ana/internal/executor/music"
+	"github.com/anastreamer/ana/internal/executor/spotify"
+	"github.com/anastreamer/ana/internal/llm"
+	"github.com/anastreamer/ana/internal/vad"
+	"github.com/anastreamer/ana/internal/wakeword"
+	"github.com/anastreamer/ana/pkg/utils"
+)
+
+// runCommand runs a CLI subcommand (e.g. "ana spotify login").
+// It returns false if args don't name a subcommand so Ana starts normally.
+func runCommand(cfg *config.Config, args []string) (bool, error) {
+	if len(args) == 0 {
+		return false, nil
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	switch args[0] {
+	case "spotify":
+		return true, runSpotifyCommand(ctx, cfg, args[1:])
+	case "wakeword":
+		return true, runWakeWordCommand(cfg, args[1:])
+	case "vad":
+		return true, runVADCommand(ctx, cfg, args[1:])
+	case "devices":
+		return true, runDevicesCommand(cfg)
+	case "prompt":
+		return true, runPromptCommand(ctx, cfg, args[1:])
+	default:
+		return true, fmt.Errorf("unknown command: %s", args[0])
+	}
+}
+
+// runSpotifyCommand handles "ana spotify <subcommand>"
+func runSpotifyCommand(ctx context.Context, cfg *config.Config, args []string) error {
+	if len(args) == 0 || args[0] != "login" {
+		return fmt.Errorf("usage: ana spotify login")
+	}
+	if cfg.Spotify.ClientID == "" {
+		return fmt.Errorf("spotify.client_id is not configured")
+	}
+
+	exec := spotify.NewExecutor(cfg.Spotify)
+	err := exec.Login(ctx, func(authURL string) {
+		fmt.Println("Abre esta URL en tu navegador para conectar Spotify:")
+		fmt.Println(authURL)
+	})
+	if err != nil {
+		return fmt.Errorf("spotify login failed: %w", err)
+	}
+
+	fmt.Printf("✅ Spotify conectado, token guardado en %s\n", cfg.Spotify.TokenFile)
+	return nil
+}
+
+// runDevicesCommand handles "ana devices", which lists the input devices
+// that audio.device can name
+func runDevicesCommand(cfg *config.Config) error {
+	devices, err := audio.ListDevices()
+	if err != nil {
+		return err
+	}
+	if len(devices) == 0 {
+		fmt.Println("No se encontró ningún dispositivo de entrada")
+		return nil
+	}
+
+	fmt.Println("Dispositivos de entrada (usa el índice o el nombre en audio.device):")
+	for _, d := range devices {
+		mark := "  "
+		if d.Default {
+			mark = "⭐"
+		}
+		fmt.Printf("%s %3d  %-40s %d canales, %.0f Hz\n", mark, d.Index, d.Name, d.Channels, d.DefaultRate)
+	}
+	fmt.Printf("\nConfigurado: %q\n", cfg.Audio.Device)
+	return nil
+}
+
+// runPromptCommand handles "ana prompt render [language [persona]]", which
+// prints the system prompt Ana sends to the LLM with the current
+// configuration and prompt templates
+func runPromptCommand(ctx context.Context, cfg *config.Config, args []string) error {
+	if len(args) == 0 || args[0] != "render" || len(args) > 3 {
+		return fmt.Errorf("usage: ana prompt render [language [persona]]")
+	}
+
+	prompts, err := llm.NewPrompts(cfg)
+	if err != nil {
+		return err
+	}
+
+	lang := cfg.General.Language
+	if len(args) > 1 {
+		lang = args[1]
+	}
+	if !slices.Contains(prompts.Languages(), lang) {
+		fmt.Fprintf(os.Stderr, "⚠️  No hay prompt para %q, Ana usaría el de %q\n", lang, cfg.General.Language)
+	}
+	if len(args) > 2 {
+		if _, ok := cfg.Personas.Profiles[args[2]]; !ok {
+			return fmt.Errorf("unknown persona: %s", args[2])
+		}
+		prompts.SetPersona(args[2])
+	}
+
+	// The executors that Ana would register, without connecting them
+	var executors []executor.Executor
+	if cfg.Twitch.Enabled {
+		executors = append(executors, &executor.TwitchExecutor{})
+	}
+	if cfg.OBS.Enabled {
+		executors = append(executors, &executor.OBSExecutor{})
+	}
+	if cfg.Music.Enabled {
+		executors = append(executors, &music.Executor{})
+	}
+	if cfg.Spotify.Enabled {
+		executors = append(executors, &spotify.Executor{})
+	}
+	prompts.SetActions(func() []string {
+		var actions []string
+		for _, e := range executors {
+			actions = append(actions, e.SupportedActions()...)
+		}
+		return actions
+	})
+
+	// The current scene, if OBS is running
+	if cfg.OBS.Enabled {
+		if obs, err := executor.NewOBSExecutor(cfg.OBS); err == nil {
+			defer obs.Close()
+			prompts.SetScene(obs.CurrentScene)
+		}
+	}
+
+	prompt, err := prompts.Render(prompts.Data(ctx, lang))
+	if err != nil {
+		return err
+	}
+	fmt.Println(prompt)
+	return nil
+}
+
+// runWakeWordCommand handles "ana wakeword test <file.wav>...", which runs
+// the detector over recordings and prints the scores to tune the threshold
+func runWakeWordCommand(cfg *config.Config, args []string) error {
+	if len(args) < 2 || args[0] != "test" {
+		return fmt.Errorf("usage: ana wakeword test <file.wav>...")
+	}
+
+	detector, err := wakeword.NewTemplateDetector(cfg.Audio.WakeWord, cfg.Audio.SampleRate)
+	if err != nil {
+		return err
+	}
+
+	for _, path := range args[1:] {
+		detections, best, err := wakeword.ScanWAV(detector, path, cfg.Audio.SampleRate, cfg.Audio.ChunkSize)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("%s: mejor puntuación %.2f (umbral %.2f)\n", path, best, cfg.Audio.WakeWord.Threshold)
+		for _, d := range detections {
+			fmt.Printf("   ✅ \"%s\" en %s (%.2f)\n", d.Word, d.Offset, d.Score)
+		}
+		if len(detections) == 0 {
+			fmt.Println("   ❌ sin detecciones")
+		}
+	}
+	return nil
+}
+
+// runVADCommand handles "ana vad calibrate [noise.wav [speech.wav]]", which
+// measures the room noise (and the streamer's voice) and suggests settings.
+// Without files it records from the microphone.
+func runVADCommand(ctx context.Context, cfg *config.Config, args []string) error {
+	if len(args) == 0 || args[0] != "calibrate" || len(args) > 3 {
+		return fmt.Errorf("usage: ana vad calibrate [noise.wav [speech.wav]]")
+	}
+
+	var noise, speech []int16
+	var err error
+	if len(args) > 1 {
+		if noise, err = readCalibrationWAV(args[1], cfg.Audio.SampleRate); err != nil {
+			return err
+		}
+		if len(args) > 2 {
+			if speech, err = readCalibrationWAV(args[2], cfg.Audio.SampleRate); err != nil {
+				return err
+			}
+		}
+	} else {
+		src, err := initializeAudioSource(cfg)
+		if err != nil {
+			return err
+		}
+		fmt.Println("🤫 Quédate en silencio 5 segundos (deja el juego y la música como en directo)...")
+		if noise, err = audio.Record(ctx, src, cfg.Audio.SampleRate, 5*time.Second); err != nil {
+			return err
+		}
+		fmt.Println("🗣️  Ahora habla con normalidad 5 segundos...")
+		if speech, err = audio.Record(ctx, src, cfg.Audio.SampleRate, 5*time.Second); err != nil {
+			return err
+		}
+	}
+
+	c := vad.Calibrate(cfg.Audio.VAD, noise, speech, cfg.Audio.SampleRate, cfg.Audio.ChunkSize)
+
+	fmt.Println()
+	fmt.Printf("Ruido de fondo:  %.1f dBFS (picos %.1f dBFS)\n", c.NoiseFloorDB, c.NoisePeakDB)
+	if len(speech) > 0 {
+		fmt.Printf("Voz:             %.1f dBFS\n", c.SpeechDB)
+		fmt.Printf("Voz detectada:   %.0f%%\n", c.SpeechHitRate*100)
+	}
+	fmt.Printf("Falsos positivos: %.0f%%\n", c.FalseRate*100)
+	fmt.Println()
+	fmt.Println("Valores sugeridos para audio.vad:")
+	fmt.Printf("  engine: \"%s\"\n", c.Engine)
+	fmt.Printf("  sensitivity: %.2f\n", c.Sensitivity)
+	fmt.Printf("  noise_floor_db: %.0f\n", c.NoiseFloorDB)
+
+	if len(speech) > 0 && c.SpeechDB-c.NoisePeakDB < 6 {
+		fmt.Println()
+		fmt.Println("⚠️  Tu voz apenas supera el ruido: acerca el micro o sube su ganancia.")
+	}
+	return nil
+}
+
+// readCalibrationWAV reads a WAV file at the capture sample rate
+func readCalibrationWAV(path string, sampleRate int) ([]int16, error) {
+	samples, rate, err := utils.ReadWAV(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return utils.Resample(samples, rate, sampleRate), nil
+}
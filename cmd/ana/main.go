@@ -5,18 +5,29 @@ import (
 	"fmt"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
+	"time"
 
 	"github.com/anastreamer/ana/internal/audio"
 	"github.com/anastreamer/ana/internal/brain"
 	"github.com/anastreamer/ana/internal/config"
+	"github.com/anastreamer/ana/internal/ducking"
+	"github.com/anastreamer/ana/internal/echo"
 	"github.com/anastreamer/ana/internal/executor"
+	"github.com/anastreamer/ana/internal/executor/music"
+	"github.com/anastreamer/ana/internal/executor/spotify"
 	"github.com/anastreamer/ana/internal/hotkey"
 	"github.com/anastreamer/ana/internal/llm"
 	"github.com/anastreamer/ana/internal/pipeline"
+	"github.com/anastreamer/ana/internal/songrequest"
+	"github.com/anastreamer/ana/internal/sounds"
 	"github.com/anastreamer/ana/internal/stt"
 	"github.com/anastreamer/ana/internal/tts"
+	"github.com/anastreamer/ana/internal/vocab"
+	"github.com/anastreamer/ana/internal/wakeword"
 	"github.com/anastreamer/ana/pkg/logger"
+	"github.com/anastreamer/ana/pkg/utils"
 )
 
 func main() {
@@ -30,6 +41,15 @@ func main() {
 		os.Exit(1)
 	}
 
+	// Run a subcommand instead of the assistant if one was given
+	if handled, err := runCommand(cfg, os.Args[1:]); handled {
+		if err != nil {
+			logger.Error("Command failed", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	logger.Info("Ana Streamer starting...")
 
 	// Create context
@@ -43,10 +63,28 @@ func main() {
 		logger.Error("Failed to initialize STT provider", err)
 		os.Exit(1)
 	}
+	if len(cfg.General.Languages) > 1 {
+		// Bilingual streams: detect the language of each utterance
+		sttProvider.SetLanguage("auto")
+	}
+	sttBase := sttProvider // For the status, without the streaming wrapper
+	if cfg.STT.Streaming.Enabled {
+		interval := time.Duration(cfg.STT.Streaming.PartialIntervalMs) * time.Millisecond
+		if streamer, err := stt.NewStreamingProvider(sttProvider, interval); err != nil {
+			logger.Warnf("Streaming STT disabled: %v", err)
+		} else {
+			sttProvider = streamer
+		}
+	}
 
 	// Initialize LLM Provider
+	prompts, err := llm.NewPrompts(cfg)
+	if err != nil {
+		logger.Error("Failed to load prompts", err)
+		os.Exit(1)
+	}
 	var llmProvider llm.Provider
-	llmProvider, err = initializeLLM(ctx, cfg)
+	llmProvider, err = initializeLLM(cfg, prompts)
 	if err != nil {
 		logger.Error("Failed to initialize LLM provider", err)
 		os.Exit(1)
@@ -59,8 +97,40 @@ func main() {
 		logger.Warn(fmt.Sprintf("TTS provider not available, continuing without audio: %v", err))
 	}
 
+	// Ducking lowers music while Ana or the streamer speaks
+	var ducker *ducking.Coordinator
+	if cfg.Ducking.Enabled {
+		ducker = ducking.NewCoordinator(cfg.Ducking)
+		if cfg.Ducking.OnTTS {
+			ttsProvider = tts.WithHooks(ttsProvider, tts.Hooks{
+				OnStart: func() { ducker.SetActive(ducking.SourceTTS, true) },
+				OnStop:  func() { ducker.SetActive(ducking.SourceTTS, false) },
+			})
+		}
+		ducker.Start(ctx)
+	}
+
+	// The echo canceller learns Ana's voice from the audio being played
+	var canceller *echo.Canceller
+	if cfg.Audio.Echo.Mode != "off" {
+		canceller = echo.New(cfg.Audio.Echo, cfg.Audio.SampleRate)
+		ttsProvider = tts.WithHooks(ttsProvider, tts.Hooks{
+			OnStart: canceller.PlaybackStarted,
+			OnStop:  canceller.PlaybackStopped,
+			OnPlay:  canceller.PlaybackAudio,
+		})
+	}
+
 	// Create Brain
 	brn := brain.New(llmProvider, ttsProvider)
+	brn.SetConfidenceThresholds(cfg.STT.Confidence.RepeatBelow, cfg.STT.Confidence.ConfirmBelow)
+	brn.SetSTT(sttBase)
+
+	// The vocabulary teaches STT the streamer's own words; executors add theirs
+	var vocabulary *vocab.Vocabulary
+	if cfg.STT.Vocabulary.Enabled {
+		vocabulary = vocab.New(cfg.STT.Vocabulary, cfg.Audio.WakeWord.Word)
+	}
 
 	// Register executors
 	if cfg.Twitch.Enabled {
@@ -77,28 +147,120 @@ func main() {
 		logger.Info("Registering Kick executor")
 		// TODO: Initialize Kick executor when API client is ready
 	}
+	var obsExecutor *executor.OBSExecutor
 	if cfg.OBS.Enabled {
 		logger.Info("Registering OBS executor")
-		obsExecutor, err := executor.NewOBSExecutor(cfg.OBS)
+		var err error
+		obsExecutor, err = executor.NewOBSExecutor(cfg.OBS)
 		if err != nil {
 			logger.Warn(fmt.Sprintf("Failed to initialize OBS executor: %v", err))
 		} else {
 			brn.RegisterExecutor(obsExecutor)
 			logger.Info("OBS executor registered successfully")
+			if vocabulary != nil {
+				vocabulary.AddSource("obs-scenes", obsExecutor.SceneNames)
+				vocabulary.AddSource("obs-inputs", obsExecutor.InputNames)
+			}
 		}
 	}
 	if cfg.Music.Enabled {
 		logger.Info("Registering Music executor")
-		// TODO: Initialize Music executor
+		musicExecutor := music.NewExecutor(cfg.Music)
+		if obsExecutor != nil && cfg.Music.NowPlaying.OBSSource != "" {
+			musicExecutor.SetTextSink(obsExecutor)
+		}
+		brn.RegisterExecutor(musicExecutor)
+		if ducker != nil && cfg.Ducking.Music {
+			ducker.AddTarget(musicExecutor)
+		}
+		if cfg.Music.Requests.Enabled {
+			startSongRequests(ctx, cfg, musicExecutor, vocabulary)
+		}
+		if vocabulary != nil {
+			vocabulary.AddSource("music-artists", musicExecutor.Artists)
+		}
+	}
+	if cfg.Spotify.Enabled {
+		logger.Info("Registering Spotify executor")
+		brn.RegisterExecutor(spotify.NewExecutor(cfg.Spotify))
+	}
+
+	if ducker != nil && obsExecutor != nil && cfg.Ducking.OBSInput != "" {
+		ducker.AddTarget(ducking.NewOBSInput(obsExecutor, cfg.Ducking.OBSInput))
+	}
+
+	// The prompts list what the executors can do and where the stream is
+	prompts.SetActions(brn.GetAvailableActions)
+	if obsExecutor != nil {
+		prompts.SetScene(obsExecutor.CurrentScene)
+	}
+	prompts.Watch(ctx)
+
+	// Simple commands run without waiting for the LLM
+	if cfg.Intents.Enabled {
+		if err := brn.SetIntents(cfg.Intents, cfg.General.Language); err != nil {
+			logger.Warn(fmt.Sprintf("Some intent rules were skipped: %v", err))
+		}
+	}
+
+	// Personas swap the LLM settings, the prompt and the voice
+	if len(cfg.Personas.Profiles) > 0 {
+		brn.SetPersonas(cfg.Personas.Profiles, prompts, func(persona config.PersonaConfig) (llm.Provider, error) {
+			return initializeLLM(personaConfig(cfg, persona), prompts)
+		})
+		if cfg.Personas.Default != "" {
+			if err := brn.SwitchPersona(cfg.Personas.Default); err != nil {
+				logger.Warn(fmt.Sprintf("Cannot start as persona %s: %v", cfg.Personas.Default, err))
+			}
+		}
+	}
+
+	if vocabulary != nil {
+		vocabulary.OnChange(sttProvider.SetPrompt)
+		vocabulary.Start(ctx)
 	}
 
 	// Create Pipeline
 	ppl := pipeline.NewPipeline(cfg, sttProvider, brn)
+	if vocabulary != nil {
+		ppl.SetVocabulary(vocabulary)
+	}
+	if canceller != nil {
+		ppl.SetEchoCanceller(canceller)
+	}
+	if ducker != nil && cfg.Ducking.OnSpeech {
+		ppl.SetSpeechActivityCallback(func(active bool) {
+			ducker.SetActive(ducking.SourceSpeech, active)
+		})
+	}
+
+	if cfg.Sounds.Enabled {
+		cues := sounds.NewPlayer(cfg.Sounds)
+		ppl.SetFollowUpCallback(func(open bool) {
+			if open {
+				cues.FollowUpStart()
+			} else {
+				cues.FollowUpEnd()
+			}
+		})
+	}
+
+	if cfg.Audio.WakeWord.Enabled {
+		detector, err := initializeWakeWord(cfg)
+		if err != nil {
+			logger.Warn(fmt.Sprintf("Wake word detector not available, transcribing speech to find the wake word: %v", err))
+		} else if detector != nil {
+			ppl.SetWakeWordDetector(detector)
+		}
+	}
 
 	// Set callbacks for UI feedback
 	ppl.SetCallbacks(
 		func(state pipeline.State) {
 			logger.Info(fmt.Sprintf("Pipeline state: %s", state.String()))
+			if ducker != nil {
+				ducker.SetActive(ducking.SourceRecording, state == pipeline.StateRecording)
+			}
 		},
 		func(text string) {
 			fmt.Printf("📝 You: %s\n", text)
@@ -118,7 +280,12 @@ func main() {
 	}
 
 	// Initialize audio capture
-	audioCapture, err := audio.Start(ctx, cfg.Audio, ppl, sttProvider, nil)
+	audioSource, err := initializeAudioSource(cfg)
+	if err == nil {
+		err = audioSource.Start(ctx, func(chunk []int16) {
+			ppl.FeedAudio(utils.Int16ToBytes(chunk))
+		})
+	}
 	if err != nil {
 		logger.Error("Failed to initialize audio capture", err)
 		os.Exit(1)
@@ -139,12 +306,22 @@ func main() {
 	fmt.Println("═══════════════════════════════════════════════════════")
 	fmt.Println()
 	fmt.Println("🔊 How to use:")
-	fmt.Println("   1. Say 'Ana' to activate persistent session")
-	fmt.Println("   2. Keep talking - no need to repeat 'Ana'")
-	fmt.Println("   3. Say 'Adiós Ana' or similar to deactivate")
-	fmt.Println()
-	fmt.Println("💬 Deactivation words: adiós, detente, silencio, para ana,")
-	fmt.Println("   cállate, quieta, deja de grabar, stop, adiós ana")
+	switch cfg.Session.Mode {
+	case "persistent":
+		fmt.Println("   1. Say 'Ana' to activate persistent session")
+		fmt.Println("   2. Keep talking - no need to repeat 'Ana'")
+		fmt.Println("   3. Say 'Adiós Ana' or similar to deactivate")
+		fmt.Println()
+		fmt.Println("💬 Deactivation words, on their own: adiós, eso es todo, detente,")
+		fmt.Println("   silencio, para ana, cállate, quieta, stop, goodbye, that's all")
+	case "follow_up":
+		fmt.Println("   1. Start a command with 'Ana' (e.g. 'Ana, crea un clip')")
+		fmt.Printf("   2. For %d seconds after each reply, no need to repeat 'Ana'\n", cfg.Session.FollowUpSeconds)
+		fmt.Println("   3. Say 'Adiós Ana' or similar to stop listening earlier")
+	default:
+		fmt.Println("   Start every command with 'Ana' (e.g. 'Ana, crea un clip')")
+		fmt.Println("   Set session.mode: persistent to keep listening after the first one")
+	}
 	fmt.Println()
 	fmt.Println("⌨️  Hotkey: F4 (press and hold to record)")
 	fmt.Println()
@@ -159,8 +336,8 @@ func main() {
 
 	// Cleanup
 	cancel()
-	if audioCapture != nil {
-		audioCapture.Stop()
+	if audioSource != nil {
+		audioSource.Stop()
 	}
 	if hk != nil {
 		hk.Close()
@@ -170,49 +347,140 @@ func main() {
 	if sttProvider != nil {
 		sttProvider.Close()
 	}
-	if llmProvider != nil {
-		llmProvider.Close()
-	}
-	if ttsProvider != nil {
-		ttsProvider.Close()
+	// The brain closes the executors, the persona's LLM and TTS
+	if err := brn.Close(); err != nil {
+		logger.Warn(fmt.Sprintf("Error during shutdown: %v", err))
 	}
 
 	fmt.Println("✅ Ana Streamer stopped")
 }
 
+// startSongRequests starts every configured song request source. Chat users
+// are added to the vocabulary, if any.
+func startSongRequests(ctx context.Context, cfg *config.Config, musicExecutor *music.Executor, vocabulary *vocab.Vocabulary) {
+	var sources []music.RequestSource
+	if cfg.Music.Requests.HTTP.Enabled {
+		sources = append(sources, songrequest.NewHTTPSource(cfg.Music.Requests.HTTP.Addr))
+	}
+	if cfg.Music.Requests.Chat.Enabled {
+		chat := songrequest.NewTwitchChatSource(cfg.Music.Requests.Chat)
+		if vocabulary != nil {
+			chat.SetChatterCallback(vocabulary.AddChatter)
+		}
+		sources = append(sources, chat)
+	}
+	if cfg.Music.Requests.ChannelPoints.Enabled {
+		sources = append(sources, songrequest.NewChannelPointsSource(cfg.Music.Requests.ChannelPoints, cfg.Twitch))
+	}
+
+	for _, src := range sources {
+		if err := src.Start(ctx, musicExecutor.HandleRequest); err != nil {
+			logger.Warn(fmt.Sprintf("Failed to start %s song requests: %v", src.Name(), err))
+			continue
+		}
+		logger.Info(fmt.Sprintf("Song requests enabled via %s", src.Name()))
+	}
+}
+
+func initializeAudioSource(cfg *config.Config) (audio.AudioSource, error) {
+	switch cfg.Audio.Source {
+	case "portaudio":
+		logger.Info("Initializing PortAudio capture")
+		return newPortAudioSource(cfg)
+	case "pulse":
+		logger.Info("Initializing PulseAudio capture")
+		return audio.NewPulseSource(cfg.Audio)
+	case "file":
+		logger.Info(fmt.Sprintf("Reading audio from %s", cfg.Audio.File))
+		return audio.NewFileSource(cfg.Audio, cfg.Audio.File)
+	case "auto":
+		logger.Info("Trying PortAudio first, fallback to PulseAudio")
+		src, err := newPortAudioSource(cfg)
+		if err != nil {
+			logger.Warn("PortAudio not available, using PulseAudio")
+			return audio.NewPulseSource(cfg.Audio)
+		}
+		return src, nil
+	default:
+		return nil, fmt.Errorf("unknown audio source: %s", cfg.Audio.Source)
+	}
+}
+
+// newPortAudioSource avoids returning a typed nil AudioSource on error
+func newPortAudioSource(cfg *config.Config) (audio.AudioSource, error) {
+	src, err := audio.NewPortAudioSource(cfg.Audio)
+	if err != nil {
+		return nil, err
+	}
+	return src, nil
+}
+
+func initializeWakeWord(cfg *config.Config) (wakeword.Detector, error) {
+	switch cfg.Audio.WakeWord.Engine {
+	case "template":
+		logger.Info("Initializing template wake word detector")
+		return wakeword.NewTemplateDetector(cfg.Audio.WakeWord, cfg.Audio.SampleRate)
+	case "stt":
+		// No detector: the pipeline transcribes speech and looks for the word
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unknown wake word engine: %s", cfg.Audio.WakeWord.Engine)
+	}
+}
+
 func initializeSTT(ctx context.Context, cfg *config.Config) (stt.Provider, error) {
 	switch cfg.STT.Provider {
 	case "whisper":
+		if cfg.STT.Whisper.Mode == "server" {
+			logger.Info("Initializing Whisper server STT provider")
+			return stt.NewWhisperServerProvider(cfg.STT.Whisper)
+		}
 		logger.Info("Initializing Whisper STT provider")
 		return stt.NewWhisperProvider(cfg.STT.Whisper)
 	case "openai":
 		logger.Info("Initializing OpenAI STT provider")
 		return stt.NewOpenAIProvider(cfg.STT.OpenAI)
+	case "auto":
+		logger.Info(fmt.Sprintf("Initializing auto STT provider (order: %v)", cfg.STT.Auto.Order))
+		return stt.NewAutoProvider(cfg)
 	default:
 		return nil, fmt.Errorf("unknown STT provider: %s", cfg.STT.Provider)
 	}
 }
 
-func initializeLLM(ctx context.Context, cfg *config.Config) (llm.Provider, error) {
-	streamerName := cfg.General.StreamerName
-	switch cfg.LLM.Provider {
-	case "ollama":
-		logger.Info("Initializing Ollama LLM provider")
-		return llm.NewOllamaProvider(cfg.LLM.Ollama, streamerName)
-	case "openai":
-		logger.Info("Initializing OpenAI LLM provider")
-		return llm.NewOpenAIProvider(cfg.LLM.OpenAI, streamerName)
-	case "auto":
-		logger.Info("Trying Ollama first, fallback to OpenAI")
-		provider, err := llm.NewOllamaProvider(cfg.LLM.Ollama, streamerName)
-		if err != nil || !provider.IsAvailable(ctx) {
-			logger.Warn("Ollama not available, using OpenAI")
-			return llm.NewOpenAIProvider(cfg.LLM.OpenAI, streamerName)
+func initializeLLM(cfg *config.Config, prompts *llm.Prompts) (llm.Provider, error) {
+	if cfg.LLM.Provider == "auto" {
+		logger.Info(fmt.Sprintf("Initializing auto LLM provider, trying %s", strings.Join(cfg.LLM.Auto.Order, ", ")))
+	} else {
+		logger.Info(fmt.Sprintf("Initializing %s LLM provider", cfg.LLM.Provider))
+	}
+	return llm.New(cfg, prompts)
+}
+
+// personaConfig returns a copy of cfg with the LLM settings of persona
+func personaConfig(cfg *config.Config, persona config.PersonaConfig) *config.Config {
+	c := *cfg
+	c.LLM.Endpoints = make(map[string]config.OpenAILLMConfig, len(cfg.LLM.Endpoints))
+	for name, endpoint := range cfg.LLM.Endpoints {
+		if persona.Temperature > 0 {
+			endpoint.Temperature = persona.Temperature
 		}
-		return provider, nil
-	default:
-		return nil, fmt.Errorf("unknown LLM provider: %s", cfg.LLM.Provider)
+		if persona.MaxTokens > 0 {
+			endpoint.MaxTokens = persona.MaxTokens
+		}
+		c.LLM.Endpoints[name] = endpoint
+	}
+	if persona.Temperature > 0 {
+		c.LLM.Ollama.Temperature = persona.Temperature
+		c.LLM.OpenAI.Temperature = persona.Temperature
+		c.LLM.LlamaCpp.Temperature = persona.Temperature
+	}
+	if persona.MaxTokens > 0 {
+		c.LLM.Ollama.MaxTokens = persona.MaxTokens
+		c.LLM.OpenAI.MaxTokens = persona.MaxTokens
+		c.LLM.LlamaCpp.MaxTokens = persona.MaxTokens
 	}
+	return &c
 }
 
 func initializeTTS(ctx context.Context, cfg *config.Config) (tts.Provider, error) {
@@ -3,6 +3,7 @@ module github.com/anastreamer/ana
 go 1.23.0
 
 require (
+	github.com/andreykaipov/goobs v1.5.6
 	github.com/gordonklaus/portaudio v0.0.0-20221027163845-7c3b689db3cc
 	github.com/gorilla/websocket v1.5.3
 	github.com/rs/zerolog v1.32.0
@@ -11,7 +12,6 @@ require (
 )
 
 require (
-	github.com/andreykaipov/goobs v1.5.6 // indirect
 	github.com/buger/jsonparser v1.1.1 // indirect
 	github.com/christopher-dG/go-obs-websocket v0.0.0-20200720193653-c4fed10356a5 // indirect
 	github.com/fsnotify/fsnotify v1.7.0 // indirect
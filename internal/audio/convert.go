@@ -0,0 +1,89 @@
+package audio
+
+// converter turns the device's native format into the mono stream at the
+// configured rate the pipeline expects, in chunks of a fixed size. It keeps
+// state across calls so chunk boundaries don't click.
+type converter struct {
+	channels int
+	step     float64 // Input samples per output sample
+	pos      float64 // Position of the next output sample, relative to prev
+	prev     int16   // Last mono input sample of the previous call
+	started  bool
+	chunk    int
+	pending  []int16
+}
+
+// newConverter creates a converter from interleaved audio with channels
+// channels at fromRate to mono at toRate, emitted chunkSize samples at a time
+func newConverter(channels int, fromRate, toRate float64, chunkSize int) *converter {
+	return &converter{
+		channels: max(channels, 1),
+		step:     fromRate / toRate,
+		chunk:    max(chunkSize, 1),
+	}
+}
+
+// Write converts interleaved device samples and returns the complete chunks
+// now available
+func (c *converter) Write(in []int16) [][]int16 {
+	mono := downmix(in, c.channels)
+	if c.step == 1 {
+		c.pending = append(c.pending, mono...)
+	} else {
+		c.resample(mono)
+	}
+
+	var chunks [][]int16
+	for len(c.pending) >= c.chunk {
+		chunks = append(chunks, append([]int16(nil), c.pending[:c.chunk]...))
+		c.pending = c.pending[c.chunk:]
+	}
+	return chunks
+}
+
+// resample interpolates linearly between input samples, continuing from
+// where the previous call stopped
+func (c *converter) resample(mono []int16) {
+	if len(mono) == 0 {
+		return
+	}
+	if !c.started {
+		c.pos = 1 // Start at mono[0]; there's no previous sample yet
+		c.started = true
+	}
+
+	// Position 0 is prev, position i+1 is mono[i]
+	sample := func(i int) float64 {
+		if i == 0 {
+			return float64(c.prev)
+		}
+		return float64(mono[i-1])
+	}
+
+	for c.pos < float64(len(mono)) {
+		idx := int(c.pos)
+		frac := c.pos - float64(idx)
+		c.pending = append(c.pending, int16(sample(idx)*(1-frac)+sample(idx+1)*frac))
+		c.pos += c.step
+	}
+
+	c.pos -= float64(len(mono))
+	c.prev = mono[len(mono)-1]
+}
+
+// downmix averages interleaved channels into mono
+func downmix(in []int16, channels int) []int16 {
+	if channels <= 1 {
+		return in
+	}
+
+	mono := make([]int16, len(in)/channels)
+	for i := range mono {
+		var sum int
+		for ch := 0; ch < channels; ch++ {
+			sum += int(in[i*channels+ch])
+		}
+		mono[i] = int16(sum / channels)
+	}
+	return mono
+}
@@ -0,0 +1,72 @@
+package audio
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Device describes an audio input device
+type Device struct {
+	Index       int
+	Name        string
+	Channels    int     // Maximum input channels
+	DefaultRate float64 // Default sample rate in Hz
+	Default     bool    // The system's default input
+}
+
+// selectDevice picks the input device named by spec (AudioConfig.Device):
+// "default" or empty for the system default, a device index, or a name.
+// Names match exactly (ignoring case) or, failing that, as a substring
+// of a single device.
+func selectDevice(devices []Device, spec string) (Device, error) {
+	spec = strings.TrimSpace(spec)
+
+	var inputs []Device
+	for _, d := range devices {
+		if d.Channels > 0 {
+			inputs = append(inputs, d)
+		}
+	}
+
+	if spec == "" || strings.EqualFold(spec, "default") {
+		for _, d := range inputs {
+			if d.Default {
+				return d, nil
+			}
+		}
+		return Device{}, fmt.Errorf("no default input device")
+	}
+
+	if index, err := strconv.Atoi(spec); err == nil {
+		for _, d := range inputs {
+			if d.Index == index {
+				return d, nil
+			}
+		}
+		return Device{}, fmt.Errorf("no input device with index %d (list them with: ana devices)", index)
+	}
+
+	var matches []Device
+	for _, d := range inputs {
+		if strings.EqualFold(d.Name, spec) {
+			return d, nil
+		}
+		if strings.Contains(strings.ToLower(d.Name), strings.ToLower(spec)) {
+			matches = append(matches, d)
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return Device{}, fmt.Errorf("no input device matches %q (list them with: ana devices)", spec)
+	case 1:
+		return matches[0], nil
+	default:
+		names := make([]string, len(matches))
+		for i, d := range matches {
+			names[i] = fmt.Sprintf("%d: %s", d.Index, d.Name)
+		}
+		return Device{}, fmt.Errorf("%q matches several input devices (%s); use its index or full name", spec, strings.Join(names, ", "))
+	}
+}
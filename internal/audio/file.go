@@ -0,0 +1,199 @@
+package audio
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/anastreamer/ana/internal/config"
+	"github.com/anastreamer/ana/pkg/logger"
+	"github.com/rs/zerolog"
+)
+
+// FileSource reads audio from a WAV or raw file, or from stdin when the path
+// is "-" (e.g. piped from arecord or ffmpeg). WAV input is detected by its
+// header; anything else is raw 16-bit little-endian PCM with the configured
+// channels and sample rate. Files are played back in real time so the
+// pipeline's timing works as with a mic; stdin is read as it arrives.
+type FileSource struct {
+	cfg  config.AudioConfig
+	path string
+	log  zerolog.Logger
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewFileSource creates a source reading path, or stdin for "-"
+func NewFileSource(cfg config.AudioConfig, path string) (*FileSource, error) {
+	if path == "" {
+		return nil, fmt.Errorf("audio file is not configured")
+	}
+	return &FileSource{
+		cfg:  cfg,
+		path: path,
+		log:  logger.Component("audio"),
+	}, nil
+}
+
+// Name implements AudioSource
+func (s *FileSource) Name() string {
+	if s.path == "-" {
+		return "stdin"
+	}
+	return s.path
+}
+
+// Start implements AudioSource
+func (s *FileSource) Start(ctx context.Context, onChunk func([]int16)) error {
+	var r io.ReadCloser = os.Stdin
+	if s.path != "-" {
+		f, err := os.Open(s.path)
+		if err != nil {
+			return fmt.Errorf("open audio file: %w", err)
+		}
+		r = f
+	}
+
+	in := bufio.NewReader(r)
+	channels, rate, err := readFormat(in, s.cfg)
+	if err != nil {
+		r.Close()
+		return fmt.Errorf("read %s: %w", s.Name(), err)
+	}
+	s.log.Info().Str("source", s.Name()).Int("channels", channels).Int("rate", rate).Msg("Audio input opened")
+
+	ctx, s.cancel = context.WithCancel(ctx)
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer r.Close()
+
+		conv := newConverter(channels, float64(rate), float64(s.cfg.SampleRate), s.cfg.ChunkSize)
+		if err := s.read(ctx, in, channels, conv, onChunk); err != nil {
+			s.log.Error().Err(err).Str("source", s.Name()).Msg("Audio input failed")
+			return
+		}
+		s.log.Info().Str("source", s.Name()).Msg("Audio input finished")
+	}()
+
+	// Stdin blocks in Read; closing it is the only way to unblock on Stop
+	if s.path == "-" {
+		go func() {
+			<-ctx.Done()
+			r.Close()
+		}()
+	}
+	return nil
+}
+
+// Stop implements AudioSource
+func (s *FileSource) Stop() {
+	if s.cancel != nil {
+		s.cancel()
+	}
+	s.wg.Wait()
+}
+
+// read delivers the audio in chunks until EOF, pacing files in real time
+func (s *FileSource) read(ctx context.Context, in io.Reader, channels int, conv *converter, onChunk func([]int16)) error {
+	buf := make([]byte, s.cfg.ChunkSize*channels*2)
+	chunkTime := time.Duration(s.cfg.ChunkSize) * time.Second / time.Duration(s.cfg.SampleRate)
+	realtime := s.path != "-"
+	next := time.Now()
+
+	for {
+		n, err := io.ReadFull(in, buf)
+		n -= n % (channels * 2)
+		if n > 0 {
+			samples := make([]int16, n/2)
+			for i := range samples {
+				samples[i] = int16(binary.LittleEndian.Uint16(buf[2*i:]))
+			}
+			for _, chunk := range conv.Write(samples) {
+				if realtime {
+					next = next.Add(chunkTime)
+					select {
+					case <-ctx.Done():
+						return nil
+					case <-time.After(time.Until(next)):
+					}
+				}
+				if ctx.Err() != nil {
+					return nil
+				}
+				onChunk(chunk)
+			}
+		}
+
+		switch {
+		case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+			return nil
+		case err != nil:
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+// readFormat consumes a WAV header if there is one and returns the channels
+// and sample rate of the audio that follows
+func readFormat(in *bufio.Reader, cfg config.AudioConfig) (int, int, error) {
+	magic, err := in.Peek(4)
+	if err != nil && len(magic) == 0 {
+		return 0, 0, err
+	}
+	if !bytes.Equal(magic, []byte("RIFF")) {
+		return cfg.Channels, cfg.SampleRate, nil
+	}
+
+	header := make([]byte, 12)
+	if _, err := io.ReadFull(in, header); err != nil {
+		return 0, 0, err
+	}
+	if string(header[8:12]) != "WAVE" {
+		return 0, 0, fmt.Errorf("not a WAV file")
+	}
+
+	// Walk the chunks up to "data", reading the format from "fmt "
+	var channels, rate int
+	for {
+		chunk := make([]byte, 8)
+		if _, err := io.ReadFull(in, chunk); err != nil {
+			return 0, 0, fmt.Errorf("no data chunk: %w", err)
+		}
+		id := string(chunk[:4])
+		size := int64(binary.LittleEndian.Uint32(chunk[4:]))
+
+		if id == "data" {
+			if channels == 0 {
+				return 0, 0, fmt.Errorf("no fmt chunk before data")
+			}
+			return channels, rate, nil
+		}
+
+		body := make([]byte, size+size%2) // Chunks are padded to even sizes
+		if _, err := io.ReadFull(in, body); err != nil {
+			return 0, 0, err
+		}
+		if id == "fmt " && len(body) >= 16 {
+			format := binary.LittleEndian.Uint16(body[0:])
+			bits := binary.LittleEndian.Uint16(body[14:])
+			// 0xFFFE is WAVE_FORMAT_EXTENSIBLE, which ffmpeg uses for >2 channels
+			if (format != 1 && format != 0xFFFE) || bits != 16 {
+				return 0, 0, fmt.Errorf("unsupported WAV format (need 16-bit PCM)")
+			}
+			channels = int(binary.LittleEndian.Uint16(body[2:]))
+			rate = int(binary.LittleEndian.Uint32(body[4:]))
+		}
+	}
+}
@@ -0,0 +1,247 @@
+//go:build portaudio
+
+package audio
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/anastreamer/ana/internal/config"
+	"github.com/anastreamer/ana/pkg/logger"
+	"github.com/gordonklaus/portaudio"
+	"github.com/rs/zerolog"
+)
+
+// PortAudioSource captures from a device through PortAudio. When the device
+// disappears (e.g. a USB mic is unplugged) it waits for it and reopens it.
+type PortAudioSource struct {
+	cfg    config.AudioConfig
+	log    zerolog.Logger
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	// Owned by the watch goroutine once started
+	stream      *portaudio.Stream
+	initialized bool
+	onChunk     func([]int16)
+	lastAudio   atomic.Int64 // UnixNano of the last chunk received
+}
+
+// NewPortAudioSource creates a PortAudio source for cfg.Device
+func NewPortAudioSource(cfg config.AudioConfig) (*PortAudioSource, error) {
+	return &PortAudioSource{
+		cfg: cfg,
+		log: logger.Component("audio"),
+	}, nil
+}
+
+// Name implements AudioSource
+func (s *PortAudioSource) Name() string {
+	return "portaudio"
+}
+
+// Start implements AudioSource
+func (s *PortAudioSource) Start(ctx context.Context, onChunk func([]int16)) error {
+	if err := portaudio.Initialize(); err != nil {
+		return fmt.Errorf("portaudio init: %w", err)
+	}
+	s.initialized = true
+	s.onChunk = onChunk
+
+	if err := s.open(); err != nil {
+		portaudio.Terminate()
+		s.initialized = false
+		return err
+	}
+
+	ctx, s.cancel = context.WithCancel(ctx)
+	s.wg.Add(1)
+	go s.watch(ctx)
+	return nil
+}
+
+// Stop implements AudioSource
+func (s *PortAudioSource) Stop() {
+	if s.cancel != nil {
+		s.cancel()
+	}
+	s.wg.Wait()
+}
+
+// open opens and starts a stream on the configured device
+func (s *PortAudioSource) open() error {
+	stream, device, err := openInput(s.cfg, func(chunk []int16) {
+		s.lastAudio.Store(time.Now().UnixNano())
+		s.onChunk(chunk)
+	})
+	if err != nil {
+		return err
+	}
+
+	s.lastAudio.Store(time.Now().UnixNano())
+	if err := stream.Start(); err != nil {
+		stream.Close()
+		return fmt.Errorf("start stream: %w", err)
+	}
+
+	s.stream = stream
+	s.log.Info().Int("index", device.Index).Str("device", device.Name).Msg("Audio input opened")
+	return nil
+}
+
+// close stops and closes the current stream, if any
+func (s *PortAudioSource) close() {
+	if s.stream == nil {
+		return
+	}
+	s.stream.Stop()
+	s.stream.Close()
+	s.stream = nil
+}
+
+// watch reopens the stream when its device stops delivering audio, and
+// releases PortAudio on Stop
+func (s *PortAudioSource) watch(ctx context.Context) {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(reopenInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			s.close()
+			if s.initialized {
+				portaudio.Terminate()
+				s.initialized = false
+			}
+			return
+		case <-ticker.C:
+		}
+
+		if s.stream != nil {
+			if time.Since(time.Unix(0, s.lastAudio.Load())) < stallTimeout {
+				continue
+			}
+			s.log.Warn().Msg("Audio input stopped, waiting for the device to come back")
+			s.close()
+		}
+		s.reopen()
+	}
+}
+
+// reopen restarts PortAudio, which only sees new devices after that, and
+// tries to open the configured device again
+func (s *PortAudioSource) reopen() {
+	if s.initialized {
+		portaudio.Terminate()
+		s.initialized = false
+	}
+	if err := portaudio.Initialize(); err != nil {
+		s.log.Debug().Err(err).Msg("PortAudio init failed")
+		return
+	}
+	s.initialized = true
+
+	if err := s.open(); err != nil {
+		s.log.Debug().Err(err).Msg("Audio device not available yet")
+		return
+	}
+	s.log.Info().Msg("Audio input reconnected")
+}
+
+// openInput opens (without starting) a stream on the device named by
+// cfg.Device. onChunk receives mono audio at cfg.SampleRate in chunks of
+// cfg.ChunkSize samples; when the device can't deliver that directly it is
+// opened in its own format and converted.
+func openInput(cfg config.AudioConfig, onChunk func([]int16)) (*portaudio.Stream, Device, error) {
+	devices, infos, err := listDevices()
+	if err != nil {
+		return nil, Device{}, err
+	}
+	device, err := selectDevice(devices, cfg.Device)
+	if err != nil {
+		return nil, Device{}, err
+	}
+	info := infos[device.Index]
+
+	channels := min(cfg.Channels, info.MaxInputChannels)
+	formats := []struct {
+		channels int
+		rate     float64
+	}{
+		{channels, float64(cfg.SampleRate)},
+		{channels, info.DefaultSampleRate},
+		{info.MaxInputChannels, info.DefaultSampleRate},
+	}
+
+	var lastErr error
+	for _, f := range formats {
+		params := portaudio.StreamParameters{
+			Input: portaudio.StreamDeviceParameters{
+				Device:   info,
+				Channels: f.channels,
+				Latency:  info.DefaultLowInputLatency,
+			},
+			SampleRate:      f.rate,
+			FramesPerBuffer: int(float64(cfg.ChunkSize) * f.rate / float64(cfg.SampleRate)),
+		}
+		conv := newConverter(f.channels, f.rate, float64(cfg.SampleRate), cfg.ChunkSize)
+
+		stream, err := portaudio.OpenStream(params, func(in []int16) {
+			for _, chunk := range conv.Write(in) {
+				onChunk(chunk)
+			}
+		})
+		if err == nil {
+			return stream, device, nil
+		}
+		lastErr = err
+	}
+	return nil, Device{}, fmt.Errorf("open stream on %q: %w", device.Name, lastErr)
+}
+
+// listDevices lists all devices; PortAudio must be initialized
+func listDevices() ([]Device, []*portaudio.DeviceInfo, error) {
+	infos, err := portaudio.Devices()
+	if err != nil {
+		return nil, nil, fmt.Errorf("list devices: %w", err)
+	}
+	defaultInput, _ := portaudio.DefaultInputDevice()
+
+	devices := make([]Device, len(infos))
+	for i, info := range infos {
+		devices[i] = Device{
+			Index:       i,
+			Name:        info.Name,
+			Channels:    info.MaxInputChannels,
+			DefaultRate: info.DefaultSampleRate,
+			Default:     info == defaultInput,
+		}
+	}
+	return devices, infos, nil
+}
+
+// ListDevices returns the audio devices that can record, for "ana devices"
+func ListDevices() ([]Device, error) {
+	if err := portaudio.Initialize(); err != nil {
+		return nil, fmt.Errorf("portaudio init: %w", err)
+	}
+	defer portaudio.Terminate()
+
+	devices, _, err := listDevices()
+	if err != nil {
+		return nil, err
+	}
+
+	var inputs []Device
+	for _, d := range devices {
+		if d.Channels > 0 {
+			inputs = append(inputs, d)
+		}
+	}
+	return inputs, nil
+}
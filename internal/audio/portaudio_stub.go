@@ -0,0 +1,31 @@
+//go:build !portaudio
+
+package audio
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/anastreamer/ana/internal/config"
+)
+
+// PortAudioSource is unavailable without the portaudio build tag
+type PortAudioSource struct{}
+
+// NewPortAudioSource returns a stub error when PortAudio is disabled.
+func NewPortAudioSource(cfg config.AudioConfig) (*PortAudioSource, error) {
+	return nil, fmt.Errorf("PortAudio build tag is required for audio capture")
+}
+
+// ListDevices returns a stub error when PortAudio is disabled.
+func ListDevices() ([]Device, error) {
+	return nil, fmt.Errorf("PortAudio build tag is required for audio capture")
+}
+
+func (s *PortAudioSource) Name() string { return "portaudio" }
+
+func (s *PortAudioSource) Start(ctx context.Context, onChunk func([]int16)) error {
+	return fmt.Errorf("PortAudio build tag is required for audio capture")
+}
+
+func (s *PortAudioSource) Stop() {}
@@ -0,0 +1,139 @@
+package audio
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/anastreamer/ana/internal/config"
+	"github.com/anastreamer/ana/pkg/logger"
+	"github.com/rs/zerolog"
+)
+
+// PulseSource captures through PulseAudio, or PipeWire's PulseAudio server,
+// by running parec. It needs no cgo, and the sound server converts the
+// format and follows the default source. When parec exits (e.g. the source
+// was unplugged) it is started again until the source comes back.
+type PulseSource struct {
+	cfg  config.AudioConfig
+	path string
+	log  zerolog.Logger
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewPulseSource creates a PulseAudio source for cfg.Device, a source name
+// or index as listed by "pactl list short sources"
+func NewPulseSource(cfg config.AudioConfig) (*PulseSource, error) {
+	path, err := exec.LookPath("parec")
+	if err != nil {
+		return nil, fmt.Errorf("parec not found (install pulseaudio-utils): %w", err)
+	}
+	return &PulseSource{
+		cfg:  cfg,
+		path: path,
+		log:  logger.Component("audio"),
+	}, nil
+}
+
+// Name implements AudioSource
+func (s *PulseSource) Name() string {
+	return "pulse"
+}
+
+// Start implements AudioSource
+func (s *PulseSource) Start(ctx context.Context, onChunk func([]int16)) error {
+	ctx, s.cancel = context.WithCancel(ctx)
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+
+		for {
+			start := time.Now()
+			err := s.record(ctx, onChunk)
+			if ctx.Err() != nil {
+				return
+			}
+
+			// Only a recorder that ran for a while is worth a warning; the
+			// rest are retries while the source is missing
+			if time.Since(start) > stallTimeout {
+				s.log.Warn().Err(err).Msg("Audio input stopped, waiting for the device to come back")
+			} else {
+				s.log.Debug().Err(err).Msg("Audio device not available yet")
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(reopenInterval):
+			}
+		}
+	}()
+	return nil
+}
+
+// Stop implements AudioSource
+func (s *PulseSource) Stop() {
+	if s.cancel != nil {
+		s.cancel()
+	}
+	s.wg.Wait()
+}
+
+// record runs parec until it exits, delivering its output in chunks
+func (s *PulseSource) record(ctx context.Context, onChunk func([]int16)) error {
+	args := []string{
+		"--raw",
+		"--format=s16le",
+		"--rate=" + strconv.Itoa(s.cfg.SampleRate),
+		"--channels=1",
+		"--latency-msec=50",
+		"--client-name=ana",
+	}
+	if s.cfg.Device != "" && !strings.EqualFold(s.cfg.Device, "default") {
+		args = append(args, "--device="+s.cfg.Device)
+	}
+
+	cmd := exec.CommandContext(ctx, s.path, args...)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("start parec: %w", err)
+	}
+
+	buf := make([]byte, s.cfg.ChunkSize*2)
+	for opened := false; ; opened = true {
+		if _, err := io.ReadFull(stdout, buf); err != nil {
+			break
+		}
+		if !opened {
+			s.log.Info().Str("device", s.cfg.Device).Msg("Audio input opened")
+		}
+		chunk := make([]int16, s.cfg.ChunkSize)
+		for i := range chunk {
+			chunk[i] = int16(binary.LittleEndian.Uint16(buf[2*i:]))
+		}
+		onChunk(chunk)
+	}
+
+	if err := cmd.Wait(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("parec: %s", msg)
+		}
+		return fmt.Errorf("parec: %w", err)
+	}
+	return fmt.Errorf("parec exited")
+}
@@ -0,0 +1,66 @@
+// Package audio captures the streamer's microphone from one of several
+// backends (PortAudio, PulseAudio/PipeWire, a file or stdin)
+package audio
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// A live source that delivers no audio for stallTimeout has lost its
+// device; it is reopened every reopenInterval until the device comes back
+const (
+	stallTimeout   = 2 * time.Second
+	reopenInterval = time.Second
+)
+
+// AudioSource delivers the microphone as mono 16-bit samples at
+// AudioConfig.SampleRate, in chunks of AudioConfig.ChunkSize samples
+type AudioSource interface {
+	// Start begins capturing. onChunk is called from a capture goroutine
+	// until ctx is done or Stop is called.
+	Start(ctx context.Context, onChunk func([]int16)) error
+	// Stop ends capturing and waits for the capture goroutine to exit
+	Stop()
+	// Name identifies the source in logs
+	Name() string
+}
+
+// Record captures d of audio from src, for one-off measurements like
+// "ana vad calibrate"
+func Record(ctx context.Context, src AudioSource, sampleRate int, d time.Duration) ([]int16, error) {
+	total := int(d.Seconds() * float64(sampleRate))
+	samples := make([]int16, 0, total)
+	done := make(chan struct{})
+	var mu sync.Mutex
+
+	err := src.Start(ctx, func(chunk []int16) {
+		mu.Lock()
+		defer mu.Unlock()
+		if len(samples) >= total {
+			return
+		}
+		samples = append(samples, chunk...)
+		if len(samples) >= total {
+			close(done)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer src.Stop()
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-time.After(d + stallTimeout):
+		return nil, fmt.Errorf("not enough audio from %s", src.Name())
+	case <-done:
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	return samples[:total], nil
+}
@@ -7,9 +7,12 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
 
+	"github.com/anastreamer/ana/internal/config"
 	"github.com/anastreamer/ana/internal/executor"
 	"github.com/anastreamer/ana/internal/llm"
+	"github.com/anastreamer/ana/internal/stt"
 	"github.com/anastreamer/ana/internal/tts"
 	"github.com/anastreamer/ana/pkg/logger"
 	"github.com/rs/zerolog"
@@ -17,16 +20,35 @@ import (
 
 // Brain is the central orchestrator that processes commands
 type Brain struct {
-	llmProvider llm.Provider
+	llmProvider *sharedLLM
 	ttsProvider tts.Provider
+	sttProvider stt.Provider // Only reported in the status, the pipeline owns it
 	registry    *executor.Registry
 	log         zerolog.Logger
+
+	// Spoken commands heard with less confidence than repeatBelow are asked
+	// again; below confirmBelow, actions wait for a yes (pending)
+	repeatBelow  float64
+	confirmBelow float64
+	pending      *pendingAction
+	pendingMu    sync.Mutex
+
+	// Commands matching an intent rule skip the LLM (see intents.go)
+	intents *intentMatcher
+
+	// The LLM changes with the persona (see persona.go)
+	mu            sync.RWMutex
+	personas      map[string]config.PersonaConfig
+	prompts       *llm.Prompts
+	newLLM        LLMFactory
+	persona       string // Active persona, "" for none
+	personaConfig config.PersonaConfig
 }
 
 // New creates a new Brain instance
 func New(llmProvider llm.Provider, ttsProvider tts.Provider) *Brain {
 	return &Brain{
-		llmProvider: llmProvider,
+		llmProvider: &sharedLLM{Provider: llmProvider},
 		ttsProvider: ttsProvider,
 		registry:    executor.NewRegistry(),
 		log:         logger.Component("brain"),
@@ -44,18 +66,102 @@ func (b *Brain) RegisterExecutor(exec executor.Executor) {
 
 // ProcessCommand processes a voice command and returns the response
 func (b *Brain) ProcessCommand(ctx context.Context, text string) (string, error) {
-	b.log.Info().Str("input", text).Msg("Processing command")
+	return b.ProcessTranscript(ctx, text, 1, nil)
+}
+
+// ProcessTranscript processes a command the STT heard with the given
+// confidence (0-1). When it's low Ana asks the streamer to repeat it, or to
+// confirm the action before running it. action is the intent already
+// interpreted from text, if any.
+func (b *Brain) ProcessTranscript(ctx context.Context, text string, confidence float64, action *llm.Action) (string, error) {
+	response, _, err := b.ProcessTranscriptStream(ctx, text, confidence, action, nil)
+	return response, err
+}
+
+// ProcessTranscriptStream is ProcessTranscript with a streaming LLM request
+// when speak is set: see stream. It returns the response and the part of it
+// that wasn't passed to speak.
+func (b *Brain) ProcessTranscriptStream(ctx context.Context, text string, confidence float64, action *llm.Action, speak func(sentence string)) (string, string, error) {
+	b.log.Info().Str("input", text).Float64("confidence", confidence).Msg("Processing command")
+	lang := llm.LanguageFromContext(ctx)
+	ctx = b.personaContext(ctx)
+
+	if response, ok := b.answerPending(ctx, text); ok {
+		return response, response, nil
+	}
+
+	if confidence < b.repeatBelow {
+		b.log.Info().Float64("confidence", confidence).Msg("Transcription too unsure, asking to repeat")
+		response := localize(ctx, "Perdona, no te he entendido bien. ¿Me lo repites?")
+		return response, response, nil
+	}
+
+	// Simple commands don't need the LLM, even when it's down
+	if action == nil {
+		if matched, ok := b.matchIntent(text, lang); ok {
+			action = &matched
+		}
+	}
+
+	if action == nil {
+		provider, done := b.useLLM()
+		available := provider != nil && provider.IsAvailable(ctx)
+		done()
+		if !available {
+			b.log.Warn().Msg("LLM provider is not available, skipping command")
+			response := localize(ctx, "No hay ningún proveedor de IA disponible. Revisa tu configuración o prueba más tarde.")
+			return response, response, nil
+		}
+
+		if speak != nil {
+			return b.stream(ctx, text, confidence, speak)
+		}
+
+		interpreted, err := b.Interpret(ctx, text)
+		if err != nil {
+			return "", "", err
+		}
+		action = &interpreted
+	}
+
+	if confidence < b.confirmBelow && needsConfirmation(*action) {
+		b.log.Info().
+			Str("action", action.Action).
+			Float64("confidence", confidence).
+			Msg("Transcription unsure, asking to confirm the action")
+		b.setPending(text, *action)
+		response := fmt.Sprintf(localize(ctx, "No sé si te he entendido bien: «%s». ¿Lo hago?"), text)
+		return response, response, nil
+	}
+
+	response, err := b.Execute(ctx, *action)
+	return response, response, err
+}
+
+// SetConfidenceThresholds sets below which transcription confidence Ana asks
+// to repeat a command, and below which she asks before running its action
+func (b *Brain) SetConfidenceThresholds(repeatBelow, confirmBelow float64) {
+	b.repeatBelow = repeatBelow
+	b.confirmBelow = confirmBelow
+}
+
+// Interpret asks the LLM which action a command means without running it,
+// so it can be done ahead of time (e.g. from a partial transcript)
+func (b *Brain) Interpret(ctx context.Context, text string) (llm.Action, error) {
+	if action, ok := b.matchIntent(text, llm.LanguageFromContext(ctx)); ok {
+		return action, nil
+	}
 
-	if b.llmProvider == nil || !b.llmProvider.IsAvailable(ctx) {
-		b.log.Warn().Msg("LLM provider is not available, skipping command")
-		return "No hay ningún proveedor de IA disponible. Revisa tu configuración o prueba más tarde.", nil
+	provider, done := b.useLLM()
+	defer done()
+	if provider == nil {
+		return llm.Action{}, fmt.Errorf("no LLM provider")
 	}
 
-	// Get action from LLM
-	action, err := b.llmProvider.Complete(ctx, text)
+	action, err := provider.Complete(b.personaContext(ctx), text)
 	if err != nil {
 		b.log.Error().Err(err).Msg("LLM completion failed")
-		return "", fmt.Errorf("failed to interpret command: %w", err)
+		return llm.Action{}, fmt.Errorf("failed to interpret command: %w", err)
 	}
 
 	b.log.Debug().
@@ -64,6 +170,13 @@ func (b *Brain) ProcessCommand(ctx context.Context, text string) (string, error)
 		Str("reply", action.Reply).
 		Msg("LLM response")
 
+	return action, nil
+}
+
+// Execute runs an action returned by Interpret and returns the response
+func (b *Brain) Execute(ctx context.Context, action llm.Action) (string, error) {
+	action.Reply = b.limitReply(action.Reply)
+
 	// Handle special actions
 	if action.Action == "none" || action.Action == "" {
 		// Just respond without executing
@@ -82,12 +195,21 @@ func (b *Brain) ProcessCommand(ctx context.Context, text string) (string, error)
 		return b.handleCalc(ctx, action)
 	}
 
+	if action.Action == "system.persona" {
+		return b.handlePersona(ctx, action)
+	}
+
 	// Execute the action
 	result, err := b.registry.Execute(ctx, action)
+	return b.respond(ctx, action, result, err), nil
+}
+
+// respond turns the outcome of an executor's action into Ana's response
+func (b *Brain) respond(ctx context.Context, action llm.Action, result executor.Result, err error) string {
 	if err != nil {
 		b.log.Error().Err(err).Str("action", action.Action).Msg("Action execution failed")
 		// Return the LLM's reply anyway, plus error info
-		return fmt.Sprintf("%s. Sin embargo, hubo un error: %s", action.Reply, err.Error()), nil
+		return fmt.Sprintf(localize(ctx, "%s. Sin embargo, hubo un error: %s"), action.Reply, err.Error())
 	}
 
 	if !result.Success {
@@ -95,7 +217,7 @@ func (b *Brain) ProcessCommand(ctx context.Context, text string) (string, error)
 			Str("action", action.Action).
 			Str("error", result.Error).
 			Msg("Action failed")
-		return fmt.Sprintf("%s. Error: %s", action.Reply, result.Error), nil
+		return fmt.Sprintf(localize(ctx, "%s. Error: %s"), action.Reply, result.Error)
 	}
 
 	b.log.Info().
@@ -103,23 +225,38 @@ func (b *Brain) ProcessCommand(ctx context.Context, text string) (string, error)
 		Str("result", result.Message).
 		Msg("Action executed successfully")
 
+	// Some actions answer with information only the executor knows
+	if result.Reply != "" {
+		return result.Reply
+	}
+
 	// Return the LLM's reply (which should be natural language)
-	return action.Reply, nil
+	return action.Reply
 }
 
 // ProcessAndSpeak processes a command and speaks the response
 func (b *Brain) ProcessAndSpeak(ctx context.Context, text string) error {
 	response, err := b.ProcessCommand(ctx, text)
 	if err != nil {
-		response = "Lo siento, ocurrió un error procesando tu solicitud."
+		response = localize(ctx, "Lo siento, ocurrió un error procesando tu solicitud.")
 	}
 
+	return b.Speak(ctx, response)
+}
+
+// Speak says a response that was already produced by ProcessCommand
+func (b *Brain) Speak(ctx context.Context, response string) error {
 	if response == "" {
 		return nil
 	}
 
 	// Speak the response (but don't fail if TTS has issues)
 	if b.ttsProvider != nil {
+		ctx = b.personaContext(ctx)
+		// A persona's own voice is used for every language
+		if lang := llm.LanguageFromContext(ctx); lang != "" && !b.personaVoice() {
+			b.ttsProvider.SetLanguage(lang)
+		}
 		if b.ttsProvider.IsAvailable(ctx) {
 			if err := b.ttsProvider.Speak(ctx, response); err != nil {
 				b.log.Warn().Err(err).Msg("TTS failed, but continuing without audio")
@@ -133,22 +270,48 @@ func (b *Brain) ProcessAndSpeak(ctx context.Context, text string) error {
 	return nil
 }
 
+// StopSpeaking interrupts the response being spoken, if any
+func (b *Brain) StopSpeaking() {
+	if b.ttsProvider != nil {
+		b.ttsProvider.Stop()
+	}
+}
+
 // handleStatus returns the system status
 func (b *Brain) handleStatus(ctx context.Context, action llm.Action) (string, error) {
 	var status []string
 
 	// Check LLM
-	if b.llmProvider.IsAvailable(ctx) {
-		status = append(status, fmt.Sprintf("LLM %s: activo", b.llmProvider.Name()))
-	} else {
-		status = append(status, fmt.Sprintf("LLM %s: no disponible", b.llmProvider.Name()))
+	provider, done := b.useLLM()
+	switch {
+	case provider == nil:
+		status = append(status, localize(ctx, "LLM: no disponible"))
+	case provider.IsAvailable(ctx):
+		status = append(status, fmt.Sprintf(localize(ctx, "LLM %s: activo"), provider.Name()))
+	default:
+		status = append(status, fmt.Sprintf(localize(ctx, "LLM %s: no disponible"), provider.Name()))
+	}
+	done()
+	if persona := b.Persona(); persona != "" {
+		status = append(status, fmt.Sprintf(localize(ctx, "persona: %s"), persona))
+	}
+
+	// Check STT
+	if sp, ok := b.sttProvider.(stt.StatusProvider); ok {
+		status = append(status, sp.GetStatus())
+	} else if b.sttProvider != nil {
+		if b.sttProvider.IsAvailable(ctx) {
+			status = append(status, fmt.Sprintf(localize(ctx, "STT %s: activo"), b.sttProvider.Name()))
+		} else {
+			status = append(status, fmt.Sprintf(localize(ctx, "STT %s: no disponible"), b.sttProvider.Name()))
+		}
 	}
 
 	// Check TTS
 	if b.ttsProvider != nil && b.ttsProvider.IsAvailable(ctx) {
-		status = append(status, fmt.Sprintf("TTS %s: activo", b.ttsProvider.Name()))
+		status = append(status, fmt.Sprintf(localize(ctx, "TTS %s: activo"), b.ttsProvider.Name()))
 	} else {
-		status = append(status, "TTS: no disponible")
+		status = append(status, localize(ctx, "TTS: no disponible"))
 	}
 
 	// Check executors
@@ -162,13 +325,13 @@ func (b *Brain) handleStatus(ctx context.Context, action llm.Action) (string, er
 		if statusProvider, ok := exec.(executor.StatusProvider); ok {
 			status = append(status, statusProvider.GetStatus())
 		} else if exec.IsAvailable() {
-			status = append(status, fmt.Sprintf("%s: conectado", actionName))
+			status = append(status, fmt.Sprintf(localize(ctx, "%s: conectado"), actionName))
 		} else {
-			status = append(status, fmt.Sprintf("%s: desconectado", actionName))
+			status = append(status, fmt.Sprintf(localize(ctx, "%s: desconectado"), actionName))
 		}
 	}
 
-	return "Estado del sistema: " + strings.Join(status, ", "), nil
+	return localize(ctx, "Estado del sistema: ") + strings.Join(status, ", "), nil
 }
 
 // handleHelp returns help information
@@ -184,19 +347,19 @@ func (b *Brain) handleHelp(ctx context.Context, action llm.Action) (string, erro
 	}
 
 	var help []string
-	help = append(help, "Puedo ayudarte con:")
+	help = append(help, localize(ctx, "Puedo ayudarte con:"))
 
 	if actions, ok := categories["twitch"]; ok {
-		help = append(help, fmt.Sprintf("- Twitch: %d acciones (clips, título, bans)", len(actions)))
+		help = append(help, fmt.Sprintf(localize(ctx, "- Twitch: %d acciones (clips, título, bans)"), len(actions)))
 	}
 	if actions, ok := categories["obs"]; ok {
-		help = append(help, fmt.Sprintf("- OBS: %d acciones (escenas, fuentes, volumen)", len(actions)))
+		help = append(help, fmt.Sprintf(localize(ctx, "- OBS: %d acciones (escenas, fuentes, volumen)"), len(actions)))
 	}
 	if actions, ok := categories["music"]; ok {
-		help = append(help, fmt.Sprintf("- Música: %d acciones (play, pause, volumen)", len(actions)))
+		help = append(help, fmt.Sprintf(localize(ctx, "- Música: %d acciones (play, pause, volumen)"), len(actions)))
 	}
 
-	help = append(help, "¿Qué necesitas?")
+	help = append(help, localize(ctx, "¿Qué necesitas?"))
 
 	return strings.Join(help, " "), nil
 }
@@ -205,13 +368,13 @@ func (b *Brain) handleHelp(ctx context.Context, action llm.Action) (string, erro
 func (b *Brain) handleCalc(ctx context.Context, action llm.Action) (string, error) {
 	expression, ok := action.Params["expression"].(string)
 	if !ok || expression == "" {
-		return "No entiendo la expresión matemática. Por favor intenta de nuevo.", nil
+		return localize(ctx, "No entiendo la expresión matemática. Por favor intenta de nuevo."), nil
 	}
 
 	result, err := evaluateExpression(expression)
 	if err != nil {
 		b.log.Error().Err(err).Str("expression", expression).Msg("Calculation failed")
-		return fmt.Sprintf("No pude calcular esa expresión: %v", err), nil
+		return fmt.Sprintf(localize(ctx, "No pude calcular esa expresión: %v"), err), nil
 	}
 
 	b.log.Debug().Str("expression", expression).Float64("result", result).Msg("Calculation successful")
@@ -364,9 +527,44 @@ func (b *Brain) GetAvailableActions() []string {
 	return b.registry.GetAllActions()
 }
 
-// SetLLM sets the LLM provider
+// sharedLLM is an LLM provider with the calls still using it, so it's only
+// closed once they're done
+type sharedLLM struct {
+	llm.Provider
+	calls sync.WaitGroup
+}
+
+// SetLLM sets the LLM provider. The previous one is closed once the calls
+// using it, like an Interpret ahead of time, finish.
 func (b *Brain) SetLLM(provider llm.Provider) {
-	b.llmProvider = provider
+	b.mu.Lock()
+	old := b.llmProvider
+	b.llmProvider = &sharedLLM{Provider: provider}
+	b.mu.Unlock()
+
+	if old == nil || old.Provider == nil || old.Provider == provider {
+		return
+	}
+	go func() {
+		old.calls.Wait()
+		if err := old.Close(); err != nil {
+			b.log.Warn().Err(err).Msg("Failed to close the previous LLM provider")
+		}
+	}()
+}
+
+// useLLM returns the LLM provider in use, nil if none, and the function to
+// call once done with it
+func (b *Brain) useLLM() (llm.Provider, func()) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	shared := b.llmProvider
+	if shared == nil || shared.Provider == nil {
+		return nil, func() {}
+	}
+	shared.calls.Add(1)
+	return shared.Provider, shared.calls.Done
 }
 
 // SetTTS sets the TTS provider
@@ -374,7 +572,13 @@ func (b *Brain) SetTTS(provider tts.Provider) {
 	b.ttsProvider = provider
 }
 
-// Close releases resources
+// SetSTT sets the STT provider reported by system.status
+func (b *Brain) SetSTT(provider stt.Provider) {
+	b.sttProvider = provider
+}
+
+// Close releases the executors, the LLM provider in use (once the calls
+// using it finish) and the TTS provider
 func (b *Brain) Close() error {
 	var errs []error
 
@@ -382,8 +586,12 @@ func (b *Brain) Close() error {
 		errs = append(errs, err)
 	}
 
-	if b.llmProvider != nil {
-		if err := b.llmProvider.Close(); err != nil {
+	b.mu.RLock()
+	shared := b.llmProvider
+	b.mu.RUnlock()
+	if shared != nil && shared.Provider != nil {
+		shared.calls.Wait()
+		if err := shared.Close(); err != nil {
 			errs = append(errs, err)
 		}
 	}
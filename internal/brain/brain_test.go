@@ -0,0 +1,100 @@
+package brain
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/anastreamer/ana/internal/config"
+	"github.com/anastreamer/ana/internal/llm"
+)
+
+// closingLLM counts how many times it's closed
+type closingLLM struct {
+	mu     sync.Mutex
+	closed int
+}
+
+func (l *closingLLM) Name() string                     { return "fake" }
+func (l *closingLLM) IsAvailable(context.Context) bool { return true }
+
+func (l *closingLLM) Complete(ctx context.Context, prompt string) (llm.Action, error) {
+	return llm.Action{Action: "none"}, nil
+}
+
+func (l *closingLLM) CompleteStream(ctx context.Context, prompt string, h llm.StreamHandler) (llm.Action, error) {
+	return l.Complete(ctx, prompt)
+}
+
+func (l *closingLLM) CompleteRaw(ctx context.Context, prompt string) (string, error) {
+	return "", nil
+}
+
+func (l *closingLLM) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.closed++
+	return nil
+}
+
+func (l *closingLLM) closes() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.closed
+}
+
+func TestCloseAfterPersonaSwitch(t *testing.T) {
+	base, pirate := &closingLLM{}, &closingLLM{}
+	b := New(base, nil)
+	b.SetPersonas(map[string]config.PersonaConfig{"pirata": {}}, nil, func(config.PersonaConfig) (llm.Provider, error) {
+		return pirate, nil
+	})
+	if err := b.SwitchPersona("pirata"); err != nil {
+		t.Fatal(err)
+	}
+
+	// The replaced LLM is closed in the background
+	deadline := time.Now().Add(2 * time.Second)
+	for base.closes() == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	if err := b.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if base.closes() != 1 || pirate.closes() != 1 {
+		t.Errorf("closed the first LLM %d times and the persona's %d times, want once each", base.closes(), pirate.closes())
+	}
+}
+
+func TestCloseWaitsForCalls(t *testing.T) {
+	provider := &closingLLM{}
+	b := New(provider, nil)
+	_, done := b.useLLM()
+
+	closed := make(chan struct{})
+	go func() {
+		b.Close()
+		close(closed)
+	}()
+
+	select {
+	case <-closed:
+		t.Fatal("Close returned while a call was using the LLM")
+	case <-time.After(50 * time.Millisecond):
+	}
+	if provider.closes() != 0 {
+		t.Fatal("the LLM was closed while in use")
+	}
+
+	done()
+	select {
+	case <-closed:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Close never returned")
+	}
+	if provider.closes() != 1 {
+		t.Errorf("closed %d times, want once", provider.closes())
+	}
+}
@@ -0,0 +1,66 @@
+package brain
+
+import (
+	"context"
+	"time"
+
+	"github.com/anastreamer/ana/internal/llm"
+)
+
+// pendingTimeout is how long an action waits for the streamer to confirm it
+const pendingTimeout = time.Minute
+
+// pendingAction is an action of an unsure transcription waiting for a yes
+type pendingAction struct {
+	text   string
+	action llm.Action
+	asked  time.Time
+}
+
+// needsConfirmation tells whether an action changes something. Replies and
+// read-only answers are given even if the transcription was unsure.
+func needsConfirmation(action llm.Action) bool {
+	switch action.Action {
+	case "", "none", "system.status", "system.help", "system.persona", "calc":
+		return false
+	}
+	return true
+}
+
+// setPending makes action wait for confirmation
+func (b *Brain) setPending(text string, action llm.Action) {
+	b.pendingMu.Lock()
+	defer b.pendingMu.Unlock()
+	b.pending = &pendingAction{text: text, action: action, asked: time.Now()}
+}
+
+// answerPending handles the answer to a pending confirmation: yes runs the
+// action and no drops it. Anything else drops it too and isn't handled, so
+// it's processed as a new command.
+func (b *Brain) answerPending(ctx context.Context, text string) (string, bool) {
+	b.pendingMu.Lock()
+	pending := b.pending
+	b.pending = nil
+	b.pendingMu.Unlock()
+
+	if pending == nil || time.Since(pending.asked) > pendingTimeout {
+		return "", false
+	}
+
+	switch {
+	case llm.IsAffirmative(text):
+		b.log.Info().Str("command", pending.text).Msg("Unsure command confirmed")
+		response, err := b.Execute(ctx, pending.action)
+		if err != nil {
+			b.log.Error().Err(err).Msg("Confirmed action failed")
+			return localize(ctx, "Lo siento, ocurrió un error procesando tu solicitud."), true
+		}
+		return response, true
+
+	case llm.IsNegative(text):
+		b.log.Info().Str("command", pending.text).Msg("Unsure command cancelled")
+		return localize(ctx, "Vale, no hago nada."), true
+	}
+
+	return "", false
+}
@@ -0,0 +1,421 @@
+package brain
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/anastreamer/ana/internal/config"
+	"github.com/anastreamer/ana/internal/llm"
+)
+
+// Every pattern must cover the whole command, optionally after Ana's name
+// and with a "por favor" at either end
+const (
+	intentPrefix = `(?i)^[¡¿\s]*(?:(?:oye|hey|ok)\s+)?(?:ana[\s,.!]+)?(?:(?:por favor|please)[\s,]+)?`
+	intentSuffix = `(?:[\s,]+(?:por favor|please))?[\s.!?,;]*$`
+)
+
+// slotPatterns is what each slot type matches
+var slotPatterns = map[string]string{
+	"text":    `.+?`,
+	"int":     `\d+`,
+	"number":  `\d+(?:[.,]\d+)?`,
+	"percent": `\d+(?:[.,]\d+)?`,
+}
+
+// compoundCommand finds a second command in a text slot, as in "cambia a
+// escena juego y pon música", which the LLM has to split
+var compoundCommand = regexp.MustCompile(`(?i)\s(?:y|and|then|luego|despu[eé]s)\s`)
+
+// slotName is a valid slot name, which becomes a regexp group name
+var slotName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// builtinIntents are the rules for the most common commands
+var builtinIntents = []config.IntentRule{
+	{Name: "siguiente canción", Action: "music.next", Language: "es", Reply: "Siguiente canción",
+		Patterns: []string{"[pon] [la] (siguiente|próxima) [canción|tema]", "(salta|pasa|cambia) [de|la|esta] (canción|tema)"}},
+	{Name: "canción anterior", Action: "music.previous", Language: "es", Reply: "Canción anterior",
+		Patterns: []string{"[pon|vuelve a] [la] (canción|tema) anterior"}},
+	{Name: "pausa", Action: "music.pause", Language: "es", Reply: "Música pausada",
+		Patterns: []string{"(pausa|para|pausar) [la] música"}},
+	{Name: "reanudar", Action: "music.resume", Language: "es", Reply: "Reanudando música",
+		Patterns: []string{"(reanuda|continúa|quita la pausa a) [la] música"}},
+	{Name: "volumen de la música", Action: "music.volume", Language: "es", Reply: "Volumen de música al {volume}%",
+		Patterns: []string{"(pon|sube|baja) [el] volumen de [la] música (a|al|hasta) {volume:percent}"}},
+	{Name: "qué suena", Action: "music.nowplaying", Language: "es",
+		Patterns: []string{"qué (canción|tema) (es esta|es|está sonando|suena)"}},
+	{Name: "cambiar escena", Action: "obs.scene", Language: "es", Reply: "Cambiando a escena {scene}",
+		Patterns: []string{"(cambia|pasa|pon|ve) [a] [la] escena [de] {scene}"}},
+	{Name: "silenciar micro", Action: "obs.mute", Language: "es", Reply: "Micro silenciado",
+		Patterns: []string{"(silencia|mutea|apaga) [el] (micro|micrófono)"}},
+	{Name: "activar micro", Action: "obs.unmute", Language: "es", Reply: "Micro activado",
+		Patterns: []string{"(activa|desmutea|enciende) [el] (micro|micrófono)"}},
+	{Name: "grabar", Action: "obs.start_recording", Language: "es", Reply: "Dale, ya estoy grabando",
+		Patterns: []string{"(empieza|inicia|comienza) [a] (grabar|la grabación)"}},
+	{Name: "parar grabación", Action: "obs.stop_recording", Language: "es", Reply: "Grabación detenida",
+		Patterns: []string{"(para|detén|termina|corta|deja) [de] (grabar|la grabación)"}},
+	{Name: "clip", Action: "twitch.clip", Language: "es", Reply: "Clip creado",
+		Patterns: []string{"(haz|crea|graba|saca) [un] clip", "clipea [eso|esto]"}},
+	{Name: "estado", Action: "system.status", Language: "es",
+		Patterns: []string{"[dime el] estado del sistema"}},
+
+	{Name: "next song", Action: "music.next", Language: "en", Reply: "Next song",
+		Patterns: []string{"(next|skip) [this] [song|track]", "play the next (song|track)"}},
+	{Name: "previous song", Action: "music.previous", Language: "en", Reply: "Previous song",
+		Patterns: []string{"[play the] previous (song|track)"}},
+	{Name: "pause", Action: "music.pause", Language: "en", Reply: "Music paused",
+		Patterns: []string{"pause [the] music"}},
+	{Name: "resume", Action: "music.resume", Language: "en", Reply: "Resuming music",
+		Patterns: []string{"(resume|unpause) [the] music"}},
+	{Name: "music volume", Action: "music.volume", Language: "en", Reply: "Music volume at {volume}%",
+		Patterns: []string{"(set|turn) [the] music volume to {volume:percent}"}},
+	{Name: "switch scene", Action: "obs.scene", Language: "en", Reply: "Switching to scene {scene}",
+		Patterns: []string{"(switch|change|go) to [the] scene {scene}", "(switch|change) [the] scene to {scene}"}},
+	{Name: "mute mic", Action: "obs.mute", Language: "en", Reply: "Mic muted",
+		Patterns: []string{"mute [the] (mic|microphone)"}},
+	{Name: "unmute mic", Action: "obs.unmute", Language: "en", Reply: "Mic unmuted",
+		Patterns: []string{"unmute [the] (mic|microphone)"}},
+	{Name: "clip", Action: "twitch.clip", Language: "en", Reply: "Clip created",
+		Patterns: []string{"clip (that|it|this)", "(make|create) a clip"}},
+	{Name: "status", Action: "system.status", Language: "en",
+		Patterns: []string{"system status"}},
+}
+
+// intentRule is a rule with its patterns compiled
+type intentRule struct {
+	config.IntentRule
+	patterns []*regexp.Regexp
+	slots    map[string]string // Type of each slot
+}
+
+// intentMatcher maps commands to actions with rules, before the LLM
+type intentMatcher struct {
+	rules    []intentRule
+	language string // Language of commands with none detected
+}
+
+// SetIntents makes Ana run the commands that match a rule of cfg (and the
+// built-in ones, with cfg.Builtin) without asking the LLM. language is the
+// main one, for commands with no language detected. Rules that don't
+// compile are left out and reported in the error.
+func (b *Brain) SetIntents(cfg config.IntentsConfig, language string) error {
+	rules := cfg.Rules
+	if cfg.Builtin {
+		rules = append(append([]config.IntentRule{}, rules...), builtinIntents...)
+	}
+
+	m := &intentMatcher{language: language}
+	var errs []string
+	for _, rule := range rules {
+		compiled, err := compileIntent(rule)
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		m.rules = append(m.rules, compiled)
+	}
+
+	b.intents = m
+	b.log.Debug().Int("rules", len(m.rules)).Msg("Intent rules loaded")
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid intent rules: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// matchIntent returns the action of the first rule matching text, a
+// command in lang. Only actions Ana can run now count, so anything else
+// goes to the LLM.
+func (b *Brain) matchIntent(text, lang string) (llm.Action, bool) {
+	if b.intents == nil {
+		return llm.Action{}, false
+	}
+	if lang == "" {
+		lang = b.intents.language
+	}
+
+	for _, rule := range b.intents.rules {
+		if rule.Language != "" && rule.Language != lang {
+			continue
+		}
+		action, ok := rule.match(text)
+		if !ok {
+			continue
+		}
+		if !b.canRun(action.Action) {
+			b.log.Debug().Str("rule", rule.Name).Str("action", action.Action).Msg("Intent rule matched an action that is not available")
+			continue
+		}
+
+		b.log.Info().
+			Str("rule", rule.Name).
+			Str("action", action.Action).
+			Interface("params", action.Params).
+			Msg("Command matched an intent rule, skipping the LLM")
+		return action, true
+	}
+	return llm.Action{}, false
+}
+
+// canRun tells whether an action is handled by the brain or an executor
+func (b *Brain) canRun(action string) bool {
+	switch action {
+	case "none", "system.status", "system.help", "calc":
+		return true
+	case "system.persona":
+		b.mu.RLock()
+		defer b.mu.RUnlock()
+		return len(b.personas) > 0
+	}
+	_, err := b.registry.FindExecutor(action)
+	return err == nil
+}
+
+// match returns the action for text if one of the rule's patterns covers
+// all of it and its slots have valid values
+func (r *intentRule) match(text string) (llm.Action, bool) {
+	for _, re := range r.patterns {
+		sub := re.FindStringSubmatch(text)
+		if sub == nil {
+			continue
+		}
+
+		params := make(map[string]interface{}, len(r.Params))
+		for key, value := range r.Params {
+			params[key] = value
+		}
+		said := make([]string, 0, 2*len(r.slots))
+		valid := true
+		for i, name := range re.SubexpNames() {
+			if name == "" || sub[i] == "" {
+				continue
+			}
+			raw := strings.TrimSpace(sub[i])
+			value, ok := slotValue(r.slots[name], raw)
+			if !ok {
+				valid = false
+				break
+			}
+			params[name] = value
+			said = append(said, "{"+name+"}", raw)
+		}
+		if !valid {
+			continue
+		}
+
+		return llm.Action{
+			Action: r.Action,
+			Params: params,
+			Reply:  strings.NewReplacer(said...).Replace(r.Reply),
+		}, true
+	}
+	return llm.Action{}, false
+}
+
+// slotValue converts what was said for a slot to its type
+func slotValue(kind, raw string) (interface{}, bool) {
+	switch kind {
+	case "int":
+		n, err := strconv.Atoi(raw)
+		return n, err == nil
+	case "number", "percent":
+		n, err := strconv.ParseFloat(strings.Replace(raw, ",", ".", 1), 64)
+		if err != nil {
+			return nil, false
+		}
+		if kind == "percent" {
+			n /= 100
+		}
+		return n, true
+	default:
+		return raw, raw != "" && !compoundCommand.MatchString(" "+raw+" ")
+	}
+}
+
+// compileIntent compiles the patterns and regexps of rule
+func compileIntent(rule config.IntentRule) (intentRule, error) {
+	if rule.Name == "" {
+		rule.Name = rule.Action
+	}
+	compiled := intentRule{IntentRule: rule, slots: make(map[string]string)}
+	for name, kind := range rule.Slots {
+		compiled.slots[name] = kind
+	}
+
+	var bodies []string
+	for _, pattern := range rule.Patterns {
+		p := &patternParser{src: []rune(pattern), slots: compiled.slots}
+		body, err := p.alternatives(0)
+		if err != nil {
+			return intentRule{}, fmt.Errorf("%s: pattern %q: %w", rule.Name, pattern, err)
+		}
+		bodies = append(bodies, body)
+	}
+	for _, expr := range rule.Regex {
+		bodies = append(bodies, strings.TrimSuffix(strings.TrimPrefix(expr, "^"), "$"))
+	}
+
+	for _, body := range bodies {
+		re, err := regexp.Compile(intentPrefix + "(?:" + body + ")" + intentSuffix)
+		if err != nil {
+			return intentRule{}, fmt.Errorf("%s: %w", rule.Name, err)
+		}
+		compiled.patterns = append(compiled.patterns, re)
+	}
+	return compiled, nil
+}
+
+// patternParser turns a rule pattern into a regexp. Words match with or
+// without accents and any case, [words] are optional, (a|b) are
+// alternatives and {slot} or {slot:type} capture a slot.
+type patternParser struct {
+	src   []rune
+	pos   int
+	slots map[string]string
+}
+
+// alternatives parses sequences separated by | up to end (0 for the end of
+// the pattern)
+func (p *patternParser) alternatives(end rune) (string, error) {
+	var alts []string
+	for {
+		seq, err := p.sequence()
+		if err != nil {
+			return "", err
+		}
+		alts = append(alts, seq)
+		if p.pos < len(p.src) && p.src[p.pos] == '|' {
+			p.pos++
+			continue
+		}
+		break
+	}
+
+	switch {
+	case end == 0 && p.pos < len(p.src):
+		return "", fmt.Errorf("unexpected %q", p.src[p.pos])
+	case end != 0 && (p.pos >= len(p.src) || p.src[p.pos] != end):
+		return "", fmt.Errorf("missing %q", end)
+	case end != 0:
+		p.pos++
+	}
+
+	if len(alts) == 1 {
+		return alts[0], nil
+	}
+	return "(?:" + strings.Join(alts, "|") + ")", nil
+}
+
+// sequence parses items separated by spaces. An optional item takes the
+// space before it, or after it at the start, so it can be left out.
+func (p *patternParser) sequence() (string, error) {
+	var out strings.Builder
+	needSpace := false
+
+	for {
+		for p.pos < len(p.src) && unicode.IsSpace(p.src[p.pos]) {
+			p.pos++
+		}
+		if p.pos >= len(p.src) || strings.ContainsRune("|)]", p.src[p.pos]) {
+			return out.String(), nil
+		}
+
+		var item string
+		var err error
+		optional := false
+		switch p.src[p.pos] {
+		case '[':
+			p.pos++
+			item, err = p.alternatives(']')
+			optional = true
+		case '(':
+			p.pos++
+			item, err = p.alternatives(')')
+			item = "(?:" + item + ")"
+		case '{':
+			item, err = p.slot()
+		default:
+			item = p.word()
+		}
+		if err != nil {
+			return "", err
+		}
+
+		switch {
+		case optional && needSpace:
+			out.WriteString(`(?:\s+` + item + `)?`)
+		case optional:
+			out.WriteString(`(?:` + item + `\s+)?`)
+		case needSpace:
+			out.WriteString(`\s+` + item)
+			needSpace = true
+		default:
+			out.WriteString(item)
+			needSpace = true
+		}
+	}
+}
+
+// slot parses {name} or {name:type}
+func (p *patternParser) slot() (string, error) {
+	end := p.pos
+	for end < len(p.src) && p.src[end] != '}' {
+		end++
+	}
+	if end >= len(p.src) {
+		return "", fmt.Errorf("missing '}'")
+	}
+	name, kind, _ := strings.Cut(string(p.src[p.pos+1:end]), ":")
+	p.pos = end + 1
+
+	if !slotName.MatchString(name) {
+		return "", fmt.Errorf("invalid slot name %q", name)
+	}
+	if kind == "" {
+		kind = p.slots[name]
+	}
+	if kind == "" {
+		kind = "text"
+	}
+	re, ok := slotPatterns[kind]
+	if !ok {
+		return "", fmt.Errorf("invalid type %q for slot %s", kind, name)
+	}
+	p.slots[name] = kind
+
+	if kind == "percent" {
+		return `(?P<` + name + `>` + re + `)(?:\s*%|\s+por ciento|\s+percent)?`, nil
+	}
+	return `(?P<` + name + `>` + re + `)`, nil
+}
+
+// word parses literal text up to a space or a special character
+func (p *patternParser) word() string {
+	var out strings.Builder
+	for p.pos < len(p.src) {
+		r := p.src[p.pos]
+		if unicode.IsSpace(r) || strings.ContainsRune("[](){}|", r) {
+			break
+		}
+		switch unicode.ToLower(r) {
+		case 'a', 'á':
+			out.WriteString("[aá]")
+		case 'e', 'é':
+			out.WriteString("[eé]")
+		case 'i', 'í':
+			out.WriteString("[ií]")
+		case 'o', 'ó':
+			out.WriteString("[oó]")
+		case 'u', 'ú', 'ü':
+			out.WriteString("[uúü]")
+		default:
+			out.WriteString(regexp.QuoteMeta(string(r)))
+		}
+		p.pos++
+	}
+	return out.String()
+}
@@ -0,0 +1,277 @@
+package brain
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/anastreamer/ana/internal/config"
+	"github.com/anastreamer/ana/internal/executor"
+	"github.com/anastreamer/ana/internal/llm"
+)
+
+// fakeExecutor handles every action with one of its prefixes
+type fakeExecutor struct {
+	name     string
+	prefixes []string
+}
+
+func (e *fakeExecutor) Name() string               { return e.name }
+func (e *fakeExecutor) SupportedActions() []string { return e.prefixes }
+func (e *fakeExecutor) IsAvailable() bool          { return true }
+func (e *fakeExecutor) Close() error               { return nil }
+
+func (e *fakeExecutor) CanHandle(action string) bool {
+	for _, prefix := range e.prefixes {
+		if strings.HasPrefix(action, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func (e *fakeExecutor) Execute(ctx context.Context, action llm.Action) (executor.Result, error) {
+	return executor.NewResult(action.Action), nil
+}
+
+// newIntentBrain returns a brain with the built-in intents and executors
+// for music, OBS and Twitch
+func newIntentBrain(t *testing.T) *Brain {
+	t.Helper()
+	b := New(nil, nil)
+	b.RegisterExecutor(&fakeExecutor{name: "music", prefixes: []string{"music."}})
+	b.RegisterExecutor(&fakeExecutor{name: "obs", prefixes: []string{"obs."}})
+	b.RegisterExecutor(&fakeExecutor{name: "twitch", prefixes: []string{"twitch."}})
+	if err := b.SetIntents(config.IntentsConfig{Enabled: true, Builtin: true}, "es"); err != nil {
+		t.Fatalf("SetIntents: %v", err)
+	}
+	return b
+}
+
+func TestPatternParser(t *testing.T) {
+	tests := []struct {
+		pattern string
+		matches []string
+		misses  []string
+	}{
+		{
+			pattern: "pausa",
+			matches: []string{"pausa", "Pausa.", "PAUSA", "Ana, pausa", "oye ana pausa por favor"},
+			misses:  []string{"pausas", "la pausa", "pausa todo"},
+		},
+		{
+			pattern: "[pon] [la] siguiente canción",
+			matches: []string{"siguiente canción", "pon la siguiente canción", "la siguiente cancion", "pon siguiente canción"},
+			misses:  []string{"pon la la siguiente canción", "siguiente"},
+		},
+		{
+			pattern: "(salta|pasa) [de] canción",
+			matches: []string{"salta canción", "pasa de canción", "¿Salta de canción?"},
+			misses:  []string{"canción", "salta pasa canción"},
+		},
+		{
+			pattern: "qué (canción|tema) suena",
+			matches: []string{"que canción suena", "qué tema suena", "QUÉ TEMA SUENA"},
+			misses:  []string{"qué suena"},
+		},
+		{
+			pattern: "volumen a {v:int}",
+			matches: []string{"volumen a 30"},
+			misses:  []string{"volumen a treinta", "volumen a 3.5"},
+		},
+		{
+			pattern: "volumen a {v:percent}",
+			matches: []string{"volumen a 30%", "volumen a 30 %", "volumen a 30 por ciento", "volumen a 2,5"},
+			misses:  []string{"volumen a mucho"},
+		},
+		{
+			pattern: "escena {scene}",
+			matches: []string{"escena Juego", "escena de inicio"},
+			misses:  []string{"escena", "escena juego y pon música"},
+		},
+		{
+			pattern: "a.b (c+)",
+			matches: []string{"a.b c+"},
+			misses:  []string{"axb c", "a.b cc"},
+		},
+	}
+
+	for _, tt := range tests {
+		rule, err := compileIntent(config.IntentRule{Action: "none", Patterns: []string{tt.pattern}})
+		if err != nil {
+			t.Errorf("compileIntent(%q): %v", tt.pattern, err)
+			continue
+		}
+		for _, text := range tt.matches {
+			if _, ok := rule.match(text); !ok {
+				t.Errorf("pattern %q does not match %q", tt.pattern, text)
+			}
+		}
+		for _, text := range tt.misses {
+			if _, ok := rule.match(text); ok {
+				t.Errorf("pattern %q matches %q", tt.pattern, text)
+			}
+		}
+	}
+}
+
+func TestPatternParserErrors(t *testing.T) {
+	patterns := []string{
+		"[pon la",
+		"(pon|quita la",
+		"pon)",
+		"pon]",
+		"{volume",
+		"{1st}",
+		"{volume:loud}",
+	}
+	for _, pattern := range patterns {
+		if _, err := compileIntent(config.IntentRule{Action: "none", Patterns: []string{pattern}}); err == nil {
+			t.Errorf("compileIntent(%q) succeeded, want an error", pattern)
+		}
+	}
+}
+
+func TestBuiltinIntents(t *testing.T) {
+	tests := []struct {
+		lang   string
+		text   string
+		rule   string
+		params map[string]interface{}
+		reply  string
+	}{
+		{"es", "siguiente canción", "siguiente canción", nil, "Siguiente canción"},
+		{"es", "Ana, pon la próxima canción", "siguiente canción", nil, ""},
+		{"es", "salta esta canción", "siguiente canción", nil, ""},
+		{"es", "canción anterior", "canción anterior", nil, ""},
+		{"es", "vuelve a la canción anterior", "canción anterior", nil, ""},
+		{"es", "pausa la música", "pausa", nil, ""},
+		{"es", "reanuda la música", "reanudar", nil, ""},
+		{"es", "continua la musica", "reanudar", nil, ""},
+		{"es", "pon el volumen de la música al 30%", "volumen de la música", map[string]interface{}{"volume": 0.3}, "Volumen de música al 30%"},
+		{"es", "baja el volumen de la música a 15 por ciento", "volumen de la música", map[string]interface{}{"volume": 0.15}, ""},
+		{"es", "¿qué canción está sonando?", "qué suena", nil, ""},
+		{"es", "cambia a la escena de Juego", "cambiar escena", map[string]interface{}{"scene": "Juego"}, "Cambiando a escena Juego"},
+		{"es", "pon la escena Just Chatting", "cambiar escena", map[string]interface{}{"scene": "Just Chatting"}, ""},
+		{"es", "silencia el micro", "silenciar micro", nil, "Micro silenciado"},
+		{"es", "activa el micrófono", "activar micro", nil, ""},
+		{"es", "empieza a grabar", "grabar", nil, ""},
+		{"es", "inicia la grabación", "grabar", nil, ""},
+		{"es", "para de grabar", "parar grabación", nil, ""},
+		{"es", "termina la grabación", "parar grabación", nil, ""},
+		{"es", "Ana, termina la grabación", "parar grabación", nil, ""},
+		{"es", "deja de grabar", "parar grabación", nil, ""},
+		{"es", "haz un clip", "clip", nil, "Clip creado"},
+		{"es", "clipea eso", "clip", nil, ""},
+		{"es", "estado del sistema", "estado", nil, ""},
+
+		{"en", "next song", "next song", nil, "Next song"},
+		{"en", "skip this track", "next song", nil, ""},
+		{"en", "play the previous song", "previous song", nil, ""},
+		{"en", "pause the music", "pause", nil, ""},
+		{"en", "resume music", "resume", nil, ""},
+		{"en", "set the music volume to 45 percent", "music volume", map[string]interface{}{"volume": 0.45}, "Music volume at 45%"},
+		{"en", "switch to scene Gameplay", "switch scene", map[string]interface{}{"scene": "Gameplay"}, "Switching to scene Gameplay"},
+		{"en", "change the scene to Starting Soon", "switch scene", map[string]interface{}{"scene": "Starting Soon"}, ""},
+		{"en", "mute the mic", "mute mic", nil, ""},
+		{"en", "unmute the microphone", "unmute mic", nil, ""},
+		{"en", "clip that", "clip", nil, ""},
+		{"en", "system status", "status", nil, ""},
+	}
+
+	b := newIntentBrain(t)
+	rules := make(map[string]intentRule)
+	for _, rule := range b.intents.rules {
+		rules[rule.Language+"/"+rule.Name] = rule
+	}
+	covered := make(map[string]bool)
+
+	for _, tt := range tests {
+		rule, ok := rules[tt.lang+"/"+tt.rule]
+		if !ok {
+			t.Fatalf("no built-in rule %s/%s", tt.lang, tt.rule)
+		}
+		covered[tt.lang+"/"+tt.rule] = true
+
+		action, ok := b.matchIntent(tt.text, tt.lang)
+		if !ok {
+			t.Errorf("%q (%s) matched no rule, want %s", tt.text, tt.lang, rule.Action)
+			continue
+		}
+		if action.Action != rule.Action {
+			t.Errorf("%q (%s) = %s, want %s", tt.text, tt.lang, action.Action, rule.Action)
+		}
+		params := tt.params
+		if params == nil {
+			params = map[string]interface{}{}
+		}
+		if !reflect.DeepEqual(action.Params, params) {
+			t.Errorf("%q (%s) params = %v, want %v", tt.text, tt.lang, action.Params, params)
+		}
+		if tt.reply != "" && action.Reply != tt.reply {
+			t.Errorf("%q (%s) reply = %q, want %q", tt.text, tt.lang, action.Reply, tt.reply)
+		}
+	}
+
+	for _, rule := range builtinIntents {
+		if !covered[rule.Language+"/"+rule.Name] {
+			t.Errorf("built-in rule %s/%s has no test", rule.Language, rule.Name)
+		}
+	}
+}
+
+func TestBuiltinIntentsMisses(t *testing.T) {
+	tests := []struct {
+		lang string
+		text string
+	}{
+		// Something else besides the command, for the LLM
+		{"es", "cambia a escena juego y pon música"},
+		{"es", "pon la siguiente canción y sube el volumen"},
+		{"es", "qué tal estás"},
+		{"es", "pon música de rock"},
+		{"es", "sube el volumen de la música un poco"},
+		{"es", "¿por qué paraste la música?"},
+		{"es", "termina"},
+		{"es", "haz un clip de los últimos dos minutos"},
+		{"en", "switch to scene Gameplay and start recording"},
+		{"en", "what's the weather like"},
+		{"en", "set the music volume to loud"},
+
+		// A command in the other language
+		{"en", "siguiente canción"},
+		{"es", "next song"},
+	}
+
+	b := newIntentBrain(t)
+	for _, tt := range tests {
+		if action, ok := b.matchIntent(tt.text, tt.lang); ok {
+			t.Errorf("%q (%s) matched %s, want no match", tt.text, tt.lang, action.Action)
+		}
+	}
+}
+
+func TestIntentsSkipUnavailableActions(t *testing.T) {
+	b := New(nil, nil)
+	if err := b.SetIntents(config.IntentsConfig{Enabled: true, Builtin: true}, "es"); err != nil {
+		t.Fatalf("SetIntents: %v", err)
+	}
+
+	if action, ok := b.matchIntent("siguiente canción", "es"); ok {
+		t.Errorf("matched %s without a music executor", action.Action)
+	}
+	if _, ok := b.matchIntent("estado del sistema", "es"); !ok {
+		t.Error("system.status did not match without executors")
+	}
+}
+
+func TestIntentsMainLanguage(t *testing.T) {
+	b := newIntentBrain(t)
+	if _, ok := b.matchIntent("pausa la música", ""); !ok {
+		t.Error("a command with no language did not match the main language's rules")
+	}
+	if _, ok := b.matchIntent("pause the music", ""); ok {
+		t.Error("a command with no language matched another language's rules")
+	}
+}
@@ -0,0 +1,59 @@
+package brain
+
+import (
+	"context"
+
+	"github.com/anastreamer/ana/internal/llm"
+)
+
+// translations of Ana's own replies, keyed by language and then by the
+// Spanish text. Replies without a translation are said in Spanish.
+var translations = map[string]map[string]string{
+	"en": {
+		"Perdona, no te he entendido bien. ¿Me lo repites?":                                     "Sorry, I didn't quite catch that. Can you say it again?",
+		"No hay ningún proveedor de IA disponible. Revisa tu configuración o prueba más tarde.": "There's no AI provider available. Check your settings or try again later.",
+		"No sé si te he entendido bien: «%s». ¿Lo hago?":                                        "I'm not sure I got that right: \"%s\". Should I do it?",
+		"Vale, no hago nada.": "Okay, I won't do anything.",
+		"Lo siento, ocurrió un error procesando tu solicitud.": "Sorry, something went wrong with your request.",
+		"%s. Sin embargo, hubo un error: %s":                   "%s. But there was an error: %s",
+		"No tengo otras personalidades configuradas.":          "I don't have other personalities set up.",
+		"No conozco ese modo. Tengo: %s.":                      "I don't know that mode. I have: %s.",
+		"Modo %s activado.":                                    "%s mode on.",
+		"Vuelvo a ser yo.":                                     "Back to being myself.",
+		"LLM: no disponible":                                   "LLM: unavailable",
+		"LLM %s: activo":                                       "LLM %s: active",
+		"LLM %s: no disponible":                                "LLM %s: unavailable",
+		"persona: %s":                                          "personality: %s",
+		"STT %s: activo":                                       "STT %s: active",
+		"STT %s: no disponible":                                "STT %s: unavailable",
+		"TTS %s: activo":                                       "TTS %s: active",
+		"TTS: no disponible":                                   "TTS: unavailable",
+		"%s: conectado":                                        "%s: connected",
+		"%s: desconectado":                                     "%s: disconnected",
+		"Estado del sistema: ":                                 "System status: ",
+		"Puedo ayudarte con:":                                  "I can help you with:",
+		"- Twitch: %d acciones (clips, título, bans)":          "- Twitch: %d actions (clips, title, bans)",
+		"- OBS: %d acciones (escenas, fuentes, volumen)":       "- OBS: %d actions (scenes, sources, volume)",
+		"- Música: %d acciones (play, pause, volumen)":         "- Music: %d actions (play, pause, volume)",
+		"¿Qué necesitas?":                                      "What do you need?",
+		"%s. Error: %s":                                        "%s. Something went wrong: %s",
+		"No entiendo la expresión matemática. Por favor intenta de nuevo.": "I don't understand that math expression. Please try again.",
+		"No pude calcular esa expresión: %v":                               "I couldn't calculate that expression: %v",
+		"Adiós! Estoy aquí si me necesitas.":                               "Bye! I'm here if you need me.",
+	},
+}
+
+// localize returns msg in the language of the command in ctx, if it has a
+// translation
+func localize(ctx context.Context, msg string) string {
+	return Localize(llm.LanguageFromContext(ctx), msg)
+}
+
+// Localize returns one of Ana's Spanish messages in lang, if it has a
+// translation
+func Localize(lang, msg string) string {
+	if translated, ok := translations[lang][msg]; ok {
+		return translated
+	}
+	return msg
+}
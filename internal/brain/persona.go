@@ -0,0 +1,176 @@
+package brain
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/anastreamer/ana/internal/config"
+	"github.com/anastreamer/ana/internal/llm"
+)
+
+// normalPersona names Ana without a persona, as in "Ana, modo normal"
+var normalPersona = []string{"", "normal", "default", "ana", "none", "ninguna", "ninguno"}
+
+// LLMFactory creates the LLM provider for a persona, with its temperature
+// and token limit over the general LLM settings
+type LLMFactory func(persona config.PersonaConfig) (llm.Provider, error)
+
+// SetPersonas sets the personas system.persona switches between. prompts
+// gets the personality of the active one and newLLM builds its LLM.
+func (b *Brain) SetPersonas(profiles map[string]config.PersonaConfig, prompts *llm.Prompts, newLLM LLMFactory) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.personas = profiles
+	b.prompts = prompts
+	b.newLLM = newLLM
+}
+
+// SwitchPersona makes Ana talk as the persona called name, or as herself
+// for "normal". It swaps the LLM, the prompt and the voice; if the LLM
+// cannot be created nothing changes.
+func (b *Brain) SwitchPersona(name string) error {
+	b.mu.RLock()
+	key, persona, ok := b.findPersona(name)
+	prompts, newLLM := b.prompts, b.newLLM
+	b.mu.RUnlock()
+	if !ok {
+		return fmt.Errorf("unknown persona: %s", name)
+	}
+
+	if newLLM != nil {
+		provider, err := newLLM(persona)
+		if err != nil {
+			return fmt.Errorf("failed to create LLM for persona %s: %w", key, err)
+		}
+		b.SetLLM(provider)
+	}
+
+	if b.ttsProvider != nil {
+		// The providers lock their settings, so a sentence being spoken
+		// keeps the voice it started with. Empty voice and zero speed go
+		// back to the configured ones.
+		if err := b.ttsProvider.SetVoice(persona.Voice); err != nil {
+			b.log.Warn().Err(err).Str("persona", key).Msg("Cannot use the persona's voice")
+		}
+		b.ttsProvider.SetSpeed(persona.Speed)
+	}
+
+	if prompts != nil {
+		prompts.SetPersona(key)
+	}
+
+	b.mu.Lock()
+	b.persona = key
+	b.personaConfig = persona
+	b.mu.Unlock()
+
+	b.log.Info().Str("persona", key).Msg("Persona switched")
+	return nil
+}
+
+// findPersona looks up a persona by name or alias, ignoring case. The
+// normal persona is "" with no settings.
+func (b *Brain) findPersona(name string) (string, config.PersonaConfig, bool) {
+	name = strings.TrimSpace(strings.ToLower(name))
+	for _, normal := range normalPersona {
+		if name == normal {
+			return "", config.PersonaConfig{}, true
+		}
+	}
+
+	for key, persona := range b.personas {
+		if strings.EqualFold(key, name) {
+			return key, persona, true
+		}
+		for _, alias := range persona.Aliases {
+			if strings.EqualFold(alias, name) {
+				return key, persona, true
+			}
+		}
+	}
+	return "", config.PersonaConfig{}, false
+}
+
+// handlePersona switches persona for system.persona
+func (b *Brain) handlePersona(ctx context.Context, action llm.Action) (string, error) {
+	name := action.GetStringParam("name")
+	if err := b.SwitchPersona(name); err != nil {
+		b.log.Warn().Err(err).Str("persona", name).Msg("Cannot switch persona")
+
+		b.mu.RLock()
+		names := make([]string, 0, len(b.personas))
+		for key := range b.personas {
+			names = append(names, key)
+		}
+		b.mu.RUnlock()
+		sort.Strings(names)
+
+		if len(names) == 0 {
+			return localize(ctx, "No tengo otras personalidades configuradas."), nil
+		}
+		return fmt.Sprintf(localize(ctx, "No conozco ese modo. Tengo: %s."), strings.Join(names, ", ")), nil
+	}
+
+	// The reply was written by the previous persona; the new one may have
+	// another language
+	ctx = b.personaContext(ctx)
+	if action.Reply != "" {
+		return b.limitReply(action.Reply), nil
+	}
+	if name := b.Persona(); name != "" {
+		return fmt.Sprintf(localize(ctx, "Modo %s activado."), name), nil
+	}
+	return localize(ctx, "Vuelvo a ser yo."), nil
+}
+
+// Persona returns the name of the active persona, "" for none
+func (b *Brain) Persona() string {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.persona
+}
+
+// personaContext sets the language of the active persona in ctx, if it has
+// one, so Ana replies in it whatever the streamer speaks
+func (b *Brain) personaContext(ctx context.Context) context.Context {
+	b.mu.RLock()
+	lang := b.personaConfig.Language
+	b.mu.RUnlock()
+
+	if lang == "" {
+		return ctx
+	}
+	return llm.WithLanguage(ctx, lang)
+}
+
+// personaVoice tells whether the active persona has its own voice, which
+// is used for every language
+func (b *Brain) personaVoice() bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.personaConfig.Voice != ""
+}
+
+// limitReply cuts a reply longer than the active persona allows at the end
+// of its last whole sentence, or of its last whole word
+func (b *Brain) limitReply(reply string) string {
+	b.mu.RLock()
+	limit := b.personaConfig.MaxReplyChars
+	b.mu.RUnlock()
+
+	runes := []rune(reply)
+	if limit <= 0 || len(runes) <= limit {
+		return reply
+	}
+
+	cut := string(runes[:limit])
+	if i := strings.LastIndexAny(cut, ".!?"); i > 0 {
+		return cut[:i+1]
+	}
+	if i := strings.LastIndex(cut, " "); i > 0 {
+		return strings.TrimRight(cut[:i], ",;:") + "…"
+	}
+	return cut + "…"
+}
@@ -0,0 +1,145 @@
+package brain
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/anastreamer/ana/internal/executor"
+	"github.com/anastreamer/ana/internal/llm"
+)
+
+// execution is the outcome of an action started before the reply was
+// complete
+type execution struct {
+	result executor.Result
+	err    error
+}
+
+// stream interprets text with a streaming LLM request. An action that can
+// run without confirmation starts as soon as the LLM has chosen it, and the
+// sentences of a conversational reply are passed to speak while the rest
+// is still being written, up to the persona's reply limit. It returns the
+// response and the part of it not passed to speak.
+func (b *Brain) stream(ctx context.Context, text string, confidence float64, speak func(sentence string)) (string, string, error) {
+	b.mu.RLock()
+	limit := b.personaConfig.MaxReplyChars
+	b.mu.RUnlock()
+
+	var (
+		chosen  *llm.Action    // Action once the LLM has chosen it
+		running chan execution // Action started early, if any
+		waiting []string       // Sentences written before the action
+		spoken  []string       // Sentences passed to speak
+		length  int            // Characters passed to speak
+		full    bool           // The reply limit was reached
+	)
+
+	say := func(sentence string) {
+		if full {
+			return
+		}
+		if n := utf8.RuneCountInString(sentence); limit > 0 && length+n > limit {
+			full = true
+			return
+		}
+		length += utf8.RuneCountInString(sentence) + 1
+		spoken = append(spoken, sentence)
+		speak(sentence)
+	}
+
+	handler := llm.StreamHandler{
+		OnAction: func(action llm.Action) {
+			chosen = &action
+			if action.Action == "none" {
+				for _, sentence := range waiting {
+					say(sentence)
+				}
+				waiting = nil
+			}
+
+			if needsConfirmation(action) && confidence >= b.confirmBelow {
+				b.log.Debug().Str("action", action.Action).Msg("Running the action while the reply is written")
+				running = make(chan execution, 1)
+				go func() {
+					result, err := b.registry.Execute(ctx, action)
+					running <- execution{result: result, err: err}
+				}()
+			}
+		},
+		OnSentence: func(sentence string) {
+			// Only a conversational reply is final; executors may answer
+			// with their own
+			switch {
+			case chosen == nil:
+				waiting = append(waiting, sentence)
+			case chosen.Action == "none":
+				say(sentence)
+			}
+		},
+	}
+
+	provider, done := b.useLLM()
+	defer done()
+	if provider == nil {
+		return "", "", fmt.Errorf("no LLM provider")
+	}
+
+	action, err := provider.CompleteStream(ctx, text, handler)
+	if err != nil {
+		b.log.Error().Err(err).Msg("LLM completion failed")
+		return "", "", fmt.Errorf("failed to interpret command: %w", err)
+	}
+
+	b.log.Debug().
+		Str("action", action.Action).
+		Interface("params", action.Params).
+		Str("reply", action.Reply).
+		Msg("LLM response")
+
+	if running != nil {
+		// The action that ran is the one chosen, whatever the rest became
+		started := *chosen
+		started.Reply = b.limitReply(action.Reply)
+
+		var outcome execution
+		select {
+		case outcome = <-running:
+		case <-ctx.Done():
+			return "", "", ctx.Err()
+		}
+		response := b.respond(ctx, started, outcome.result, outcome.err)
+		return response, response, nil
+	}
+
+	if confidence < b.confirmBelow && needsConfirmation(action) {
+		b.log.Info().
+			Str("action", action.Action).
+			Float64("confidence", confidence).
+			Msg("Transcription unsure, asking to confirm the action")
+		b.setPending(text, action)
+		response := fmt.Sprintf(localize(ctx, "No sé si te he entendido bien: «%s». ¿Lo hago?"), text)
+		return response, response, nil
+	}
+
+	response, err := b.Execute(ctx, action)
+	if err != nil {
+		return "", "", err
+	}
+	return response, unspoken(response, spoken), nil
+}
+
+// unspoken returns what is left of response after the sentences already
+// spoken, which are at its start
+func unspoken(response string, spoken []string) string {
+	rest := response
+	for _, sentence := range spoken {
+		i := strings.Index(rest, sentence)
+		if i < 0 {
+			break
+		}
+		rest = rest[i+len(sentence):]
+	}
+	return strings.TrimSpace(rest)
+}
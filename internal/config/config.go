@@ -5,50 +5,88 @@ import "time"
 
 // Config is the main configuration structure
 type Config struct {
-	General GeneralConfig `yaml:"general" mapstructure:"general"`
-	Audio   AudioConfig   `yaml:"audio" mapstructure:"audio"`
-	Hotkey  HotkeyConfig  `yaml:"hotkey" mapstructure:"hotkey"`
-	STT     STTConfig     `yaml:"stt" mapstructure:"stt"`
-	LLM     LLMConfig     `yaml:"llm" mapstructure:"llm"`
-	TTS     TTSConfig     `yaml:"tts" mapstructure:"tts"`
-	Twitch  TwitchConfig  `yaml:"twitch" mapstructure:"twitch"`
-	Kick    KickConfig    `yaml:"kick" mapstructure:"kick"`
-	OBS     OBSConfig     `yaml:"obs" mapstructure:"obs"`
-	Music   MusicConfig   `yaml:"music" mapstructure:"music"`
-	Sounds  SoundsConfig  `yaml:"sounds" mapstructure:"sounds"`
+	General  GeneralConfig  `yaml:"general" mapstructure:"general"`
+	Audio    AudioConfig    `yaml:"audio" mapstructure:"audio"`
+	Hotkey   HotkeyConfig   `yaml:"hotkey" mapstructure:"hotkey"`
+	STT      STTConfig      `yaml:"stt" mapstructure:"stt"`
+	LLM      LLMConfig      `yaml:"llm" mapstructure:"llm"`
+	TTS      TTSConfig      `yaml:"tts" mapstructure:"tts"`
+	Twitch   TwitchConfig   `yaml:"twitch" mapstructure:"twitch"`
+	Kick     KickConfig     `yaml:"kick" mapstructure:"kick"`
+	OBS      OBSConfig      `yaml:"obs" mapstructure:"obs"`
+	Music    MusicConfig    `yaml:"music" mapstructure:"music"`
+	Spotify  SpotifyConfig  `yaml:"spotify" mapstructure:"spotify"`
+	Ducking  DuckingConfig  `yaml:"ducking" mapstructure:"ducking"`
+	Session  SessionConfig  `yaml:"session" mapstructure:"session"`
+	Sounds   SoundsConfig   `yaml:"sounds" mapstructure:"sounds"`
+	Personas PersonasConfig `yaml:"personas" mapstructure:"personas"`
+	Intents  IntentsConfig  `yaml:"intents" mapstructure:"intents"`
 }
 
 // GeneralConfig contains general application settings
 type GeneralConfig struct {
-	Language      string `yaml:"language" mapstructure:"language"`
-	LogLevel      string `yaml:"log_level" mapstructure:"log_level"`
-	DataDir       string `yaml:"data_dir" mapstructure:"data_dir"`
-	StreamerName  string `yaml:"streamer_name" mapstructure:"streamer_name"`
+	Language     string   `yaml:"language" mapstructure:"language"`
+	LogLevel     string   `yaml:"log_level" mapstructure:"log_level"`
+	DataDir      string   `yaml:"data_dir" mapstructure:"data_dir"`
+	StreamerName string   `yaml:"streamer_name" mapstructure:"streamer_name"`
+	Languages    []string `yaml:"languages" mapstructure:"languages"` // Languages Ana switches between, detected per utterance (empty = only Language)
 }
 
 // AudioConfig contains audio capture settings
 type AudioConfig struct {
-	Device     string       `yaml:"device" mapstructure:"device"`
-	SampleRate int          `yaml:"sample_rate" mapstructure:"sample_rate"`
-	Channels   int          `yaml:"channels" mapstructure:"channels"`
-	ChunkSize  int          `yaml:"chunk_size" mapstructure:"chunk_size"`
-	VAD        VADConfig    `yaml:"vad" mapstructure:"vad"`
+	Source     string         `yaml:"source" mapstructure:"source"` // "auto", "portaudio", "pulse" or "file"
+	File       string         `yaml:"file" mapstructure:"file"`     // WAV/raw file for the "file" source, "-" for stdin
+	Device     string         `yaml:"device" mapstructure:"device"`
+	SampleRate int            `yaml:"sample_rate" mapstructure:"sample_rate"`
+	Channels   int            `yaml:"channels" mapstructure:"channels"`
+	ChunkSize  int            `yaml:"chunk_size" mapstructure:"chunk_size"`
+	VAD        VADConfig      `yaml:"vad" mapstructure:"vad"`
 	WakeWord   WakeWordConfig `yaml:"wake_word" mapstructure:"wake_word"`
+	Echo       EchoConfig     `yaml:"echo" mapstructure:"echo"`
 }
 
 // VADConfig contains Voice Activity Detection settings
 type VADConfig struct {
 	Enabled            bool    `yaml:"enabled" mapstructure:"enabled"`
+	Engine             string  `yaml:"engine" mapstructure:"engine"` // "energy" or "spectral"
 	Sensitivity        float64 `yaml:"sensitivity" mapstructure:"sensitivity"`
 	SilenceThresholdMs int     `yaml:"silence_threshold_ms" mapstructure:"silence_threshold_ms"`
 	MinSpeechMs        int     `yaml:"min_speech_ms" mapstructure:"min_speech_ms"`
+	HangoverMs         int     `yaml:"hangover_ms" mapstructure:"hangover_ms"`       // Keep "speech" on after the voice drops
+	NoiseFloorDB       float64 `yaml:"noise_floor_db" mapstructure:"noise_floor_db"` // Starting noise estimate in dBFS (0 = learn it)
+	PreRollMs          int     `yaml:"pre_roll_ms" mapstructure:"pre_roll_ms"`       // Audio kept from before a recording starts
 }
 
 // WakeWordConfig contains wake word detection settings
 type WakeWordConfig struct {
-	Enabled   bool    `yaml:"enabled" mapstructure:"enabled"`
-	Word      string  `yaml:"word" mapstructure:"word"`
-	Threshold float64 `yaml:"threshold" mapstructure:"threshold"`
+	Enabled      bool    `yaml:"enabled" mapstructure:"enabled"`
+	Word         string  `yaml:"word" mapstructure:"word"`
+	Threshold    float64 `yaml:"threshold" mapstructure:"threshold"`
+	Engine       string  `yaml:"engine" mapstructure:"engine"`               // "template" (on-device) or "stt" (transcribe and search the text)
+	TemplatesDir string  `yaml:"templates_dir" mapstructure:"templates_dir"` // WAV clips of the word in <templates_dir>/<word>/
+}
+
+// EchoConfig controls how Ana's own voice is kept out of the mic signal
+type EchoConfig struct {
+	// "cancel": subtract the TTS audio from the mic (falls back to gating
+	// when the TTS engine can't provide its audio). "gate": ignore the wake
+	// word while Ana speaks and TailMs after. "off": do nothing.
+	Mode     string `yaml:"mode" mapstructure:"mode"`
+	DelayMs  int    `yaml:"delay_ms" mapstructure:"delay_ms"`   // Fixed playback latency before the filter window
+	FilterMs int    `yaml:"filter_ms" mapstructure:"filter_ms"` // Echo path length the canceller models
+	TailMs   int    `yaml:"tail_ms" mapstructure:"tail_ms"`     // Keep suppressing this long after playback
+}
+
+// SessionConfig controls what happens after Ana answers a command
+type SessionConfig struct {
+	// "single": every command needs the wake word. "follow_up": after each
+	// reply Ana listens FollowUpSeconds more without it. "persistent": she
+	// keeps listening until a deactivation phrase ("adiós", "para ana", ...)
+	Mode            string `yaml:"mode" mapstructure:"mode"`
+	FollowUpSeconds int    `yaml:"follow_up_seconds" mapstructure:"follow_up_seconds"`
+	// BargeIn keeps listening while Ana thinks or speaks, so the wake word or
+	// "para"/"cállate" interrupts her
+	BargeIn bool `yaml:"barge_in" mapstructure:"barge_in"`
 }
 
 // HotkeyConfig contains hotkey settings
@@ -60,9 +98,50 @@ type HotkeyConfig struct {
 
 // STTConfig contains Speech-to-Text settings
 type STTConfig struct {
-	Provider string        `yaml:"provider" mapstructure:"provider"` // "whisper" or "openai"
-	Whisper  WhisperConfig `yaml:"whisper" mapstructure:"whisper"`
-	OpenAI   OpenAISTTConfig `yaml:"openai" mapstructure:"openai"`
+	Provider   string              `yaml:"provider" mapstructure:"provider"` // "whisper" or "openai"
+	Whisper    WhisperConfig       `yaml:"whisper" mapstructure:"whisper"`
+	OpenAI     OpenAISTTConfig     `yaml:"openai" mapstructure:"openai"`
+	Streaming  STTStreamingConfig  `yaml:"streaming" mapstructure:"streaming"`
+	Confidence STTConfidenceConfig `yaml:"confidence" mapstructure:"confidence"`
+	Vocabulary STTVocabularyConfig `yaml:"vocabulary" mapstructure:"vocabulary"`
+	Auto       STTAutoConfig       `yaml:"auto" mapstructure:"auto"`
+}
+
+// STTAutoConfig controls the "auto" STT provider, which tries a chain of
+// providers and skips the ones that keep failing
+type STTAutoConfig struct {
+	Order            []string       `yaml:"order" mapstructure:"order"`                         // Providers to try, first one preferred
+	TimeoutsMs       map[string]int `yaml:"timeouts_ms" mapstructure:"timeouts_ms"`             // Per provider
+	FailureThreshold int            `yaml:"failure_threshold" mapstructure:"failure_threshold"` // Failures in a row that pause a provider
+	CooldownSeconds  int            `yaml:"cooldown_seconds" mapstructure:"cooldown_seconds"`   // How long a paused provider is skipped
+	RaceAfterMs      int            `yaml:"race_after_ms" mapstructure:"race_after_ms"`         // Also start the next provider if the first hasn't answered by then (0 = never)
+}
+
+// STTVocabularyConfig controls biasing STT towards the streamer's own words:
+// Ana's name, the wake word, OBS scenes and inputs, chatters and artists
+type STTVocabularyConfig struct {
+	Enabled        bool     `yaml:"enabled" mapstructure:"enabled"`
+	Terms          []string `yaml:"terms" mapstructure:"terms"`                     // Always included
+	MaxChatters    int      `yaml:"max_chatters" mapstructure:"max_chatters"`       // Most recent chat users kept
+	RefreshSeconds int      `yaml:"refresh_seconds" mapstructure:"refresh_seconds"` // How often OBS and the music library are read
+	MaxPromptChars int      `yaml:"max_prompt_chars" mapstructure:"max_prompt_chars"`
+	Correct        bool     `yaml:"correct" mapstructure:"correct"` // Snap near-misses in transcriptions to known terms
+}
+
+// STTConfidenceConfig controls what's done with transcriptions the STT isn't
+// sure of. Confidence is 0-1; providers without scores always report 1.
+type STTConfidenceConfig struct {
+	FilterHallucinations bool    `yaml:"filter_hallucinations" mapstructure:"filter_hallucinations"`
+	NoSpeechThreshold    float64 `yaml:"no_speech_threshold" mapstructure:"no_speech_threshold"` // Segments more likely silence than this...
+	LogProbThreshold     float64 `yaml:"logprob_threshold" mapstructure:"logprob_threshold"`     // ...and less sure than this are dropped
+	RepeatBelow          float64 `yaml:"repeat_below" mapstructure:"repeat_below"`               // Ask to repeat below this confidence
+	ConfirmBelow         float64 `yaml:"confirm_below" mapstructure:"confirm_below"`             // Ask before running an action below this confidence
+}
+
+// STTStreamingConfig controls transcribing commands while they're recorded
+type STTStreamingConfig struct {
+	Enabled           bool `yaml:"enabled" mapstructure:"enabled"`
+	PartialIntervalMs int  `yaml:"partial_interval_ms" mapstructure:"partial_interval_ms"` // New audio between partial transcripts
 }
 
 // WhisperConfig contains local Whisper settings
@@ -70,6 +149,9 @@ type WhisperConfig struct {
 	BinaryPath string `yaml:"binary_path" mapstructure:"binary_path"`
 	ModelPath  string `yaml:"model_path" mapstructure:"model_path"`
 	Language   string `yaml:"language" mapstructure:"language"`
+	Mode       string `yaml:"mode" mapstructure:"mode"`               // "cli" or "server"
+	ServerPath string `yaml:"server_path" mapstructure:"server_path"` // whisper.cpp server binary
+	Port       int    `yaml:"port" mapstructure:"port"`               // Local port of the server
 }
 
 // OpenAISTTConfig contains OpenAI Whisper API settings
@@ -80,16 +162,24 @@ type OpenAISTTConfig struct {
 
 // LLMConfig contains Language Model settings
 type LLMConfig struct {
-	Provider string       `yaml:"provider" mapstructure:"provider"` // "ollama" or "openai"
-	Ollama   OllamaConfig `yaml:"ollama" mapstructure:"ollama"`
-	OpenAI   OpenAILLMConfig `yaml:"openai" mapstructure:"openai"`
+	Provider   string                     `yaml:"provider" mapstructure:"provider"` // "ollama", "openai", "llamacpp", "auto" or an endpoint name
+	Ollama     OllamaConfig               `yaml:"ollama" mapstructure:"ollama"`
+	OpenAI     OpenAILLMConfig            `yaml:"openai" mapstructure:"openai"`
+	LlamaCpp   LlamaCppConfig             `yaml:"llamacpp" mapstructure:"llamacpp"`
+	Endpoints  map[string]OpenAILLMConfig `yaml:"endpoints" mapstructure:"endpoints"` // Named OpenAI-compatible servers (LM Studio, vLLM, LocalAI...)
+	Auto       LLMAutoConfig              `yaml:"auto" mapstructure:"auto"`
+	PromptsDir string                     `yaml:"prompts_dir" mapstructure:"prompts_dir"` // <lang>.tmpl files here replace the built-in prompts
+	Macros     map[string]string          `yaml:"macros" mapstructure:"macros"`           // Named snippets for the prompt templates (.Macros)
+	Streaming  bool                       `yaml:"streaming" mapstructure:"streaming"`     // Speak the reply sentence by sentence while it's generated
 }
 
 // OllamaConfig contains local Ollama settings
 type OllamaConfig struct {
-	URL            string `yaml:"url" mapstructure:"url"`
-	Model          string `yaml:"model" mapstructure:"model"`
-	TimeoutSeconds int    `yaml:"timeout_seconds" mapstructure:"timeout_seconds"`
+	URL            string  `yaml:"url" mapstructure:"url"`
+	Model          string  `yaml:"model" mapstructure:"model"`
+	TimeoutSeconds int     `yaml:"timeout_seconds" mapstructure:"timeout_seconds"`
+	Temperature    float64 `yaml:"temperature" mapstructure:"temperature"`
+	MaxTokens      int     `yaml:"max_tokens" mapstructure:"max_tokens"`
 }
 
 // Timeout returns the timeout as a time.Duration
@@ -99,30 +189,55 @@ func (c OllamaConfig) Timeout() time.Duration {
 
 // OpenAILLMConfig contains OpenAI GPT settings
 type OpenAILLMConfig struct {
-	APIKey      string  `yaml:"api_key" mapstructure:"api_key"`
-	Model       string  `yaml:"model" mapstructure:"model"`
-	Temperature float64 `yaml:"temperature" mapstructure:"temperature"`
+	APIKey      string            `yaml:"api_key" mapstructure:"api_key"`
+	Model       string            `yaml:"model" mapstructure:"model"`
+	Temperature float64           `yaml:"temperature" mapstructure:"temperature"`
+	MaxTokens   int               `yaml:"max_tokens" mapstructure:"max_tokens"`
+	BaseURL     string            `yaml:"base_url" mapstructure:"base_url"` // "" = api.openai.com
+	Headers     map[string]string `yaml:"headers" mapstructure:"headers"`   // Sent with every request
+}
+
+// LlamaCppConfig contains the settings of a llama.cpp server
+type LlamaCppConfig struct {
+	URL            string  `yaml:"url" mapstructure:"url"`
+	Model          string  `yaml:"model" mapstructure:"model"` // Only reported, the server runs one model
+	TimeoutSeconds int     `yaml:"timeout_seconds" mapstructure:"timeout_seconds"`
+	Temperature    float64 `yaml:"temperature" mapstructure:"temperature"`
+	MaxTokens      int     `yaml:"max_tokens" mapstructure:"max_tokens"`
+	Grammar        bool    `yaml:"grammar" mapstructure:"grammar"` // Constrain the output to a valid action with GBNF
+}
+
+// Timeout returns the timeout as a time.Duration
+func (c LlamaCppConfig) Timeout() time.Duration {
+	return time.Duration(c.TimeoutSeconds) * time.Second
+}
+
+// LLMAutoConfig contains the backends the auto LLM provider tries
+type LLMAutoConfig struct {
+	Order []string `yaml:"order" mapstructure:"order"` // First available wins: "ollama", "llamacpp", "openai" or endpoint names
 }
 
 // TTSConfig contains Text-to-Speech settings
 type TTSConfig struct {
-	Provider string        `yaml:"provider" mapstructure:"provider"` // "piper" or "openai"
-	Piper    PiperConfig   `yaml:"piper" mapstructure:"piper"`
+	Provider string          `yaml:"provider" mapstructure:"provider"` // "piper" or "openai"
+	Piper    PiperConfig     `yaml:"piper" mapstructure:"piper"`
 	OpenAI   OpenAITTSConfig `yaml:"openai" mapstructure:"openai"`
 }
 
 // PiperConfig contains local Piper TTS settings
 type PiperConfig struct {
-	BinaryPath string  `yaml:"binary_path" mapstructure:"binary_path"`
-	ModelPath  string  `yaml:"model_path" mapstructure:"model_path"`
-	Speed      float64 `yaml:"speed" mapstructure:"speed"`
+	BinaryPath string            `yaml:"binary_path" mapstructure:"binary_path"`
+	ModelPath  string            `yaml:"model_path" mapstructure:"model_path"`
+	Speed      float64           `yaml:"speed" mapstructure:"speed"`
+	Voices     map[string]string `yaml:"voices" mapstructure:"voices"` // Model per language, model_path for the rest
 }
 
 // OpenAITTSConfig contains OpenAI TTS settings
 type OpenAITTSConfig struct {
-	APIKey string `yaml:"api_key" mapstructure:"api_key"`
-	Model  string `yaml:"model" mapstructure:"model"`
-	Voice  string `yaml:"voice" mapstructure:"voice"`
+	APIKey string            `yaml:"api_key" mapstructure:"api_key"`
+	Model  string            `yaml:"model" mapstructure:"model"`
+	Voice  string            `yaml:"voice" mapstructure:"voice"`
+	Voices map[string]string `yaml:"voices" mapstructure:"voices"` // Voice per language, voice for the rest
 }
 
 // TwitchConfig contains Twitch integration settings
@@ -152,6 +267,7 @@ type OBSConfig struct {
 	Enabled  bool   `yaml:"enabled" mapstructure:"enabled"`
 	URL      string `yaml:"url" mapstructure:"url"`
 	Password string `yaml:"password" mapstructure:"password"`
+	MicInput string `yaml:"mic_input" mapstructure:"mic_input"` // Input muted by obs.mute/obs.unmute without a source
 }
 
 // MusicConfig contains music player settings
@@ -161,6 +277,78 @@ type MusicConfig struct {
 	SupportedFormats []string `yaml:"supported_formats" mapstructure:"supported_formats"`
 	DefaultVolume    float64  `yaml:"default_volume" mapstructure:"default_volume"`
 	Shuffle          bool     `yaml:"shuffle" mapstructure:"shuffle"`
+	Repeat           string   `yaml:"repeat" mapstructure:"repeat"` // "off", "one" or "all"
+
+	NowPlaying NowPlayingConfig  `yaml:"now_playing" mapstructure:"now_playing"`
+	Requests   SongRequestConfig `yaml:"requests" mapstructure:"requests"`
+}
+
+// NowPlayingConfig contains now-playing output settings for stream overlays
+type NowPlayingConfig struct {
+	Enabled   bool   `yaml:"enabled" mapstructure:"enabled"`
+	File      string `yaml:"file" mapstructure:"file"`             // Text file read by OBS "Read from file"
+	Template  string `yaml:"template" mapstructure:"template"`     // Placeholders: {title} {artist} {album} {file}
+	ArtFile   string `yaml:"art_file" mapstructure:"art_file"`     // Album art image for an OBS image source
+	OBSSource string `yaml:"obs_source" mapstructure:"obs_source"` // OBS text source updated via WebSocket
+}
+
+// SongRequestConfig contains viewer song request settings
+type SongRequestConfig struct {
+	Enabled        bool     `yaml:"enabled" mapstructure:"enabled"`
+	MaxPerUser     int      `yaml:"max_per_user" mapstructure:"max_per_user"`         // Pending requests per viewer (0 = unlimited)
+	MaxDurationSec int      `yaml:"max_duration_sec" mapstructure:"max_duration_sec"` // Longest allowed track (0 = unlimited)
+	Blocklist      []string `yaml:"blocklist" mapstructure:"blocklist"`               // Words, artists or titles that can't be requested
+
+	HTTP          SongRequestHTTPConfig          `yaml:"http" mapstructure:"http"`
+	Chat          SongRequestChatConfig          `yaml:"chat" mapstructure:"chat"`
+	ChannelPoints SongRequestChannelPointsConfig `yaml:"channel_points" mapstructure:"channel_points"`
+}
+
+// SongRequestHTTPConfig contains the HTTP song request endpoint settings
+type SongRequestHTTPConfig struct {
+	Enabled bool   `yaml:"enabled" mapstructure:"enabled"`
+	Addr    string `yaml:"addr" mapstructure:"addr"`
+}
+
+// SongRequestChatConfig contains the Twitch chat command settings
+type SongRequestChatConfig struct {
+	Enabled  bool   `yaml:"enabled" mapstructure:"enabled"`
+	Channel  string `yaml:"channel" mapstructure:"channel"`
+	Username string `yaml:"username" mapstructure:"username"` // Defaults to the channel
+	Token    string `yaml:"token" mapstructure:"token"`       // Needs chat:read and chat:edit, defaults to twitch.access_token
+	Command  string `yaml:"command" mapstructure:"command"`
+	Server   string `yaml:"server" mapstructure:"server"`
+}
+
+// SongRequestChannelPointsConfig contains the channel point redemption settings
+type SongRequestChannelPointsConfig struct {
+	Enabled bool   `yaml:"enabled" mapstructure:"enabled"`
+	Reward  string `yaml:"reward" mapstructure:"reward"` // Reward title, the viewer's input is the song
+	URL     string `yaml:"url" mapstructure:"url"`       // EventSub WebSocket URL
+}
+
+// SpotifyConfig contains Spotify Web API settings
+type SpotifyConfig struct {
+	Enabled     bool   `yaml:"enabled" mapstructure:"enabled"`
+	ClientID    string `yaml:"client_id" mapstructure:"client_id"`
+	RedirectURI string `yaml:"redirect_uri" mapstructure:"redirect_uri"`
+	TokenFile   string `yaml:"token_file" mapstructure:"token_file"` // Persisted OAuth tokens
+	Device      string `yaml:"device" mapstructure:"device"`         // Preferred playback device name (optional)
+	APIURL      string `yaml:"api_url" mapstructure:"api_url"`
+	AuthURL     string `yaml:"auth_url" mapstructure:"auth_url"`
+}
+
+// DuckingConfig contains settings for lowering music while someone speaks
+type DuckingConfig struct {
+	Enabled  bool    `yaml:"enabled" mapstructure:"enabled"`
+	AmountDB float64 `yaml:"amount_db" mapstructure:"amount_db"` // Attenuation while ducked (positive dB)
+	AttackMs int     `yaml:"attack_ms" mapstructure:"attack_ms"` // Fade down time
+	HoldMs   int     `yaml:"hold_ms" mapstructure:"hold_ms"`     // Wait after speech ends before restoring
+	FadeMs   int     `yaml:"fade_ms" mapstructure:"fade_ms"`     // Fade back up time
+	OnTTS    bool    `yaml:"on_tts" mapstructure:"on_tts"`       // Duck while Ana speaks
+	OnSpeech bool    `yaml:"on_speech" mapstructure:"on_speech"` // Duck while the streamer speaks (VAD)
+	Music    bool    `yaml:"music" mapstructure:"music"`         // Duck the local music player
+	OBSInput string  `yaml:"obs_input" mapstructure:"obs_input"` // OBS input to duck (optional)
 }
 
 // SoundsConfig contains system sound settings
@@ -170,4 +358,46 @@ type SoundsConfig struct {
 	Error          string `yaml:"error" mapstructure:"error"`
 	StartRecording string `yaml:"start_recording" mapstructure:"start_recording"`
 	StopRecording  string `yaml:"stop_recording" mapstructure:"stop_recording"`
+	FollowUpStart  string `yaml:"follow_up_start" mapstructure:"follow_up_start"` // Follow-up window opens
+	FollowUpEnd    string `yaml:"follow_up_end" mapstructure:"follow_up_end"`     // Follow-up window closes
+}
+
+// PersonasConfig contains the personalities Ana can switch to while live
+type PersonasConfig struct {
+	Default  string                   `yaml:"default" mapstructure:"default"` // Persona at startup ("" = none)
+	Profiles map[string]PersonaConfig `yaml:"profiles" mapstructure:"profiles"`
+}
+
+// IntentsConfig contains the rules that map simple commands straight to an
+// action, without asking the LLM
+type IntentsConfig struct {
+	Enabled bool         `yaml:"enabled" mapstructure:"enabled"`
+	Builtin bool         `yaml:"builtin" mapstructure:"builtin"` // Also use the built-in rules (music, scenes, recording...)
+	Rules   []IntentRule `yaml:"rules" mapstructure:"rules"`     // Tried before the built-in ones
+}
+
+// IntentRule maps the commands matching its patterns to an action. Slots
+// captured from the command become params of the action.
+type IntentRule struct {
+	Name     string                 `yaml:"name" mapstructure:"name"`
+	Action   string                 `yaml:"action" mapstructure:"action"`
+	Patterns []string               `yaml:"patterns" mapstructure:"patterns"` // "cambia a [la] escena {scene}": [optional], (a|b), {slot} or {slot:type}
+	Regex    []string               `yaml:"regex" mapstructure:"regex"`       // Go regexps, (?P<slot>...) captures a slot
+	Params   map[string]interface{} `yaml:"params" mapstructure:"params"`     // Fixed params of the action
+	Slots    map[string]string      `yaml:"slots" mapstructure:"slots"`       // Slot types: text, int, number or percent (50 -> 0.5)
+	Reply    string                 `yaml:"reply" mapstructure:"reply"`       // {slot} is replaced by what was said
+	Language string                 `yaml:"language" mapstructure:"language"` // Only for commands in this language ("" = any)
+}
+
+// PersonaConfig is a personality of Ana. Empty fields keep the general
+// settings.
+type PersonaConfig struct {
+	Aliases       []string `yaml:"aliases" mapstructure:"aliases"` // Other names the streamer may call it
+	Prompt        string   `yaml:"prompt" mapstructure:"prompt"`   // Personality instructions, a template like the prompts (.Persona)
+	Temperature   float64  `yaml:"temperature" mapstructure:"temperature"`
+	MaxTokens     int      `yaml:"max_tokens" mapstructure:"max_tokens"`
+	MaxReplyChars int      `yaml:"max_reply_chars" mapstructure:"max_reply_chars"` // Longer replies are cut at a sentence end
+	Language      string   `yaml:"language" mapstructure:"language"`               // Ana replies in it whatever the streamer speaks
+	Voice         string   `yaml:"voice" mapstructure:"voice"`                     // Piper model or OpenAI voice
+	Speed         float64  `yaml:"speed" mapstructure:"speed"`
 }
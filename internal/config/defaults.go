@@ -10,20 +10,31 @@ func DefaultConfig() *Config {
 			StreamerName: "Streamer",
 		},
 		Audio: AudioConfig{
+			Source:     "auto",
 			Device:     "default",
 			SampleRate: 16000,
 			Channels:   1,
 			ChunkSize:  1024,
 			VAD: VADConfig{
 				Enabled:            true,
+				Engine:             "energy",
 				Sensitivity:        0.5,
 				SilenceThresholdMs: 1500,
 				MinSpeechMs:        300,
+				HangoverMs:         200,
+				PreRollMs:          500,
 			},
 			WakeWord: WakeWordConfig{
-				Enabled:   true,
-				Word:      "ana",
-				Threshold: 0.7,
+				Enabled:      true,
+				Word:         "ana",
+				Threshold:    0.7,
+				Engine:       "template",
+				TemplatesDir: "./assets/models/wakeword",
+			},
+			Echo: EchoConfig{
+				Mode:     "cancel",
+				FilterMs: 128,
+				TailMs:   300,
 			},
 		},
 		Hotkey: HotkeyConfig{
@@ -37,21 +48,62 @@ func DefaultConfig() *Config {
 				BinaryPath: "./bin/whisper/whisper-cli",
 				ModelPath:  "./assets/models/whisper/ggml-base.bin",
 				Language:   "es",
+				Mode:       "cli",
+				ServerPath: "./bin/whisper/whisper-server",
+				Port:       8178,
 			},
 			OpenAI: OpenAISTTConfig{
 				Model: "whisper-1",
 			},
+			Streaming: STTStreamingConfig{
+				PartialIntervalMs: 1000,
+			},
+			Confidence: STTConfidenceConfig{
+				FilterHallucinations: true,
+				NoSpeechThreshold:    0.6,
+				LogProbThreshold:     -1.0,
+				RepeatBelow:          0.3,
+				ConfirmBelow:         0.5,
+			},
+			Vocabulary: STTVocabularyConfig{
+				Enabled:        true,
+				MaxChatters:    30,
+				RefreshSeconds: 60,
+				MaxPromptChars: 600,
+				Correct:        true,
+			},
+			Auto: STTAutoConfig{
+				Order:            []string{"whisper", "openai"},
+				TimeoutsMs:       map[string]int{"whisper": 20000, "openai": 15000},
+				FailureThreshold: 3,
+				CooldownSeconds:  60,
+			},
 		},
 		LLM: LLMConfig{
-			Provider: "ollama",
+			Provider:   "ollama",
+			PromptsDir: "./config/prompts",
+			Streaming:  true,
 			Ollama: OllamaConfig{
 				URL:            "http://localhost:11434",
 				Model:          "gemma3:4b",
 				TimeoutSeconds: 30,
+				Temperature:    0.3,
+				MaxTokens:      500,
 			},
 			OpenAI: OpenAILLMConfig{
 				Model:       "gpt-4o-mini",
 				Temperature: 0.3,
+				MaxTokens:   500,
+			},
+			LlamaCpp: LlamaCppConfig{
+				URL:            "http://localhost:8080",
+				TimeoutSeconds: 30,
+				Temperature:    0.3,
+				MaxTokens:      500,
+				Grammar:        true,
+			},
+			Auto: LLMAutoConfig{
+				Order: []string{"ollama", "openai"},
 			},
 		},
 		TTS: TTSConfig{
@@ -71,8 +123,9 @@ func DefaultConfig() *Config {
 			RedirectURI: "http://localhost:3000/callback",
 		},
 		OBS: OBSConfig{
-			Enabled: false,
-			URL:     "ws://localhost:4455",
+			Enabled:  false,
+			URL:      "ws://localhost:4455",
+			MicInput: "Mic/Aux",
 		},
 		Music: MusicConfig{
 			Enabled: true,
@@ -85,6 +138,50 @@ func DefaultConfig() *Config {
 			},
 			DefaultVolume: 0.5,
 			Shuffle:       false,
+			Repeat:        "off",
+			NowPlaying: NowPlayingConfig{
+				Enabled:  false,
+				File:     "./data/now_playing.txt",
+				Template: "{artist} - {title}",
+				ArtFile:  "./data/now_playing_art.jpg",
+			},
+			Requests: SongRequestConfig{
+				Enabled:        false,
+				MaxPerUser:     2,
+				MaxDurationSec: 600,
+				HTTP: SongRequestHTTPConfig{
+					Addr: "127.0.0.1:8787",
+				},
+				Chat: SongRequestChatConfig{
+					Command: "!sr",
+					Server:  "irc.chat.twitch.tv:6697",
+				},
+				ChannelPoints: SongRequestChannelPointsConfig{
+					URL: "wss://eventsub.wss.twitch.tv/ws",
+				},
+			},
+		},
+		Spotify: SpotifyConfig{
+			Enabled:     false,
+			RedirectURI: "http://127.0.0.1:8888/callback",
+			TokenFile:   "./data/spotify_token.json",
+			APIURL:      "https://api.spotify.com/v1",
+			AuthURL:     "https://accounts.spotify.com",
+		},
+		Ducking: DuckingConfig{
+			Enabled:  false,
+			AmountDB: 12,
+			AttackMs: 150,
+			HoldMs:   600,
+			FadeMs:   800,
+			OnTTS:    true,
+			OnSpeech: true,
+			Music:    true,
+		},
+		Session: SessionConfig{
+			Mode:            "follow_up",
+			FollowUpSeconds: 8,
+			BargeIn:         true,
 		},
 		Sounds: SoundsConfig{
 			Enabled:        true,
@@ -92,6 +189,12 @@ func DefaultConfig() *Config {
 			Error:          "./assets/sounds/error.wav",
 			StartRecording: "./assets/sounds/beep_start.wav",
 			StopRecording:  "./assets/sounds/beep_end.wav",
+			FollowUpStart:  "./assets/sounds/follow_up_start.wav",
+			FollowUpEnd:    "./assets/sounds/follow_up_end.wav",
+		},
+		Intents: IntentsConfig{
+			Enabled: true,
+			Builtin: true,
 		},
 	}
 }
@@ -127,6 +230,9 @@ func ApplyDefaults(cfg *Config) {
 	if cfg.Audio.Device == "" {
 		cfg.Audio.Device = defaults.Audio.Device
 	}
+	if cfg.Audio.Source == "" {
+		cfg.Audio.Source = defaults.Audio.Source
+	}
 
 	// VAD
 	if cfg.Audio.VAD.SilenceThresholdMs == 0 {
@@ -135,6 +241,15 @@ func ApplyDefaults(cfg *Config) {
 	if cfg.Audio.VAD.MinSpeechMs == 0 {
 		cfg.Audio.VAD.MinSpeechMs = defaults.Audio.VAD.MinSpeechMs
 	}
+	if cfg.Audio.VAD.Engine == "" {
+		cfg.Audio.VAD.Engine = defaults.Audio.VAD.Engine
+	}
+	if cfg.Audio.VAD.HangoverMs == 0 {
+		cfg.Audio.VAD.HangoverMs = defaults.Audio.VAD.HangoverMs
+	}
+	if cfg.Audio.VAD.PreRollMs == 0 {
+		cfg.Audio.VAD.PreRollMs = defaults.Audio.VAD.PreRollMs
+	}
 
 	// Wake Word
 	if cfg.Audio.WakeWord.Word == "" {
@@ -143,6 +258,23 @@ func ApplyDefaults(cfg *Config) {
 	if cfg.Audio.WakeWord.Threshold == 0 {
 		cfg.Audio.WakeWord.Threshold = defaults.Audio.WakeWord.Threshold
 	}
+	if cfg.Audio.WakeWord.Engine == "" {
+		cfg.Audio.WakeWord.Engine = defaults.Audio.WakeWord.Engine
+	}
+	if cfg.Audio.WakeWord.TemplatesDir == "" {
+		cfg.Audio.WakeWord.TemplatesDir = defaults.Audio.WakeWord.TemplatesDir
+	}
+
+	// Echo
+	if cfg.Audio.Echo.Mode == "" {
+		cfg.Audio.Echo.Mode = defaults.Audio.Echo.Mode
+	}
+	if cfg.Audio.Echo.FilterMs == 0 {
+		cfg.Audio.Echo.FilterMs = defaults.Audio.Echo.FilterMs
+	}
+	if cfg.Audio.Echo.TailMs == 0 {
+		cfg.Audio.Echo.TailMs = defaults.Audio.Echo.TailMs
+	}
 
 	// Hotkey
 	if cfg.Hotkey.Key == "" {
@@ -162,11 +294,64 @@ func ApplyDefaults(cfg *Config) {
 	if cfg.STT.Whisper.Language == "" {
 		cfg.STT.Whisper.Language = defaults.STT.Whisper.Language
 	}
+	if cfg.STT.Whisper.Mode == "" {
+		cfg.STT.Whisper.Mode = defaults.STT.Whisper.Mode
+	}
+	if cfg.STT.Whisper.ServerPath == "" {
+		cfg.STT.Whisper.ServerPath = defaults.STT.Whisper.ServerPath
+	}
+	if cfg.STT.Whisper.Port == 0 {
+		cfg.STT.Whisper.Port = defaults.STT.Whisper.Port
+	}
 	if cfg.STT.OpenAI.Model == "" {
 		cfg.STT.OpenAI.Model = defaults.STT.OpenAI.Model
 	}
+	if cfg.STT.Streaming.PartialIntervalMs == 0 {
+		cfg.STT.Streaming.PartialIntervalMs = defaults.STT.Streaming.PartialIntervalMs
+	}
+	if cfg.STT.Confidence.NoSpeechThreshold == 0 {
+		cfg.STT.Confidence.NoSpeechThreshold = defaults.STT.Confidence.NoSpeechThreshold
+	}
+	if cfg.STT.Confidence.LogProbThreshold == 0 {
+		cfg.STT.Confidence.LogProbThreshold = defaults.STT.Confidence.LogProbThreshold
+	}
+	if cfg.STT.Confidence.RepeatBelow == 0 {
+		cfg.STT.Confidence.RepeatBelow = defaults.STT.Confidence.RepeatBelow
+	}
+	if cfg.STT.Confidence.ConfirmBelow == 0 {
+		cfg.STT.Confidence.ConfirmBelow = defaults.STT.Confidence.ConfirmBelow
+	}
+	if cfg.STT.Vocabulary.MaxChatters == 0 {
+		cfg.STT.Vocabulary.MaxChatters = defaults.STT.Vocabulary.MaxChatters
+	}
+	if cfg.STT.Vocabulary.RefreshSeconds == 0 {
+		cfg.STT.Vocabulary.RefreshSeconds = defaults.STT.Vocabulary.RefreshSeconds
+	}
+	if cfg.STT.Vocabulary.MaxPromptChars == 0 {
+		cfg.STT.Vocabulary.MaxPromptChars = defaults.STT.Vocabulary.MaxPromptChars
+	}
+	if len(cfg.STT.Auto.Order) == 0 {
+		cfg.STT.Auto.Order = defaults.STT.Auto.Order
+	}
+	if cfg.STT.Auto.TimeoutsMs == nil {
+		cfg.STT.Auto.TimeoutsMs = map[string]int{}
+	}
+	for name, timeout := range defaults.STT.Auto.TimeoutsMs {
+		if cfg.STT.Auto.TimeoutsMs[name] == 0 {
+			cfg.STT.Auto.TimeoutsMs[name] = timeout
+		}
+	}
+	if cfg.STT.Auto.FailureThreshold == 0 {
+		cfg.STT.Auto.FailureThreshold = defaults.STT.Auto.FailureThreshold
+	}
+	if cfg.STT.Auto.CooldownSeconds == 0 {
+		cfg.STT.Auto.CooldownSeconds = defaults.STT.Auto.CooldownSeconds
+	}
 
 	// LLM
+	if cfg.LLM.PromptsDir == "" {
+		cfg.LLM.PromptsDir = defaults.LLM.PromptsDir
+	}
 	if cfg.LLM.Provider == "" {
 		cfg.LLM.Provider = defaults.LLM.Provider
 	}
@@ -185,6 +370,39 @@ func ApplyDefaults(cfg *Config) {
 	if cfg.LLM.OpenAI.Temperature == 0 {
 		cfg.LLM.OpenAI.Temperature = defaults.LLM.OpenAI.Temperature
 	}
+	if cfg.LLM.OpenAI.MaxTokens == 0 {
+		cfg.LLM.OpenAI.MaxTokens = defaults.LLM.OpenAI.MaxTokens
+	}
+	if cfg.LLM.Ollama.Temperature == 0 {
+		cfg.LLM.Ollama.Temperature = defaults.LLM.Ollama.Temperature
+	}
+	if cfg.LLM.Ollama.MaxTokens == 0 {
+		cfg.LLM.Ollama.MaxTokens = defaults.LLM.Ollama.MaxTokens
+	}
+	if cfg.LLM.LlamaCpp.URL == "" {
+		cfg.LLM.LlamaCpp.URL = defaults.LLM.LlamaCpp.URL
+	}
+	if cfg.LLM.LlamaCpp.TimeoutSeconds == 0 {
+		cfg.LLM.LlamaCpp.TimeoutSeconds = defaults.LLM.LlamaCpp.TimeoutSeconds
+	}
+	if cfg.LLM.LlamaCpp.Temperature == 0 {
+		cfg.LLM.LlamaCpp.Temperature = defaults.LLM.LlamaCpp.Temperature
+	}
+	if cfg.LLM.LlamaCpp.MaxTokens == 0 {
+		cfg.LLM.LlamaCpp.MaxTokens = defaults.LLM.LlamaCpp.MaxTokens
+	}
+	for name, endpoint := range cfg.LLM.Endpoints {
+		if endpoint.Temperature == 0 {
+			endpoint.Temperature = defaults.LLM.OpenAI.Temperature
+		}
+		if endpoint.MaxTokens == 0 {
+			endpoint.MaxTokens = defaults.LLM.OpenAI.MaxTokens
+		}
+		cfg.LLM.Endpoints[name] = endpoint
+	}
+	if len(cfg.LLM.Auto.Order) == 0 {
+		cfg.LLM.Auto.Order = defaults.LLM.Auto.Order
+	}
 
 	// TTS
 	if cfg.TTS.Provider == "" {
@@ -212,6 +430,9 @@ func ApplyDefaults(cfg *Config) {
 	if cfg.OBS.URL == "" {
 		cfg.OBS.URL = defaults.OBS.URL
 	}
+	if cfg.OBS.MicInput == "" {
+		cfg.OBS.MicInput = defaults.OBS.MicInput
+	}
 
 	// Music
 	if len(cfg.Music.Folders) == 0 {
@@ -223,6 +444,73 @@ func ApplyDefaults(cfg *Config) {
 	if cfg.Music.DefaultVolume == 0 {
 		cfg.Music.DefaultVolume = defaults.Music.DefaultVolume
 	}
+	if cfg.Music.Repeat == "" {
+		cfg.Music.Repeat = defaults.Music.Repeat
+	}
+	if cfg.Music.NowPlaying.File == "" {
+		cfg.Music.NowPlaying.File = defaults.Music.NowPlaying.File
+	}
+	if cfg.Music.NowPlaying.Template == "" {
+		cfg.Music.NowPlaying.Template = defaults.Music.NowPlaying.Template
+	}
+	if cfg.Music.NowPlaying.ArtFile == "" {
+		cfg.Music.NowPlaying.ArtFile = defaults.Music.NowPlaying.ArtFile
+	}
+
+	if cfg.Music.Requests.HTTP.Addr == "" {
+		cfg.Music.Requests.HTTP.Addr = defaults.Music.Requests.HTTP.Addr
+	}
+	if cfg.Music.Requests.Chat.Command == "" {
+		cfg.Music.Requests.Chat.Command = defaults.Music.Requests.Chat.Command
+	}
+	if cfg.Music.Requests.Chat.Server == "" {
+		cfg.Music.Requests.Chat.Server = defaults.Music.Requests.Chat.Server
+	}
+	if cfg.Music.Requests.Chat.Username == "" {
+		cfg.Music.Requests.Chat.Username = cfg.Music.Requests.Chat.Channel
+	}
+	if cfg.Music.Requests.Chat.Token == "" {
+		cfg.Music.Requests.Chat.Token = cfg.Twitch.AccessToken
+	}
+	if cfg.Music.Requests.ChannelPoints.URL == "" {
+		cfg.Music.Requests.ChannelPoints.URL = defaults.Music.Requests.ChannelPoints.URL
+	}
+
+	// Spotify
+	if cfg.Spotify.RedirectURI == "" {
+		cfg.Spotify.RedirectURI = defaults.Spotify.RedirectURI
+	}
+	if cfg.Spotify.TokenFile == "" {
+		cfg.Spotify.TokenFile = defaults.Spotify.TokenFile
+	}
+	if cfg.Spotify.APIURL == "" {
+		cfg.Spotify.APIURL = defaults.Spotify.APIURL
+	}
+	if cfg.Spotify.AuthURL == "" {
+		cfg.Spotify.AuthURL = defaults.Spotify.AuthURL
+	}
+
+	// Ducking
+	if cfg.Ducking.AmountDB == 0 {
+		cfg.Ducking.AmountDB = defaults.Ducking.AmountDB
+	}
+	if cfg.Ducking.AttackMs == 0 {
+		cfg.Ducking.AttackMs = defaults.Ducking.AttackMs
+	}
+	if cfg.Ducking.HoldMs == 0 {
+		cfg.Ducking.HoldMs = defaults.Ducking.HoldMs
+	}
+	if cfg.Ducking.FadeMs == 0 {
+		cfg.Ducking.FadeMs = defaults.Ducking.FadeMs
+	}
+
+	// Session
+	if cfg.Session.Mode == "" {
+		cfg.Session.Mode = defaults.Session.Mode
+	}
+	if cfg.Session.FollowUpSeconds == 0 {
+		cfg.Session.FollowUpSeconds = defaults.Session.FollowUpSeconds
+	}
 
 	// Sounds
 	if cfg.Sounds.Wake == "" {
@@ -237,4 +525,10 @@ func ApplyDefaults(cfg *Config) {
 	if cfg.Sounds.StopRecording == "" {
 		cfg.Sounds.StopRecording = defaults.Sounds.StopRecording
 	}
+	if cfg.Sounds.FollowUpStart == "" {
+		cfg.Sounds.FollowUpStart = defaults.Sounds.FollowUpStart
+	}
+	if cfg.Sounds.FollowUpEnd == "" {
+		cfg.Sounds.FollowUpEnd = defaults.Sounds.FollowUpEnd
+	}
 }
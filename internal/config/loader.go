@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
+	"slices"
 	"strings"
 
 	"github.com/spf13/viper"
@@ -80,44 +82,131 @@ func expandEnvVars(cfg *Config) {
 	cfg.STT.OpenAI.APIKey = os.ExpandEnv(cfg.STT.OpenAI.APIKey)
 	cfg.STT.Whisper.BinaryPath = os.ExpandEnv(cfg.STT.Whisper.BinaryPath)
 	cfg.STT.Whisper.ModelPath = os.ExpandEnv(cfg.STT.Whisper.ModelPath)
+	cfg.STT.Whisper.ServerPath = os.ExpandEnv(cfg.STT.Whisper.ServerPath)
 
 	// LLM
 	cfg.LLM.OpenAI.APIKey = os.ExpandEnv(cfg.LLM.OpenAI.APIKey)
+	for key, value := range cfg.LLM.OpenAI.Headers {
+		cfg.LLM.OpenAI.Headers[key] = os.ExpandEnv(value)
+	}
+	for name, endpoint := range cfg.LLM.Endpoints {
+		endpoint.APIKey = os.ExpandEnv(endpoint.APIKey)
+		endpoint.BaseURL = os.ExpandEnv(endpoint.BaseURL)
+		for key, value := range endpoint.Headers {
+			endpoint.Headers[key] = os.ExpandEnv(value)
+		}
+		cfg.LLM.Endpoints[name] = endpoint
+	}
 
 	// TTS
 	cfg.TTS.OpenAI.APIKey = os.ExpandEnv(cfg.TTS.OpenAI.APIKey)
 	cfg.TTS.Piper.BinaryPath = os.ExpandEnv(cfg.TTS.Piper.BinaryPath)
 	cfg.TTS.Piper.ModelPath = os.ExpandEnv(cfg.TTS.Piper.ModelPath)
+	for lang, model := range cfg.TTS.Piper.Voices {
+		cfg.TTS.Piper.Voices[lang] = os.ExpandEnv(model)
+	}
 
 	// Paths
 	cfg.General.DataDir = os.ExpandEnv(cfg.General.DataDir)
+	cfg.Audio.WakeWord.TemplatesDir = os.ExpandEnv(cfg.Audio.WakeWord.TemplatesDir)
+	cfg.LLM.PromptsDir = os.ExpandEnv(cfg.LLM.PromptsDir)
 
 	// Music folders
 	for i, folder := range cfg.Music.Folders {
 		cfg.Music.Folders[i] = os.ExpandEnv(folder)
 	}
+	cfg.Music.NowPlaying.File = os.ExpandEnv(cfg.Music.NowPlaying.File)
+	cfg.Music.NowPlaying.ArtFile = os.ExpandEnv(cfg.Music.NowPlaying.ArtFile)
+
+	cfg.Music.Requests.Chat.Token = os.ExpandEnv(cfg.Music.Requests.Chat.Token)
+
+	// Spotify
+	cfg.Spotify.ClientID = os.ExpandEnv(cfg.Spotify.ClientID)
+	cfg.Spotify.TokenFile = os.ExpandEnv(cfg.Spotify.TokenFile)
 
 	// Sounds
 	cfg.Sounds.Wake = os.ExpandEnv(cfg.Sounds.Wake)
 	cfg.Sounds.Error = os.ExpandEnv(cfg.Sounds.Error)
 	cfg.Sounds.StartRecording = os.ExpandEnv(cfg.Sounds.StartRecording)
 	cfg.Sounds.StopRecording = os.ExpandEnv(cfg.Sounds.StopRecording)
+
+	// Personas
+	for name, persona := range cfg.Personas.Profiles {
+		persona.Voice = os.ExpandEnv(persona.Voice)
+		cfg.Personas.Profiles[name] = persona
+	}
 }
 
 // Validate validates the configuration
 func Validate(cfg *Config) error {
 	var errors []string
 
+	if len(cfg.General.Languages) > 0 && !slices.Contains(cfg.General.Languages, cfg.General.Language) {
+		errors = append(errors, fmt.Sprintf("general.languages must include the main language %s", cfg.General.Language))
+	}
+
 	// Validate STT config
 	switch cfg.STT.Provider {
 	case "whisper":
 		// Whisper binary and model will be validated at runtime
+		if cfg.STT.Whisper.Mode != "cli" && cfg.STT.Whisper.Mode != "server" {
+			errors = append(errors, fmt.Sprintf("invalid Whisper mode: %s (must be 'cli' or 'server')", cfg.STT.Whisper.Mode))
+		}
+		if cfg.STT.Whisper.Port < 1 || cfg.STT.Whisper.Port > 65535 {
+			errors = append(errors, "Whisper server port must be between 1 and 65535")
+		}
 	case "openai":
 		if cfg.STT.OpenAI.APIKey == "" {
 			errors = append(errors, "OpenAI API key required for STT when using OpenAI provider")
 		}
+	case "auto":
+		seen := map[string]bool{}
+		for _, name := range cfg.STT.Auto.Order {
+			if name != "whisper" && name != "openai" {
+				errors = append(errors, fmt.Sprintf("invalid STT auto provider: %s (must be 'whisper' or 'openai')", name))
+			}
+			if seen[name] {
+				errors = append(errors, fmt.Sprintf("STT auto provider %s listed twice", name))
+			}
+			seen[name] = true
+		}
+		for name, timeout := range cfg.STT.Auto.TimeoutsMs {
+			if timeout < 0 {
+				errors = append(errors, fmt.Sprintf("STT auto timeout for %s cannot be negative", name))
+			}
+		}
+		if cfg.STT.Auto.FailureThreshold < 1 {
+			errors = append(errors, "STT auto failure_threshold must be at least 1")
+		}
+		if cfg.STT.Auto.CooldownSeconds < 1 {
+			errors = append(errors, "STT auto cooldown_seconds must be at least 1")
+		}
+		if cfg.STT.Auto.RaceAfterMs < 0 {
+			errors = append(errors, "STT auto race_after_ms cannot be negative")
+		}
 	default:
-		errors = append(errors, fmt.Sprintf("invalid STT provider: %s (must be 'whisper' or 'openai')", cfg.STT.Provider))
+		errors = append(errors, fmt.Sprintf("invalid STT provider: %s (must be 'whisper', 'openai' or 'auto')", cfg.STT.Provider))
+	}
+	if cfg.STT.Streaming.PartialIntervalMs < 200 || cfg.STT.Streaming.PartialIntervalMs > 10000 {
+		errors = append(errors, "STT streaming partial_interval_ms must be between 200 and 10000")
+	}
+	if c := cfg.STT.Confidence; c.NoSpeechThreshold < 0 || c.NoSpeechThreshold > 1 {
+		errors = append(errors, "STT confidence no_speech_threshold must be between 0 and 1")
+	}
+	if cfg.STT.Confidence.LogProbThreshold > 0 {
+		errors = append(errors, "STT confidence logprob_threshold must be negative")
+	}
+	if c := cfg.STT.Confidence; c.RepeatBelow < 0 || c.ConfirmBelow > 1 || c.RepeatBelow > c.ConfirmBelow {
+		errors = append(errors, "STT confidence must have 0 <= repeat_below <= confirm_below <= 1")
+	}
+	if cfg.STT.Vocabulary.MaxChatters < 0 {
+		errors = append(errors, "STT vocabulary max_chatters cannot be negative")
+	}
+	if cfg.STT.Vocabulary.RefreshSeconds < 5 {
+		errors = append(errors, "STT vocabulary refresh_seconds must be at least 5")
+	}
+	if cfg.STT.Vocabulary.MaxPromptChars < 50 || cfg.STT.Vocabulary.MaxPromptChars > 2000 {
+		errors = append(errors, "STT vocabulary max_prompt_chars must be between 50 and 2000")
 	}
 
 	// Validate LLM config
@@ -130,15 +219,39 @@ func Validate(cfg *Config) error {
 			errors = append(errors, "Ollama model required when using Ollama provider")
 		}
 	case "openai":
-		if cfg.LLM.OpenAI.APIKey == "" {
+		if cfg.LLM.OpenAI.APIKey == "" && cfg.LLM.OpenAI.BaseURL == "" {
 			errors = append(errors, "OpenAI API key required for LLM when using OpenAI provider")
 		}
+	case "llamacpp":
+		if cfg.LLM.LlamaCpp.URL == "" {
+			errors = append(errors, "llama.cpp URL required when using llama.cpp provider")
+		}
 	case "auto":
-		if cfg.LLM.OpenAI.APIKey == "" && cfg.LLM.Ollama.URL == "" {
-			errors = append(errors, "Auto LLM provider requires either Ollama config or OpenAI API key")
+		if len(cfg.LLM.Auto.Order) == 0 {
+			errors = append(errors, "Auto LLM provider requires at least one backend in llm.auto.order")
+		}
+		seen := make(map[string]bool)
+		for _, name := range cfg.LLM.Auto.Order {
+			if !isLLMBackend(cfg, name) {
+				errors = append(errors, fmt.Sprintf("invalid LLM auto provider: %s (must be 'ollama', 'openai', 'llamacpp' or an endpoint name)", name))
+			}
+			if seen[name] {
+				errors = append(errors, fmt.Sprintf("LLM auto provider %s listed twice", name))
+			}
+			seen[name] = true
 		}
 	default:
-		errors = append(errors, fmt.Sprintf("invalid LLM provider: %s (must be 'ollama', 'openai' or 'auto')", cfg.LLM.Provider))
+		if _, ok := cfg.LLM.Endpoints[cfg.LLM.Provider]; !ok {
+			errors = append(errors, fmt.Sprintf("invalid LLM provider: %s (must be 'ollama', 'openai', 'llamacpp', 'auto' or an endpoint name)", cfg.LLM.Provider))
+		}
+	}
+	for name, endpoint := range cfg.LLM.Endpoints {
+		if endpoint.BaseURL == "" {
+			errors = append(errors, fmt.Sprintf("LLM endpoint %s requires a base_url", name))
+		}
+		if isLLMBackend(&Config{}, name) || name == "auto" {
+			errors = append(errors, fmt.Sprintf("LLM endpoint name %s is reserved", name))
+		}
 	}
 
 	// Validate TTS config
@@ -171,11 +284,49 @@ func Validate(cfg *Config) error {
 	if cfg.Audio.Channels <= 0 {
 		errors = append(errors, "audio channels must be positive")
 	}
+	validSources := map[string]bool{"auto": true, "portaudio": true, "pulse": true, "file": true}
+	if !validSources[cfg.Audio.Source] {
+		errors = append(errors, fmt.Sprintf("invalid audio source: %s (must be 'auto', 'portaudio', 'pulse' or 'file')", cfg.Audio.Source))
+	}
+	if cfg.Audio.Source == "file" && cfg.Audio.File == "" {
+		errors = append(errors, "audio file required when audio source is 'file'")
+	}
 
 	// Validate VAD config
 	if cfg.Audio.VAD.Sensitivity < 0 || cfg.Audio.VAD.Sensitivity > 1 {
 		errors = append(errors, "VAD sensitivity must be between 0 and 1")
 	}
+	if cfg.Audio.VAD.PreRollMs < 0 || cfg.Audio.VAD.PreRollMs > 5000 {
+		errors = append(errors, "VAD pre_roll_ms must be between 0 and 5000")
+	}
+	if cfg.Audio.VAD.Engine != "energy" && cfg.Audio.VAD.Engine != "spectral" {
+		errors = append(errors, fmt.Sprintf("invalid VAD engine: %s (must be 'energy' or 'spectral')", cfg.Audio.VAD.Engine))
+	}
+
+	// Validate wake word config
+	if cfg.Audio.WakeWord.Enabled {
+		if cfg.Audio.WakeWord.Threshold <= 0 || cfg.Audio.WakeWord.Threshold > 1 {
+			errors = append(errors, "wake word threshold must be between 0 and 1")
+		}
+		if cfg.Audio.WakeWord.Engine != "template" && cfg.Audio.WakeWord.Engine != "stt" {
+			errors = append(errors, fmt.Sprintf("invalid wake word engine: %s (must be 'template' or 'stt')", cfg.Audio.WakeWord.Engine))
+		}
+	}
+
+	// Validate echo config
+	validEchoModes := map[string]bool{"cancel": true, "gate": true, "off": true}
+	if !validEchoModes[cfg.Audio.Echo.Mode] {
+		errors = append(errors, fmt.Sprintf("invalid echo mode: %s (must be 'cancel', 'gate' or 'off')", cfg.Audio.Echo.Mode))
+	}
+	if cfg.Audio.Echo.FilterMs < 16 || cfg.Audio.Echo.FilterMs > 500 {
+		errors = append(errors, "echo filter_ms must be between 16 and 500")
+	}
+	if cfg.Audio.Echo.DelayMs < 0 || cfg.Audio.Echo.DelayMs > 1000 {
+		errors = append(errors, "echo delay_ms must be between 0 and 1000")
+	}
+	if cfg.Audio.Echo.TailMs < 0 || cfg.Audio.Echo.TailMs > 5000 {
+		errors = append(errors, "echo tail_ms must be between 0 and 5000")
+	}
 
 	// Validate hotkey mode
 	if cfg.Hotkey.Enabled {
@@ -188,6 +339,81 @@ func Validate(cfg *Config) error {
 	if cfg.Music.DefaultVolume < 0 || cfg.Music.DefaultVolume > 1 {
 		errors = append(errors, "music default_volume must be between 0 and 1")
 	}
+	switch cfg.Music.Repeat {
+	case "off", "one", "all":
+	default:
+		errors = append(errors, fmt.Sprintf("invalid music repeat mode: %s (must be 'off', 'one' or 'all')", cfg.Music.Repeat))
+	}
+
+	// Validate song requests
+	if cfg.Music.Requests.Chat.Enabled && cfg.Music.Requests.Chat.Channel == "" {
+		errors = append(errors, "music requests chat channel required when chat requests are enabled")
+	}
+	if cfg.Music.Requests.ChannelPoints.Enabled && cfg.Music.Requests.ChannelPoints.Reward == "" {
+		errors = append(errors, "music requests channel_points reward required when channel point requests are enabled")
+	}
+
+	// Validate ducking config
+	// A negative amount would raise the music, and the fades never settle
+	if cfg.Ducking.AmountDB <= 0 || cfg.Ducking.AmountDB > 60 {
+		errors = append(errors, "ducking amount_db must be greater than 0 and at most 60")
+	}
+
+	// Validate session config
+	validSessionModes := map[string]bool{"single": true, "follow_up": true, "persistent": true}
+	if !validSessionModes[cfg.Session.Mode] {
+		errors = append(errors, fmt.Sprintf("invalid session mode: %s (must be 'single', 'follow_up' or 'persistent')", cfg.Session.Mode))
+	}
+	if cfg.Session.FollowUpSeconds < 1 || cfg.Session.FollowUpSeconds > 300 {
+		errors = append(errors, "session follow_up_seconds must be between 1 and 300")
+	}
+
+	// Validate Spotify config
+	if cfg.Spotify.Enabled && cfg.Spotify.ClientID == "" {
+		errors = append(errors, "Spotify client_id required when Spotify is enabled")
+	}
+
+	// Validate personas
+	if cfg.Personas.Default != "" {
+		if _, ok := cfg.Personas.Profiles[cfg.Personas.Default]; !ok {
+			errors = append(errors, fmt.Sprintf("default persona %s is not in personas.profiles", cfg.Personas.Default))
+		}
+	}
+	for name, persona := range cfg.Personas.Profiles {
+		if persona.Temperature < 0 || persona.Temperature > 2 {
+			errors = append(errors, fmt.Sprintf("persona %s temperature must be between 0 and 2", name))
+		}
+		if persona.MaxTokens < 0 || persona.MaxReplyChars < 0 {
+			errors = append(errors, fmt.Sprintf("persona %s reply limits cannot be negative", name))
+		}
+		if persona.Speed < 0 || persona.Speed > 4 {
+			errors = append(errors, fmt.Sprintf("persona %s speed must be between 0 and 4", name))
+		}
+	}
+
+	// Validate intent rules
+	for i, rule := range cfg.Intents.Rules {
+		name := rule.Name
+		if name == "" {
+			name = fmt.Sprintf("#%d", i+1)
+		}
+		if rule.Action == "" {
+			errors = append(errors, fmt.Sprintf("intent rule %s requires an action", name))
+		}
+		if len(rule.Patterns) == 0 && len(rule.Regex) == 0 {
+			errors = append(errors, fmt.Sprintf("intent rule %s requires patterns or regex", name))
+		}
+		for _, expr := range rule.Regex {
+			if _, err := regexp.Compile(expr); err != nil {
+				errors = append(errors, fmt.Sprintf("intent rule %s has an invalid regex %q: %v", name, expr, err))
+			}
+		}
+		for slot, kind := range rule.Slots {
+			if !slices.Contains([]string{"text", "int", "number", "percent"}, kind) {
+				errors = append(errors, fmt.Sprintf("intent rule %s slot %s has an invalid type: %s (must be 'text', 'int', 'number' or 'percent')", name, slot, kind))
+			}
+		}
+	}
 
 	if len(errors) > 0 {
 		return fmt.Errorf("validation errors:\n- %s", strings.Join(errors, "\n- "))
@@ -196,6 +422,17 @@ func Validate(cfg *Config) error {
 	return nil
 }
 
+// isLLMBackend tells whether name is an LLM provider the auto provider can
+// use: a built-in one or a configured endpoint
+func isLLMBackend(cfg *Config, name string) bool {
+	switch name {
+	case "ollama", "openai", "llamacpp":
+		return true
+	}
+	_, ok := cfg.LLM.Endpoints[name]
+	return ok
+}
+
 // Save saves the configuration to a file
 func Save(cfg *Config, path string) error {
 	v := viper.New()
@@ -210,7 +447,12 @@ func Save(cfg *Config, path string) error {
 	v.Set("twitch", cfg.Twitch)
 	v.Set("obs", cfg.OBS)
 	v.Set("music", cfg.Music)
+	v.Set("spotify", cfg.Spotify)
+	v.Set("ducking", cfg.Ducking)
+	v.Set("session", cfg.Session)
 	v.Set("sounds", cfg.Sounds)
+	v.Set("personas", cfg.Personas)
+	v.Set("intents", cfg.Intents)
 
 	// Ensure directory exists
 	dir := filepath.Dir(path)
@@ -0,0 +1,165 @@
+// Package ducking lowers background music while Ana or the streamer speaks
+package ducking
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/anastreamer/ana/internal/config"
+	"github.com/anastreamer/ana/pkg/logger"
+	"github.com/rs/zerolog"
+)
+
+// Sources that can request ducking
+const (
+	SourceTTS       = "tts"       // Ana is speaking
+	SourceSpeech    = "speech"    // The VAD hears the streamer
+	SourceRecording = "recording" // The pipeline is recording a command
+)
+
+// stepInterval is how often the level moves during a fade
+const stepInterval = 25 * time.Millisecond
+
+// Target is something whose volume can be ducked
+type Target interface {
+	// SetDuck sets the attenuation in dB (0 = normal, negative = quieter)
+	SetDuck(ctx context.Context, db float64) error
+}
+
+// Coordinator tracks who is speaking and fades the targets down and back up
+type Coordinator struct {
+	amount float64
+	attack time.Duration
+	hold   time.Duration
+	fade   time.Duration
+	log    zerolog.Logger
+	now    func() time.Time
+
+	mu        sync.Mutex
+	targets   []Target
+	active    map[string]bool
+	holdUntil time.Time
+	level     float64 // Current attenuation in dB, only touched by run
+	wake      chan struct{}
+}
+
+// NewCoordinator creates a ducking coordinator
+func NewCoordinator(cfg config.DuckingConfig, targets ...Target) *Coordinator {
+	return &Coordinator{
+		amount:  cfg.AmountDB,
+		attack:  time.Duration(cfg.AttackMs) * time.Millisecond,
+		hold:    time.Duration(cfg.HoldMs) * time.Millisecond,
+		fade:    time.Duration(cfg.FadeMs) * time.Millisecond,
+		log:     logger.Component("ducking"),
+		now:     time.Now,
+		targets: targets,
+		active:  make(map[string]bool),
+		wake:    make(chan struct{}, 1),
+	}
+}
+
+// AddTarget adds a target to duck
+func (c *Coordinator) AddTarget(t Target) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.targets = append(c.targets, t)
+}
+
+// SetActive marks a source as speaking or not. Music stays ducked while any
+// source is active and for the hold time after the last one stops.
+func (c *Coordinator) SetActive(source string, active bool) {
+	c.mu.Lock()
+	if c.active[source] == active {
+		c.mu.Unlock()
+		return
+	}
+	if active {
+		c.active[source] = true
+	} else {
+		delete(c.active, source)
+		c.holdUntil = c.now().Add(c.hold)
+	}
+	c.mu.Unlock()
+
+	c.log.Debug().Str("source", source).Bool("active", active).Msg("Ducking source changed")
+
+	select {
+	case c.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Start runs the fade loop until ctx is done, then restores the volume
+func (c *Coordinator) Start(ctx context.Context) {
+	go c.run(ctx)
+}
+
+// run moves the level toward the wanted attenuation in small steps
+func (c *Coordinator) run(ctx context.Context) {
+	ticker := time.NewTicker(stepInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			if c.level != 0 {
+				c.level = 0
+				c.apply(context.Background())
+			}
+			return
+		case <-c.wake:
+		case <-ticker.C:
+		}
+
+		c.step(ctx)
+	}
+}
+
+// step advances the fade by one interval
+func (c *Coordinator) step(ctx context.Context) {
+	want := c.wanted()
+	if c.level == want {
+		return
+	}
+
+	if want < c.level {
+		c.level = max(want, c.level-c.rate(c.attack))
+	} else {
+		c.level = min(want, c.level+c.rate(c.fade))
+	}
+
+	c.apply(ctx)
+}
+
+// wanted returns the attenuation the targets should reach
+func (c *Coordinator) wanted() float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.active) > 0 || c.now().Before(c.holdUntil) {
+		return -c.amount
+	}
+	return 0
+}
+
+// rate returns how many dB to move per step for a fade of duration d
+func (c *Coordinator) rate(d time.Duration) float64 {
+	if d <= stepInterval {
+		return c.amount
+	}
+	return c.amount * float64(stepInterval) / float64(d)
+}
+
+// apply pushes the current level to every target
+func (c *Coordinator) apply(ctx context.Context) {
+	c.mu.Lock()
+	targets := append([]Target(nil), c.targets...)
+	c.mu.Unlock()
+
+	for _, t := range targets {
+		if err := t.SetDuck(ctx, c.level); err != nil {
+			c.log.Debug().Err(err).Msg("Failed to duck target")
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package ducking
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/anastreamer/ana/internal/config"
+)
+
+// levelTarget records the last level it was set to
+type levelTarget struct {
+	mu    sync.Mutex
+	level float64
+}
+
+func (t *levelTarget) SetDuck(ctx context.Context, db float64) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.level = db
+	return nil
+}
+
+// newTestCoordinator ducks 12 dB in 100 ms, holds 200 ms and restores in
+// 200 ms, with a clock that only moves when the test says
+func newTestCoordinator() (*Coordinator, *levelTarget, *time.Time) {
+	target := &levelTarget{}
+	c := NewCoordinator(config.DuckingConfig{AmountDB: 12, AttackMs: 100, HoldMs: 200, FadeMs: 200}, target)
+	now := time.Unix(0, 0)
+	c.now = func() time.Time { return now }
+	return c, target, &now
+}
+
+func TestCoordinatorFades(t *testing.T) {
+	c, target, now := newTestCoordinator()
+	ctx := context.Background()
+
+	// Attack: 100 ms are 4 steps of 3 dB
+	c.SetActive(SourceTTS, true)
+	for _, want := range []float64{-3, -6, -9, -12, -12} {
+		c.step(ctx)
+		if target.level != want {
+			t.Fatalf("level while ducking = %v, want %v", target.level, want)
+		}
+	}
+
+	// Hold: stays down until 200 ms after the source stops
+	c.SetActive(SourceTTS, false)
+	c.step(ctx)
+	*now = now.Add(199 * time.Millisecond)
+	c.step(ctx)
+	if target.level != -12 {
+		t.Fatalf("level during the hold = %v, want -12", target.level)
+	}
+
+	// Fade: 200 ms are 8 steps of 1.5 dB
+	*now = now.Add(time.Millisecond)
+	for i := 1; i <= 8; i++ {
+		c.step(ctx)
+		if want := -12 + 1.5*float64(i); target.level != want {
+			t.Fatalf("level after %d fade steps = %v, want %v", i, target.level, want)
+		}
+	}
+	if c.level != 0 {
+		t.Errorf("level after the fade = %v, want 0", c.level)
+	}
+}
+
+func TestCoordinatorSpeechDuringHold(t *testing.T) {
+	c, target, now := newTestCoordinator()
+	ctx := context.Background()
+
+	c.SetActive(SourceTTS, true)
+	for i := 0; i < 4; i++ {
+		c.step(ctx)
+	}
+	c.SetActive(SourceTTS, false)
+
+	// The streamer speaks during the hold, then stops: the hold starts over
+	*now = now.Add(150 * time.Millisecond)
+	c.SetActive(SourceSpeech, true)
+	c.step(ctx)
+	c.SetActive(SourceSpeech, false)
+	*now = now.Add(150 * time.Millisecond)
+	c.step(ctx)
+	if target.level != -12 {
+		t.Errorf("level = %v, want -12 until the new hold ends", target.level)
+	}
+
+	*now = now.Add(50 * time.Millisecond)
+	c.step(ctx)
+	if target.level != -10.5 {
+		t.Errorf("level = %v, want the fade back up started", target.level)
+	}
+}
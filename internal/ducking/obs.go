@@ -0,0 +1,53 @@
+package ducking
+
+import (
+	"context"
+)
+
+// obsMinDB is the lowest volume OBS accepts
+const obsMinDB = -100
+
+// OBSVolume reads and sets OBS input volumes (implemented by executor.OBSExecutor)
+type OBSVolume interface {
+	GetInputVolumeDB(ctx context.Context, input string) (float64, error)
+	SetInputVolumeDB(ctx context.Context, input string, db float64) error
+}
+
+// OBSInput ducks an OBS audio input relative to its volume before ducking
+type OBSInput struct {
+	obs   OBSVolume
+	input string
+
+	base    float64
+	hasBase bool
+}
+
+// NewOBSInput creates a target for the named OBS input
+func NewOBSInput(obs OBSVolume, input string) *OBSInput {
+	return &OBSInput{obs: obs, input: input}
+}
+
+// SetDuck sets the input to its original volume plus db. The original volume
+// is read when ducking starts so changes made in OBS meanwhile are kept.
+func (o *OBSInput) SetDuck(ctx context.Context, db float64) error {
+	if !o.hasBase {
+		if db == 0 {
+			return nil
+		}
+		base, err := o.obs.GetInputVolumeDB(ctx, o.input)
+		if err != nil {
+			return err
+		}
+		o.base = base
+		o.hasBase = true
+	}
+
+	if err := o.obs.SetInputVolumeDB(ctx, o.input, max(o.base+db, obsMinDB)); err != nil {
+		return err
+	}
+
+	if db == 0 {
+		o.hasBase = false
+	}
+	return nil
+}
@@ -0,0 +1,273 @@
+// Package echo keeps Ana's own voice (the TTS playback) out of the mic signal
+package echo
+
+import (
+	"math"
+	"sync"
+
+	"github.com/anastreamer/ana/internal/config"
+	"github.com/anastreamer/ana/pkg/logger"
+	"github.com/anastreamer/ana/pkg/utils"
+	"github.com/rs/zerolog"
+)
+
+// NLMS settings
+const (
+	stepSize = 0.3  // Adaptation speed, between 0 and 2
+	epsilon  = 1e-6 // Regularization for quiet reference
+	// Geigel double-talk threshold: the echo is assumed at least 6 dB below
+	// the reference, so a louder mic means the streamer is talking too and
+	// the filter must not learn from it
+	geigelThreshold = 0.5
+
+	convergedERLE        = 4   // Echo return loss enhancement (power ratio) of a working filter
+	maxERLE              = 1e4 // Cap for the tracked ERLE (40 dB)
+	minGain              = 0.1 // Strongest residual suppression (-20 dB)
+	maxDoubleTalkSeconds = 3   // Longer "double talk" means the echo path changed
+)
+
+// Result is the outcome of processing a chunk of mic audio
+type Result struct {
+	Samples []int16 // Mic audio with Ana's voice removed (the input itself when there's nothing to remove)
+	Active  bool    // Ana is speaking, or stopped less than the tail ago
+	Gated   bool    // Active without a usable reference: don't trust the mic for the wake word
+}
+
+// Canceller removes the echo of the TTS playback from the capture stream.
+// An NLMS adaptive filter learns the speaker-to-mic path from the exact
+// audio being played (the reference) and subtracts its estimate; a residual
+// suppressor then attenuates what's left when it's mostly echo. Without a
+// reference (TTS engines that play audio themselves) or in "gate" mode it
+// only reports the mic as gated while Ana speaks and for a tail after, as it
+// does for chunks on which the filter diverged.
+//
+// The Playback methods are called from the TTS goroutine and Process from
+// the pipeline; they can be used concurrently.
+type Canceller struct {
+	gateOnly   bool
+	sampleRate int
+	delay      int64 // Samples between the start of playback and the filter window
+	tail       int   // Samples to stay active after playback stops
+	log        zerolog.Logger
+
+	mu       sync.Mutex
+	playing  bool
+	ref      []float64 // Reference at the capture rate, -1..1
+	refStart int64     // Capture sample index where ref[0] enters the filter
+	captured int64     // Capture samples processed so far
+	tailLeft int
+	weights  []float64 // Echo path estimate, one tap per sample
+
+	erle           float64 // Smoothed mic/residual power ratio on echo-only audio
+	doubleTalkLeft int     // Samples of double talk left before relearning
+}
+
+// New creates an echo canceller for mic audio at sampleRate
+func New(cfg config.EchoConfig, sampleRate int) *Canceller {
+	return &Canceller{
+		gateOnly:   cfg.Mode == "gate",
+		sampleRate: sampleRate,
+		delay:      int64(cfg.DelayMs * sampleRate / 1000),
+		tail:       cfg.TailMs * sampleRate / 1000,
+		weights:    make([]float64, max(cfg.FilterMs*sampleRate/1000, 1)),
+		erle:       1,
+		log:        logger.Component("echo"),
+	}
+}
+
+// PlaybackStarted marks the start of a TTS reply. Until PlaybackAudio
+// provides its audio, the mic is gated.
+func (c *Canceller) PlaybackStarted() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.playing = true
+	c.ref = nil
+}
+
+// PlaybackAudio sets the WAV audio that starts playing now as the reference
+func (c *Canceller) PlaybackAudio(wav []byte) {
+	samples, rate, err := utils.DecodeWAV(wav)
+	if err != nil {
+		c.log.Debug().Err(err).Msg("Cannot use playback audio as echo reference")
+		return
+	}
+	c.SetReference(samples, rate)
+}
+
+// SetReference sets the PCM audio that starts playing now as the reference
+func (c *Canceller) SetReference(samples []int16, sampleRate int) {
+	if sampleRate != c.sampleRate {
+		samples = utils.Resample(samples, sampleRate, c.sampleRate)
+	}
+	ref := make([]float64, len(samples))
+	for i, s := range samples {
+		ref[i] = float64(s) / 32768
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.playing = true
+	c.ref = ref
+	c.refStart = c.captured + c.delay
+}
+
+// PlaybackStopped marks the end of a TTS reply, finished or interrupted
+func (c *Canceller) PlaybackStopped() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.playing = false
+	c.tailLeft = c.tail
+
+	// An interrupted reply never played its end; keep only what could
+	// still be on its way to the mic so the filter doesn't subtract audio
+	// that was never heard
+	if c.ref != nil {
+		played := max(c.captured-c.refStart, 0) + int64(len(c.weights))
+		if played < int64(len(c.ref)) {
+			c.ref = c.ref[:played]
+		}
+	}
+}
+
+// Process removes the echo from a chunk of mic audio
+func (c *Canceller) Process(samples []int16) Result {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	start := c.captured
+	c.captured += int64(len(samples))
+
+	// The reference stays useful until its last sample has left the filter
+	refActive := c.ref != nil && start-c.refStart < int64(len(c.ref)+len(c.weights))
+	active := c.playing || c.tailLeft > 0 || refActive
+	if !c.playing {
+		c.tailLeft = max(c.tailLeft-len(samples), 0)
+	}
+
+	if !active {
+		c.ref = nil
+		return Result{Samples: samples}
+	}
+	if c.gateOnly || !refActive {
+		return Result{Samples: samples, Active: true, Gated: true}
+	}
+
+	out, ok := c.cancel(samples, start)
+	if !ok {
+		// The filter diverged and is relearning; gate as without a reference
+		return Result{Samples: samples, Active: true, Gated: true}
+	}
+	return Result{Samples: out, Active: true}
+}
+
+// cancel runs the NLMS filter over a chunk starting at capture index start.
+// It returns false if the filter diverged and had to be reset.
+func (c *Canceller) cancel(samples []int16, start int64) ([]int16, bool) {
+	// Filter once without learning to tell whether the streamer is talking
+	// over Ana, then again learning from the chunk if not
+	residual, micEnergy, echoEnergy, residualEnergy := c.filter(samples, start, false)
+	if c.doubleTalk(samples, start, micEnergy, residualEnergy) {
+		c.doubleTalkLeft -= len(samples)
+		if c.doubleTalkLeft <= 0 {
+			// Too long to be talking over her: the echo path changed
+			c.log.Debug().Msg("Echo path changed, relearning")
+			c.erle = 1
+			c.doubleTalkLeft = c.sampleRate * maxDoubleTalkSeconds
+		}
+	} else {
+		c.doubleTalkLeft = c.sampleRate * maxDoubleTalkSeconds
+		residual, micEnergy, echoEnergy, residualEnergy = c.filter(samples, start, true)
+		if residualEnergy > 0 {
+			c.erle = 0.9*c.erle + 0.1*min(micEnergy/residualEnergy, maxERLE)
+		}
+	}
+
+	// A filter making things worse has diverged; start learning again
+	if residualEnergy > 4*micEnergy && micEnergy > 0 {
+		c.log.Debug().Msg("Echo canceller diverged, resetting")
+		clear(c.weights)
+		c.erle = 1
+		return nil, false
+	}
+
+	// Residual suppression: the filter leaves about echoEnergy/erle of echo
+	// behind; keep only what's above that (the streamer's voice)
+	gain := 1.0
+	if residualEnergy > 0 {
+		expected := echoEnergy / c.erle
+		gain = math.Max(minGain, math.Sqrt(math.Max(residualEnergy-expected, 0)/residualEnergy))
+	}
+
+	out := make([]int16, len(samples))
+	for i, e := range residual {
+		out[i] = int16(math.Max(-32768, math.Min(32767, e*gain*32768)))
+	}
+	return out, true
+}
+
+// filter subtracts the echo estimate from a chunk starting at capture index
+// start, adapting the filter if learn is set. It returns the residual and
+// the energies of the mic, the echo estimate and the residual.
+func (c *Canceller) filter(samples []int16, start int64, learn bool) ([]float64, float64, float64, float64) {
+	taps := len(c.weights)
+	residual := make([]float64, len(samples))
+	var micEnergy, echoEnergy, residualEnergy float64
+
+	for i, s := range samples {
+		// Index of the reference sample entering the filter now; ref[idx-k]
+		// is the sample played k samples ago
+		idx := int(start + int64(i) - c.refStart)
+		lo := max(0, idx-len(c.ref)+1)
+		hi := min(taps-1, idx)
+
+		var estimate, power float64
+		for k := lo; k <= hi; k++ {
+			x := c.ref[idx-k]
+			estimate += c.weights[k] * x
+			power += x * x
+		}
+
+		d := float64(s) / 32768
+		e := d - estimate
+
+		if learn && power > 0 {
+			step := stepSize * e / (power + epsilon)
+			for k := lo; k <= hi; k++ {
+				c.weights[k] += step * c.ref[idx-k]
+			}
+		}
+
+		residual[i] = e
+		micEnergy += d * d
+		echoEnergy += estimate * estimate
+		residualEnergy += e * e
+	}
+
+	return residual, micEnergy, echoEnergy, residualEnergy
+}
+
+// doubleTalk tells whether the streamer is talking over Ana. Once the filter
+// has converged, that shows as a residual well above what it usually leaves;
+// before, as a mic louder than the echo of the reference could be (Geigel).
+func (c *Canceller) doubleTalk(samples []int16, start int64, micEnergy, residualEnergy float64) bool {
+	if c.erle > convergedERLE {
+		return residualEnergy*c.erle > 4*micEnergy
+	}
+
+	var micPeak float64
+	for _, s := range samples {
+		micPeak = math.Max(micPeak, math.Abs(float64(s)/32768))
+	}
+
+	first := max(int(start-c.refStart)-len(c.weights)+1, 0)
+	last := min(int(start-c.refStart)+len(samples), len(c.ref))
+	var refPeak float64
+	for i := first; i < last; i++ {
+		refPeak = math.Max(refPeak, math.Abs(c.ref[i]))
+	}
+
+	return refPeak == 0 || micPeak > geigelThreshold*refPeak
+}
@@ -0,0 +1,296 @@
+package echo
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/anastreamer/ana/internal/config"
+	"github.com/anastreamer/ana/pkg/utils"
+)
+
+const (
+	testRate  = 16000
+	testChunk = 512
+)
+
+// speechLike returns seconds of lowpassed noise with a syllable-rate
+// envelope, roughly the spectrum and dynamics of a voice
+func speechLike(seconds float64, seed int64) []float64 {
+	rng := rand.New(rand.NewSource(seed))
+	out := make([]float64, int(seconds*testRate))
+	var lp1, lp2 float64
+	for i := range out {
+		lp1 += (rng.NormFloat64() - lp1) * 0.4
+		lp2 += (lp1 - lp2) * 0.4
+		envelope := 0.6 + 0.4*math.Sin(2*math.Pi*4*float64(i)/testRate)
+		out[i] = 0.5 * lp2 * envelope
+	}
+	return out
+}
+
+// echoOf returns what the mic hears of ref through a room: a direct path
+// and two reflections, all within the filter length
+func echoOf(ref []float64) []float64 {
+	path := map[int]float64{30: 0.6, 120: 0.25, 400: -0.1}
+	out := make([]float64, len(ref))
+	for delay, gain := range path {
+		for i := delay; i < len(ref); i++ {
+			out[i] += gain * ref[i-delay]
+		}
+	}
+	return out
+}
+
+func toPCM(x []float64) []int16 {
+	out := make([]int16, len(x))
+	for i, v := range x {
+		out[i] = int16(math.Max(-32768, math.Min(32767, v*32768)))
+	}
+	return out
+}
+
+func fromPCM(x []int16) []float64 {
+	out := make([]float64, len(x))
+	for i, v := range x {
+		out[i] = float64(v) / 32768
+	}
+	return out
+}
+
+func power(x []float64) float64 {
+	var sum float64
+	for _, v := range x {
+		sum += v * v
+	}
+	return sum / float64(len(x))
+}
+
+func add(a, b []float64) []float64 {
+	out := make([]float64, len(a))
+	for i := range a {
+		out[i] = a[i] + b[i]
+	}
+	return out
+}
+
+// process feeds mic in chunks and returns the output and every result
+func process(c *Canceller, mic []float64) ([]float64, []Result) {
+	var out []float64
+	var results []Result
+	pcm := toPCM(mic)
+	for start := 0; start < len(pcm); start += testChunk {
+		res := c.Process(pcm[start:min(start+testChunk, len(pcm))])
+		out = append(out, fromPCM(res.Samples)...)
+		results = append(results, res)
+	}
+	return out, results
+}
+
+func newTestCanceller(mode string) *Canceller {
+	return New(config.EchoConfig{Mode: mode, FilterMs: 32, TailMs: 300}, testRate)
+}
+
+func TestCancellerEchoOnly(t *testing.T) {
+	c := newTestCanceller("cancel")
+	ref := speechLike(4, 1)
+	mic := echoOf(ref)
+
+	c.PlaybackStarted()
+	c.SetReference(toPCM(ref), testRate)
+	out, results := process(c, mic)
+
+	for i, res := range results {
+		if !res.Active || res.Gated {
+			t.Fatalf("chunk %d: Active = %v, Gated = %v, want active and cancelled", i, res.Active, res.Gated)
+		}
+	}
+
+	// Once converged (the last two seconds) the echo is at least 30 dB down
+	tail := 2 * testRate
+	erle := 10 * math.Log10(power(mic[tail:])/power(out[tail:]))
+	if erle < 30 {
+		t.Errorf("ERLE = %.1f dB, want at least 30 dB", erle)
+	}
+}
+
+func TestCancellerResampledReference(t *testing.T) {
+	c := newTestCanceller("cancel")
+	ref := speechLike(4, 2)
+	mic := echoOf(ref)
+
+	// TTS audio at another rate is converted to the capture rate
+	wide := make([]int16, 0, 2*len(ref))
+	for _, s := range toPCM(ref) {
+		wide = append(wide, s, s)
+	}
+	c.PlaybackStarted()
+	c.SetReference(wide, 2*testRate)
+	out, _ := process(c, mic)
+
+	tail := 2 * testRate
+	if erle := 10 * math.Log10(power(mic[tail:])/power(out[tail:])); erle < 30 {
+		t.Errorf("ERLE = %.1f dB with a 32 kHz reference, want at least 30 dB", erle)
+	}
+}
+
+func TestCancellerDoubleTalk(t *testing.T) {
+	c := newTestCanceller("cancel")
+	ref := speechLike(5, 3)
+	echo := echoOf(ref)
+
+	// The streamer talks over Ana from the third second on, as loud as her echo
+	near := speechLike(5, 4)
+	talkStart := 3 * testRate
+	for i := range near[:talkStart] {
+		near[i] = 0
+	}
+	for i := range near {
+		near[i] *= 0.6
+	}
+
+	c.PlaybackStarted()
+	c.SetReference(toPCM(ref), testRate)
+	out, results := process(c, add(echo, near))
+
+	for i, res := range results {
+		if res.Gated {
+			t.Fatalf("chunk %d gated during double talk", i)
+		}
+	}
+
+	// The streamer's voice comes through: the output is close to it,
+	// neither suppressed nor left with much echo
+	outNear := out[talkStart+testChunk:]
+	nearOnly := near[talkStart+testChunk:]
+	kept := 10 * math.Log10(power(outNear)/power(nearOnly))
+	if kept < -3 || kept > 3 {
+		t.Errorf("near-end speech at %.1f dB of its level, want within 3 dB", kept)
+	}
+	diff := make([]float64, len(outNear))
+	for i := range outNear {
+		diff[i] = outNear[i] - nearOnly[i]
+	}
+	if snr := 10 * math.Log10(power(nearOnly)/power(diff)); snr < 20 {
+		t.Errorf("near-end speech to distortion and echo ratio = %.1f dB, want at least 20 dB", snr)
+	}
+
+	// The filter didn't learn from the streamer's voice
+	echoOnly := 10 * math.Log10(power(echo[2*testRate:talkStart])/power(out[2*testRate:talkStart]))
+	if echoOnly < 30 {
+		t.Errorf("ERLE before the double talk = %.1f dB, want at least 30 dB", echoOnly)
+	}
+}
+
+func TestCancellerDiverged(t *testing.T) {
+	c := newTestCanceller("cancel")
+	ref := speechLike(3, 5)
+	mic := echoOf(ref)
+
+	c.PlaybackStarted()
+	c.SetReference(toPCM(ref), testRate)
+	half := 3 * testRate / 2
+	process(c, mic[:half])
+
+	// A wildly wrong echo path estimate makes the residual louder than the mic
+	c.mu.Lock()
+	for k := range c.weights {
+		c.weights[k] = -4 * c.weights[k]
+	}
+	c.mu.Unlock()
+
+	pcm := toPCM(mic[half : half+testChunk])
+	res := c.Process(pcm)
+	if !res.Active || !res.Gated {
+		t.Fatalf("Active = %v, Gated = %v after diverging, want the gate", res.Active, res.Gated)
+	}
+	for i := range pcm {
+		if res.Samples[i] != pcm[i] {
+			t.Fatal("a gated chunk was modified")
+		}
+	}
+	c.mu.Lock()
+	for k, w := range c.weights {
+		if w != 0 {
+			t.Fatalf("weight %d = %g after the reset", k, w)
+		}
+	}
+	c.mu.Unlock()
+
+	// It learns the echo path again
+	out, _ := process(c, mic[half+testChunk:])
+	tail := len(out) - testRate/2
+	if erle := 10 * math.Log10(power(mic[len(mic)-testRate/2:])/power(out[tail:])); erle < 25 {
+		t.Errorf("ERLE after relearning = %.1f dB, want at least 25 dB", erle)
+	}
+}
+
+func TestCancellerNoReference(t *testing.T) {
+	mic := echoOf(speechLike(1, 6))
+	pcm := toPCM(mic[:testChunk])
+
+	// Without a reference the mic is gated while Ana speaks and for the tail
+	c := newTestCanceller("cancel")
+	if res := c.Process(pcm); res.Active || res.Gated {
+		t.Fatalf("Active = %v, Gated = %v before playback", res.Active, res.Gated)
+	}
+	c.PlaybackStarted()
+	for i := 0; i < 3; i++ {
+		res := c.Process(pcm)
+		if !res.Active || !res.Gated || &res.Samples[0] != &pcm[0] {
+			t.Fatalf("Active = %v, Gated = %v while playing without a reference, want the mic gated and untouched", res.Active, res.Gated)
+		}
+	}
+
+	c.PlaybackStopped()
+	tailChunks := 0
+	for c.Process(pcm).Gated {
+		tailChunks++
+		if tailChunks > 100 {
+			t.Fatal("still gated long after the playback stopped")
+		}
+	}
+	// 300 ms is 4800 samples, ten 512-sample chunks
+	if tailChunks != 10 {
+		t.Errorf("gated for %d chunks after the playback stopped, want 10", tailChunks)
+	}
+	if res := c.Process(pcm); res.Active {
+		t.Error("still active after the tail")
+	}
+}
+
+func TestCancellerGateMode(t *testing.T) {
+	c := newTestCanceller("gate")
+	ref := speechLike(1, 7)
+	pcm := toPCM(echoOf(ref)[:testChunk])
+
+	// Even with a reference, gate mode doesn't cancel
+	c.PlaybackStarted()
+	wav, err := utils.PCMToWAV(utils.Int16ToBytes(toPCM(ref)), testRate, 1, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.PlaybackAudio(wav)
+	if res := c.Process(pcm); !res.Active || !res.Gated {
+		t.Errorf("Active = %v, Gated = %v in gate mode", res.Active, res.Gated)
+	}
+}
+
+func TestCancellerInterrupted(t *testing.T) {
+	c := newTestCanceller("cancel")
+	ref := speechLike(3, 8)
+	mic := echoOf(ref)
+
+	c.PlaybackStarted()
+	c.SetReference(toPCM(ref), testRate)
+	process(c, mic[:testRate])
+
+	// Cut off after one second: the rest of the reply never plays, so once
+	// the tail and the filter window pass, the mic is left alone
+	c.PlaybackStopped()
+	silence := make([]float64, testRate)
+	_, results := process(c, silence)
+	if last := results[len(results)-1]; last.Active {
+		t.Error("still active a second after an interrupted reply")
+	}
+}
@@ -14,6 +14,8 @@ type Result struct {
 	Message string                 `json:"message,omitempty"`
 	Data    map[string]interface{} `json:"data,omitempty"`
 	Error   string                 `json:"error,omitempty"`
+	// Reply, when set, replaces the LLM reply (e.g. for answers only the executor knows)
+	Reply string `json:"reply,omitempty"`
 }
 
 // NewResult creates a successful result
@@ -33,6 +35,16 @@ func NewResultWithData(message string, data map[string]interface{}) Result {
 	}
 }
 
+// NewReplyResult creates a successful result whose message is spoken to the user
+func NewReplyResult(reply string, data map[string]interface{}) Result {
+	return Result{
+		Success: true,
+		Message: reply,
+		Data:    data,
+		Reply:   reply,
+	}
+}
+
 // NewErrorResult creates an error result
 func NewErrorResult(err error) Result {
 	return Result{
@@ -0,0 +1,253 @@
+package music
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ReadDuration returns the playback length of an MP3, FLAC, WAV or Ogg file
+// by reading its headers (no decoding)
+func ReadDuration(path string) (time.Duration, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".mp3":
+		return mp3Duration(f, info.Size())
+	case ".flac":
+		return flacDuration(f)
+	case ".wav":
+		return wavDuration(f)
+	case ".ogg", ".oga", ".opus":
+		return oggDuration(f, info.Size())
+	default:
+		return 0, fmt.Errorf("unsupported format: %s", filepath.Ext(path))
+	}
+}
+
+// MPEG audio tables indexed by version (0 = MPEG1, 1 = MPEG2/2.5) and layer (0 = I, 1 = II, 2 = III)
+var (
+	mpegBitrates = [2][3][15]int{
+		{
+			{0, 32, 64, 96, 128, 160, 192, 224, 256, 288, 320, 352, 384, 416, 448},
+			{0, 32, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 384},
+			{0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320},
+		},
+		{
+			{0, 32, 48, 56, 64, 80, 96, 112, 128, 144, 160, 176, 192, 224, 256},
+			{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160},
+			{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160},
+		},
+	}
+	mpegSampleRates = [3]int{44100, 48000, 32000}
+)
+
+// mp3Duration reads the Xing/Info or VBRI header of the first frame, falling
+// back to a constant bitrate estimate
+func mp3Duration(r io.ReadSeeker, size int64) (time.Duration, error) {
+	// Skip an ID3v2 tag
+	var start int64
+	header := make([]byte, 10)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return 0, err
+	}
+	if string(header[:3]) == "ID3" {
+		start = int64(syncsafe(header[6:10])) + 10
+		if header[5]&0x10 != 0 {
+			start += 10 // Footer
+		}
+	}
+
+	if _, err := r.Seek(start, io.SeekStart); err != nil {
+		return 0, err
+	}
+	buf := make([]byte, 64*1024)
+	n, _ := io.ReadFull(r, buf)
+	buf = buf[:n]
+
+	// Find the first frame sync
+	for i := 0; i+4 <= len(buf); i++ {
+		if buf[i] != 0xFF || buf[i+1]&0xE0 != 0xE0 {
+			continue
+		}
+
+		versionBits := (buf[i+1] >> 3) & 0x03 // 3 = MPEG1, 2 = MPEG2, 0 = MPEG2.5
+		layerBits := (buf[i+1] >> 1) & 0x03   // 3 = I, 2 = II, 1 = III
+		bitrateIdx := buf[i+2] >> 4
+		rateIdx := (buf[i+2] >> 2) & 0x03
+		if versionBits == 1 || layerBits == 0 || bitrateIdx == 0 || bitrateIdx == 15 || rateIdx == 3 {
+			continue
+		}
+
+		v := 0
+		if versionBits != 3 {
+			v = 1
+		}
+		layer := 3 - int(layerBits)
+		sampleRate := mpegSampleRates[rateIdx]
+		switch versionBits {
+		case 2:
+			sampleRate /= 2
+		case 0:
+			sampleRate /= 4
+		}
+		bitrate := mpegBitrates[v][layer][bitrateIdx] * 1000
+
+		samplesPerFrame := 1152
+		switch {
+		case layer == 0:
+			samplesPerFrame = 384
+		case layer == 2 && v == 1:
+			samplesPerFrame = 576
+		}
+
+		frame := buf[i:]
+		mono := (buf[i+3] >> 6) == 3
+		sideInfo := 32
+		switch {
+		case v == 0 && mono:
+			sideInfo = 17
+		case v == 1 && !mono:
+			sideInfo = 17
+		case v == 1 && mono:
+			sideInfo = 9
+		}
+
+		// Xing/Info header (VBR)
+		if off := 4 + sideInfo; len(frame) >= off+12 {
+			tag := string(frame[off : off+4])
+			if (tag == "Xing" || tag == "Info") && frame[off+7]&0x01 != 0 {
+				frames := binary.BigEndian.Uint32(frame[off+8 : off+12])
+				return samplesToDuration(int64(frames)*int64(samplesPerFrame), sampleRate), nil
+			}
+		}
+
+		// VBRI header (Fraunhofer encoder)
+		if len(frame) >= 36+18 && string(frame[36:40]) == "VBRI" {
+			frames := binary.BigEndian.Uint32(frame[36+14 : 36+18])
+			return samplesToDuration(int64(frames)*int64(samplesPerFrame), sampleRate), nil
+		}
+
+		// Constant bitrate estimate
+		audioBytes := size - start - int64(i)
+		return time.Duration(audioBytes * 8 * int64(time.Second) / int64(bitrate)), nil
+	}
+
+	return 0, fmt.Errorf("no MPEG frame found")
+}
+
+// flacDuration reads the total samples from the STREAMINFO block
+func flacDuration(r io.Reader) (time.Duration, error) {
+	header := make([]byte, 4+4+34)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return 0, err
+	}
+	if string(header[:4]) != "fLaC" || header[4]&0x7f != 0 {
+		return 0, fmt.Errorf("missing FLAC STREAMINFO")
+	}
+
+	info := header[8:]
+	sampleRate := int(info[10])<<12 | int(info[11])<<4 | int(info[12])>>4
+	total := int64(info[13]&0x0f)<<32 | int64(binary.BigEndian.Uint32(info[14:18]))
+	if sampleRate == 0 || total == 0 {
+		return 0, fmt.Errorf("unknown FLAC length")
+	}
+	return samplesToDuration(total, sampleRate), nil
+}
+
+// wavDuration divides the data chunk size by the byte rate
+func wavDuration(r io.ReadSeeker) (time.Duration, error) {
+	header := make([]byte, 12)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return 0, err
+	}
+	if string(header[:4]) != "RIFF" || string(header[8:12]) != "WAVE" {
+		return 0, fmt.Errorf("not a WAV file")
+	}
+
+	var byteRate uint32
+	chunk := make([]byte, 8)
+	for {
+		if _, err := io.ReadFull(r, chunk); err != nil {
+			return 0, fmt.Errorf("missing WAV data chunk")
+		}
+		id := string(chunk[:4])
+		size := binary.LittleEndian.Uint32(chunk[4:8])
+
+		switch id {
+		case "fmt ":
+			fmtChunk := make([]byte, size)
+			if _, err := io.ReadFull(r, fmtChunk); err != nil || size < 12 {
+				return 0, fmt.Errorf("invalid WAV fmt chunk")
+			}
+			byteRate = binary.LittleEndian.Uint32(fmtChunk[8:12])
+		case "data":
+			if byteRate == 0 {
+				return 0, fmt.Errorf("WAV data before fmt chunk")
+			}
+			return time.Duration(int64(size) * int64(time.Second) / int64(byteRate)), nil
+		default:
+			if _, err := r.Seek(int64(size+size%2), io.SeekCurrent); err != nil {
+				return 0, err
+			}
+		}
+	}
+}
+
+// oggDuration reads the sample rate from the Vorbis/Opus header and the
+// granule position of the last page
+func oggDuration(r io.ReadSeeker, size int64) (time.Duration, error) {
+	head := make([]byte, 512)
+	n, _ := io.ReadFull(r, head)
+	head = head[:n]
+
+	var sampleRate int
+	var preSkip int64
+	if i := bytes.Index(head, []byte("\x01vorbis")); i >= 0 && len(head) >= i+16 {
+		sampleRate = int(binary.LittleEndian.Uint32(head[i+12 : i+16]))
+	} else if i := bytes.Index(head, []byte("OpusHead")); i >= 0 && len(head) >= i+12 {
+		sampleRate = 48000 // Opus granules always count 48 kHz samples
+		preSkip = int64(binary.LittleEndian.Uint16(head[i+10 : i+12]))
+	}
+	if sampleRate == 0 {
+		return 0, fmt.Errorf("unsupported Ogg codec")
+	}
+
+	tailSize := min(size, 64*1024)
+	if _, err := r.Seek(size-tailSize, io.SeekStart); err != nil {
+		return 0, err
+	}
+	tail := make([]byte, tailSize)
+	if _, err := io.ReadFull(r, tail); err != nil {
+		return 0, err
+	}
+
+	i := bytes.LastIndex(tail, []byte("OggS"))
+	if i < 0 || len(tail) < i+14 {
+		return 0, fmt.Errorf("no Ogg page found")
+	}
+	granule := int64(binary.LittleEndian.Uint64(tail[i+6 : i+14]))
+	if granule <= preSkip {
+		return 0, fmt.Errorf("unknown Ogg length")
+	}
+	return samplesToDuration(granule-preSkip, sampleRate), nil
+}
+
+// samplesToDuration converts a sample count to a duration
+func samplesToDuration(samples int64, sampleRate int) time.Duration {
+	return time.Duration(samples * int64(time.Second) / int64(sampleRate))
+}
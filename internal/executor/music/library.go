@@ -0,0 +1,166 @@
+package music
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// libraryTTL is how long a scan is reused before the folders are walked again
+const libraryTTL = 5 * time.Minute
+
+// Library is an index of the tracks in the music folders. Scanning reads tags
+// and durations, so the result is cached and refreshed periodically.
+type Library struct {
+	folders []string
+	formats []string
+	log     zerolog.Logger
+
+	mu        sync.Mutex
+	tracks    []Track
+	scannedAt time.Time
+}
+
+// NewLibrary creates a library over the given folders and file extensions
+func NewLibrary(folders, formats []string, log zerolog.Logger) *Library {
+	return &Library{
+		folders: folders,
+		formats: formats,
+		log:     log,
+	}
+}
+
+// Tracks returns every track, scanning the folders if the index is stale
+func (l *Library) Tracks() []Track {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.tracks == nil || time.Since(l.scannedAt) > libraryTTL {
+		l.scanLocked()
+	}
+	return l.tracks
+}
+
+// Refresh forces a rescan on the next lookup
+func (l *Library) Refresh() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.scannedAt = time.Time{}
+}
+
+// Search returns all tracks whose file name or tags contain the query
+func (l *Library) Search(query string) []Track {
+	query = strings.ToLower(strings.TrimSpace(query))
+
+	var tracks []Track
+	for _, t := range l.Tracks() {
+		if t.Matches(query) {
+			tracks = append(tracks, t)
+		}
+	}
+	return tracks
+}
+
+// Best returns the track that best matches a free-form query such as
+// "queen bohemian rhapsody": an exact title wins, then a substring match,
+// then a track containing every word of the query
+func (l *Library) Best(query string) (Track, bool) {
+	query = strings.ToLower(strings.TrimSpace(query))
+	if query == "" {
+		return Track{}, false
+	}
+	words := strings.Fields(query)
+
+	var best Track
+	bestScore := 0
+	for _, t := range l.Tracks() {
+		score := 0
+		switch {
+		case strings.ToLower(t.Title) == query:
+			score = 3
+		case t.Matches(query):
+			score = 2
+		case matchesAll(t, words):
+			score = 1
+		}
+		if score > bestScore {
+			best, bestScore = t, score
+		}
+	}
+	return best, bestScore > 0
+}
+
+// Artists returns every artist in the library, most tracks first
+func (l *Library) Artists() []string {
+	counts := map[string]int{}
+	var artists []string
+	for _, t := range l.Tracks() {
+		if t.Artist == "" {
+			continue
+		}
+		if counts[t.Artist] == 0 {
+			artists = append(artists, t.Artist)
+		}
+		counts[t.Artist]++
+	}
+
+	sort.SliceStable(artists, func(i, j int) bool {
+		return counts[artists[i]] > counts[artists[j]]
+	})
+	return artists
+}
+
+// matchesAll returns true if every word appears somewhere in the track
+func matchesAll(t Track, words []string) bool {
+	haystack := strings.ToLower(strings.Join([]string{filepath.Base(t.Path), t.Title, t.Artist, t.Album}, " "))
+	for _, w := range words {
+		if !strings.Contains(haystack, w) {
+			return false
+		}
+	}
+	return true
+}
+
+// scanLocked walks the folders. Caller must hold l.mu.
+func (l *Library) scanLocked() {
+	var tracks []Track
+
+	for _, folder := range l.folders {
+		err := filepath.Walk(folder, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return nil // Skip errors
+			}
+			if info.IsDir() || !l.supported(path) {
+				return nil
+			}
+			tracks = append(tracks, NewTrack(path))
+			return nil
+		})
+		if err != nil {
+			l.log.Warn().Err(err).Str("folder", folder).Msg("Error scanning folder")
+		}
+	}
+
+	if tracks == nil {
+		tracks = []Track{}
+	}
+	l.tracks = tracks
+	l.scannedAt = time.Now()
+	l.log.Debug().Int("tracks", len(tracks)).Msg("Music library scanned")
+}
+
+// supported returns true if the file has a configured extension
+func (l *Library) supported(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	for _, f := range l.formats {
+		if ext == f {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,23 @@
+package music
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// sendMPVCommand sends a JSON IPC command to a running mpv
+// (see https://mpv.io/manual/stable/#json-ipc)
+func sendMPVCommand(path string, args ...interface{}) error {
+	conn, err := dialMPV(path)
+	if err != nil {
+		return fmt.Errorf("failed to connect to mpv: %w", err)
+	}
+	defer conn.Close()
+
+	msg, err := json.Marshal(map[string]interface{}{"command": args})
+	if err != nil {
+		return err
+	}
+	_, err = conn.Write(append(msg, '\n'))
+	return err
+}
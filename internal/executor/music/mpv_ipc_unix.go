@@ -0,0 +1,22 @@
+//go:build !windows
+
+package music
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// mpvIPCPath returns the IPC socket path used to control mpv while it plays
+func mpvIPCPath() string {
+	return filepath.Join(os.TempDir(), fmt.Sprintf("ana-mpv-%d.sock", os.Getpid()))
+}
+
+// dialMPV connects to mpv's IPC socket
+func dialMPV(path string) (io.WriteCloser, error) {
+	return net.DialTimeout("unix", path, 500*time.Millisecond)
+}
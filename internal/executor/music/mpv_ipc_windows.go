@@ -0,0 +1,19 @@
+//go:build windows
+
+package music
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// mpvIPCPath returns the named pipe used to control mpv while it plays
+func mpvIPCPath() string {
+	return fmt.Sprintf(`\\.\pipe\ana-mpv-%d`, os.Getpid())
+}
+
+// dialMPV opens mpv's IPC named pipe
+func dialMPV(path string) (io.WriteCloser, error) {
+	return os.OpenFile(path, os.O_WRONLY, 0)
+}
@@ -0,0 +1,220 @@
+package music
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	_ "image/gif" // Decoders for artConvert
+	"image/jpeg"
+	"image/png"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/anastreamer/ana/internal/config"
+	"github.com/anastreamer/ana/pkg/logger"
+	"github.com/anastreamer/ana/pkg/utils"
+	"github.com/rs/zerolog"
+)
+
+// TextSink pushes text to an external display, e.g. an OBS text source
+type TextSink interface {
+	SetText(ctx context.Context, source, text string) error
+}
+
+// coverFileNames are checked next to the track when it has no embedded art
+var coverFileNames = []string{"cover.jpg", "cover.png", "folder.jpg", "folder.png", "front.jpg"}
+
+// NowPlayingPublisher writes the current track to a text file, an optional
+// OBS text source and an album art image so overlays can display it
+type NowPlayingPublisher struct {
+	file      string
+	template  string
+	artFile   string
+	obsSource string
+	log       zerolog.Logger
+
+	// seq is bumped on every Publish so queued updates for skipped tracks are dropped
+	seq atomic.Uint64
+
+	// mu serializes writes to the outputs
+	mu   sync.Mutex
+	sink TextSink
+	last string
+}
+
+// NewNowPlayingPublisher creates a now-playing publisher
+func NewNowPlayingPublisher(cfg config.NowPlayingConfig) *NowPlayingPublisher {
+	template := cfg.Template
+	if template == "" {
+		template = "{artist} - {title}"
+	}
+
+	return &NowPlayingPublisher{
+		file:      cfg.File,
+		template:  template,
+		artFile:   cfg.ArtFile,
+		obsSource: cfg.OBSSource,
+		log:       logger.Component("now-playing"),
+	}
+}
+
+// SetSink sets where the formatted text is pushed (e.g. the OBS executor)
+func (p *NowPlayingPublisher) SetSink(sink TextSink) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.sink = sink
+}
+
+// Publish updates every output for the given track; nil clears them.
+// It runs in the background so playback never waits on disk or OBS.
+func (p *NowPlayingPublisher) Publish(track *Track) {
+	seq := p.seq.Add(1)
+	go p.publish(seq, track)
+}
+
+// publish writes the outputs unless a newer update has been requested
+func (p *NowPlayingPublisher) publish(seq uint64, track *Track) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if seq != p.seq.Load() {
+		return // A newer track superseded this one
+	}
+
+	key := ""
+	text := ""
+	if track != nil {
+		key = track.Path
+		text = FormatNowPlaying(p.template, *track)
+	}
+	if key == p.last {
+		return
+	}
+	p.last = key
+
+	p.log.Debug().Str("text", text).Msg("Publishing now playing")
+
+	if p.file != "" {
+		if err := writeFileAtomic(p.file, []byte(text)); err != nil {
+			p.log.Warn().Err(err).Str("file", p.file).Msg("Failed to write now playing file")
+		}
+	}
+
+	if p.artFile != "" {
+		p.writeArt(track)
+	}
+
+	if p.sink != nil && p.obsSource != "" {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		if err := p.sink.SetText(ctx, p.obsSource, text); err != nil {
+			p.log.Warn().Err(err).Str("source", p.obsSource).Msg("Failed to update OBS now playing source")
+		}
+		cancel()
+	}
+}
+
+// writeArt writes the track's album art, or removes the image when there is none
+func (p *NowPlayingPublisher) writeArt(track *Track) {
+	var art []byte
+	if track != nil {
+		art = findArtwork(track.Path)
+	}
+
+	if len(art) == 0 {
+		if err := os.Remove(p.artFile); err != nil && !os.IsNotExist(err) {
+			p.log.Warn().Err(err).Str("file", p.artFile).Msg("Failed to remove album art")
+		}
+		return
+	}
+
+	// The OBS image source reads art_file by its extension, whatever the
+	// track's art is
+	converted, err := artConvert(art, p.artFile)
+	if err != nil {
+		p.log.Warn().Err(err).Str("file", p.artFile).Msg("Cannot convert album art, writing it as is")
+		converted = art
+	}
+
+	if err := writeFileAtomic(p.artFile, converted); err != nil {
+		p.log.Warn().Err(err).Str("file", p.artFile).Msg("Failed to write album art")
+	}
+}
+
+// artConvert returns art in the image format of the extension of path:
+// PNG for .png and JPEG otherwise. Art already in that format is returned
+// as is.
+func artConvert(art []byte, path string) ([]byte, error) {
+	want := "image/jpeg"
+	if strings.EqualFold(filepath.Ext(path), ".png") {
+		want = "image/png"
+	}
+	if http.DetectContentType(art) == want {
+		return art, nil
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(art))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %w", http.DetectContentType(art), err)
+	}
+
+	var out bytes.Buffer
+	if want == "image/png" {
+		err = png.Encode(&out, img)
+	} else {
+		err = jpeg.Encode(&out, img, &jpeg.Options{Quality: 90})
+	}
+	if err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// findArtwork returns embedded album art or a cover image from the track's folder
+func findArtwork(path string) []byte {
+	if tags, err := ReadTags(path); err == nil && len(tags.Picture) > 0 {
+		return tags.Picture
+	}
+
+	dir := filepath.Dir(path)
+	for _, name := range coverFileNames {
+		if data, err := os.ReadFile(filepath.Join(dir, name)); err == nil {
+			return data
+		}
+	}
+	return nil
+}
+
+// FormatNowPlaying renders a template with {title}, {artist}, {album} and {file}
+// placeholders, dropping dangling " - " separators when a field is empty
+func FormatNowPlaying(template string, t Track) string {
+	r := strings.NewReplacer(
+		"{title}", t.Title,
+		"{artist}", t.Artist,
+		"{album}", t.Album,
+		"{file}", strings.TrimSuffix(filepath.Base(t.Path), filepath.Ext(t.Path)),
+	)
+
+	text := strings.TrimSpace(r.Replace(template))
+	text = strings.TrimPrefix(text, "- ")
+	text = strings.TrimSuffix(text, " -")
+	return strings.TrimSpace(text)
+}
+
+// writeFileAtomic writes through a temp file and rename so OBS never reads a partial file
+func writeFileAtomic(path string, data []byte) error {
+	if err := utils.EnsureDir(filepath.Dir(path)); err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,122 @@
+package music
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/anastreamer/ana/internal/config"
+)
+
+func TestFormatNowPlaying(t *testing.T) {
+	song := Track{Path: "/music/Rock/My Song.mp3", Title: "Song", Artist: "Band", Album: "Album"}
+
+	tests := []struct {
+		template string
+		track    Track
+		want     string
+	}{
+		{"{artist} - {title}", song, "Band - Song"},
+		{"{title} ({album})", song, "Song (Album)"},
+		{"{file}", song, "My Song"},
+		{"{artist} - {title}", Track{Title: "Song"}, "Song"},
+		{"{artist} - {title}", Track{Artist: "Band"}, "Band"},
+		{"  {title}  ", song, "Song"},
+	}
+
+	for _, tt := range tests {
+		if got := FormatNowPlaying(tt.template, tt.track); got != tt.want {
+			t.Errorf("FormatNowPlaying(%q, %+v) = %q, want %q", tt.template, tt.track, got, tt.want)
+		}
+	}
+}
+
+func TestWriteFileAtomic(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "obs", "now_playing.txt")
+
+	// Creates the folder and replaces what was there
+	for _, text := range []string{"a", "b"} {
+		if err := writeFileAtomic(path, []byte(text)); err != nil {
+			t.Fatal(err)
+		}
+		if data, _ := os.ReadFile(path); string(data) != text {
+			t.Errorf("file = %q, want %q", data, text)
+		}
+	}
+	if _, err := os.Stat(path + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("temp file left behind: %v", err)
+	}
+}
+
+// textSink records the texts pushed to it
+type textSink struct {
+	mu    sync.Mutex
+	texts []string
+}
+
+func (s *textSink) SetText(ctx context.Context, source, text string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.texts = append(s.texts, text)
+	return nil
+}
+
+func newTestPublisher(t *testing.T) (*NowPlayingPublisher, string, *textSink) {
+	path := filepath.Join(t.TempDir(), "now_playing.txt")
+	p := NewNowPlayingPublisher(config.NowPlayingConfig{File: path, Template: "{title}", OBSSource: "Now playing"})
+	sink := &textSink{}
+	p.SetSink(sink)
+	return p, path, sink
+}
+
+func TestNowPlayingDropsStalePublishes(t *testing.T) {
+	p, path, sink := newTestPublisher(t)
+	list := tracks("a", "b")
+
+	// The update for a was still queued when b started
+	stale := p.seq.Add(1)
+	current := p.seq.Add(1)
+	p.publish(current, &list[1])
+	p.publish(stale, &list[0])
+
+	if data, _ := os.ReadFile(path); string(data) != "b" {
+		t.Errorf("file = %q, want b", data)
+	}
+	if len(sink.texts) != 1 || sink.texts[0] != "b" {
+		t.Errorf("pushed %q, want only b", sink.texts)
+	}
+
+	// The same track again isn't rewritten; nil clears it
+	p.publish(p.seq.Add(1), &list[1])
+	p.publish(p.seq.Add(1), nil)
+	if data, err := os.ReadFile(path); err != nil || len(data) != 0 {
+		t.Errorf("file = %q, %v, want it empty", data, err)
+	}
+	if len(sink.texts) != 2 || sink.texts[1] != "" {
+		t.Errorf("pushed %q, want b and then a clear", sink.texts)
+	}
+}
+
+func TestNowPlayingPublishLatest(t *testing.T) {
+	p, path, _ := newTestPublisher(t)
+	list := tracks("a", "b", "c")
+	for i := range list {
+		p.Publish(&list[i])
+	}
+
+	// Whatever ran of the earlier ones, the last track ends up published
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		data, _ := os.ReadFile(path)
+		if string(data) == "c" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("file = %q, want c", data)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
@@ -3,11 +3,11 @@ package music
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"math/rand"
-	"os"
+	"math"
 	"os/exec"
-	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
 
@@ -18,36 +18,79 @@ import (
 	"github.com/rs/zerolog"
 )
 
+// errNoPlayer is returned when no supported audio player is installed
+var errNoPlayer = errors.New("no audio player found (install mpv or ffplay)")
+
 // Executor implements the music player executor
 type Executor struct {
-	folders          []string
-	supportedFormats []string
-	defaultVolume    float64
-	shuffle          bool
-	log              zerolog.Logger
-	enabled          bool
+	library       *Library
+	requests      config.SongRequestConfig
+	defaultVolume float64
+	log           zerolog.Logger
+	enabled       bool
 
 	mu         sync.Mutex
-	playlist   []string
-	currentIdx int
+	queue      *Queue
 	isPlaying  bool
 	isPaused   bool
 	volume     float64
 	currentCmd *exec.Cmd
 	stopChan   chan struct{}
+
+	// Live volume control: mpv is started with an IPC server so volume and
+	// ducking apply to the playing track; other players pick it up on the next one
+	ipcPath       string
+	currentPlayer string
+	duckGain      float64    // Linear gain applied while ducked (1 = not ducked)
+	volumeMu      sync.Mutex // Keeps live volume changes in order, without holding mu
+
+	// Set by next/previous before killing the current track so the
+	// playback loop knows which way to move
+	skipForward bool
+	skipBack    bool
+
+	// publisher writes the current track for stream overlays (nil when disabled)
+	publisher *NowPlayingPublisher
 }
 
 // NewExecutor creates a new music executor
 func NewExecutor(cfg config.MusicConfig) *Executor {
-	return &Executor{
-		folders:          cfg.Folders,
-		supportedFormats: cfg.SupportedFormats,
-		defaultVolume:    cfg.DefaultVolume,
-		shuffle:          cfg.Shuffle,
-		log:              logger.Component("music"),
-		enabled:          cfg.Enabled,
-		volume:           cfg.DefaultVolume,
-		stopChan:         make(chan struct{}),
+	repeat, err := ParseRepeatMode(cfg.Repeat)
+	if err != nil {
+		repeat = RepeatOff
+	}
+
+	log := logger.Component("music")
+	e := &Executor{
+		library:       NewLibrary(cfg.Folders, cfg.SupportedFormats, log),
+		requests:      cfg.Requests,
+		defaultVolume: cfg.DefaultVolume,
+		log:           log,
+		enabled:       cfg.Enabled,
+		volume:        cfg.DefaultVolume,
+		queue:         NewQueue(cfg.Shuffle, repeat),
+		ipcPath:       mpvIPCPath(),
+		duckGain:      1,
+	}
+
+	if cfg.NowPlaying.Enabled {
+		e.publisher = NewNowPlayingPublisher(cfg.NowPlaying)
+	}
+
+	return e
+}
+
+// SetTextSink sets where now-playing text is pushed (e.g. an OBS text source)
+func (e *Executor) SetTextSink(sink TextSink) {
+	if e.publisher != nil {
+		e.publisher.SetSink(sink)
+	}
+}
+
+// publishNowPlaying updates the now-playing outputs; nil clears them
+func (e *Executor) publishNowPlaying(track *Track) {
+	if e.publisher != nil {
+		e.publisher.Publish(track)
 	}
 }
 
@@ -66,6 +109,15 @@ func (e *Executor) SupportedActions() []string {
 		"music.previous",
 		"music.volume",
 		"music.stop",
+		"music.queue.add",
+		"music.queue.list",
+		"music.queue.remove",
+		"music.queue.clear",
+		"music.shuffle",
+		"music.repeat",
+		"music.nowplaying",
+		"music.request.skip",
+		"music.request.who",
 	}
 }
 
@@ -95,6 +147,24 @@ func (e *Executor) Execute(ctx context.Context, action llm.Action) (executor.Res
 		return e.setVolume(ctx, action)
 	case "music.stop":
 		return e.stop(ctx)
+	case "music.queue.add":
+		return e.queueAdd(ctx, action)
+	case "music.queue.list":
+		return e.queueList(ctx)
+	case "music.queue.remove":
+		return e.queueRemove(ctx, action)
+	case "music.queue.clear":
+		return e.queueClear(ctx)
+	case "music.shuffle":
+		return e.setShuffle(ctx, action)
+	case "music.repeat":
+		return e.setRepeat(ctx, action)
+	case "music.nowplaying":
+		return e.nowPlaying(ctx)
+	case "music.request.skip":
+		return e.requestSkip(ctx)
+	case "music.request.who":
+		return e.requestWho(ctx)
 	default:
 		return executor.NewErrorResult(fmt.Errorf("unknown music action: %s", action.Action)), nil
 	}
@@ -111,146 +181,149 @@ func (e *Executor) Close() error {
 	return nil
 }
 
-// play starts playing music
+// play replaces the queue with the tracks matching the query and starts playing
 func (e *Executor) play(ctx context.Context, action llm.Action) (executor.Result, error) {
 	query := action.GetStringParam("query")
 
-	// Load playlist
-	if err := e.loadPlaylist(query); err != nil {
+	tracks, err := e.searchTracks(query)
+	if err != nil {
 		return executor.NewErrorResult(err), err
 	}
 
-	if len(e.playlist) == 0 {
+	if len(tracks) == 0 {
 		return executor.NewErrorResult(fmt.Errorf("no music found")), nil
 	}
 
-	// Shuffle if enabled
-	if e.shuffle {
-		e.shufflePlaylist()
-	}
+	e.stopPlayback()
 
-	// Start playing
-	e.currentIdx = 0
-	go e.playLoop()
+	e.mu.Lock()
+	e.queue.Replace(tracks)
+	track, _ := e.queue.Start()
+	e.startLoopLocked()
+	e.mu.Unlock()
 
-	currentTrack := filepath.Base(e.playlist[e.currentIdx])
-	e.log.Info().Str("track", currentTrack).Int("total", len(e.playlist)).Msg("Playing music")
+	e.log.Info().Str("track", track.DisplayName()).Int("total", len(tracks)).Msg("Playing music")
 
 	return executor.NewResultWithData("Playing music", map[string]interface{}{
-		"track": currentTrack,
-		"total": len(e.playlist),
+		"track": track.DisplayName(),
+		"total": len(tracks),
 	}), nil
 }
 
-// loadPlaylist loads music files from configured folders
-func (e *Executor) loadPlaylist(query string) error {
-	e.mu.Lock()
-	defer e.mu.Unlock()
+// Artists returns the artists of the music library, most tracks first
+func (e *Executor) Artists(ctx context.Context) ([]string, error) {
+	return e.library.Artists(), nil
+}
 
-	e.playlist = nil
-	query = strings.ToLower(query)
-
-	for _, folder := range e.folders {
-		err := filepath.Walk(folder, func(path string, info os.FileInfo, err error) error {
-			if err != nil {
-				return nil // Skip errors
-			}
-			if info.IsDir() {
-				return nil
-			}
-
-			// Check if supported format
-			ext := strings.ToLower(filepath.Ext(path))
-			supported := false
-			for _, fmt := range e.supportedFormats {
-				if ext == fmt {
-					supported = true
-					break
-				}
-			}
-			if !supported {
-				return nil
-			}
-
-			// Filter by query if provided
-			if query != "" {
-				name := strings.ToLower(filepath.Base(path))
-				if !strings.Contains(name, query) {
-					return nil
-				}
-			}
-
-			e.playlist = append(e.playlist, path)
-			return nil
-		})
-		if err != nil {
-			e.log.Warn().Err(err).Str("folder", folder).Msg("Error scanning folder")
-		}
+// searchTracks returns the library tracks matching the query, rescanning
+// once when nothing matches in case new files were added
+func (e *Executor) searchTracks(query string) ([]Track, error) {
+	tracks := e.library.Search(query)
+	if len(tracks) == 0 {
+		e.library.Refresh()
+		tracks = e.library.Search(query)
 	}
-
-	return nil
+	return tracks, nil
 }
 
-// shufflePlaylist shuffles the playlist
-func (e *Executor) shufflePlaylist() {
-	e.mu.Lock()
-	defer e.mu.Unlock()
+// startLoopLocked starts a new playback loop, stopping any previous one.
+// Caller must hold e.mu.
+func (e *Executor) startLoopLocked() {
+	if e.stopChan != nil {
+		close(e.stopChan)
+	}
+	e.stopChan = make(chan struct{})
+	e.isPlaying = true
+	e.isPaused = false
+	go e.playLoop(e.stopChan)
+}
 
-	rand.Shuffle(len(e.playlist), func(i, j int) {
-		e.playlist[i], e.playlist[j] = e.playlist[j], e.playlist[i]
-	})
+// restartLoopLocked plays the current track of the queue from the start if
+// music is playing or paused; between tracks, the loop would otherwise play
+// the track it already picked. Caller must hold e.mu.
+func (e *Executor) restartLoopLocked() {
+	if !e.isPlaying && !e.isPaused {
+		return
+	}
+	e.killCurrentLocked()
+	e.startLoopLocked()
 }
 
 // playLoop is the main playback loop
-func (e *Executor) playLoop() {
+func (e *Executor) playLoop(stop chan struct{}) {
 	for {
 		e.mu.Lock()
-		if e.currentIdx >= len(e.playlist) {
+		select {
+		case <-stop:
+			e.mu.Unlock()
+			return
+		default:
+		}
+
+		track, ok := e.queue.Current()
+		if !ok {
 			e.isPlaying = false
 			e.mu.Unlock()
+			e.publishNowPlaying(nil)
 			return
 		}
-		track := e.playlist[e.currentIdx]
 		e.isPlaying = true
 		e.isPaused = false
+		volume := e.volume * e.duckGain
 		e.mu.Unlock()
 
+		e.publishNowPlaying(&track)
+
 		// Play the track
-		err := e.playTrack(track)
+		err := e.playTrack(track.Path, volume, stop)
+		if errors.Is(err, errNoPlayer) {
+			e.log.Error().Err(err).Msg("Cannot play music")
+			e.mu.Lock()
+			e.isPlaying = false
+			e.mu.Unlock()
+			e.publishNowPlaying(nil)
+			return
+		}
 		if err != nil {
-			e.log.Error().Err(err).Str("track", track).Msg("Error playing track")
+			e.log.Error().Err(err).Str("track", track.Path).Msg("Error playing track")
 		}
 
 		// Check if we should continue
+		e.mu.Lock()
 		select {
-		case <-e.stopChan:
-			e.mu.Lock()
-			e.isPlaying = false
+		case <-stop:
 			e.mu.Unlock()
 			return
 		default:
 		}
 
-		// Move to next track
-		e.mu.Lock()
-		e.currentIdx++
+		// Move to the next (or previous) track
+		switch {
+		case e.skipBack:
+			e.queue.Previous()
+		default:
+			e.queue.Advance(e.skipForward)
+		}
+		e.skipBack = false
+		e.skipForward = false
 		e.mu.Unlock()
 	}
 }
 
 // playTrack plays a single track
-func (e *Executor) playTrack(path string) error {
+func (e *Executor) playTrack(path string, volume float64, stop chan struct{}) error {
 	e.log.Debug().Str("track", path).Msg("Playing track")
 
 	// Find available player
 	var cmd *exec.Cmd
+	var playerName string
 
 	players := []struct {
 		name string
 		args func(string, float64) []string
 	}{
 		{"mpv", func(path string, vol float64) []string {
-			return []string{"--no-video", "--really-quiet", fmt.Sprintf("--volume=%.0f", vol*100), path}
+			return []string{"--no-video", "--really-quiet", "--input-ipc-server=" + e.ipcPath, fmt.Sprintf("--volume=%.0f", vol*100), path}
 		}},
 		{"ffplay", func(path string, vol float64) []string {
 			return []string{"-nodisp", "-autoexit", "-loglevel", "quiet", "-volume", fmt.Sprintf("%.0f", vol*100), path}
@@ -262,44 +335,70 @@ func (e *Executor) playTrack(path string) error {
 
 	for _, player := range players {
 		if _, err := exec.LookPath(player.name); err == nil {
-			args := player.args(path, e.volume)
+			args := player.args(path, volume)
 			cmd = exec.Command(player.name, args...)
+			playerName = player.name
 			break
 		}
 	}
 
 	if cmd == nil {
-		return fmt.Errorf("no audio player found (install mpv or ffplay)")
+		return errNoPlayer
 	}
 
+	// Start under the lock so a concurrent stop either prevents the start
+	// or sees currentCmd and kills it
 	e.mu.Lock()
+	select {
+	case <-stop:
+		e.mu.Unlock()
+		return nil
+	default:
+	}
+	if err := cmd.Start(); err != nil {
+		e.mu.Unlock()
+		return err
+	}
 	e.currentCmd = cmd
+	e.currentPlayer = playerName
 	e.mu.Unlock()
 
-	err := cmd.Run()
+	err := cmd.Wait()
 
 	e.mu.Lock()
-	e.currentCmd = nil
+	if e.currentCmd == cmd {
+		e.currentCmd = nil
+		e.currentPlayer = ""
+	}
 	e.mu.Unlock()
 
 	return err
 }
 
+// killCurrentLocked kills the running player process. Caller must hold e.mu.
+func (e *Executor) killCurrentLocked() {
+	if e.currentCmd != nil && e.currentCmd.Process != nil {
+		e.currentCmd.Process.Kill()
+	}
+}
+
 // pause pauses playback
 func (e *Executor) pause(ctx context.Context) (executor.Result, error) {
 	e.mu.Lock()
 	defer e.mu.Unlock()
 
-	if !e.isPlaying {
+	if !e.isPlaying || e.isPaused {
 		return executor.NewErrorResult(fmt.Errorf("nothing is playing")), nil
 	}
 
-	if e.currentCmd != nil && e.currentCmd.Process != nil {
-		// Send SIGSTOP on Unix systems
-		// For cross-platform, we'll just kill and remember position
-		e.currentCmd.Process.Kill()
-	}
+	// For cross-platform support we kill the player and restart the
+	// current track on resume
 	e.isPaused = true
+	if e.stopChan != nil {
+		close(e.stopChan)
+		e.stopChan = nil
+	}
+	e.killCurrentLocked()
 
 	e.log.Info().Msg("Music paused")
 	return executor.NewResult("Music paused"), nil
@@ -308,17 +407,14 @@ func (e *Executor) pause(ctx context.Context) (executor.Result, error) {
 // resume resumes playback
 func (e *Executor) resume(ctx context.Context) (executor.Result, error) {
 	e.mu.Lock()
+	defer e.mu.Unlock()
 
 	if !e.isPaused {
-		e.mu.Unlock()
 		return executor.NewErrorResult(fmt.Errorf("music is not paused")), nil
 	}
 
-	e.isPaused = false
-	e.mu.Unlock()
-
 	// Restart playback loop
-	go e.playLoop()
+	e.startLoopLocked()
 
 	e.log.Info().Msg("Music resumed")
 	return executor.NewResult("Music resumed"), nil
@@ -327,65 +423,57 @@ func (e *Executor) resume(ctx context.Context) (executor.Result, error) {
 // next skips to the next track
 func (e *Executor) next(ctx context.Context) (executor.Result, error) {
 	e.mu.Lock()
+	defer e.mu.Unlock()
 
-	if len(e.playlist) == 0 {
-		e.mu.Unlock()
+	if _, ok := e.queue.Current(); !ok {
 		return executor.NewErrorResult(fmt.Errorf("no playlist")), nil
 	}
 
-	// Kill current track
-	if e.currentCmd != nil && e.currentCmd.Process != nil {
-		e.currentCmd.Process.Kill()
-	}
-
-	// Index will be incremented by playLoop
-	nextIdx := e.currentIdx + 1
-	if nextIdx >= len(e.playlist) {
-		nextIdx = 0 // Loop back
-	}
-
 	track := ""
-	if nextIdx < len(e.playlist) {
-		track = filepath.Base(e.playlist[nextIdx])
+	if e.isPlaying && !e.isPaused && e.currentCmd != nil {
+		// playLoop advances once the player exits
+		e.skipForward = true
+		e.killCurrentLocked()
+		if upcoming := e.queue.List(); len(upcoming) > 0 && !e.queue.Shuffle() {
+			track = upcoming[0].DisplayName()
+		}
+	} else {
+		e.queue.Advance(true)
+		if current, ok := e.queue.Current(); ok {
+			track = current.DisplayName()
+		}
+		e.restartLoopLocked()
 	}
 
-	e.mu.Unlock()
-
 	e.log.Info().Str("track", track).Msg("Next track")
 	return executor.NewResultWithData("Next track", map[string]interface{}{
 		"track": track,
 	}), nil
 }
 
-// previous goes to the previous track
+// previous goes back to the previously played track
 func (e *Executor) previous(ctx context.Context) (executor.Result, error) {
 	e.mu.Lock()
+	defer e.mu.Unlock()
 
-	if len(e.playlist) == 0 {
-		e.mu.Unlock()
-		return executor.NewErrorResult(fmt.Errorf("no playlist")), nil
+	if e.queue.HistoryLen() == 0 {
+		return executor.NewErrorResult(fmt.Errorf("no previous track")), nil
 	}
 
-	// Kill current track
-	if e.currentCmd != nil && e.currentCmd.Process != nil {
-		e.currentCmd.Process.Kill()
+	var track Track
+	if e.isPlaying && !e.isPaused && e.currentCmd != nil {
+		// playLoop moves back once the player exits
+		e.skipBack = true
+		e.killCurrentLocked()
+		track, _ = e.queue.PeekPrevious()
+	} else {
+		track, _ = e.queue.Previous()
+		e.restartLoopLocked()
 	}
 
-	// Go back one (will be incremented by playLoop, so go back 2)
-	e.currentIdx -= 2
-	if e.currentIdx < 0 {
-		e.currentIdx = len(e.playlist) - 2
-	}
-	if e.currentIdx < 0 {
-		e.currentIdx = 0
-	}
-
-	track := filepath.Base(e.playlist[e.currentIdx+1])
-	e.mu.Unlock()
-
-	e.log.Info().Str("track", track).Msg("Previous track")
+	e.log.Info().Str("track", track.DisplayName()).Msg("Previous track")
 	return executor.NewResultWithData("Previous track", map[string]interface{}{
-		"track": track,
+		"track": track.DisplayName(),
 	}), nil
 }
 
@@ -402,11 +490,43 @@ func (e *Executor) setVolume(ctx context.Context, action llm.Action) (executor.R
 	e.mu.Lock()
 	e.volume = volume
 	e.mu.Unlock()
+	e.applyVolume()
 
 	e.log.Info().Float64("volume", volume).Msg("Volume set")
 	return executor.NewResult(fmt.Sprintf("Volume set to %.0f%%", volume*100)), nil
 }
 
+// SetDuck attenuates the music by db (0 = normal, negative = quieter) without
+// changing the user's volume. Used by the ducking coordinator.
+func (e *Executor) SetDuck(ctx context.Context, db float64) error {
+	e.mu.Lock()
+	e.duckGain = math.Pow(10, db/20)
+	e.mu.Unlock()
+
+	e.applyVolume()
+	return nil
+}
+
+// applyVolume pushes the effective volume to a running mpv. The IPC call
+// can take a while, so it's made without holding e.mu; volumeMu keeps a
+// stale volume from landing after a newer one.
+func (e *Executor) applyVolume() {
+	e.volumeMu.Lock()
+	defer e.volumeMu.Unlock()
+
+	e.mu.Lock()
+	live := e.currentPlayer == "mpv"
+	volume := e.volume * e.duckGain * 100
+	e.mu.Unlock()
+
+	if !live {
+		return
+	}
+	if err := sendMPVCommand(e.ipcPath, "set_property", "volume", volume); err != nil {
+		e.log.Debug().Err(err).Msg("Failed to set live volume")
+	}
+}
+
 // stop stops playback
 func (e *Executor) stop(ctx context.Context) (executor.Result, error) {
 	e.stopPlayback()
@@ -420,18 +540,186 @@ func (e *Executor) stopPlayback() {
 	e.mu.Lock()
 	defer e.mu.Unlock()
 
-	if e.currentCmd != nil && e.currentCmd.Process != nil {
-		e.currentCmd.Process.Kill()
-	}
-
-	// Signal stop
-	select {
-	case e.stopChan <- struct{}{}:
-	default:
+	// Signal stop before killing so the loop exits instead of advancing
+	if e.stopChan != nil {
+		close(e.stopChan)
+		e.stopChan = nil
 	}
+	e.killCurrentLocked()
 
 	e.isPlaying = false
 	e.isPaused = false
+	e.skipBack = false
+	e.skipForward = false
+
+	e.publishNowPlaying(nil)
+}
+
+// queueAdd adds the best match for a query to the end of the queue
+func (e *Executor) queueAdd(ctx context.Context, action llm.Action) (executor.Result, error) {
+	query := action.GetStringParam("query")
+	if query == "" {
+		return executor.NewErrorResult(fmt.Errorf("query is required")), nil
+	}
+
+	tracks, err := e.searchTracks(query)
+	if err != nil {
+		return executor.NewErrorResult(err), err
+	}
+	if len(tracks) == 0 {
+		return executor.NewErrorResult(fmt.Errorf("no music found for: %s", query)), nil
+	}
+
+	track := tracks[0]
+
+	e.mu.Lock()
+	e.queue.Add(track)
+	if !e.isPlaying && !e.isPaused {
+		// Nothing is playing, move past the last (stopped) track and start
+		if _, ok := e.queue.Current(); ok {
+			e.queue.Advance(true)
+		} else {
+			e.queue.Start()
+		}
+		e.startLoopLocked()
+	}
+	position := e.queue.Len()
+	e.mu.Unlock()
+
+	e.log.Info().Str("track", track.DisplayName()).Int("queued", position).Msg("Track queued")
+	return executor.NewResultWithData("Track queued", map[string]interface{}{
+		"track":  track.DisplayName(),
+		"queued": position,
+	}), nil
+}
+
+// queueList describes the upcoming tracks
+func (e *Executor) queueList(ctx context.Context) (executor.Result, error) {
+	e.mu.Lock()
+	upcoming := e.queue.List()
+	e.mu.Unlock()
+
+	if len(upcoming) == 0 {
+		return executor.NewReplyResult("La cola está vacía", nil), nil
+	}
+
+	const maxSpoken = 5
+	names := make([]string, 0, len(upcoming))
+	var spoken []string
+	for i, t := range upcoming {
+		names = append(names, t.DisplayName())
+		if i < maxSpoken {
+			spoken = append(spoken, fmt.Sprintf("%d, %s", i+1, t.DisplayName()))
+		}
+	}
+
+	reply := fmt.Sprintf("En la cola hay %d canciones: %s", len(upcoming), strings.Join(spoken, "; "))
+	if len(upcoming) > maxSpoken {
+		reply += fmt.Sprintf(" y %d más", len(upcoming)-maxSpoken)
+	}
+
+	return executor.NewReplyResult(reply, map[string]interface{}{
+		"tracks": names,
+	}), nil
+}
+
+// queueRemove removes a track by 1-based position or by query
+func (e *Executor) queueRemove(ctx context.Context, action llm.Action) (executor.Result, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	index := -1
+	if pos := action.GetIntParam("position"); pos > 0 {
+		index = pos - 1
+	} else if query := action.GetStringParam("query"); query != "" {
+		if n, err := strconv.Atoi(query); err == nil {
+			index = n - 1
+		} else {
+			index = e.queue.Find(query)
+		}
+	}
+
+	if index < 0 {
+		return executor.NewErrorResult(fmt.Errorf("track not found in queue")), nil
+	}
+
+	removed, err := e.queue.Remove(index)
+	if err != nil {
+		return executor.NewErrorResult(err), nil
+	}
+
+	e.log.Info().Str("track", removed.DisplayName()).Msg("Track removed from queue")
+	return executor.NewResultWithData("Track removed", map[string]interface{}{
+		"track": removed.DisplayName(),
+	}), nil
+}
+
+// queueClear removes all upcoming tracks
+func (e *Executor) queueClear(ctx context.Context) (executor.Result, error) {
+	e.mu.Lock()
+	e.queue.Clear()
+	e.mu.Unlock()
+
+	e.log.Info().Msg("Queue cleared")
+	return executor.NewResult("Queue cleared"), nil
+}
+
+// setShuffle turns shuffle on or off
+func (e *Executor) setShuffle(ctx context.Context, action llm.Action) (executor.Result, error) {
+	enabled := action.GetBoolParam("enabled")
+	if state := strings.ToLower(action.GetStringParam("state")); state != "" {
+		enabled = state == "on" || state == "true" || state == "si" || state == "sí"
+	}
+
+	e.mu.Lock()
+	e.queue.SetShuffle(enabled)
+	e.mu.Unlock()
+
+	e.log.Info().Bool("shuffle", enabled).Msg("Shuffle set")
+	return executor.NewResultWithData("Shuffle set", map[string]interface{}{
+		"shuffle": enabled,
+	}), nil
+}
+
+// setRepeat sets the repeat mode (off, one, all)
+func (e *Executor) setRepeat(ctx context.Context, action llm.Action) (executor.Result, error) {
+	mode, err := ParseRepeatMode(action.GetStringParam("mode"))
+	if err != nil {
+		return executor.NewErrorResult(err), nil
+	}
+
+	e.mu.Lock()
+	e.queue.SetRepeat(mode)
+	e.mu.Unlock()
+
+	e.log.Info().Str("repeat", string(mode)).Msg("Repeat mode set")
+	return executor.NewResultWithData("Repeat mode set", map[string]interface{}{
+		"repeat": string(mode),
+	}), nil
+}
+
+// nowPlaying describes the current track so Ana can speak it
+func (e *Executor) nowPlaying(ctx context.Context) (executor.Result, error) {
+	e.mu.Lock()
+	track, ok := e.queue.Current()
+	playing := e.isPlaying && !e.isPaused
+	e.mu.Unlock()
+
+	if !ok || !playing {
+		return executor.NewReplyResult("Ahora mismo no está sonando nada", nil), nil
+	}
+
+	reply := fmt.Sprintf("Está sonando %s", track.Title)
+	if track.Artist != "" {
+		reply = fmt.Sprintf("Está sonando %s de %s", track.Title, track.Artist)
+	}
+
+	return executor.NewReplyResult(reply, map[string]interface{}{
+		"title":  track.Title,
+		"artist": track.Artist,
+		"album":  track.Album,
+		"path":   track.Path,
+	}), nil
 }
 
 // GetCurrentTrack returns the currently playing track
@@ -439,10 +727,11 @@ func (e *Executor) GetCurrentTrack() string {
 	e.mu.Lock()
 	defer e.mu.Unlock()
 
-	if !e.isPlaying || e.currentIdx >= len(e.playlist) {
+	track, ok := e.queue.Current()
+	if !e.isPlaying || !ok {
 		return ""
 	}
-	return filepath.Base(e.playlist[e.currentIdx])
+	return track.DisplayName()
 }
 
 // IsPlaying returns whether music is currently playing
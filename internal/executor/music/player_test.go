@@ -0,0 +1,133 @@
+package music
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/anastreamer/ana/internal/config"
+	"github.com/anastreamer/ana/internal/llm"
+)
+
+// newTestExecutor returns an executor with the queue a, b, c started. With
+// a fake mpv that plays every track until killed, else without any player.
+func newTestExecutor(t *testing.T, withPlayer bool) *Executor {
+	t.Helper()
+	dir := t.TempDir()
+	if withPlayer {
+		if runtime.GOOS == "windows" {
+			t.Skip("the fake player is a shell script")
+		}
+		sleep, err := exec.LookPath("sleep")
+		if err != nil {
+			t.Skip("no sleep command for the fake player")
+		}
+		if err := os.WriteFile(filepath.Join(dir, "mpv"), []byte("#!/bin/sh\nexec "+sleep+" 60\n"), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("PATH", dir)
+
+	e := NewExecutor(config.MusicConfig{Enabled: true, DefaultVolume: 0.5})
+	e.queue.Replace(tracks("a", "b", "c"))
+	e.queue.Start()
+	t.Cleanup(e.stopPlayback)
+	return e
+}
+
+// waitFor polls cond under the executor lock
+func waitFor(t *testing.T, e *Executor, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		e.mu.Lock()
+		ok := cond()
+		e.mu.Unlock()
+		if ok {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func currentTitle(e *Executor) string {
+	track, _ := e.queue.Current()
+	return track.Title
+}
+
+func TestExecutorNextPlaying(t *testing.T) {
+	e := newTestExecutor(t, true)
+	e.mu.Lock()
+	e.startLoopLocked()
+	e.mu.Unlock()
+	waitFor(t, e, "the player", func() bool { return e.currentCmd != nil })
+
+	result, _ := e.Execute(context.Background(), llm.Action{Action: "music.next"})
+	if result.Data["track"] != "b" {
+		t.Errorf("next track = %v, want b", result.Data["track"])
+	}
+	waitFor(t, e, "track b", func() bool { return currentTitle(e) == "b" && e.currentCmd != nil })
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.skipForward {
+		t.Error("skipForward left set for the next track")
+	}
+}
+
+func TestExecutorNextStopped(t *testing.T) {
+	e := newTestExecutor(t, false)
+
+	result, _ := e.Execute(context.Background(), llm.Action{Action: "music.next"})
+	if result.Data["track"] != "b" {
+		t.Errorf("next track = %v, want b", result.Data["track"])
+	}
+	if result, _ := e.Execute(context.Background(), llm.Action{Action: "music.previous"}); result.Data["track"] != "a" {
+		t.Errorf("previous track = %v, want a", result.Data["track"])
+	}
+	if e.isPlaying || e.isPaused {
+		t.Error("skipping while stopped started the music")
+	}
+}
+
+func TestExecutorNextPaused(t *testing.T) {
+	e := newTestExecutor(t, true)
+	e.mu.Lock()
+	e.startLoopLocked()
+	e.mu.Unlock()
+	waitFor(t, e, "the player", func() bool { return e.currentCmd != nil })
+	e.Execute(context.Background(), llm.Action{Action: "music.pause"})
+
+	// Skipping while paused plays the new track from the start
+	result, _ := e.Execute(context.Background(), llm.Action{Action: "music.next"})
+	if result.Data["track"] != "b" {
+		t.Errorf("next track = %v, want b", result.Data["track"])
+	}
+	waitFor(t, e, "track b", func() bool { return currentTitle(e) == "b" && e.currentCmd != nil && !e.isPaused })
+}
+
+func TestExecutorNextBetweenTracks(t *testing.T) {
+	e := newTestExecutor(t, true)
+
+	// Playing, but the loop hasn't started the next player yet
+	e.mu.Lock()
+	e.isPlaying = true
+	e.mu.Unlock()
+
+	result, _ := e.Execute(context.Background(), llm.Action{Action: "music.next"})
+	if result.Data["track"] != "b" {
+		t.Errorf("next track = %v, want b", result.Data["track"])
+	}
+	waitFor(t, e, "track b", func() bool { return currentTitle(e) == "b" && e.currentCmd != nil })
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.skipForward || e.skipBack {
+		t.Error("a skip flag was left set for the next track")
+	}
+}
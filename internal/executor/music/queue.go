@@ -0,0 +1,281 @@
+package music
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+)
+
+// RepeatMode controls what happens when a track or the queue ends
+type RepeatMode string
+
+const (
+	RepeatOff RepeatMode = "off"
+	RepeatOne RepeatMode = "one"
+	RepeatAll RepeatMode = "all"
+)
+
+// ParseRepeatMode converts a user supplied value into a RepeatMode
+func ParseRepeatMode(value string) (RepeatMode, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "", "off", "none", "no", "false":
+		return RepeatOff, nil
+	case "one", "track", "song", "una", "cancion", "canción":
+		return RepeatOne, nil
+	case "all", "queue", "playlist", "todo", "todas":
+		return RepeatAll, nil
+	default:
+		return RepeatOff, fmt.Errorf("invalid repeat mode: %s (must be off, one or all)", value)
+	}
+}
+
+// Queue holds the upcoming tracks, the current track and the playback history.
+// It is not safe for concurrent use; the Executor guards it with its mutex.
+type Queue struct {
+	upcoming []Track
+	history  []Track
+	current  *Track
+
+	// all keeps every queued track in insertion order so RepeatAll can refill
+	all []Track
+
+	// pinned counts the tracks at the front of upcoming that were pushed back
+	// by Previous or added with AddPriority; they play in order even when
+	// shuffle is enabled
+	pinned int
+
+	shuffle bool
+	repeat  RepeatMode
+	rng     *rand.Rand
+
+	maxHistory int
+}
+
+// NewQueue creates an empty queue
+func NewQueue(shuffle bool, repeat RepeatMode) *Queue {
+	if repeat == "" {
+		repeat = RepeatOff
+	}
+	return &Queue{
+		shuffle:    shuffle,
+		repeat:     repeat,
+		rng:        rand.New(rand.NewSource(time.Now().UnixNano())),
+		maxHistory: 100,
+	}
+}
+
+// Add appends tracks to the end of the queue
+func (q *Queue) Add(tracks ...Track) {
+	q.upcoming = append(q.upcoming, tracks...)
+	q.all = append(q.all, tracks...)
+}
+
+// AddPriority queues a track to play before the regular queue, after any
+// earlier priority tracks, even with shuffle enabled. Used for viewer song
+// requests, which are not kept for RepeatAll. Returns the 1-based position.
+func (q *Queue) AddPriority(t Track) int {
+	q.upcoming = append(q.upcoming, Track{})
+	copy(q.upcoming[q.pinned+1:], q.upcoming[q.pinned:])
+	q.upcoming[q.pinned] = t
+	q.pinned++
+	return q.pinned
+}
+
+// CountRequestedBy returns how many upcoming or playing tracks a viewer requested
+func (q *Queue) CountRequestedBy(user string) int {
+	n := 0
+	if q.current != nil && strings.EqualFold(q.current.RequestedBy, user) {
+		n++
+	}
+	for _, t := range q.upcoming {
+		if strings.EqualFold(t.RequestedBy, user) {
+			n++
+		}
+	}
+	return n
+}
+
+// Replace clears the queue and history and loads the given tracks
+func (q *Queue) Replace(tracks []Track) {
+	q.current = nil
+	q.history = nil
+	q.Clear()
+	q.Add(tracks...)
+}
+
+// Current returns the track that is currently selected for playback
+func (q *Queue) Current() (Track, bool) {
+	if q.current == nil {
+		return Track{}, false
+	}
+	return *q.current, true
+}
+
+// Start selects the first track if nothing is selected yet
+func (q *Queue) Start() (Track, bool) {
+	if q.current != nil {
+		return *q.current, true
+	}
+	return q.pop()
+}
+
+// Advance moves to the next track. When skip is false and repeat is RepeatOne
+// the current track is kept; an explicit skip always moves forward.
+func (q *Queue) Advance(skip bool) (Track, bool) {
+	if q.current != nil && q.repeat == RepeatOne && !skip {
+		return *q.current, true
+	}
+
+	if q.current != nil {
+		q.pushHistory(*q.current)
+		q.current = nil
+	}
+
+	if len(q.upcoming) == 0 && q.repeat == RepeatAll && len(q.all) > 0 {
+		q.upcoming = append([]Track(nil), q.all...)
+	}
+
+	return q.pop()
+}
+
+// Previous goes back to the last played track. The current track is put back
+// at the front of the queue so a following Advance plays it again.
+func (q *Queue) Previous() (Track, bool) {
+	if len(q.history) == 0 {
+		if q.current != nil {
+			return *q.current, true
+		}
+		return Track{}, false
+	}
+
+	last := q.history[len(q.history)-1]
+	q.history = q.history[:len(q.history)-1]
+
+	if q.current != nil {
+		q.upcoming = append([]Track{*q.current}, q.upcoming...)
+		q.pinned++
+	}
+	q.current = &last
+	return last, true
+}
+
+// PeekPrevious returns the last played track without changing the queue
+func (q *Queue) PeekPrevious() (Track, bool) {
+	if len(q.history) == 0 {
+		return Track{}, false
+	}
+	return q.history[len(q.history)-1], true
+}
+
+// Remove removes the upcoming track at the given zero-based index
+func (q *Queue) Remove(index int) (Track, error) {
+	if index < 0 || index >= len(q.upcoming) {
+		return Track{}, fmt.Errorf("queue position %d out of range (queue has %d tracks)", index+1, len(q.upcoming))
+	}
+
+	removed := q.upcoming[index]
+	q.upcoming = append(q.upcoming[:index], q.upcoming[index+1:]...)
+	if index < q.pinned {
+		q.pinned--
+	}
+
+	// Requests are never in all
+	if removed.RequestedBy == "" {
+		for i, t := range q.all {
+			if t.Path == removed.Path {
+				q.all = append(q.all[:i], q.all[i+1:]...)
+				break
+			}
+		}
+	}
+
+	return removed, nil
+}
+
+// Find returns the index of the first upcoming track matching the query
+func (q *Queue) Find(query string) int {
+	query = strings.ToLower(strings.TrimSpace(query))
+	if query == "" {
+		return -1
+	}
+	for i, t := range q.upcoming {
+		if t.Matches(query) {
+			return i
+		}
+	}
+	return -1
+}
+
+// Clear removes all upcoming tracks. The current track keeps playing.
+func (q *Queue) Clear() {
+	q.upcoming = nil
+	q.all = nil
+	q.pinned = 0
+	if q.current != nil {
+		q.all = append(q.all, *q.current)
+	}
+}
+
+// List returns a copy of the upcoming tracks in play order (when shuffle is
+// enabled the order is only a hint, the next track is picked at random)
+func (q *Queue) List() []Track {
+	return append([]Track(nil), q.upcoming...)
+}
+
+// Len returns the number of upcoming tracks
+func (q *Queue) Len() int {
+	return len(q.upcoming)
+}
+
+// HistoryLen returns the number of tracks in the playback history
+func (q *Queue) HistoryLen() int {
+	return len(q.history)
+}
+
+// SetShuffle enables or disables shuffle
+func (q *Queue) SetShuffle(enabled bool) {
+	q.shuffle = enabled
+}
+
+// Shuffle returns whether shuffle is enabled
+func (q *Queue) Shuffle() bool {
+	return q.shuffle
+}
+
+// SetRepeat sets the repeat mode
+func (q *Queue) SetRepeat(mode RepeatMode) {
+	q.repeat = mode
+}
+
+// Repeat returns the repeat mode
+func (q *Queue) Repeat() RepeatMode {
+	return q.repeat
+}
+
+// pop takes the next track from the queue and makes it current
+func (q *Queue) pop() (Track, bool) {
+	if len(q.upcoming) == 0 {
+		return Track{}, false
+	}
+
+	idx := 0
+	if q.pinned > 0 {
+		q.pinned--
+	} else if q.shuffle && len(q.upcoming) > 1 {
+		idx = q.rng.Intn(len(q.upcoming))
+	}
+
+	next := q.upcoming[idx]
+	q.upcoming = append(q.upcoming[:idx], q.upcoming[idx+1:]...)
+	q.current = &next
+	return next, true
+}
+
+// pushHistory records a played track, trimming the oldest entries
+func (q *Queue) pushHistory(t Track) {
+	q.history = append(q.history, t)
+	if q.maxHistory > 0 && len(q.history) > q.maxHistory {
+		q.history = q.history[len(q.history)-q.maxHistory:]
+	}
+}
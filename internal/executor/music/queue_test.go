@@ -0,0 +1,280 @@
+package music
+
+import (
+	"math/rand"
+	"slices"
+	"testing"
+)
+
+// tracks returns a track for each name, with the name as title and path
+func tracks(names ...string) []Track {
+	list := make([]Track, len(names))
+	for i, name := range names {
+		list[i] = Track{Path: "/music/" + name + ".mp3", Title: name}
+	}
+	return list
+}
+
+// newTestQueue returns a queue with tracks and a fixed random seed
+func newTestQueue(shuffle bool, repeat RepeatMode, seed int64, names ...string) *Queue {
+	q := NewQueue(shuffle, repeat)
+	q.rng = rand.New(rand.NewSource(seed))
+	q.Add(tracks(names...)...)
+	return q
+}
+
+// playAll advances the queue until it ends or n tracks played, returning
+// their titles, starting with the current one
+func playAll(q *Queue, n int) []string {
+	var played []string
+	t, ok := q.Start()
+	for ok && len(played) < n {
+		played = append(played, t.Title)
+		t, ok = q.Advance(true)
+	}
+	return played
+}
+
+func TestQueueInOrder(t *testing.T) {
+	q := newTestQueue(false, RepeatOff, 1, "a", "b", "c")
+
+	if got := playAll(q, 10); !slices.Equal(got, []string{"a", "b", "c"}) {
+		t.Errorf("played %v, want a b c", got)
+	}
+	if _, ok := q.Current(); ok {
+		t.Error("a track is current after the queue ended")
+	}
+	if q.HistoryLen() != 3 {
+		t.Errorf("HistoryLen() = %d, want 3", q.HistoryLen())
+	}
+}
+
+func TestQueueShuffle(t *testing.T) {
+	names := []string{"a", "b", "c", "d", "e", "f", "g", "h"}
+	shuffled := false
+
+	for seed := int64(1); seed <= 20; seed++ {
+		q := newTestQueue(true, RepeatOff, seed, names...)
+		played := playAll(q, 100)
+
+		// Every track plays once
+		sorted := slices.Clone(played)
+		slices.Sort(sorted)
+		if !slices.Equal(sorted, names) {
+			t.Fatalf("seed %d: played %v, want each track once", seed, played)
+		}
+		if !slices.Equal(played, names) {
+			shuffled = true
+		}
+	}
+	if !shuffled {
+		t.Error("shuffle always played the tracks in order")
+	}
+}
+
+func TestQueueShuffleToggle(t *testing.T) {
+	q := newTestQueue(true, RepeatOff, 1, "a", "b", "c", "d")
+	q.Start()
+
+	q.SetShuffle(false)
+	if q.Shuffle() {
+		t.Fatal("Shuffle() = true after disabling it")
+	}
+	// Without shuffle the rest plays in the listed order
+	var want []string
+	for _, track := range q.List() {
+		want = append(want, track.Title)
+	}
+	var got []string
+	for track, ok := q.Advance(true); ok; track, ok = q.Advance(true) {
+		got = append(got, track.Title)
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("played %v, want the listed order %v", got, want)
+	}
+}
+
+func TestQueuePreviousAtStart(t *testing.T) {
+	q := newTestQueue(false, RepeatOff, 1, "a", "b")
+
+	// Nothing played yet
+	if track, ok := q.Previous(); ok {
+		t.Errorf("Previous() = %s before playing anything", track.Title)
+	}
+	if _, ok := q.PeekPrevious(); ok {
+		t.Error("PeekPrevious() found a track before playing anything")
+	}
+
+	// The first track has nothing before it, so it stays
+	q.Start()
+	track, ok := q.Previous()
+	if !ok || track.Title != "a" {
+		t.Errorf("Previous() on the first track = %s, %v, want a", track.Title, ok)
+	}
+	if q.Len() != 1 || q.HistoryLen() != 0 {
+		t.Errorf("Len() = %d, HistoryLen() = %d, want the queue unchanged", q.Len(), q.HistoryLen())
+	}
+	if next, _ := q.Advance(true); next.Title != "b" {
+		t.Errorf("Advance() = %s, want b", next.Title)
+	}
+}
+
+func TestQueuePrevious(t *testing.T) {
+	q := newTestQueue(false, RepeatOff, 1, "a", "b", "c")
+	q.Start()
+	q.Advance(true)
+	q.Advance(true) // c
+
+	if prev, _ := q.PeekPrevious(); prev.Title != "b" {
+		t.Errorf("PeekPrevious() = %s, want b", prev.Title)
+	}
+	for _, want := range []string{"b", "a"} {
+		if track, ok := q.Previous(); !ok || track.Title != want {
+			t.Errorf("Previous() = %s, %v, want %s", track.Title, ok, want)
+		}
+	}
+
+	// Going forward again replays them in order
+	var got []string
+	for track, ok := q.Advance(true); ok; track, ok = q.Advance(true) {
+		got = append(got, track.Title)
+	}
+	if !slices.Equal(got, []string{"b", "c"}) {
+		t.Errorf("played %v after going back, want b c", got)
+	}
+}
+
+func TestQueuePinnedSurviveShuffle(t *testing.T) {
+	names := []string{"a", "b", "c", "d", "e", "f", "g", "h"}
+
+	for seed := int64(1); seed <= 20; seed++ {
+		q := newTestQueue(true, RepeatOff, seed, names...)
+		first, _ := q.Start()
+		second, _ := q.Advance(true)
+
+		// Requests play first and in order
+		req1 := Track{Path: "/music/r1.mp3", Title: "r1", RequestedBy: "viewer"}
+		req2 := Track{Path: "/music/r2.mp3", Title: "r2", RequestedBy: "viewer"}
+		if pos := q.AddPriority(req1); pos != 1 {
+			t.Errorf("seed %d: AddPriority() = %d, want 1", seed, pos)
+		}
+		if pos := q.AddPriority(req2); pos != 2 {
+			t.Errorf("seed %d: AddPriority() = %d, want 2", seed, pos)
+		}
+
+		// Going back pins the current track in front of them
+		if prev, _ := q.Previous(); prev.Title != first.Title {
+			t.Fatalf("seed %d: Previous() = %s, want %s", seed, prev.Title, first.Title)
+		}
+
+		var got []string
+		for i := 0; i < 3; i++ {
+			track, _ := q.Advance(true)
+			got = append(got, track.Title)
+		}
+		if want := []string{second.Title, "r1", "r2"}; !slices.Equal(got, want) {
+			t.Errorf("seed %d: played %v, want %v", seed, got, want)
+		}
+	}
+}
+
+func TestQueueRemovePinned(t *testing.T) {
+	q := newTestQueue(true, RepeatOff, 1, "a", "b", "c")
+	q.AddPriority(Track{Path: "/music/r1.mp3", Title: "r1", RequestedBy: "viewer"})
+	q.AddPriority(Track{Path: "/music/r2.mp3", Title: "r2", RequestedBy: "viewer"})
+
+	if removed, err := q.Remove(0); err != nil || removed.Title != "r1" {
+		t.Fatalf("Remove(0) = %s, %v", removed.Title, err)
+	}
+	if _, err := q.Remove(10); err == nil {
+		t.Error("Remove() out of range succeeded")
+	}
+
+	// r2 is still the only pinned track
+	if track, _ := q.Start(); track.Title != "r2" {
+		t.Errorf("Start() = %s, want r2", track.Title)
+	}
+	if q.pinned != 0 {
+		t.Errorf("pinned = %d after playing the requests, want 0", q.pinned)
+	}
+}
+
+func TestQueueRepeatOne(t *testing.T) {
+	q := newTestQueue(false, RepeatOne, 1, "a", "b")
+	q.Start()
+
+	for i := 0; i < 3; i++ {
+		if track, ok := q.Advance(false); !ok || track.Title != "a" {
+			t.Fatalf("Advance(false) = %s, %v, want a again", track.Title, ok)
+		}
+	}
+	if q.HistoryLen() != 0 {
+		t.Errorf("HistoryLen() = %d, repeats should not be history", q.HistoryLen())
+	}
+
+	// Skipping moves on
+	if track, _ := q.Advance(true); track.Title != "b" {
+		t.Errorf("Advance(true) = %s, want b", track.Title)
+	}
+	if track, _ := q.Advance(false); track.Title != "b" {
+		t.Errorf("Advance(false) = %s, want b again", track.Title)
+	}
+	if _, ok := q.Advance(true); ok {
+		t.Error("Advance(true) past the end found a track")
+	}
+}
+
+func TestQueueRepeatAll(t *testing.T) {
+	q := newTestQueue(false, RepeatAll, 1, "a", "b", "c")
+	q.AddPriority(Track{Path: "/music/r1.mp3", Title: "r1", RequestedBy: "viewer"})
+
+	// Requests play once, the queue starts over
+	want := []string{"r1", "a", "b", "c", "a", "b", "c", "a"}
+	if got := playAll(q, len(want)); !slices.Equal(got, want) {
+		t.Errorf("played %v, want %v", got, want)
+	}
+
+	// Removed tracks don't come back
+	q = newTestQueue(false, RepeatAll, 1, "a", "b", "c")
+	q.Remove(q.Find("b"))
+	if got := playAll(q, 4); !slices.Equal(got, []string{"a", "c", "a", "c"}) {
+		t.Errorf("played %v after removing b, want a c a c", got)
+	}
+}
+
+func TestQueueRepeatAllShuffle(t *testing.T) {
+	names := []string{"a", "b", "c", "d"}
+	q := newTestQueue(true, RepeatAll, 3, names...)
+
+	// Each round plays every track once
+	played := playAll(q, 3*len(names))
+	for round := 0; round < 3; round++ {
+		got := slices.Clone(played[round*len(names) : (round+1)*len(names)])
+		slices.Sort(got)
+		if !slices.Equal(got, names) {
+			t.Errorf("round %d played %v, want each track once", round, played[round*len(names):(round+1)*len(names)])
+		}
+	}
+}
+
+func TestParseRepeatMode(t *testing.T) {
+	tests := []struct {
+		value string
+		want  RepeatMode
+		err   bool
+	}{
+		{"", RepeatOff, false},
+		{"off", RepeatOff, false},
+		{" One ", RepeatOne, false},
+		{"canción", RepeatOne, false},
+		{"todo", RepeatAll, false},
+		{"playlist", RepeatAll, false},
+		{"sometimes", RepeatOff, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseRepeatMode(tt.value)
+		if got != tt.want || (err != nil) != tt.err {
+			t.Errorf("ParseRepeatMode(%q) = %s, %v", tt.value, got, err)
+		}
+	}
+}
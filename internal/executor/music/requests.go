@@ -0,0 +1,166 @@
+package music
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/anastreamer/ana/internal/executor"
+)
+
+// Song request errors, reported back to the viewer by the request source
+var (
+	ErrRequestsDisabled = errors.New("song requests are disabled")
+	ErrRequestNotFound  = errors.New("song not found in library")
+	ErrRequestLimit     = errors.New("too many pending requests")
+	ErrRequestTooLong   = errors.New("song is too long")
+	ErrRequestBlocked   = errors.New("song is blocked")
+)
+
+// Request is a song requested by a viewer
+type Request struct {
+	User   string // Viewer name
+	Query  string // What the viewer asked for
+	Source string // Where the request came from (chat, channel_points, http)
+}
+
+// RequestHandler queues a request and returns the track and its position
+type RequestHandler func(ctx context.Context, req Request) (Track, int, error)
+
+// RequestSource delivers viewer requests (chat commands, channel point
+// redemptions, HTTP, ...). Start returns once the source is set up and keeps
+// delivering requests in the background until ctx is done.
+type RequestSource interface {
+	Name() string
+	Start(ctx context.Context, handle RequestHandler) error
+}
+
+// HandleRequest resolves a viewer request against the library, enforces the
+// limits and queues the track ahead of the regular queue
+func (e *Executor) HandleRequest(ctx context.Context, req Request) (Track, int, error) {
+	if !e.enabled || !e.requests.Enabled {
+		return Track{}, 0, ErrRequestsDisabled
+	}
+
+	query := strings.TrimSpace(req.Query)
+	if e.isBlocked(query) {
+		return Track{}, 0, ErrRequestBlocked
+	}
+
+	track, ok := e.library.Best(query)
+	if !ok {
+		return Track{}, 0, fmt.Errorf("%w: %s", ErrRequestNotFound, query)
+	}
+	if e.isBlocked(track.Path, track.Title, track.Artist, track.Album) {
+		return Track{}, 0, ErrRequestBlocked
+	}
+
+	maxDuration := time.Duration(e.requests.MaxDurationSec) * time.Second
+	if maxDuration > 0 && track.Duration > maxDuration {
+		return Track{}, 0, fmt.Errorf("%w (%s, max %s)", ErrRequestTooLong, track.Duration.Round(time.Second), maxDuration)
+	}
+
+	e.mu.Lock()
+	if limit := e.requests.MaxPerUser; limit > 0 && e.queue.CountRequestedBy(req.User) >= limit {
+		e.mu.Unlock()
+		return Track{}, 0, ErrRequestLimit
+	}
+
+	track.RequestedBy = req.User
+	position := e.queue.AddPriority(track)
+	if !e.isPlaying && !e.isPaused {
+		if _, ok := e.queue.Current(); ok {
+			e.queue.Advance(true)
+		} else {
+			e.queue.Start()
+		}
+		e.startLoopLocked()
+		position = 0 // Playing now
+	}
+	e.mu.Unlock()
+
+	e.log.Info().
+		Str("user", req.User).
+		Str("source", req.Source).
+		Str("track", track.DisplayName()).
+		Int("position", position).
+		Msg("Song requested")
+	return track, position, nil
+}
+
+// isBlocked returns true if any value contains a blocklisted term
+func (e *Executor) isBlocked(values ...string) bool {
+	for _, v := range values {
+		v = strings.ToLower(v)
+		for _, term := range e.requests.Blocklist {
+			if term = strings.ToLower(strings.TrimSpace(term)); term != "" && strings.Contains(v, term) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// requestSkip skips the current track if it was requested by a viewer
+func (e *Executor) requestSkip(ctx context.Context) (executor.Result, error) {
+	e.mu.Lock()
+	track, ok := e.queue.Current()
+	e.mu.Unlock()
+
+	if !ok || track.RequestedBy == "" {
+		return executor.NewReplyResult("Lo que suena ahora no es una petición", nil), nil
+	}
+
+	if _, err := e.next(ctx); err != nil {
+		return executor.NewErrorResult(err), err
+	}
+
+	e.log.Info().Str("track", track.DisplayName()).Str("user", track.RequestedBy).Msg("Request skipped")
+	return executor.NewResultWithData("Request skipped", map[string]interface{}{
+		"track": track.DisplayName(),
+		"user":  track.RequestedBy,
+	}), nil
+}
+
+// requestWho says who requested the current track
+func (e *Executor) requestWho(ctx context.Context) (executor.Result, error) {
+	e.mu.Lock()
+	track, ok := e.queue.Current()
+	e.mu.Unlock()
+
+	if !ok {
+		return executor.NewReplyResult("Ahora mismo no está sonando nada", nil), nil
+	}
+	if track.RequestedBy == "" {
+		return executor.NewReplyResult("Nadie la pidió, es de la lista de reproducción", nil), nil
+	}
+
+	return executor.NewReplyResult(fmt.Sprintf("La pidió %s", track.RequestedBy), map[string]interface{}{
+		"track": track.DisplayName(),
+		"user":  track.RequestedBy,
+	}), nil
+}
+
+// FormatRequestReply builds the message sent back to the viewer
+func FormatRequestReply(req Request, track Track, position int, err error) string {
+	switch {
+	case err == nil && position == 0:
+		return fmt.Sprintf("@%s suena ahora: %s", req.User, track.DisplayName())
+	case err == nil:
+		return fmt.Sprintf("@%s añadida a la cola (#%d): %s", req.User, position, track.DisplayName())
+	case errors.Is(err, ErrRequestNotFound):
+		return fmt.Sprintf("@%s no encontré \"%s\"", req.User, req.Query)
+	case errors.Is(err, ErrRequestLimit):
+		return fmt.Sprintf("@%s ya tienes el máximo de peticiones en cola", req.User)
+	case errors.Is(err, ErrRequestTooLong):
+		return fmt.Sprintf("@%s esa canción es demasiado larga", req.User)
+	case errors.Is(err, ErrRequestBlocked):
+		return fmt.Sprintf("@%s esa canción no está permitida", req.User)
+	case errors.Is(err, ErrRequestsDisabled):
+		return fmt.Sprintf("@%s las peticiones están cerradas", req.User)
+	default:
+		return fmt.Sprintf("@%s no pude añadir tu petición", req.User)
+	}
+}
@@ -0,0 +1,444 @@
+package music
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+// Track is a playable file with the metadata we could read from it
+type Track struct {
+	Path   string `json:"path"`
+	Title  string `json:"title"`
+	Artist string `json:"artist,omitempty"`
+	Album  string `json:"album,omitempty"`
+
+	// Duration is zero when it couldn't be determined
+	Duration time.Duration `json:"duration,omitempty"`
+
+	// RequestedBy is the viewer who requested the track, empty for regular playback
+	RequestedBy string `json:"requested_by,omitempty"`
+}
+
+// NewTrack builds a Track from a file, reading tags when possible and
+// falling back to the "Artist - Title" file name convention
+func NewTrack(path string) Track {
+	t := Track{Path: path}
+
+	if tags, err := readTags(path, false); err == nil {
+		t.Title = tags.Title
+		t.Artist = tags.Artist
+		t.Album = tags.Album
+	}
+
+	if d, err := ReadDuration(path); err == nil {
+		t.Duration = d
+	}
+
+	if t.Title == "" || t.Artist == "" {
+		artist, title := parseFileName(path)
+		if t.Title == "" {
+			t.Title = title
+		}
+		if t.Artist == "" {
+			t.Artist = artist
+		}
+	}
+
+	return t
+}
+
+// DisplayName returns a human friendly "Title - Artist" string
+func (t Track) DisplayName() string {
+	if t.Artist == "" {
+		return t.Title
+	}
+	return fmt.Sprintf("%s - %s", t.Title, t.Artist)
+}
+
+// Matches returns true if the lower-cased query appears in the file name or tags
+func (t Track) Matches(query string) bool {
+	if query == "" {
+		return true
+	}
+	fields := []string{filepath.Base(t.Path), t.Title, t.Artist, t.Album}
+	for _, f := range fields {
+		if strings.Contains(strings.ToLower(f), query) {
+			return true
+		}
+	}
+	return false
+}
+
+// Tags holds the metadata read from an audio file
+type Tags struct {
+	Title  string
+	Artist string
+	Album  string
+
+	// Embedded album art, if any
+	Picture     []byte
+	PictureMIME string
+}
+
+// ReadTags reads ID3v2 (MP3) or Vorbis comment (FLAC) tags, including album art, from a file
+func ReadTags(path string) (*Tags, error) {
+	return readTags(path, true)
+}
+
+// readTags reads tags, optionally skipping the (potentially large) album art
+func readTags(path string, pictures bool) (*Tags, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	magic := make([]byte, 4)
+	if _, err := io.ReadFull(f, magic); err != nil {
+		return nil, fmt.Errorf("failed to read header: %w", err)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	switch {
+	case string(magic[:3]) == "ID3":
+		return readID3v2(f, pictures)
+	case string(magic) == "fLaC":
+		return readFLAC(f, pictures)
+	default:
+		return nil, fmt.Errorf("no supported tags in %s", filepath.Base(path))
+	}
+}
+
+// readID3v2 parses the text and picture frames of an ID3v2.2/2.3/2.4 tag
+func readID3v2(r io.Reader, pictures bool) (*Tags, error) {
+	header := make([]byte, 10)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, fmt.Errorf("failed to read ID3 header: %w", err)
+	}
+
+	version := header[3]
+	size := syncsafe(header[6:10])
+	if size <= 0 || size > 16<<20 {
+		return nil, fmt.Errorf("invalid ID3 tag size: %d", size)
+	}
+
+	data := make([]byte, size)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, fmt.Errorf("failed to read ID3 tag: %w", err)
+	}
+
+	// Skip extended header if present
+	if header[5]&0x40 != 0 && len(data) >= 4 {
+		extSize := int(binary.BigEndian.Uint32(data[:4]))
+		if version == 4 {
+			extSize = syncsafe(data[:4])
+		} else {
+			extSize += 4
+		}
+		if extSize < len(data) {
+			data = data[extSize:]
+		}
+	}
+
+	idLen, headerLen := 4, 10
+	if version == 2 {
+		idLen, headerLen = 3, 6
+	}
+
+	tags := &Tags{}
+	for len(data) >= headerLen {
+		id := string(data[:idLen])
+		if id[0] == 0 {
+			break // Padding
+		}
+
+		var frameSize int
+		switch version {
+		case 2:
+			frameSize = int(data[3])<<16 | int(data[4])<<8 | int(data[5])
+		case 4:
+			frameSize = syncsafe(data[4:8])
+		default:
+			frameSize = int(binary.BigEndian.Uint32(data[4:8]))
+		}
+
+		if frameSize <= 0 || headerLen+frameSize > len(data) {
+			break
+		}
+		body := data[headerLen : headerLen+frameSize]
+
+		switch id {
+		case "TIT2", "TT2":
+			tags.Title = decodeID3Text(body)
+		case "TPE1", "TP1":
+			tags.Artist = decodeID3Text(body)
+		case "TALB", "TAL":
+			tags.Album = decodeID3Text(body)
+		case "APIC", "PIC":
+			if !pictures {
+				break
+			}
+			mime, picType, pic := decodeID3Picture(body, version == 2)
+			// Prefer the front cover (type 3), otherwise keep the first picture
+			if len(pic) > 0 && (tags.Picture == nil || picType == 3) {
+				tags.Picture = pic
+				tags.PictureMIME = mime
+			}
+		}
+
+		data = data[headerLen+frameSize:]
+	}
+
+	return tags, nil
+}
+
+// readFLAC parses the Vorbis comment and picture blocks of a FLAC file
+func readFLAC(r io.Reader, pictures bool) (*Tags, error) {
+	magic := make([]byte, 4)
+	if _, err := io.ReadFull(r, magic); err != nil {
+		return nil, err
+	}
+
+	tags := &Tags{}
+	for {
+		blockHeader := make([]byte, 4)
+		if _, err := io.ReadFull(r, blockHeader); err != nil {
+			return nil, fmt.Errorf("failed to read FLAC block header: %w", err)
+		}
+
+		last := blockHeader[0]&0x80 != 0
+		blockType := blockHeader[0] & 0x7f
+		length := int(blockHeader[1])<<16 | int(blockHeader[2])<<8 | int(blockHeader[3])
+
+		block := make([]byte, length)
+		if _, err := io.ReadFull(r, block); err != nil {
+			return nil, fmt.Errorf("failed to read FLAC block: %w", err)
+		}
+
+		switch blockType {
+		case 4:
+			parseVorbisComments(block, tags)
+		case 6:
+			if pictures {
+				parseFLACPicture(block, tags)
+			}
+		}
+
+		if last {
+			return tags, nil
+		}
+	}
+}
+
+// parseVorbisComments fills tags from a little-endian Vorbis comment block
+func parseVorbisComments(block []byte, tags *Tags) {
+	rd := bytes.NewReader(block)
+
+	var vendorLen uint32
+	if err := binary.Read(rd, binary.LittleEndian, &vendorLen); err != nil {
+		return
+	}
+	if _, err := rd.Seek(int64(vendorLen), io.SeekCurrent); err != nil {
+		return
+	}
+
+	var count uint32
+	if err := binary.Read(rd, binary.LittleEndian, &count); err != nil {
+		return
+	}
+
+	for i := uint32(0); i < count; i++ {
+		var n uint32
+		if err := binary.Read(rd, binary.LittleEndian, &n); err != nil {
+			return
+		}
+		if int(n) > rd.Len() {
+			return
+		}
+		comment := make([]byte, n)
+		if _, err := io.ReadFull(rd, comment); err != nil {
+			return
+		}
+
+		key, value, ok := strings.Cut(string(comment), "=")
+		if !ok {
+			continue
+		}
+		switch strings.ToUpper(key) {
+		case "TITLE":
+			tags.Title = value
+		case "ARTIST":
+			tags.Artist = value
+		case "ALBUM":
+			tags.Album = value
+		}
+	}
+}
+
+// parseFLACPicture fills the album art from a big-endian FLAC PICTURE block
+func parseFLACPicture(block []byte, tags *Tags) {
+	rd := bytes.NewReader(block)
+
+	var picType, mimeLen uint32
+	if binary.Read(rd, binary.BigEndian, &picType) != nil || binary.Read(rd, binary.BigEndian, &mimeLen) != nil {
+		return
+	}
+	if int(mimeLen) > rd.Len() {
+		return
+	}
+	mime := make([]byte, mimeLen)
+	if _, err := io.ReadFull(rd, mime); err != nil {
+		return
+	}
+
+	var descLen uint32
+	if binary.Read(rd, binary.BigEndian, &descLen) != nil || int(descLen) > rd.Len() {
+		return
+	}
+	// Skip description plus width, height, depth and color count
+	if _, err := rd.Seek(int64(descLen)+16, io.SeekCurrent); err != nil {
+		return
+	}
+
+	var dataLen uint32
+	if binary.Read(rd, binary.BigEndian, &dataLen) != nil || int(dataLen) > rd.Len() {
+		return
+	}
+	data := make([]byte, dataLen)
+	if _, err := io.ReadFull(rd, data); err != nil {
+		return
+	}
+
+	if tags.Picture == nil || picType == 3 {
+		tags.Picture = data
+		tags.PictureMIME = string(mime)
+	}
+}
+
+// decodeID3Picture decodes an APIC (v2.3/2.4) or PIC (v2.2) frame body
+func decodeID3Picture(body []byte, v22 bool) (mime string, picType byte, data []byte) {
+	if len(body) < 4 {
+		return "", 0, nil
+	}
+	encoding := body[0]
+	rest := body[1:]
+
+	if v22 {
+		// v2.2 uses a 3-character image format instead of a MIME type
+		switch strings.ToUpper(string(rest[:3])) {
+		case "PNG":
+			mime = "image/png"
+		default:
+			mime = "image/jpeg"
+		}
+		rest = rest[3:]
+	} else {
+		end := bytes.IndexByte(rest, 0)
+		if end < 0 {
+			return "", 0, nil
+		}
+		mime = string(rest[:end])
+		rest = rest[end+1:]
+	}
+
+	if len(rest) < 1 {
+		return "", 0, nil
+	}
+	picType = rest[0]
+
+	// Skip the description
+	_, rest = decodeID3String(encoding, rest[1:])
+	return mime, picType, rest
+}
+
+// decodeID3Text decodes an ID3 text frame body (encoding byte + text)
+func decodeID3Text(body []byte) string {
+	if len(body) < 2 {
+		return ""
+	}
+	text, _ := decodeID3String(body[0], body[1:])
+	return strings.TrimSpace(text)
+}
+
+// decodeID3String decodes a null-terminated string in the given ID3 encoding
+// and returns it together with the remaining bytes after the terminator
+func decodeID3String(encoding byte, data []byte) (string, []byte) {
+	switch encoding {
+	case 1, 2: // UTF-16 with BOM, UTF-16BE
+		end := len(data)
+		for i := 0; i+1 < len(data); i += 2 {
+			if data[i] == 0 && data[i+1] == 0 {
+				end = i
+				break
+			}
+		}
+		rest := data[min(end+2, len(data)):]
+		return decodeUTF16(data[:end], encoding == 2), rest
+	default: // ISO-8859-1, UTF-8
+		end := bytes.IndexByte(data, 0)
+		if end < 0 {
+			end = len(data)
+		}
+		rest := data[min(end+1, len(data)):]
+		if encoding == 0 {
+			return latin1ToUTF8(data[:end]), rest
+		}
+		return string(data[:end]), rest
+	}
+}
+
+// decodeUTF16 decodes UTF-16 text honoring an optional BOM
+func decodeUTF16(data []byte, bigEndian bool) string {
+	if len(data) >= 2 {
+		switch {
+		case data[0] == 0xFF && data[1] == 0xFE:
+			bigEndian = false
+			data = data[2:]
+		case data[0] == 0xFE && data[1] == 0xFF:
+			bigEndian = true
+			data = data[2:]
+		}
+	}
+
+	units := make([]uint16, len(data)/2)
+	for i := range units {
+		if bigEndian {
+			units[i] = binary.BigEndian.Uint16(data[i*2:])
+		} else {
+			units[i] = binary.LittleEndian.Uint16(data[i*2:])
+		}
+	}
+	return string(utf16.Decode(units))
+}
+
+// latin1ToUTF8 converts ISO-8859-1 bytes to a UTF-8 string
+func latin1ToUTF8(data []byte) string {
+	runes := make([]rune, len(data))
+	for i, b := range data {
+		runes[i] = rune(b)
+	}
+	return string(runes)
+}
+
+// syncsafe decodes a 4-byte ID3 syncsafe integer
+func syncsafe(b []byte) int {
+	return int(b[0]&0x7f)<<21 | int(b[1]&0x7f)<<14 | int(b[2]&0x7f)<<7 | int(b[3]&0x7f)
+}
+
+// parseFileName extracts artist and title from "Artist - Title.ext"
+func parseFileName(path string) (artist, title string) {
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	if a, t, ok := strings.Cut(name, " - "); ok {
+		return strings.TrimSpace(a), strings.TrimSpace(t)
+	}
+	return "", strings.TrimSpace(name)
+}
@@ -4,11 +4,13 @@ import (
 	"context"
 	"fmt"
 	"strings"
+	"unicode"
 
 	"github.com/anastreamer/ana/internal/config"
 	"github.com/anastreamer/ana/internal/llm"
 	"github.com/anastreamer/ana/pkg/logger"
 	"github.com/andreykaipov/goobs"
+	"github.com/andreykaipov/goobs/api/requests/inputs"
 	"github.com/andreykaipov/goobs/api/requests/scenes"
 	"github.com/andreykaipov/goobs/api/requests/stream"
 	"github.com/rs/zerolog"
@@ -80,6 +82,8 @@ func (e *OBSExecutor) SupportedActions() []string {
 		"obs.start_streaming",
 		"obs.stop_streaming",
 		"obs.scene",
+		"obs.mute",
+		"obs.unmute",
 	}
 }
 
@@ -107,6 +111,10 @@ func (e *OBSExecutor) Execute(ctx context.Context, action llm.Action) (Result, e
 		return e.stopStreaming(ctx)
 	case "obs.scene":
 		return e.switchScene(ctx, action)
+	case "obs.mute":
+		return e.setMute(ctx, action, true)
+	case "obs.unmute":
+		return e.setMute(ctx, action, false)
 	default:
 		return NewErrorResult(fmt.Errorf("unknown action: %s", action.Action)), nil
 	}
@@ -240,20 +248,7 @@ func (e *OBSExecutor) switchScene(ctx context.Context, action llm.Action) (Resul
 	}
 	e.log.Debug().Strs("available_scenes", sceneNames).Msg("Available scenes in OBS")
 
-	// Try to find exact match first, then case-insensitive match
-	var matchedScene string
-	requestedLower := strings.ToLower(requestedScene)
-
-	for _, scene := range sceneList.Scenes {
-		sceneName := scene.SceneName
-		if sceneName == requestedScene {
-			matchedScene = sceneName
-			break
-		}
-		if strings.ToLower(sceneName) == requestedLower {
-			matchedScene = sceneName
-		}
-	}
+	matchedScene := matchScene(requestedScene, sceneNames)
 
 	if matchedScene == "" {
 		e.log.Error().
@@ -277,6 +272,170 @@ func (e *OBSExecutor) switchScene(ctx context.Context, action llm.Action) (Resul
 	return NewResult(fmt.Sprintf("Cambiando a escena %s", matchedScene)), nil
 }
 
+// matchScene returns the scene of names that requested refers to: the one
+// with that exact name, or else the one that matches it ignoring case,
+// accents and punctuation, as spoken names come. Empty if none does.
+func matchScene(requested string, names []string) string {
+	var matched string
+	folded := foldName(requested)
+	for _, name := range names {
+		if name == requested {
+			return name
+		}
+		if matched == "" && foldName(name) == folded {
+			matched = name
+		}
+	}
+	return matched
+}
+
+// foldName lowercases name, drops its accents and keeps only its words
+func foldName(name string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(name) {
+		switch r {
+		case 'á', 'à', 'ä', 'â':
+			r = 'a'
+		case 'é', 'è', 'ë', 'ê':
+			r = 'e'
+		case 'í', 'ì', 'ï', 'î':
+			r = 'i'
+		case 'ó', 'ò', 'ö', 'ô':
+			r = 'o'
+		case 'ú', 'ù', 'ü', 'û':
+			r = 'u'
+		case 'ñ':
+			r = 'n'
+		}
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			r = ' '
+		}
+		b.WriteRune(r)
+	}
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+// setMute mutes or unmutes the input in the "source" param, or the
+// configured microphone (obs.mic_input) without one
+func (e *OBSExecutor) setMute(ctx context.Context, action llm.Action, muted bool) (Result, error) {
+	source, _ := action.Params["source"].(string)
+	if source == "" {
+		source = e.cfg.MicInput
+	}
+	if source == "" {
+		err := fmt.Errorf("source name is required")
+		return NewErrorResult(err), err
+	}
+
+	e.log.Info().Str("source", source).Bool("muted", muted).Msg("Setting input mute")
+
+	params := inputs.NewSetInputMuteParams().
+		WithInputName(source).
+		WithInputMuted(muted)
+	if _, err := e.client.Inputs.SetInputMute(params); err != nil {
+		e.log.Error().Err(err).Str("source", source).Msg("Failed to set input mute")
+		return NewErrorResult(err), err
+	}
+
+	if muted {
+		return NewResult(fmt.Sprintf("%s silenciado", source)), nil
+	}
+	return NewResult(fmt.Sprintf("%s activado", source)), nil
+}
+
+// SetText updates the text of an OBS text source (GDI+/FreeType)
+func (e *OBSExecutor) SetText(ctx context.Context, source, text string) error {
+	if !e.IsAvailable() {
+		return fmt.Errorf("OBS not connected")
+	}
+
+	params := inputs.NewSetInputSettingsParams().
+		WithInputName(source).
+		WithInputSettings(map[string]interface{}{"text": text})
+	if _, err := e.client.Inputs.SetInputSettings(params); err != nil {
+		return fmt.Errorf("failed to set text on %s: %w", source, err)
+	}
+	return nil
+}
+
+// GetInputVolumeDB returns the volume of an OBS input in dB
+func (e *OBSExecutor) GetInputVolumeDB(ctx context.Context, input string) (float64, error) {
+	if !e.IsAvailable() {
+		return 0, fmt.Errorf("OBS not connected")
+	}
+
+	resp, err := e.client.Inputs.GetInputVolume(inputs.NewGetInputVolumeParams().WithInputName(input))
+	if err != nil {
+		return 0, fmt.Errorf("failed to get volume of %s: %w", input, err)
+	}
+	return resp.InputVolumeDb, nil
+}
+
+// SetInputVolumeDB sets the volume of an OBS input in dB
+func (e *OBSExecutor) SetInputVolumeDB(ctx context.Context, input string, db float64) error {
+	if !e.IsAvailable() {
+		return fmt.Errorf("OBS not connected")
+	}
+
+	params := inputs.NewSetInputVolumeParams().
+		WithInputName(input).
+		WithInputVolumeDb(db)
+	if _, err := e.client.Inputs.SetInputVolume(params); err != nil {
+		return fmt.Errorf("failed to set volume of %s: %w", input, err)
+	}
+	return nil
+}
+
+// SceneNames returns the names of the OBS scenes
+func (e *OBSExecutor) SceneNames(ctx context.Context) ([]string, error) {
+	if !e.IsAvailable() {
+		return nil, fmt.Errorf("OBS not connected")
+	}
+
+	resp, err := e.client.Scenes.GetSceneList()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get scene list: %w", err)
+	}
+	var names []string
+	for _, scene := range resp.Scenes {
+		names = append(names, scene.SceneName)
+	}
+	return names, nil
+}
+
+// CurrentScene returns the name of the scene on program
+func (e *OBSExecutor) CurrentScene(ctx context.Context) (string, error) {
+	if !e.IsAvailable() {
+		return "", fmt.Errorf("OBS not connected")
+	}
+
+	resp, err := e.client.Scenes.GetCurrentProgramScene()
+	if err != nil {
+		return "", fmt.Errorf("failed to get current scene: %w", err)
+	}
+	if resp.SceneName != "" {
+		return resp.SceneName, nil
+	}
+	return resp.CurrentProgramSceneName, nil // OBS before 30
+}
+
+// InputNames returns the names of the OBS inputs (sources)
+func (e *OBSExecutor) InputNames(ctx context.Context) ([]string, error) {
+	if !e.IsAvailable() {
+		return nil, fmt.Errorf("OBS not connected")
+	}
+
+	resp, err := e.client.Inputs.GetInputList()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get input list: %w", err)
+	}
+	var names []string
+	for _, input := range resp.Inputs {
+		names = append(names, input.InputName)
+	}
+	return names, nil
+}
+
 // IsAvailable checks if OBS is connected and ready
 func (e *OBSExecutor) IsAvailable() bool {
 	return e.cfg.Enabled && e.client != nil
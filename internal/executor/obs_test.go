@@ -0,0 +1,29 @@
+package executor
+
+import "testing"
+
+func TestMatchScene(t *testing.T) {
+	scenes := []string{"Inicio", "Juego", "juego", "Cámara Completa", "Just Chatting", "BRB - Pausa"}
+
+	tests := []struct {
+		requested string
+		want      string
+	}{
+		{"Juego", "Juego"},
+		{"juego", "juego"},
+		{"JUEGO", "Juego"},
+		{"inicio", "Inicio"},
+		{"camara completa", "Cámara Completa"},
+		{"Cámara completa.", "Cámara Completa"},
+		{"just  chatting", "Just Chatting"},
+		{"brb pausa", "BRB - Pausa"},
+		{"final", ""},
+		{"cámara", ""},
+	}
+
+	for _, tt := range tests {
+		if got := matchScene(tt.requested, scenes); got != tt.want {
+			t.Errorf("matchScene(%q) = %q, want %q", tt.requested, got, tt.want)
+		}
+	}
+}
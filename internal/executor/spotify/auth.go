@@ -0,0 +1,261 @@
+package spotify
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// scopes are the permissions Ana needs to control playback and playlists
+var scopes = []string{
+	"user-read-playback-state",
+	"user-modify-playback-state",
+	"user-read-currently-playing",
+	"playlist-read-private",
+	"playlist-modify-public",
+	"playlist-modify-private",
+}
+
+// Token holds the OAuth tokens for the Spotify account
+type Token struct {
+	AccessToken  string    `json:"access_token"`
+	RefreshToken string    `json:"refresh_token"`
+	ExpiresAt    time.Time `json:"expires_at"`
+}
+
+// Expired returns true if the access token is missing or about to expire
+func (t *Token) Expired() bool {
+	return t.AccessToken == "" || time.Now().Add(30*time.Second).After(t.ExpiresAt)
+}
+
+// TokenStore persists tokens to a JSON file so the login survives restarts
+type TokenStore struct {
+	path string
+}
+
+// NewTokenStore creates a token store backed by the given file
+func NewTokenStore(path string) *TokenStore {
+	return &TokenStore{path: path}
+}
+
+// Load reads the stored token
+func (s *TokenStore) Load() (*Token, error) {
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return nil, err
+	}
+
+	var token Token
+	if err := json.Unmarshal(data, &token); err != nil {
+		return nil, fmt.Errorf("invalid token file %s: %w", s.path, err)
+	}
+	return &token, nil
+}
+
+// Save writes the token, readable only by the current user
+func (s *TokenStore) Save(token *Token) error {
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return fmt.Errorf("failed to create token directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(token, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(s.path, data, 0600)
+}
+
+// Authenticator implements the OAuth authorization code flow with PKCE,
+// which needs no client secret
+type Authenticator struct {
+	clientID    string
+	redirectURI string
+	authURL     string
+	client      *http.Client
+}
+
+// NewAuthenticator creates an authenticator against the given accounts URL
+func NewAuthenticator(clientID, redirectURI, authURL string, client *http.Client) *Authenticator {
+	return &Authenticator{
+		clientID:    clientID,
+		redirectURI: redirectURI,
+		authURL:     strings.TrimSuffix(authURL, "/"),
+		client:      client,
+	}
+}
+
+// AuthCodeURL returns the URL the user opens to grant access
+func (a *Authenticator) AuthCodeURL(state, challenge string) string {
+	params := url.Values{}
+	params.Set("client_id", a.clientID)
+	params.Set("response_type", "code")
+	params.Set("redirect_uri", a.redirectURI)
+	params.Set("code_challenge_method", "S256")
+	params.Set("code_challenge", challenge)
+	params.Set("scope", strings.Join(scopes, " "))
+	params.Set("state", state)
+
+	return a.authURL + "/authorize?" + params.Encode()
+}
+
+// Exchange trades an authorization code for tokens
+func (a *Authenticator) Exchange(ctx context.Context, code, verifier string) (*Token, error) {
+	data := url.Values{}
+	data.Set("grant_type", "authorization_code")
+	data.Set("code", code)
+	data.Set("redirect_uri", a.redirectURI)
+	data.Set("client_id", a.clientID)
+	data.Set("code_verifier", verifier)
+
+	return a.requestToken(ctx, data, "")
+}
+
+// Refresh obtains a new access token. Spotify may rotate the refresh token;
+// if it doesn't, the old one is kept.
+func (a *Authenticator) Refresh(ctx context.Context, refreshToken string) (*Token, error) {
+	if refreshToken == "" {
+		return nil, fmt.Errorf("no refresh token available")
+	}
+
+	data := url.Values{}
+	data.Set("grant_type", "refresh_token")
+	data.Set("refresh_token", refreshToken)
+	data.Set("client_id", a.clientID)
+
+	return a.requestToken(ctx, data, refreshToken)
+}
+
+// requestToken posts to the token endpoint
+func (a *Authenticator) requestToken(ctx context.Context, data url.Values, refreshToken string) (*Token, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", a.authURL+"/api/token", strings.NewReader(data.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("token request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("token request failed: %d %s", resp.StatusCode, string(body))
+	}
+
+	var result struct {
+		AccessToken  string `json:"access_token"`
+		RefreshToken string `json:"refresh_token"`
+		ExpiresIn    int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode token: %w", err)
+	}
+
+	token := &Token{
+		AccessToken:  result.AccessToken,
+		RefreshToken: result.RefreshToken,
+		ExpiresAt:    time.Now().Add(time.Duration(result.ExpiresIn) * time.Second),
+	}
+	if token.RefreshToken == "" {
+		token.RefreshToken = refreshToken
+	}
+	return token, nil
+}
+
+// Login runs the interactive PKCE flow: it serves the redirect URI locally,
+// shows the authorization URL through showURL and waits for the callback
+func (a *Authenticator) Login(ctx context.Context, showURL func(string)) (*Token, error) {
+	redirect, err := url.Parse(a.redirectURI)
+	if err != nil {
+		return nil, fmt.Errorf("invalid redirect_uri: %w", err)
+	}
+
+	verifier, err := randomString(64)
+	if err != nil {
+		return nil, err
+	}
+	state, err := randomString(16)
+	if err != nil {
+		return nil, err
+	}
+
+	listener, err := net.Listen("tcp", redirect.Host)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", redirect.Host, err)
+	}
+
+	type callback struct {
+		code string
+		err  error
+	}
+	results := make(chan callback, 1)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(redirect.Path, func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		var cb callback
+		switch {
+		case q.Get("state") != state:
+			cb.err = fmt.Errorf("state mismatch in callback")
+		case q.Get("error") != "":
+			cb.err = fmt.Errorf("authorization denied: %s", q.Get("error"))
+		default:
+			cb.code = q.Get("code")
+		}
+
+		if cb.err != nil {
+			http.Error(w, cb.err.Error(), http.StatusBadRequest)
+		} else {
+			fmt.Fprintln(w, "Ana está conectada a Spotify. Ya puedes cerrar esta ventana.")
+		}
+
+		select {
+		case results <- cb:
+		default:
+		}
+	})
+
+	server := &http.Server{Handler: mux}
+	go server.Serve(listener)
+	defer server.Close()
+
+	showURL(a.AuthCodeURL(state, challengeS256(verifier)))
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case cb := <-results:
+		if cb.err != nil {
+			return nil, cb.err
+		}
+		return a.Exchange(ctx, cb.code, verifier)
+	}
+}
+
+// challengeS256 derives the PKCE code challenge from a verifier
+func challengeS256(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// randomString returns a URL-safe random string of n bytes of entropy
+func randomString(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate random string: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
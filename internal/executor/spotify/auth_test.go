@@ -0,0 +1,181 @@
+package spotify
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestChallengeS256(t *testing.T) {
+	// Example from RFC 7636, appendix B
+	if got := challengeS256("dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"); got != "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM" {
+		t.Errorf("challengeS256() = %q", got)
+	}
+
+	a, _ := randomString(64)
+	b, _ := randomString(64)
+	if a == b || len(a) < 43 || len(a) > 128 {
+		t.Errorf("randomString(64) = %q, %q, want distinct verifiers of 43 to 128 characters", a, b)
+	}
+}
+
+// freeRedirectURI returns a local redirect URI on a free port
+func freeRedirectURI(t *testing.T) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	return "http://" + l.Addr().String() + "/callback"
+}
+
+func TestLogin(t *testing.T) {
+	s := newFakeSpotify(t)
+	redirectURI := freeRedirectURI(t)
+	auth := NewAuthenticator("client", redirectURI, s.URL+"/", s.Client())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	token, err := auth.Login(ctx, func(authURL string) {
+		// Act as the browser: check the request and follow the redirect
+		u, err := url.Parse(authURL)
+		if err != nil {
+			t.Errorf("invalid authorization URL %q", authURL)
+			return
+		}
+		q := u.Query()
+		if u.Path != "/authorize" || q.Get("client_id") != "client" || q.Get("response_type") != "code" ||
+			q.Get("redirect_uri") != redirectURI || q.Get("code_challenge_method") != "S256" {
+			t.Errorf("authorization URL = %s", authURL)
+		}
+		if !strings.Contains(q.Get("scope"), "playlist-modify-private") {
+			t.Errorf("scope = %q", q.Get("scope"))
+		}
+		s.set(func(s *fakeSpotify) { s.challenge = q.Get("code_challenge") })
+
+		resp, err := http.Get(redirectURI + "?code=the-code&state=" + url.QueryEscape(q.Get("state")))
+		if err != nil {
+			t.Errorf("callback: %v", err)
+			return
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("callback status = %d", resp.StatusCode)
+		}
+	})
+	if err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+
+	// The token endpoint checked the verifier against the challenge
+	if token.AccessToken != "access-1" || token.RefreshToken != "refresh-login" || token.Expired() {
+		t.Errorf("Login() = %+v", token)
+	}
+}
+
+func TestLoginCallbackErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		query func(state string) string
+		err   string
+	}{
+		{"state mismatch", func(state string) string { return "code=the-code&state=other" }, "state mismatch"},
+		{"denied", func(state string) string { return "error=access_denied&state=" + url.QueryEscape(state) }, "access_denied"},
+		{"wrong code", func(state string) string { return "code=stolen&state=" + url.QueryEscape(state) }, "token request failed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newFakeSpotify(t)
+			redirectURI := freeRedirectURI(t)
+			auth := NewAuthenticator("client", redirectURI, s.URL, s.Client())
+
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+
+			_, err := auth.Login(ctx, func(authURL string) {
+				u, _ := url.Parse(authURL)
+				s.set(func(s *fakeSpotify) { s.challenge = u.Query().Get("code_challenge") })
+				if resp, err := http.Get(redirectURI + "?" + tt.query(u.Query().Get("state"))); err == nil {
+					resp.Body.Close()
+				}
+			})
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("Login() error = %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestLoginCanceled(t *testing.T) {
+	s := newFakeSpotify(t)
+	auth := NewAuthenticator("client", freeRedirectURI(t), s.URL, s.Client())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	// The user never opens the URL
+	_, err := auth.Login(ctx, func(string) { cancel() })
+	if err != context.Canceled {
+		t.Errorf("Login() error = %v, want context.Canceled", err)
+	}
+}
+
+func TestRefresh(t *testing.T) {
+	s := newFakeSpotify(t)
+	auth := NewAuthenticator("client", "http://127.0.0.1:8888/callback", s.URL, s.Client())
+	ctx := context.Background()
+
+	// Without a new refresh token the old one is kept
+	token, err := auth.Refresh(ctx, "refresh-0")
+	if err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+	if token.AccessToken != "access-1" || token.RefreshToken != "refresh-0" || token.Expired() {
+		t.Errorf("Refresh() = %+v, want the old refresh token", token)
+	}
+
+	// A rotated one replaces it
+	s.set(func(s *fakeSpotify) { s.rotate = true })
+	if token, err = auth.Refresh(ctx, "refresh-0"); err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+	if token.AccessToken != "access-2" || token.RefreshToken != "refresh-2" {
+		t.Errorf("Refresh() = %+v, want the rotated refresh token", token)
+	}
+
+	if _, err := auth.Refresh(ctx, "refresh-0"); err == nil {
+		t.Error("Refresh() with a rotated-out token succeeded")
+	}
+	if _, err := auth.Refresh(ctx, ""); err == nil {
+		t.Error("Refresh() without a refresh token succeeded")
+	}
+}
+
+func TestTokenStore(t *testing.T) {
+	store := NewTokenStore(filepath.Join(t.TempDir(), "nested", "token.json"))
+	if _, err := store.Load(); err == nil {
+		t.Error("Load() without a file succeeded")
+	}
+
+	want := &Token{AccessToken: "a", RefreshToken: "r", ExpiresAt: time.Now().Add(time.Hour).Round(time.Second)}
+	if err := store.Save(want); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	got, err := store.Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if got.AccessToken != want.AccessToken || got.RefreshToken != want.RefreshToken || !got.ExpiresAt.Equal(want.ExpiresAt) {
+		t.Errorf("Load() = %+v, want %+v", got, want)
+	}
+
+	if !(&Token{RefreshToken: "r", ExpiresAt: time.Now().Add(time.Hour)}).Expired() {
+		t.Error("a token without an access token is not expired")
+	}
+}
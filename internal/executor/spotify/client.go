@@ -0,0 +1,375 @@
+package spotify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/rs/zerolog"
+)
+
+// errNotLoggedIn is returned when there is no stored token
+var errNotLoggedIn = errors.New("not logged in to Spotify (run 'ana spotify login')")
+
+// APIError is an error returned by the Web API
+type APIError struct {
+	Status  int
+	Reason  string
+	Message string
+}
+
+func (e *APIError) Error() string {
+	if e.Reason != "" {
+		return fmt.Sprintf("Spotify API error %d (%s): %s", e.Status, e.Reason, e.Message)
+	}
+	return fmt.Sprintf("Spotify API error %d: %s", e.Status, e.Message)
+}
+
+// Item is a track, album, artist or playlist returned by the API
+type Item struct {
+	ID      string `json:"id"`
+	URI     string `json:"uri"`
+	Name    string `json:"name"`
+	Artists []struct {
+		Name string `json:"name"`
+	} `json:"artists,omitempty"`
+	Album *struct {
+		Name string `json:"name"`
+	} `json:"album,omitempty"`
+}
+
+// ArtistNames returns the artists joined by commas
+func (i Item) ArtistNames() string {
+	names := make([]string, 0, len(i.Artists))
+	for _, a := range i.Artists {
+		names = append(names, a.Name)
+	}
+	return strings.Join(names, ", ")
+}
+
+// PlaybackState is the currently playing item
+type PlaybackState struct {
+	IsPlaying bool  `json:"is_playing"`
+	Item      *Item `json:"item"`
+}
+
+// Device is a Spotify Connect device
+type Device struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	IsActive bool   `json:"is_active"`
+}
+
+// Client is a minimal Spotify Web API client that refreshes and persists tokens
+type Client struct {
+	baseURL string
+	auth    *Authenticator
+	store   *TokenStore
+	client  *http.Client
+	log     zerolog.Logger
+
+	mu    sync.Mutex
+	token *Token
+}
+
+// NewClient creates a Web API client. baseURL is configurable so a local
+// fake of the API can be used.
+func NewClient(baseURL string, auth *Authenticator, store *TokenStore, client *http.Client, log zerolog.Logger) *Client {
+	c := &Client{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		auth:    auth,
+		store:   store,
+		client:  client,
+		log:     log,
+	}
+
+	if token, err := store.Load(); err == nil {
+		c.token = token
+	}
+
+	return c
+}
+
+// LoggedIn returns true if a refresh token is available
+func (c *Client) LoggedIn() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.token != nil && c.token.RefreshToken != ""
+}
+
+// SetToken stores a new token (e.g. after login)
+func (c *Client) SetToken(token *Token) error {
+	c.mu.Lock()
+	c.token = token
+	c.mu.Unlock()
+	return c.store.Save(token)
+}
+
+// accessToken returns a valid access token, refreshing it when needed
+func (c *Client) accessToken(ctx context.Context, force bool) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.token == nil {
+		return "", errNotLoggedIn
+	}
+	if !force && !c.token.Expired() {
+		return c.token.AccessToken, nil
+	}
+
+	token, err := c.auth.Refresh(ctx, c.token.RefreshToken)
+	if err != nil {
+		return "", err
+	}
+	c.token = token
+
+	if err := c.store.Save(token); err != nil {
+		c.log.Warn().Err(err).Msg("Failed to persist Spotify token")
+	}
+	c.log.Info().Msg("Access token refreshed")
+	return token.AccessToken, nil
+}
+
+// do makes an authenticated request and decodes the JSON response into out
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body, out interface{}) error {
+	token, err := c.accessToken(ctx, false)
+	if err != nil {
+		return err
+	}
+
+	status, respBody, err := c.send(ctx, method, path, query, body, token)
+	if err != nil {
+		return err
+	}
+
+	if status == http.StatusUnauthorized {
+		// Token revoked or expired early, refresh once and retry
+		if token, err = c.accessToken(ctx, true); err != nil {
+			return fmt.Errorf("unauthorized and failed to refresh token: %w", err)
+		}
+		if status, respBody, err = c.send(ctx, method, path, query, body, token); err != nil {
+			return err
+		}
+	}
+
+	if status >= 400 {
+		return parseAPIError(status, respBody)
+	}
+
+	if out != nil && len(respBody) > 0 {
+		if err := json.Unmarshal(respBody, out); err != nil {
+			return fmt.Errorf("failed to decode response: %w", err)
+		}
+	}
+	return nil
+}
+
+// send performs a single request
+func (c *Client) send(ctx context.Context, method, path string, query url.Values, body interface{}, token string) (int, []byte, error) {
+	reqURL := c.baseURL + path
+	if len(query) > 0 {
+		reqURL += "?" + query.Encode()
+	}
+
+	var reader io.Reader
+	if body != nil {
+		jsonBody, err := json.Marshal(body)
+		if err != nil {
+			return 0, nil, err
+		}
+		reader = bytes.NewReader(jsonBody)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, reqURL, reader)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return 0, nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	return resp.StatusCode, respBody, nil
+}
+
+// parseAPIError decodes the Web API error object
+func parseAPIError(status int, body []byte) error {
+	var result struct {
+		Error struct {
+			Message string `json:"message"`
+			Reason  string `json:"reason"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil || result.Error.Message == "" {
+		return &APIError{Status: status, Message: strings.TrimSpace(string(body))}
+	}
+	return &APIError{Status: status, Reason: result.Error.Reason, Message: result.Error.Message}
+}
+
+// Search returns the first result of the given type (track, album, artist, playlist)
+func (c *Client) Search(ctx context.Context, query, kind string) (*Item, error) {
+	params := url.Values{}
+	params.Set("q", query)
+	params.Set("type", kind)
+	params.Set("limit", "1")
+
+	var result map[string]struct {
+		Items []*Item `json:"items"`
+	}
+	if err := c.do(ctx, "GET", "/search", params, nil, &result); err != nil {
+		return nil, err
+	}
+
+	page := result[kind+"s"]
+	for _, item := range page.Items {
+		if item != nil {
+			return item, nil
+		}
+	}
+	return nil, fmt.Errorf("nothing found on Spotify for: %s", query)
+}
+
+// Play starts playback of track URIs or a context (album, artist, playlist).
+// With neither it resumes the current playback.
+func (c *Client) Play(ctx context.Context, deviceID string, uris []string, contextURI string) error {
+	body := map[string]interface{}{}
+	if len(uris) > 0 {
+		body["uris"] = uris
+	}
+	if contextURI != "" {
+		body["context_uri"] = contextURI
+	}
+
+	var payload interface{}
+	if len(body) > 0 {
+		payload = body
+	}
+	return c.do(ctx, "PUT", "/me/player/play", deviceQuery(deviceID), payload, nil)
+}
+
+// Pause pauses playback
+func (c *Client) Pause(ctx context.Context, deviceID string) error {
+	return c.do(ctx, "PUT", "/me/player/pause", deviceQuery(deviceID), nil, nil)
+}
+
+// Next skips to the next track
+func (c *Client) Next(ctx context.Context, deviceID string) error {
+	return c.do(ctx, "POST", "/me/player/next", deviceQuery(deviceID), nil, nil)
+}
+
+// Previous goes back to the previous track
+func (c *Client) Previous(ctx context.Context, deviceID string) error {
+	return c.do(ctx, "POST", "/me/player/previous", deviceQuery(deviceID), nil, nil)
+}
+
+// SetVolume sets the volume in percent (0-100)
+func (c *Client) SetVolume(ctx context.Context, deviceID string, percent int) error {
+	params := deviceQuery(deviceID)
+	params.Set("volume_percent", fmt.Sprintf("%d", percent))
+	return c.do(ctx, "PUT", "/me/player/volume", params, nil, nil)
+}
+
+// Queue adds a track to the playback queue
+func (c *Client) Queue(ctx context.Context, deviceID, uri string) error {
+	params := deviceQuery(deviceID)
+	params.Set("uri", uri)
+	return c.do(ctx, "POST", "/me/player/queue", params, nil, nil)
+}
+
+// CurrentlyPlaying returns the current playback, or nil if nothing is playing
+func (c *Client) CurrentlyPlaying(ctx context.Context) (*PlaybackState, error) {
+	var state PlaybackState
+	if err := c.do(ctx, "GET", "/me/player/currently-playing", nil, nil, &state); err != nil {
+		return nil, err
+	}
+	if state.Item == nil {
+		return nil, nil
+	}
+	return &state, nil
+}
+
+// Devices lists the available Spotify Connect devices
+func (c *Client) Devices(ctx context.Context) ([]Device, error) {
+	var result struct {
+		Devices []Device `json:"devices"`
+	}
+	if err := c.do(ctx, "GET", "/me/player/devices", nil, nil, &result); err != nil {
+		return nil, err
+	}
+	return result.Devices, nil
+}
+
+// FindPlaylist finds one of the user's playlists by name (case-insensitive,
+// exact match preferred over substring)
+func (c *Client) FindPlaylist(ctx context.Context, name string) (*Item, error) {
+	lower := strings.ToLower(strings.TrimSpace(name))
+	var partial *Item
+
+	params := url.Values{}
+	params.Set("limit", "50")
+	for offset := 0; ; offset += 50 {
+		params.Set("offset", fmt.Sprintf("%d", offset))
+
+		var page struct {
+			Items []*Item `json:"items"`
+			Next  string  `json:"next"`
+		}
+		if err := c.do(ctx, "GET", "/me/playlists", params, nil, &page); err != nil {
+			return nil, err
+		}
+
+		for _, p := range page.Items {
+			if p == nil {
+				continue
+			}
+			pl := strings.ToLower(p.Name)
+			if pl == lower {
+				return p, nil
+			}
+			if partial == nil && strings.Contains(pl, lower) {
+				partial = p
+			}
+		}
+
+		if page.Next == "" || len(page.Items) == 0 {
+			break
+		}
+	}
+
+	if partial == nil {
+		return nil, fmt.Errorf("playlist not found: %s", name)
+	}
+	return partial, nil
+}
+
+// AddToPlaylist adds tracks to a playlist
+func (c *Client) AddToPlaylist(ctx context.Context, playlistID string, uris []string) error {
+	body := map[string]interface{}{"uris": uris}
+	return c.do(ctx, "POST", "/playlists/"+url.PathEscape(playlistID)+"/tracks", nil, body, nil)
+}
+
+// deviceQuery returns query params targeting a device, if any
+func deviceQuery(deviceID string) url.Values {
+	params := url.Values{}
+	if deviceID != "" {
+		params.Set("device_id", deviceID)
+	}
+	return params
+}
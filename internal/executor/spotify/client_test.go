@@ -0,0 +1,325 @@
+package spotify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// fakeSpotify serves the accounts token endpoint and the parts of the Web
+// API Ana uses, under /v1
+type fakeSpotify struct {
+	*httptest.Server
+
+	mu        sync.Mutex
+	access    string // the only access token the API accepts
+	refresh   string // the only refresh token the token endpoint accepts
+	rotate    bool   // issue a new refresh token on every refresh
+	issued    int
+	refreshes int
+	challenge string // PKCE challenge the code was issued for
+	revoked   bool   // reject every access token
+	noDevice  bool   // answer playback commands without a device with 404
+	playing   *PlaybackState
+	requests  []string
+}
+
+func newFakeSpotify(t *testing.T) *fakeSpotify {
+	t.Helper()
+	s := &fakeSpotify{access: "access-0", refresh: "refresh-0"}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/token", s.token)
+	mux.HandleFunc("/v1/search", s.api(s.search))
+	for _, command := range []string{"play", "pause", "next", "previous", "volume", "queue"} {
+		mux.HandleFunc("/v1/me/player/"+command, s.api(s.playback))
+	}
+	mux.HandleFunc("/v1/me/player/devices", s.api(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"devices": [{"id": "dev-phone", "name": "Móvil"}, {"id": "dev-pc", "name": "Estudio PC"}]}`)
+	}))
+	mux.HandleFunc("/v1/me/player/currently-playing", s.api(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.playing == nil {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		json.NewEncoder(w).Encode(s.playing)
+	}))
+	mux.HandleFunc("/v1/me/playlists", s.api(s.playlists))
+	mux.HandleFunc("/v1/playlists/", s.api(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprint(w, `{"snapshot_id": "snap"}`)
+	}))
+
+	s.Server = httptest.NewServer(mux)
+	t.Cleanup(s.Close)
+	return s
+}
+
+// token implements the authorization code (with PKCE) and refresh grants
+func (s *fakeSpotify) token(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if r.PostForm.Get("client_id") != "client" {
+		http.Error(w, `{"error": "invalid_client"}`, http.StatusBadRequest)
+		return
+	}
+
+	switch r.PostForm.Get("grant_type") {
+	case "authorization_code":
+		if r.PostForm.Get("code") != "the-code" || challengeS256(r.PostForm.Get("code_verifier")) != s.challenge {
+			http.Error(w, `{"error": "invalid_grant"}`, http.StatusBadRequest)
+			return
+		}
+		s.refresh = "refresh-login"
+	case "refresh_token":
+		if r.PostForm.Get("refresh_token") != s.refresh {
+			http.Error(w, `{"error": "invalid_grant"}`, http.StatusBadRequest)
+			return
+		}
+		s.refreshes++
+	default:
+		http.Error(w, `{"error": "unsupported_grant_type"}`, http.StatusBadRequest)
+		return
+	}
+
+	s.issued++
+	s.access = fmt.Sprintf("access-%d", s.issued)
+	resp := map[string]interface{}{"access_token": s.access, "expires_in": 3600}
+	if r.PostForm.Get("grant_type") == "authorization_code" || s.rotate {
+		if s.rotate {
+			s.refresh = fmt.Sprintf("refresh-%d", s.issued)
+		}
+		resp["refresh_token"] = s.refresh
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+// api checks the access token and records the request before handling it
+func (s *fakeSpotify) api(handle http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		s.mu.Lock()
+		authorized := !s.revoked && r.Header.Get("Authorization") == "Bearer "+s.access
+		if authorized {
+			request := r.Method + " " + strings.TrimPrefix(r.URL.Path, "/v1")
+			if r.URL.RawQuery != "" {
+				request += "?" + r.URL.RawQuery
+			}
+			if len(body) > 0 {
+				request += " " + string(body)
+			}
+			s.requests = append(s.requests, request)
+		}
+		s.mu.Unlock()
+
+		if !authorized {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"error": {"status": 401, "message": "The access token expired"}}`)
+			return
+		}
+		handle(w, r)
+	}
+}
+
+func (s *fakeSpotify) search(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	kind := q.Get("type")
+	if q.Get("q") == "nada" {
+		fmt.Fprintf(w, `{"%ss": {"items": []}}`, kind)
+		return
+	}
+	fmt.Fprintf(w, `{"%ss": {"items": [null, {"id": "1", "uri": "spotify:%s:1", "name": "%s", "artists": [{"name": "Rosalía"}, {"name": "Tokischa"}]}]}}`,
+		kind, kind, q.Get("q"))
+}
+
+// playback answers playback commands like Spotify does when no
+// device is active
+func (s *fakeSpotify) playback(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	noDevice := s.noDevice
+	s.mu.Unlock()
+
+	if noDevice && r.URL.Query().Get("device_id") == "" {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"error": {"status": 404, "message": "Player command failed: No active device found", "reason": "NO_ACTIVE_DEVICE"}}`)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// playlists serves two pages: a partial match on the first, the exact
+// name on the second
+func (s *fakeSpotify) playlists(w http.ResponseWriter, r *http.Request) {
+	var items []string
+	next := ""
+	if r.URL.Query().Get("offset") == "0" {
+		for i := 0; i < 49; i++ {
+			items = append(items, fmt.Sprintf(`{"id": "pl-%d", "uri": "spotify:playlist:pl-%d", "name": "Lista %d"}`, i, i, i))
+		}
+		items = append(items, `{"id": "pl-old", "uri": "spotify:playlist:pl-old", "name": "Favoritas de 2019"}`)
+		next = s.URL + "/v1/me/playlists?offset=50&limit=50"
+	} else {
+		items = append(items, `{"id": "pl-fav", "uri": "spotify:playlist:pl-fav", "name": "FAVORITAS"}`)
+	}
+	fmt.Fprintf(w, `{"items": [%s], "next": %q}`, strings.Join(items, ", "), next)
+}
+
+// set changes the server's state
+func (s *fakeSpotify) set(change func(s *fakeSpotify)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	change(s)
+}
+
+// refreshCount returns how many times a token was refreshed
+func (s *fakeSpotify) refreshCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.refreshes
+}
+
+// takeRequests returns the API requests recorded since the last call
+func (s *fakeSpotify) takeRequests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	requests := s.requests
+	s.requests = nil
+	return requests
+}
+
+// newTestClient returns a client against s with token stored
+func newTestClient(t *testing.T, s *fakeSpotify, token *Token) (*Client, *TokenStore) {
+	t.Helper()
+	store := NewTokenStore(filepath.Join(t.TempDir(), "spotify", "token.json"))
+	if err := store.Save(token); err != nil {
+		t.Fatal(err)
+	}
+	auth := NewAuthenticator("client", "http://127.0.0.1:8888/callback", s.URL, s.Client())
+	return NewClient(s.URL+"/v1/", auth, store, s.Client(), zerolog.Nop()), store
+}
+
+func TestClientRefreshesExpiredToken(t *testing.T) {
+	s := newFakeSpotify(t)
+	c, store := newTestClient(t, s, &Token{AccessToken: "access-0", RefreshToken: "refresh-0", ExpiresAt: time.Now().Add(10 * time.Second)})
+
+	item, err := c.Search(context.Background(), "Despechá", "track")
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if item.URI != "spotify:track:1" || describe(item) != "Despechá - Rosalía, Tokischa" {
+		t.Errorf("Search() = %+v", item)
+	}
+
+	// Refreshed before the request, since it was about to expire
+	if s.refreshCount() != 1 {
+		t.Errorf("refreshed %d times, want once", s.refreshCount())
+	}
+	saved, err := store.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved.AccessToken != "access-1" || saved.RefreshToken != "refresh-0" || saved.Expired() {
+		t.Errorf("stored token = %+v, want the refreshed one", saved)
+	}
+
+	// Still valid, so no refresh
+	if _, err := c.Search(context.Background(), "Despechá", "track"); err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if s.refreshCount() != 1 {
+		t.Errorf("refreshed %d times with a valid token, want once", s.refreshCount())
+	}
+}
+
+func TestClientRetriesAfterUnauthorized(t *testing.T) {
+	s := newFakeSpotify(t)
+	// The stored token looks valid but the server no longer accepts it
+	s.set(func(s *fakeSpotify) {
+		s.rotate = true
+		s.access = "access-revoked"
+	})
+	c, store := newTestClient(t, s, &Token{AccessToken: "access-0", RefreshToken: "refresh-0", ExpiresAt: time.Now().Add(time.Hour)})
+
+	if _, err := c.Devices(context.Background()); err != nil {
+		t.Fatalf("Devices: %v", err)
+	}
+	if s.refreshCount() != 1 {
+		t.Errorf("refreshed %d times, want once", s.refreshCount())
+	}
+	if got := s.takeRequests(); len(got) != 1 || got[0] != "GET /me/player/devices" {
+		t.Errorf("requests = %q, want the retried one", got)
+	}
+	if saved, _ := store.Load(); saved.AccessToken != "access-1" || saved.RefreshToken != "refresh-1" {
+		t.Errorf("stored token = %+v, want the rotated one", saved)
+	}
+
+	// Rejected again after refreshing, the API error is returned
+	s.set(func(s *fakeSpotify) { s.revoked = true })
+	_, err := c.Devices(context.Background())
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.Status != http.StatusUnauthorized || apiErr.Message != "The access token expired" {
+		t.Errorf("Devices() error = %v, want a 401 API error", err)
+	}
+	if s.refreshCount() != 2 {
+		t.Errorf("refreshed %d times, want one retry per request", s.refreshCount())
+	}
+
+	// A refresh token the server doesn't know fails the request
+	s.set(func(s *fakeSpotify) {
+		s.revoked = false
+		s.access = "access-revoked"
+		s.refresh = "refresh-other"
+	})
+	if _, err := c.Devices(context.Background()); err == nil || !strings.Contains(err.Error(), "failed to refresh") {
+		t.Errorf("Devices() error = %v, want a refresh error", err)
+	}
+}
+
+func TestClientNotLoggedIn(t *testing.T) {
+	s := newFakeSpotify(t)
+	auth := NewAuthenticator("client", "http://127.0.0.1:8888/callback", s.URL, s.Client())
+	c := NewClient(s.URL+"/v1", auth, NewTokenStore(filepath.Join(t.TempDir(), "token.json")), s.Client(), zerolog.Nop())
+
+	if c.LoggedIn() {
+		t.Error("LoggedIn() = true without a token file")
+	}
+	if _, err := c.Devices(context.Background()); !errors.Is(err, errNotLoggedIn) {
+		t.Errorf("Devices() error = %v, want errNotLoggedIn", err)
+	}
+}
+
+func TestClientSearchNothingFound(t *testing.T) {
+	s := newFakeSpotify(t)
+	c, _ := newTestClient(t, s, &Token{AccessToken: "access-0", RefreshToken: "refresh-0", ExpiresAt: time.Now().Add(time.Hour)})
+
+	if _, err := c.Search(context.Background(), "nada", "album"); err == nil {
+		t.Error("Search() with no results succeeded")
+	}
+	if got := s.takeRequests(); len(got) != 1 || got[0] != "GET /search?limit=1&q=nada&type=album" {
+		t.Errorf("requests = %q", got)
+	}
+}
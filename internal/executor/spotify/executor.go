@@ -0,0 +1,347 @@
+// Package spotify provides Spotify playback control through the Web API
+package spotify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/anastreamer/ana/internal/config"
+	"github.com/anastreamer/ana/internal/executor"
+	"github.com/anastreamer/ana/internal/llm"
+	"github.com/anastreamer/ana/pkg/logger"
+	"github.com/rs/zerolog"
+)
+
+// Executor implements the Spotify action executor
+type Executor struct {
+	api     *Client
+	auth    *Authenticator
+	device  string
+	log     zerolog.Logger
+	enabled bool
+
+	mu       sync.Mutex
+	deviceID string // Resolved device, used when Spotify reports no active device
+}
+
+// NewExecutor creates a new Spotify executor
+func NewExecutor(cfg config.SpotifyConfig) *Executor {
+	log := logger.Component("spotify")
+	httpClient := &http.Client{
+		Timeout: 15 * time.Second,
+	}
+
+	auth := NewAuthenticator(cfg.ClientID, cfg.RedirectURI, cfg.AuthURL, httpClient)
+	api := NewClient(cfg.APIURL, auth, NewTokenStore(cfg.TokenFile), httpClient, log)
+
+	if cfg.Enabled && !api.LoggedIn() {
+		log.Warn().Msg("No Spotify token found, run 'ana spotify login' to connect your account")
+	}
+
+	return &Executor{
+		api:     api,
+		auth:    auth,
+		device:  cfg.Device,
+		log:     log,
+		enabled: cfg.Enabled,
+	}
+}
+
+// Login runs the interactive authorization and stores the resulting tokens
+func (e *Executor) Login(ctx context.Context, showURL func(string)) error {
+	token, err := e.auth.Login(ctx, showURL)
+	if err != nil {
+		return err
+	}
+	return e.api.SetToken(token)
+}
+
+// Name returns the executor name
+func (e *Executor) Name() string {
+	return "spotify"
+}
+
+// SupportedActions returns the list of supported actions
+func (e *Executor) SupportedActions() []string {
+	return []string{
+		"spotify.play",
+		"spotify.pause",
+		"spotify.resume",
+		"spotify.next",
+		"spotify.previous",
+		"spotify.volume",
+		"spotify.queue",
+		"spotify.playlist.add",
+		"spotify.nowplaying",
+	}
+}
+
+// CanHandle returns true if this executor can handle the action
+func (e *Executor) CanHandle(action string) bool {
+	return strings.HasPrefix(action, "spotify.")
+}
+
+// Execute executes a Spotify action
+func (e *Executor) Execute(ctx context.Context, action llm.Action) (executor.Result, error) {
+	if !e.enabled {
+		return executor.NewErrorResult(fmt.Errorf("Spotify is not enabled")), nil
+	}
+
+	switch action.Action {
+	case "spotify.play":
+		return e.play(ctx, action)
+	case "spotify.pause":
+		return e.simple(ctx, "Spotify paused", e.api.Pause)
+	case "spotify.resume":
+		return e.simple(ctx, "Spotify resumed", func(ctx context.Context, deviceID string) error {
+			return e.api.Play(ctx, deviceID, nil, "")
+		})
+	case "spotify.next":
+		return e.simple(ctx, "Next track", e.api.Next)
+	case "spotify.previous":
+		return e.simple(ctx, "Previous track", e.api.Previous)
+	case "spotify.volume":
+		return e.setVolume(ctx, action)
+	case "spotify.queue":
+		return e.queue(ctx, action)
+	case "spotify.playlist.add":
+		return e.addToPlaylist(ctx, action)
+	case "spotify.nowplaying":
+		return e.nowPlaying(ctx)
+	default:
+		return executor.NewErrorResult(fmt.Errorf("unknown Spotify action: %s", action.Action)), nil
+	}
+}
+
+// IsAvailable checks if Spotify is enabled and an account is connected
+func (e *Executor) IsAvailable() bool {
+	return e.enabled && e.api.LoggedIn()
+}
+
+// Close releases resources
+func (e *Executor) Close() error {
+	return nil
+}
+
+// play searches for a track (or album, artist, playlist) and plays it
+func (e *Executor) play(ctx context.Context, action llm.Action) (executor.Result, error) {
+	query := action.GetStringParam("query")
+	if query == "" {
+		// No query, behave like resume
+		return e.simple(ctx, "Spotify resumed", func(ctx context.Context, deviceID string) error {
+			return e.api.Play(ctx, deviceID, nil, "")
+		})
+	}
+
+	kind := strings.ToLower(action.GetStringParam("type"))
+	switch kind {
+	case "album", "artist", "playlist":
+	default:
+		kind = "track"
+	}
+
+	item, err := e.api.Search(ctx, query, kind)
+	if err != nil {
+		return executor.NewErrorResult(err), err
+	}
+
+	err = e.withDevice(ctx, func(ctx context.Context, deviceID string) error {
+		if kind == "track" {
+			return e.api.Play(ctx, deviceID, []string{item.URI}, "")
+		}
+		return e.api.Play(ctx, deviceID, nil, item.URI)
+	})
+	if err != nil {
+		return executor.NewErrorResult(err), err
+	}
+
+	name := describe(item)
+	e.log.Info().Str("item", name).Str("type", kind).Msg("Playing on Spotify")
+	return executor.NewResultWithData("Playing on Spotify", map[string]interface{}{
+		"track": name,
+		"uri":   item.URI,
+	}), nil
+}
+
+// simple runs a playback command that takes only the device
+func (e *Executor) simple(ctx context.Context, message string, fn func(ctx context.Context, deviceID string) error) (executor.Result, error) {
+	if err := e.withDevice(ctx, fn); err != nil {
+		return executor.NewErrorResult(err), err
+	}
+
+	e.log.Info().Msg(message)
+	return executor.NewResult(message), nil
+}
+
+// setVolume sets the Spotify volume from a 0.0-1.0 value
+func (e *Executor) setVolume(ctx context.Context, action llm.Action) (executor.Result, error) {
+	volume := action.GetFloatParam("volume")
+	if volume < 0 {
+		volume = 0
+	}
+	if volume > 1 {
+		volume = 1
+	}
+	percent := int(volume*100 + 0.5)
+
+	err := e.withDevice(ctx, func(ctx context.Context, deviceID string) error {
+		return e.api.SetVolume(ctx, deviceID, percent)
+	})
+	if err != nil {
+		return executor.NewErrorResult(err), err
+	}
+
+	e.log.Info().Int("volume", percent).Msg("Spotify volume set")
+	return executor.NewResult(fmt.Sprintf("Spotify volume set to %d%%", percent)), nil
+}
+
+// queue adds the best matching track to the playback queue
+func (e *Executor) queue(ctx context.Context, action llm.Action) (executor.Result, error) {
+	query := action.GetStringParam("query")
+	if query == "" {
+		return executor.NewErrorResult(fmt.Errorf("query is required")), nil
+	}
+
+	item, err := e.api.Search(ctx, query, "track")
+	if err != nil {
+		return executor.NewErrorResult(err), err
+	}
+
+	err = e.withDevice(ctx, func(ctx context.Context, deviceID string) error {
+		return e.api.Queue(ctx, deviceID, item.URI)
+	})
+	if err != nil {
+		return executor.NewErrorResult(err), err
+	}
+
+	name := describe(item)
+	e.log.Info().Str("track", name).Msg("Track queued on Spotify")
+	return executor.NewResultWithData("Track queued", map[string]interface{}{
+		"track": name,
+	}), nil
+}
+
+// addToPlaylist adds the currently playing track to a playlist by name
+func (e *Executor) addToPlaylist(ctx context.Context, action llm.Action) (executor.Result, error) {
+	name := action.GetStringParam("playlist")
+	if name == "" {
+		return executor.NewErrorResult(fmt.Errorf("playlist is required")), nil
+	}
+
+	state, err := e.api.CurrentlyPlaying(ctx)
+	if err != nil {
+		return executor.NewErrorResult(err), err
+	}
+	if state == nil {
+		return executor.NewErrorResult(fmt.Errorf("nothing is playing on Spotify")), nil
+	}
+
+	playlist, err := e.api.FindPlaylist(ctx, name)
+	if err != nil {
+		return executor.NewErrorResult(err), nil
+	}
+
+	if err := e.api.AddToPlaylist(ctx, playlist.ID, []string{state.Item.URI}); err != nil {
+		return executor.NewErrorResult(err), err
+	}
+
+	track := describe(state.Item)
+	e.log.Info().Str("track", track).Str("playlist", playlist.Name).Msg("Track added to playlist")
+	return executor.NewResultWithData("Track added to playlist", map[string]interface{}{
+		"track":    track,
+		"playlist": playlist.Name,
+	}), nil
+}
+
+// nowPlaying describes the current track so Ana can speak it
+func (e *Executor) nowPlaying(ctx context.Context) (executor.Result, error) {
+	state, err := e.api.CurrentlyPlaying(ctx)
+	if err != nil {
+		return executor.NewErrorResult(err), err
+	}
+	if state == nil || !state.IsPlaying {
+		return executor.NewReplyResult("Ahora mismo no está sonando nada en Spotify", nil), nil
+	}
+
+	item := state.Item
+	reply := fmt.Sprintf("Está sonando %s", item.Name)
+	if artists := item.ArtistNames(); artists != "" {
+		reply = fmt.Sprintf("Está sonando %s de %s", item.Name, artists)
+	}
+
+	data := map[string]interface{}{
+		"title":  item.Name,
+		"artist": item.ArtistNames(),
+		"uri":    item.URI,
+	}
+	if item.Album != nil {
+		data["album"] = item.Album.Name
+	}
+	return executor.NewReplyResult(reply, data), nil
+}
+
+// withDevice runs a playback command. If Spotify has no active device it
+// picks one (the configured device or the first available) and retries.
+func (e *Executor) withDevice(ctx context.Context, fn func(ctx context.Context, deviceID string) error) error {
+	e.mu.Lock()
+	deviceID := e.deviceID
+	e.mu.Unlock()
+
+	err := fn(ctx, deviceID)
+
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.Status != http.StatusNotFound {
+		return err
+	}
+
+	deviceID, derr := e.resolveDevice(ctx)
+	if derr != nil {
+		return fmt.Errorf("%w (%v)", err, derr)
+	}
+	return fn(ctx, deviceID)
+}
+
+// resolveDevice finds the device to play on and remembers it
+func (e *Executor) resolveDevice(ctx context.Context) (string, error) {
+	devices, err := e.api.Devices(ctx)
+	if err != nil {
+		return "", err
+	}
+	if len(devices) == 0 {
+		return "", fmt.Errorf("no Spotify devices available, open Spotify on any device")
+	}
+
+	chosen := devices[0]
+	if e.device != "" {
+		found := false
+		for _, d := range devices {
+			if strings.EqualFold(d.Name, e.device) {
+				chosen, found = d, true
+				break
+			}
+		}
+		if !found {
+			e.log.Warn().Str("device", e.device).Str("using", chosen.Name).Msg("Configured Spotify device not found")
+		}
+	}
+
+	e.mu.Lock()
+	e.deviceID = chosen.ID
+	e.mu.Unlock()
+
+	e.log.Info().Str("device", chosen.Name).Msg("Using Spotify device")
+	return chosen.ID, nil
+}
+
+// describe returns "Name - Artists" for an item
+func describe(item *Item) string {
+	if artists := item.ArtistNames(); artists != "" {
+		return fmt.Sprintf("%s - %s", item.Name, artists)
+	}
+	return item.Name
+}
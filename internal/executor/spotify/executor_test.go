@@ -0,0 +1,232 @@
+package spotify
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/anastreamer/ana/internal/config"
+	"github.com/anastreamer/ana/internal/llm"
+)
+
+// newTestExecutor returns an enabled executor against s, logged in
+func newTestExecutor(t *testing.T, s *fakeSpotify, device string) *Executor {
+	t.Helper()
+	tokenFile := filepath.Join(t.TempDir(), "token.json")
+	token := &Token{AccessToken: "access-0", RefreshToken: "refresh-0", ExpiresAt: time.Now().Add(time.Hour)}
+	if err := NewTokenStore(tokenFile).Save(token); err != nil {
+		t.Fatal(err)
+	}
+
+	e := NewExecutor(config.SpotifyConfig{
+		Enabled:     true,
+		ClientID:    "client",
+		RedirectURI: "http://127.0.0.1:8888/callback",
+		TokenFile:   tokenFile,
+		Device:      device,
+		APIURL:      s.URL + "/v1",
+		AuthURL:     s.URL,
+	})
+	if !e.IsAvailable() {
+		t.Fatal("IsAvailable() = false with a stored token")
+	}
+	return e
+}
+
+func TestExecutorPlay(t *testing.T) {
+	tests := []struct {
+		name     string
+		params   map[string]interface{}
+		noDevice bool
+		device   string
+		requests []string
+		data     map[string]interface{}
+	}{
+		{
+			name:   "track",
+			params: map[string]interface{}{"query": "Despechá"},
+			requests: []string{
+				"GET /search?limit=1&q=Despech%C3%A1&type=track",
+				`PUT /me/player/play {"uris":["spotify:track:1"]}`,
+			},
+			data: map[string]interface{}{"track": "Despechá - Rosalía, Tokischa", "uri": "spotify:track:1"},
+		},
+		{
+			name:   "album",
+			params: map[string]interface{}{"query": "Motomami", "type": "Album"},
+			requests: []string{
+				"GET /search?limit=1&q=Motomami&type=album",
+				`PUT /me/player/play {"context_uri":"spotify:album:1"}`,
+			},
+			data: map[string]interface{}{"track": "Motomami - Rosalía, Tokischa", "uri": "spotify:album:1"},
+		},
+		{
+			name:     "no active device",
+			params:   map[string]interface{}{"query": "Despechá"},
+			noDevice: true,
+			device:   "estudio pc",
+			requests: []string{
+				"GET /search?limit=1&q=Despech%C3%A1&type=track",
+				`PUT /me/player/play {"uris":["spotify:track:1"]}`,
+				"GET /me/player/devices",
+				`PUT /me/player/play?device_id=dev-pc {"uris":["spotify:track:1"]}`,
+			},
+			data: map[string]interface{}{"track": "Despechá - Rosalía, Tokischa", "uri": "spotify:track:1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newFakeSpotify(t)
+			s.set(func(s *fakeSpotify) { s.noDevice = tt.noDevice })
+			e := newTestExecutor(t, s, tt.device)
+
+			result, err := e.Execute(context.Background(), llm.Action{Action: "spotify.play", Params: tt.params})
+			if err != nil || !result.Success {
+				t.Fatalf("Execute() = %+v, %v", result, err)
+			}
+			if !reflect.DeepEqual(result.Data, tt.data) {
+				t.Errorf("data = %v, want %v", result.Data, tt.data)
+			}
+			if got := s.takeRequests(); !reflect.DeepEqual(got, tt.requests) {
+				t.Errorf("requests = %q, want %q", got, tt.requests)
+			}
+		})
+	}
+}
+
+func TestExecutorRemembersDevice(t *testing.T) {
+	s := newFakeSpotify(t)
+	s.set(func(s *fakeSpotify) { s.noDevice = true })
+	e := newTestExecutor(t, s, "")
+
+	if result, err := e.Execute(context.Background(), llm.Action{Action: "spotify.pause"}); err != nil || !result.Success {
+		t.Fatalf("Execute() = %+v, %v", result, err)
+	}
+	s.takeRequests()
+
+	// The first device is used from now on, without asking again
+	if result, err := e.Execute(context.Background(), llm.Action{Action: "spotify.resume"}); err != nil || !result.Success {
+		t.Fatalf("Execute() = %+v, %v", result, err)
+	}
+	if got, want := s.takeRequests(), []string{"PUT /me/player/play?device_id=dev-phone"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("requests = %q, want %q", got, want)
+	}
+}
+
+func TestExecutorQueue(t *testing.T) {
+	s := newFakeSpotify(t)
+	e := newTestExecutor(t, s, "")
+
+	result, err := e.Execute(context.Background(), llm.Action{Action: "spotify.queue", Params: map[string]interface{}{"query": "Saoko"}})
+	if err != nil || !result.Success {
+		t.Fatalf("Execute() = %+v, %v", result, err)
+	}
+	if result.Message != "Track queued" || result.Data["track"] != "Saoko - Rosalía, Tokischa" {
+		t.Errorf("Execute() = %+v", result)
+	}
+	want := []string{
+		"GET /search?limit=1&q=Saoko&type=track",
+		"POST /me/player/queue?uri=spotify%3Atrack%3A1",
+	}
+	if got := s.takeRequests(); !reflect.DeepEqual(got, want) {
+		t.Errorf("requests = %q, want %q", got, want)
+	}
+
+	// Nothing to queue
+	if result, _ := e.Execute(context.Background(), llm.Action{Action: "spotify.queue", Params: map[string]interface{}{"query": "nada"}}); result.Success {
+		t.Error("queueing a search without results succeeded")
+	}
+	if result, _ := e.Execute(context.Background(), llm.Action{Action: "spotify.queue"}); result.Success {
+		t.Error("queueing without a query succeeded")
+	}
+}
+
+func TestExecutorPlaylistAdd(t *testing.T) {
+	s := newFakeSpotify(t)
+	e := newTestExecutor(t, s, "")
+	add := llm.Action{Action: "spotify.playlist.add", Params: map[string]interface{}{"playlist": "favoritas"}}
+
+	// Nothing playing
+	if result, _ := e.Execute(context.Background(), add); result.Success {
+		t.Error("adding with nothing playing succeeded")
+	}
+	s.takeRequests()
+
+	s.set(func(s *fakeSpotify) {
+		s.playing = &PlaybackState{IsPlaying: true, Item: &Item{URI: "spotify:track:9", Name: "Candy"}}
+	})
+	result, err := e.Execute(context.Background(), add)
+	if err != nil || !result.Success {
+		t.Fatalf("Execute() = %+v, %v", result, err)
+	}
+	// The exact name on the second page wins over the partial match on the first
+	if want := map[string]interface{}{"track": "Candy", "playlist": "FAVORITAS"}; !reflect.DeepEqual(result.Data, want) {
+		t.Errorf("data = %v, want %v", result.Data, want)
+	}
+	want := []string{
+		"GET /me/player/currently-playing",
+		"GET /me/playlists?limit=50&offset=0",
+		"GET /me/playlists?limit=50&offset=50",
+		`POST /playlists/pl-fav/tracks {"uris":["spotify:track:9"]}`,
+	}
+	if got := s.takeRequests(); !reflect.DeepEqual(got, want) {
+		t.Errorf("requests = %q, want %q", got, want)
+	}
+
+	// Only a partial match
+	add.Params["playlist"] = "de 2019"
+	if result, _ := e.Execute(context.Background(), add); result.Data["playlist"] != "Favoritas de 2019" {
+		t.Errorf("Execute() = %+v, want the partial match", result)
+	}
+	add.Params["playlist"] = "Jazz"
+	if result, _ := e.Execute(context.Background(), add); result.Success {
+		t.Error("adding to a missing playlist succeeded")
+	}
+}
+
+func TestExecutorNowPlaying(t *testing.T) {
+	s := newFakeSpotify(t)
+	e := newTestExecutor(t, s, "")
+	nowPlaying := llm.Action{Action: "spotify.nowplaying"}
+
+	result, err := e.Execute(context.Background(), nowPlaying)
+	if err != nil || result.Reply != "Ahora mismo no está sonando nada en Spotify" {
+		t.Errorf("Execute() = %+v, %v with nothing playing", result, err)
+	}
+
+	// Paused counts as nothing playing
+	s.set(func(s *fakeSpotify) {
+		s.playing = &PlaybackState{IsPlaying: false, Item: &Item{URI: "spotify:track:9", Name: "Candy"}}
+	})
+	if result, _ := e.Execute(context.Background(), nowPlaying); result.Reply != "Ahora mismo no está sonando nada en Spotify" {
+		t.Errorf("Execute() = %+v while paused", result)
+	}
+
+	var item Item
+	if err := json.Unmarshal([]byte(`{"uri": "spotify:track:9", "name": "Candy", "artists": [{"name": "Rosalía"}], "album": {"name": "Motomami"}}`), &item); err != nil {
+		t.Fatal(err)
+	}
+	s.set(func(s *fakeSpotify) { s.playing = &PlaybackState{IsPlaying: true, Item: &item} })
+	result, err = e.Execute(context.Background(), nowPlaying)
+	if err != nil || result.Reply != "Está sonando Candy de Rosalía" {
+		t.Errorf("Execute() = %+v, %v", result, err)
+	}
+	want := map[string]interface{}{"title": "Candy", "artist": "Rosalía", "uri": "spotify:track:9", "album": "Motomami"}
+	if !reflect.DeepEqual(result.Data, want) {
+		t.Errorf("data = %v, want %v", result.Data, want)
+	}
+}
+
+func TestExecutorDisabled(t *testing.T) {
+	e := NewExecutor(config.SpotifyConfig{TokenFile: filepath.Join(t.TempDir(), "token.json")})
+	if e.IsAvailable() {
+		t.Error("IsAvailable() = true while disabled")
+	}
+	if result, err := e.Execute(context.Background(), llm.Action{Action: "spotify.next"}); err != nil || result.Success {
+		t.Errorf("Execute() = %+v, %v while disabled", result, err)
+	}
+}
@@ -8,27 +8,23 @@ import (
 	"github.com/anastreamer/ana/internal/config"
 )
 
-// autoProvider switches between available LLM vendors (local/openai) at runtime.
+// autoProvider switches between available LLM backends at runtime, trying
+// them in the order of llm.auto.order.
 type autoProvider struct {
 	providers []Provider
 }
 
 // NewAutoProvider instantiates the auto LLM provider.
-func NewAutoProvider(cfg *config.Config) (Provider, error) {
+func NewAutoProvider(cfg *config.Config, prompts *Prompts) (Provider, error) {
 	var providers []Provider
 	var errs []string
 
-	streamerName := cfg.General.StreamerName
-	if p, err := NewOllamaProvider(cfg.LLM.Ollama, streamerName); err == nil {
-		providers = append(providers, p)
-	} else {
-		errs = append(errs, fmt.Sprintf("ollama: %v", err))
-	}
-
-	if p, err := NewOpenAIProvider(cfg.LLM.OpenAI, streamerName); err == nil {
-		providers = append(providers, p)
-	} else {
-		errs = append(errs, fmt.Sprintf("openai: %v", err))
+	for _, name := range cfg.LLM.Auto.Order {
+		if p, err := newBackend(cfg, name, prompts); err == nil {
+			providers = append(providers, p)
+		} else {
+			errs = append(errs, fmt.Sprintf("%s: %v", name, err))
+		}
 	}
 
 	if len(providers) == 0 {
@@ -58,6 +54,14 @@ func (a *autoProvider) Complete(ctx context.Context, prompt string) (Action, err
 	return p.Complete(ctx, prompt)
 }
 
+func (a *autoProvider) CompleteStream(ctx context.Context, prompt string, h StreamHandler) (Action, error) {
+	p, err := a.selectProvider(ctx)
+	if err != nil {
+		return Action{}, err
+	}
+	return p.CompleteStream(ctx, prompt, h)
+}
+
 func (a *autoProvider) CompleteRaw(ctx context.Context, prompt string) (string, error) {
 	p, err := a.selectProvider(ctx)
 	if err != nil {
@@ -0,0 +1,106 @@
+package llm
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/anastreamer/ana/internal/config"
+)
+
+// autoConfig returns a config whose auto chain is order, with a llama.cpp
+// server and the endpoints first and second
+func autoConfig(order []string, llamacpp, first, second *fakeLLMServer) *config.Config {
+	cfg := config.DefaultConfig()
+	cfg.LLM.Provider = "auto"
+	cfg.LLM.Auto.Order = order
+	cfg.LLM.OpenAI.APIKey = ""
+	if llamacpp != nil {
+		cfg.LLM.LlamaCpp.URL = llamacpp.URL
+	}
+	cfg.LLM.Endpoints = map[string]config.OpenAILLMConfig{}
+	if first != nil {
+		cfg.LLM.Endpoints["first"] = config.OpenAILLMConfig{BaseURL: first.URL + "/v1"}
+	}
+	if second != nil {
+		cfg.LLM.Endpoints["second"] = config.OpenAILLMConfig{BaseURL: second.URL + "/v1"}
+	}
+	return cfg
+}
+
+func TestAutoProviderFallsBack(t *testing.T) {
+	llamacpp := newFakeLLMServer(t, testAction, false)
+	first := newFakeLLMServer(t, testAction, false)
+	second := newFakeLLMServer(t, testAction, true)
+
+	p, err := New(autoConfig([]string{"llamacpp", "first", "second"}, llamacpp, first, second), newTestPrompts(t))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if got := p.Name(); got != "auto(llamacpp+first+second)" {
+		t.Errorf("Name() = %q", got)
+	}
+	if !p.IsAvailable(context.Background()) {
+		t.Error("IsAvailable() = false with a backend up")
+	}
+
+	if _, err := p.Complete(context.Background(), "siguiente canción"); err != nil {
+		t.Fatalf("Complete: %v", err)
+	}
+	if llamacpp.chats() != 0 || first.chats() != 0 || second.chats() != 1 {
+		t.Errorf("chats = %d, %d, %d, want only the available backend", llamacpp.chats(), first.chats(), second.chats())
+	}
+
+	// The first one is used again once it's back
+	first.healthy = true
+	if _, err := p.CompleteStream(context.Background(), "siguiente canción", StreamHandler{}); err != nil {
+		t.Fatalf("CompleteStream: %v", err)
+	}
+	if first.chats() != 1 || second.chats() != 1 {
+		t.Errorf("chats = %d, %d, want the first available backend", first.chats(), second.chats())
+	}
+}
+
+func TestAutoProviderNoneAvailable(t *testing.T) {
+	first := newFakeLLMServer(t, testAction, false)
+	second := newFakeLLMServer(t, testAction, false)
+
+	p, err := New(autoConfig([]string{"second", "first"}, nil, first, second), newTestPrompts(t))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if p.IsAvailable(context.Background()) {
+		t.Error("IsAvailable() = true with every backend down")
+	}
+
+	// Without any available, the first in the order is tried anyway
+	if _, err := p.Complete(context.Background(), "hola"); err != nil {
+		t.Fatalf("Complete: %v", err)
+	}
+	if second.chats() != 1 || first.chats() != 0 {
+		t.Errorf("chats = %d, %d, want the first in the order", second.chats(), first.chats())
+	}
+}
+
+func TestAutoProviderSkipsInvalidBackends(t *testing.T) {
+	second := newFakeLLMServer(t, testAction, true)
+
+	// openai has no API key and "missing" is no endpoint
+	p, err := New(autoConfig([]string{"openai", "missing", "second"}, nil, nil, second), newTestPrompts(t))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if got := p.Name(); got != "auto(second)" {
+		t.Errorf("Name() = %q, want auto(second)", got)
+	}
+
+	_, err = New(autoConfig([]string{"openai", "missing"}, nil, nil, nil), newTestPrompts(t))
+	if err == nil {
+		t.Fatal("New succeeded with no valid backend")
+	}
+	for _, name := range []string{"openai", "missing"} {
+		if !strings.Contains(err.Error(), name) {
+			t.Errorf("error %q does not mention %s", err, name)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package llm
+
+import (
+	"fmt"
+	"strings"
+)
+
+// actionGrammarRules is the GBNF grammar of an Action after root, based on
+// llama.cpp's json.gbnf. The fields come in the order the streaming parser
+// wants them: the action first and the reply last.
+const actionGrammarRules = `
+value  ::= object | array | string | number | ("true" | "false" | "null") ws
+object ::= "{" ws ( string ":" ws value ("," ws string ":" ws value)* )? "}" ws
+array  ::= "[" ws ( value ("," ws value)* )? "]" ws
+string ::= "\"" ( [^"\\\x7F\x00-\x1F] | "\\" (["\\/bfnrt] | "u" [0-9a-fA-F]{4}) )* "\"" ws
+number ::= ("-"? ([0-9] | [1-9] [0-9]{0,15})) ("." [0-9]+)? ([eE] [-+]? [0-9] [1-9]{0,15})? ws
+ws     ::= | " " | "\n" [ \t]{0,20}
+`
+
+// actionGrammar returns a GBNF grammar that only lets the LLM write an
+// Action whose action is one of actions, or any string if actions is nil
+func actionGrammar(actions []string) string {
+	name := "string"
+	if len(actions) > 0 {
+		names := make([]string, len(actions))
+		for i, action := range actions {
+			names[i] = fmt.Sprintf("%q", `"`+action+`"`)
+		}
+		name = "(" + strings.Join(names, " | ") + ") ws"
+	}
+
+	var g strings.Builder
+	g.WriteString(`root   ::= "{" ws "\"action\"" ws ":" ws name "," ws "\"params\"" ws ":" ws object "," ws "\"reply\"" ws ":" ws string "}" ws` + "\n")
+	g.WriteString("name   ::= " + name + "\n")
+	g.WriteString(strings.TrimLeft(actionGrammarRules, "\n"))
+	return g.String()
+}
@@ -0,0 +1,87 @@
+package llm
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// grammarRules returns the rules of a GBNF grammar by name, failing on
+// lines that aren't a rule
+func grammarRules(t *testing.T, grammar string) map[string]string {
+	t.Helper()
+	rules := map[string]string{}
+	for _, line := range strings.Split(strings.TrimSpace(grammar), "\n") {
+		name, body, ok := strings.Cut(line, "::=")
+		if !ok {
+			t.Fatalf("grammar line %q is not a rule", line)
+		}
+		name = strings.TrimSpace(name)
+		if _, dup := rules[name]; dup {
+			t.Errorf("rule %s is defined twice", name)
+		}
+		rules[name] = body
+	}
+	return rules
+}
+
+// grammarLiterals are the quoted strings and character classes of a rule,
+// which may contain anything
+var grammarLiterals = regexp.MustCompile(`"(?:[^"\\]|\\.)*"|\[(?:[^\]\\]|\\.)*\]`)
+
+// grammarRefs are the rule names a rule body refers to
+var grammarRefs = regexp.MustCompile(`[a-z][a-z0-9-]*`)
+
+func TestActionGrammar(t *testing.T) {
+	tests := []struct {
+		name    string
+		actions []string
+		want    []string
+	}{
+		{"any action", nil, []string{`name   ::= string`}},
+		{"listed actions", []string{"music.next", "obs.scene"}, []string{
+			`name   ::= ("\"music.next\"" | "\"obs.scene\"") ws`,
+		}},
+		{"one action", []string{"none"}, []string{`name   ::= ("\"none\"") ws`}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			grammar := actionGrammar(tt.actions)
+			for _, want := range tt.want {
+				if !strings.Contains(grammar, want) {
+					t.Errorf("grammar has no %q:\n%s", want, grammar)
+				}
+			}
+
+			rules := grammarRules(t, grammar)
+			if !strings.HasPrefix(grammar, "root   ::= ") {
+				t.Errorf("grammar does not start with root:\n%s", grammar)
+			}
+			for name, body := range rules {
+				for _, ref := range grammarRefs.FindAllString(grammarLiterals.ReplaceAllString(body, ""), -1) {
+					if _, ok := rules[ref]; !ok {
+						t.Errorf("rule %s refers to undefined rule %s", name, ref)
+					}
+				}
+			}
+
+			// The fields come in the order the streaming parser wants them
+			root := rules["root"]
+			action := strings.Index(root, `"\"action\""`)
+			params := strings.Index(root, `"\"params\""`)
+			reply := strings.Index(root, `"\"reply\""`)
+			if action < 0 || !(action < params && params < reply) {
+				t.Errorf("root has the fields out of order: %s", root)
+			}
+		})
+	}
+}
+
+func TestActionGrammarQuotesActions(t *testing.T) {
+	// Action names go into GBNF string literals, so quotes are escaped
+	grammar := actionGrammar([]string{`odd"name`})
+	if !strings.Contains(grammar, `"\"odd\"name\""`) {
+		t.Errorf("grammar does not escape the action:\n%s", grammar)
+	}
+}
@@ -0,0 +1,58 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/anastreamer/ana/internal/config"
+	"github.com/anastreamer/ana/pkg/logger"
+)
+
+// LlamaCppProvider implements the Provider interface for a llama.cpp
+// server. It talks to its OpenAI-compatible API and, with grammar on,
+// constrains decoding with a GBNF grammar so the answer is always a valid
+// Action.
+type LlamaCppProvider struct {
+	*OpenAIProvider
+	url string
+}
+
+// NewLlamaCppProvider creates a new llama.cpp provider
+func NewLlamaCppProvider(cfg config.LlamaCppConfig, prompts *Prompts) (*LlamaCppProvider, error) {
+	if cfg.URL == "" {
+		return nil, fmt.Errorf("llama.cpp URL is required")
+	}
+	url := strings.TrimRight(cfg.URL, "/")
+
+	p := newOpenAIProvider("llamacpp", config.OpenAILLMConfig{
+		BaseURL:     url + "/v1",
+		Model:       cfg.Model,
+		Temperature: cfg.Temperature,
+		MaxTokens:   cfg.MaxTokens,
+	}, prompts)
+	p.grammar = cfg.Grammar
+	p.log = logger.Component("llamacpp-llm")
+	if cfg.TimeoutSeconds > 0 {
+		p.client.Timeout = cfg.Timeout()
+	}
+
+	return &LlamaCppProvider{OpenAIProvider: p, url: url}, nil
+}
+
+// IsAvailable checks if the server is up with its model loaded
+func (p *LlamaCppProvider) IsAvailable(ctx context.Context) bool {
+	req, err := http.NewRequestWithContext(ctx, "GET", p.url+"/health", nil)
+	if err != nil {
+		return false
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return false
+	}
+	defer resp.Body.Close()
+
+	return resp.StatusCode == http.StatusOK
+}
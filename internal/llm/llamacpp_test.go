@@ -0,0 +1,84 @@
+package llm
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/anastreamer/ana/internal/config"
+)
+
+func TestLlamaCppProvider(t *testing.T) {
+	server := newFakeLLMServer(t, testAction, true)
+	prompts := newTestPrompts(t)
+	prompts.SetActions(func() []string { return []string{"music.next", "obs.scene"} })
+
+	p, err := NewLlamaCppProvider(config.LlamaCppConfig{
+		URL:            server.URL + "/",
+		Model:          "qwen2.5",
+		TimeoutSeconds: 5,
+		Grammar:        true,
+	}, prompts)
+	if err != nil {
+		t.Fatalf("NewLlamaCppProvider: %v", err)
+	}
+
+	if p.Name() != "llamacpp" {
+		t.Errorf("Name() = %q, want llamacpp", p.Name())
+	}
+	if !p.IsAvailable(context.Background()) {
+		t.Error("IsAvailable() = false with /health ok")
+	}
+
+	action, err := p.Complete(context.Background(), "siguiente canción")
+	if err != nil {
+		t.Fatalf("Complete: %v", err)
+	}
+	if action.Action != "music.next" {
+		t.Errorf("Complete() = %+v", action)
+	}
+
+	req, _ := server.lastRequest(t)
+	if req.ResponseFormat != nil {
+		t.Errorf("response_format = %+v, want none with a grammar", req.ResponseFormat)
+	}
+	if req.Grammar != actionGrammar(prompts.Actions()) {
+		t.Errorf("grammar = %q, want the one of the prompt's actions", req.Grammar)
+	}
+	for _, action := range []string{"music.next", "obs.scene", "system.status", "none"} {
+		if !strings.Contains(req.Grammar, `"\"`+action+`\""`) {
+			t.Errorf("grammar does not allow %s", action)
+		}
+	}
+}
+
+func TestLlamaCppProviderWithoutGrammar(t *testing.T) {
+	server := newFakeLLMServer(t, testAction, true)
+	p, err := NewLlamaCppProvider(config.LlamaCppConfig{URL: server.URL}, newTestPrompts(t))
+	if err != nil {
+		t.Fatalf("NewLlamaCppProvider: %v", err)
+	}
+
+	if _, err := p.CompleteStream(context.Background(), "siguiente canción", StreamHandler{}); err != nil {
+		t.Fatalf("CompleteStream: %v", err)
+	}
+	req, _ := server.lastRequest(t)
+	if req.Grammar != "" || req.ResponseFormat == nil {
+		t.Errorf("grammar = %q, response_format = %+v, want JSON mode", req.Grammar, req.ResponseFormat)
+	}
+}
+
+func TestLlamaCppProviderLoading(t *testing.T) {
+	server := newFakeLLMServer(t, testAction, false)
+	p, err := NewLlamaCppProvider(config.LlamaCppConfig{URL: server.URL}, newTestPrompts(t))
+	if err != nil {
+		t.Fatalf("NewLlamaCppProvider: %v", err)
+	}
+	if p.IsAvailable(context.Background()) {
+		t.Error("IsAvailable() = true while the model loads")
+	}
+
+	if _, err := NewLlamaCppProvider(config.LlamaCppConfig{}, newTestPrompts(t)); err == nil {
+		t.Error("NewLlamaCppProvider without a URL succeeded")
+	}
+}
@@ -78,6 +78,10 @@ type Provider interface {
 	// Complete sends a prompt to the LLM and returns an Action
 	Complete(ctx context.Context, prompt string) (Action, error)
 
+	// CompleteStream is Complete, reporting the action and the sentences of
+	// the reply to h while the LLM is still writing them
+	CompleteStream(ctx context.Context, prompt string, h StreamHandler) (Action, error)
+
 	// CompleteRaw sends a prompt and returns the raw response
 	CompleteRaw(ctx context.Context, prompt string) (string, error)
 
@@ -88,17 +92,28 @@ type Provider interface {
 	Close() error
 }
 
-// New creates a new LLM provider based on configuration
-func New(cfg *config.Config) (Provider, error) {
-	streamerName := cfg.General.StreamerName
-	switch cfg.LLM.Provider {
+// New creates a new LLM provider based on configuration, answering with
+// the given system prompts
+func New(cfg *config.Config, prompts *Prompts) (Provider, error) {
+	if cfg.LLM.Provider == "auto" {
+		return NewAutoProvider(cfg, prompts)
+	}
+	return newBackend(cfg, cfg.LLM.Provider, prompts)
+}
+
+// newBackend creates the provider called name: a built-in one or an
+// OpenAI-compatible endpoint from llm.endpoints
+func newBackend(cfg *config.Config, name string, prompts *Prompts) (Provider, error) {
+	switch name {
 	case "ollama":
-		return NewOllamaProvider(cfg.LLM.Ollama, streamerName)
+		return NewOllamaProvider(cfg.LLM.Ollama, prompts)
 	case "openai":
-		return NewOpenAIProvider(cfg.LLM.OpenAI, streamerName)
-	case "auto":
-		return NewAutoProvider(cfg)
-	default:
-		return nil, fmt.Errorf("unknown LLM provider: %s", cfg.LLM.Provider)
+		return NewOpenAIProvider(cfg.LLM.OpenAI, prompts)
+	case "llamacpp":
+		return NewLlamaCppProvider(cfg.LLM.LlamaCpp, prompts)
+	}
+	if endpoint, ok := cfg.LLM.Endpoints[name]; ok {
+		return NewEndpointProvider(name, endpoint, prompts)
 	}
+	return nil, fmt.Errorf("unknown LLM provider: %s", name)
 }
@@ -1,6 +1,7 @@
 package llm
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"encoding/json"
@@ -18,12 +19,14 @@ import (
 
 // OllamaProvider implements the Provider interface for Ollama
 type OllamaProvider struct {
-	url          string
-	model        string
-	timeout      time.Duration
-	client       *http.Client
-	log          zerolog.Logger
-	streamerName string
+	url         string
+	model       string
+	timeout     time.Duration
+	temperature float64
+	maxTokens   int
+	client      *http.Client
+	log         zerolog.Logger
+	prompts     *Prompts
 }
 
 // OllamaRequest represents a request to the Ollama API
@@ -59,21 +62,33 @@ type OllamaTagsResponse struct {
 }
 
 // NewOllamaProvider creates a new Ollama provider
-func NewOllamaProvider(cfg config.OllamaConfig, streamerName string) (*OllamaProvider, error) {
+func NewOllamaProvider(cfg config.OllamaConfig, prompts *Prompts) (*OllamaProvider, error) {
 	timeout := cfg.Timeout()
 	if timeout == 0 {
 		timeout = 30 * time.Second
 	}
 
+	temperature := cfg.Temperature
+	if temperature == 0 {
+		temperature = 0.3
+	}
+
+	maxTokens := cfg.MaxTokens
+	if maxTokens == 0 {
+		maxTokens = 500
+	}
+
 	return &OllamaProvider{
-		url:          strings.TrimSuffix(cfg.URL, "/"),
-		model:        cfg.Model,
-		timeout:      timeout,
+		url:         strings.TrimSuffix(cfg.URL, "/"),
+		model:       cfg.Model,
+		timeout:     timeout,
+		temperature: temperature,
+		maxTokens:   maxTokens,
 		client: &http.Client{
 			Timeout: timeout,
 		},
-		log:          logger.Component("ollama"),
-		streamerName: streamerName,
+		log:     logger.Component("ollama"),
+		prompts: prompts,
 	}, nil
 }
 
@@ -86,68 +101,120 @@ func (p *OllamaProvider) Name() string {
 func (p *OllamaProvider) Complete(ctx context.Context, prompt string) (Action, error) {
 	p.log.Debug().Str("prompt", prompt).Msg("Sending prompt to Ollama")
 
+	resp, err := p.sendAction(ctx, prompt, false)
+	if err != nil {
+		return Action{}, err
+	}
+	defer resp.Body.Close()
+
+	// Read response
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return Action{}, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	// Parse Ollama response
+	var ollamaResp OllamaResponse
+	if err := json.Unmarshal(body, &ollamaResp); err != nil {
+		return Action{}, fmt.Errorf("failed to parse Ollama response: %w", err)
+	}
+
+	p.log.Debug().Str("response", ollamaResp.Response).Msg("Received response from Ollama")
+
+	return p.toAction(ollamaResp.Response), nil
+}
+
+// CompleteStream sends a prompt to Ollama and reads the answer as it's
+// generated, one JSON object per line
+func (p *OllamaProvider) CompleteStream(ctx context.Context, prompt string, h StreamHandler) (Action, error) {
+	p.log.Debug().Str("prompt", prompt).Msg("Streaming prompt to Ollama")
+
+	resp, err := p.sendAction(ctx, prompt, true)
+	if err != nil {
+		return Action{}, err
+	}
+	defer resp.Body.Close()
+
+	parser := newActionParser(h)
+	var response strings.Builder
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var chunk OllamaResponse
+		if err := json.Unmarshal(scanner.Bytes(), &chunk); err != nil {
+			return Action{}, fmt.Errorf("failed to parse Ollama response: %w", err)
+		}
+		response.WriteString(chunk.Response)
+		parser.Write(chunk.Response)
+		if chunk.Done {
+			break
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return Action{}, fmt.Errorf("failed to read response: %w", err)
+	}
+	parser.Close()
+
+	p.log.Debug().Str("response", response.String()).Msg("Received response from Ollama")
+
+	return p.toAction(response.String()), nil
+}
+
+// sendAction asks Ollama for the action of prompt, as JSON
+func (p *OllamaProvider) sendAction(ctx context.Context, prompt string, stream bool) (*http.Response, error) {
 	// Create request
 	reqBody := OllamaRequest{
 		Model:  p.model,
 		Prompt: prompt,
-		System: GetSystemPromptWithStreamer(p.streamerName),
-		Stream: false,
+		System: p.prompts.System(ctx),
+		Stream: stream,
 		Format: "json", // Force JSON output
 		Options: &OllamaOptions{
-			Temperature: 0.3,
-			NumPredict:  500,
+			Temperature: p.temperature,
+			NumPredict:  p.maxTokens,
 		},
 	}
 
 	jsonBody, err := json.Marshal(reqBody)
 	if err != nil {
-		return Action{}, fmt.Errorf("failed to marshal request: %w", err)
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
 	// Create HTTP request
 	req, err := http.NewRequestWithContext(ctx, "POST", p.url+"/api/generate", bytes.NewReader(jsonBody))
 	if err != nil {
-		return Action{}, fmt.Errorf("failed to create request: %w", err)
+		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 	req.Header.Set("Content-Type", "application/json")
 
 	// Send request
 	resp, err := p.client.Do(req)
 	if err != nil {
-		return Action{}, fmt.Errorf("failed to send request to Ollama: %w", err)
-	}
-	defer resp.Body.Close()
-
-	// Read response
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return Action{}, fmt.Errorf("failed to read response: %w", err)
+		return nil, fmt.Errorf("failed to send request to Ollama: %w", err)
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return Action{}, fmt.Errorf("Ollama returned status %d: %s", resp.StatusCode, string(body))
-	}
-
-	// Parse Ollama response
-	var ollamaResp OllamaResponse
-	if err := json.Unmarshal(body, &ollamaResp); err != nil {
-		return Action{}, fmt.Errorf("failed to parse Ollama response: %w", err)
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("Ollama returned status %d: %s", resp.StatusCode, string(body))
 	}
 
-	p.log.Debug().Str("response", ollamaResp.Response).Msg("Received response from Ollama")
+	return resp, nil
+}
 
-	// Parse the action from the response
-	action, err := p.parseAction(ollamaResp.Response)
+// toAction parses the action from the response, or apologizes if it can't
+func (p *OllamaProvider) toAction(response string) Action {
+	action, err := p.parseAction(response)
 	if err != nil {
-		p.log.Warn().Err(err).Str("raw_response", ollamaResp.Response).Msg("Failed to parse action, returning fallback")
+		p.log.Warn().Err(err).Str("raw_response", response).Msg("Failed to parse action, returning fallback")
 		return Action{
 			Action: "none",
 			Params: map[string]interface{}{},
 			Reply:  "Lo siento, no pude entender tu solicitud. ¿Puedes repetirlo?",
-		}, nil
+		}
 	}
 
-	return action, nil
+	return action
 }
 
 // CompleteRaw sends a prompt and returns the raw response
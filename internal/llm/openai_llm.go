@@ -1,12 +1,14 @@
 package llm
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/anastreamer/ana/internal/config"
@@ -17,14 +19,20 @@ import (
 
 const openAIBaseURL = "https://api.openai.com/v1"
 
-// OpenAIProvider implements the Provider interface for OpenAI
+// OpenAIProvider implements the Provider interface for OpenAI and servers
+// with the same API (LM Studio, vLLM, llama.cpp, LocalAI...)
 type OpenAIProvider struct {
-	apiKey       string
-	model        string
-	temperature  float64
-	client       *http.Client
-	log          zerolog.Logger
-	streamerName string
+	name        string
+	baseURL     string
+	headers     map[string]string
+	grammar     bool // Constrain the output with a GBNF grammar (llama.cpp)
+	apiKey      string
+	model       string
+	temperature float64
+	maxTokens   int
+	client      *http.Client
+	log         zerolog.Logger
+	prompts     *Prompts
 }
 
 // OpenAIChatRequest represents a chat completion request
@@ -34,6 +42,8 @@ type OpenAIChatRequest struct {
 	Temperature    float64         `json:"temperature,omitempty"`
 	MaxTokens      int             `json:"max_tokens,omitempty"`
 	ResponseFormat *ResponseFormat `json:"response_format,omitempty"`
+	Stream         bool            `json:"stream,omitempty"`
+	Grammar        string          `json:"grammar,omitempty"` // llama.cpp only
 }
 
 // ResponseFormat specifies the output format
@@ -69,6 +79,17 @@ type OpenAIChatResponse struct {
 	Error *OpenAIError `json:"error,omitempty"`
 }
 
+// OpenAIStreamChunk is a server-sent event of a streamed chat completion
+type OpenAIStreamChunk struct {
+	Choices []struct {
+		Delta struct {
+			Content string `json:"content"`
+		} `json:"delta"`
+		FinishReason string `json:"finish_reason"`
+	} `json:"choices"`
+	Error *OpenAIError `json:"error,omitempty"`
+}
+
 // OpenAIError represents an error from the OpenAI API
 type OpenAIError struct {
 	Message string `json:"message"`
@@ -76,80 +97,78 @@ type OpenAIError struct {
 	Code    string `json:"code"`
 }
 
-// NewOpenAIProvider creates a new OpenAI provider
-func NewOpenAIProvider(cfg config.OpenAILLMConfig, streamerName string) (*OpenAIProvider, error) {
-	if cfg.APIKey == "" {
+// NewOpenAIProvider creates a new OpenAI provider. With a base URL it talks
+// to that server instead, and the API key is optional.
+func NewOpenAIProvider(cfg config.OpenAILLMConfig, prompts *Prompts) (*OpenAIProvider, error) {
+	if cfg.APIKey == "" && cfg.BaseURL == "" {
 		return nil, fmt.Errorf("OpenAI API key is required")
 	}
+	return newOpenAIProvider("openai", cfg, prompts), nil
+}
+
+// NewEndpointProvider creates a provider for the OpenAI-compatible server
+// configured as the endpoint called name
+func NewEndpointProvider(name string, cfg config.OpenAILLMConfig, prompts *Prompts) (*OpenAIProvider, error) {
+	if cfg.BaseURL == "" {
+		return nil, fmt.Errorf("LLM endpoint %s has no base URL", name)
+	}
+	return newOpenAIProvider(name, cfg, prompts), nil
+}
+
+func newOpenAIProvider(name string, cfg config.OpenAILLMConfig, prompts *Prompts) *OpenAIProvider {
+	baseURL := strings.TrimRight(cfg.BaseURL, "/")
+	if baseURL == "" {
+		baseURL = openAIBaseURL
+	}
 
 	temperature := cfg.Temperature
 	if temperature == 0 {
 		temperature = 0.3
 	}
 
+	maxTokens := cfg.MaxTokens
+	if maxTokens == 0 {
+		maxTokens = 500
+	}
+
 	model := cfg.Model
-	if model == "" {
+	if model == "" && baseURL == openAIBaseURL {
 		model = "gpt-4o-mini"
 	}
 
+	log := logger.Component("openai-llm")
+	if name != "openai" {
+		log = log.With().Str("endpoint", name).Logger()
+	}
+
 	return &OpenAIProvider{
-		apiKey:       cfg.APIKey,
-		model:        model,
-		temperature:  temperature,
+		name:        name,
+		baseURL:     baseURL,
+		headers:     cfg.Headers,
+		apiKey:      cfg.APIKey,
+		model:       model,
+		temperature: temperature,
+		maxTokens:   maxTokens,
 		client: &http.Client{
 			Timeout: 30 * time.Second,
 		},
-		log:          logger.Component("openai-llm"),
-		streamerName: streamerName,
-	}, nil
+		log:     log,
+		prompts: prompts,
+	}
 }
 
 // Name returns the provider name
 func (p *OpenAIProvider) Name() string {
-	return "openai"
+	return p.name
 }
 
 // Complete sends a prompt to OpenAI and returns an Action
 func (p *OpenAIProvider) Complete(ctx context.Context, prompt string) (Action, error) {
 	p.log.Debug().Str("prompt", prompt).Msg("Sending prompt to OpenAI")
 
-	// Create request
-	reqBody := OpenAIChatRequest{
-		Model: p.model,
-		Messages: []OpenAIMessage{
-			{
-				Role:    "system",
-				Content: GetSystemPromptWithStreamer(p.streamerName),
-			},
-			{
-				Role:    "user",
-				Content: prompt,
-			},
-		},
-		Temperature: p.temperature,
-		MaxTokens:   500,
-		ResponseFormat: &ResponseFormat{
-			Type: "json_object",
-		},
-	}
-
-	jsonBody, err := json.Marshal(reqBody)
-	if err != nil {
-		return Action{}, fmt.Errorf("failed to marshal request: %w", err)
-	}
-
-	// Create HTTP request
-	req, err := http.NewRequestWithContext(ctx, "POST", openAIBaseURL+"/chat/completions", bytes.NewReader(jsonBody))
-	if err != nil {
-		return Action{}, fmt.Errorf("failed to create request: %w", err)
-	}
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+p.apiKey)
-
-	// Send request
-	resp, err := p.client.Do(req)
+	resp, err := p.sendAction(ctx, prompt, false)
 	if err != nil {
-		return Action{}, fmt.Errorf("failed to send request to OpenAI: %w", err)
+		return Action{}, err
 	}
 	defer resp.Body.Close()
 
@@ -170,10 +189,6 @@ func (p *OpenAIProvider) Complete(ctx context.Context, prompt string) (Action, e
 		return Action{}, fmt.Errorf("OpenAI API error: %s", openAIResp.Error.Message)
 	}
 
-	if resp.StatusCode != http.StatusOK {
-		return Action{}, fmt.Errorf("OpenAI returned status %d: %s", resp.StatusCode, string(body))
-	}
-
 	// Check for valid response
 	if len(openAIResp.Choices) == 0 {
 		return Action{}, fmt.Errorf("no choices in OpenAI response")
@@ -187,7 +202,117 @@ func (p *OpenAIProvider) Complete(ctx context.Context, prompt string) (Action, e
 	content := openAIResp.Choices[0].Message.Content
 	p.log.Debug().Str("response", content).Msg("Received response from OpenAI")
 
-	// Parse the action from the response
+	return p.toAction(content), nil
+}
+
+// CompleteStream sends a prompt to OpenAI and reads the answer as it's
+// generated, from server-sent events
+func (p *OpenAIProvider) CompleteStream(ctx context.Context, prompt string, h StreamHandler) (Action, error) {
+	p.log.Debug().Str("prompt", prompt).Msg("Streaming prompt to OpenAI")
+
+	resp, err := p.sendAction(ctx, prompt, true)
+	if err != nil {
+		return Action{}, err
+	}
+	defer resp.Body.Close()
+
+	parser := newActionParser(h)
+	var content strings.Builder
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data: ")
+		if !ok {
+			continue
+		}
+		if data == "[DONE]" {
+			break
+		}
+
+		var chunk OpenAIStreamChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return Action{}, fmt.Errorf("failed to parse OpenAI response: %w", err)
+		}
+		if chunk.Error != nil {
+			return Action{}, fmt.Errorf("OpenAI API error: %s", chunk.Error.Message)
+		}
+		if len(chunk.Choices) > 0 {
+			content.WriteString(chunk.Choices[0].Delta.Content)
+			parser.Write(chunk.Choices[0].Delta.Content)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return Action{}, fmt.Errorf("failed to read response: %w", err)
+	}
+	parser.Close()
+
+	if content.Len() == 0 {
+		return Action{}, fmt.Errorf("empty content in OpenAI response")
+	}
+	p.log.Debug().Str("response", content.String()).Msg("Received response from OpenAI")
+
+	return p.toAction(content.String()), nil
+}
+
+// sendAction asks OpenAI for the action of prompt, as JSON
+func (p *OpenAIProvider) sendAction(ctx context.Context, prompt string, stream bool) (*http.Response, error) {
+	// Create request
+	reqBody := OpenAIChatRequest{
+		Model: p.model,
+		Messages: []OpenAIMessage{
+			{
+				Role:    "system",
+				Content: p.prompts.System(ctx),
+			},
+			{
+				Role:    "user",
+				Content: prompt,
+			},
+		},
+		Temperature: p.temperature,
+		MaxTokens:   p.maxTokens,
+		Stream:      stream,
+	}
+	if p.grammar {
+		reqBody.Grammar = actionGrammar(p.prompts.Actions())
+	} else {
+		reqBody.ResponseFormat = &ResponseFormat{Type: "json_object"}
+	}
+
+	jsonBody, err := json.Marshal(reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	// Create HTTP request
+	req, err := http.NewRequestWithContext(ctx, "POST", p.baseURL+"/chat/completions", bytes.NewReader(jsonBody))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	p.setHeaders(req)
+
+	// Send request
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request to OpenAI: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		var openAIResp OpenAIChatResponse
+		if json.Unmarshal(body, &openAIResp) == nil && openAIResp.Error != nil {
+			return nil, fmt.Errorf("OpenAI API error: %s", openAIResp.Error.Message)
+		}
+		return nil, fmt.Errorf("OpenAI returned status %d: %s", resp.StatusCode, string(body))
+	}
+
+	return resp, nil
+}
+
+// toAction parses the action from the response, or apologizes if it can't
+func (p *OpenAIProvider) toAction(content string) Action {
 	action, err := p.parseAction(content)
 	if err != nil {
 		p.log.Warn().Err(err).Str("raw_response", content).Msg("Failed to parse action, returning fallback")
@@ -195,10 +320,10 @@ func (p *OpenAIProvider) Complete(ctx context.Context, prompt string) (Action, e
 			Action: "none",
 			Params: map[string]interface{}{},
 			Reply:  "Lo siento, no pude entender tu solicitud. ¿Puedes repetirlo?",
-		}, nil
+		}
 	}
 
-	return action, nil
+	return action
 }
 
 // CompleteRaw sends a prompt and returns the raw response
@@ -219,12 +344,12 @@ func (p *OpenAIProvider) CompleteRaw(ctx context.Context, prompt string) (string
 		return "", fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	req, err := http.NewRequestWithContext(ctx, "POST", openAIBaseURL+"/chat/completions", bytes.NewReader(jsonBody))
+	req, err := http.NewRequestWithContext(ctx, "POST", p.baseURL+"/chat/completions", bytes.NewReader(jsonBody))
 	if err != nil {
 		return "", fmt.Errorf("failed to create request: %w", err)
 	}
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+p.apiKey)
+	p.setHeaders(req)
 
 	resp, err := p.client.Do(req)
 	if err != nil {
@@ -261,11 +386,11 @@ func (p *OpenAIProvider) CompleteRaw(ctx context.Context, prompt string) (string
 // IsAvailable checks if OpenAI is available
 func (p *OpenAIProvider) IsAvailable(ctx context.Context) bool {
 	// Simple check - try to list models
-	req, err := http.NewRequestWithContext(ctx, "GET", openAIBaseURL+"/models", nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", p.baseURL+"/models", nil)
 	if err != nil {
 		return false
 	}
-	req.Header.Set("Authorization", "Bearer "+p.apiKey)
+	p.setHeaders(req)
 
 	resp, err := p.client.Do(req)
 	if err != nil {
@@ -276,6 +401,17 @@ func (p *OpenAIProvider) IsAvailable(ctx context.Context) bool {
 	return resp.StatusCode == http.StatusOK
 }
 
+// setHeaders authenticates req and adds the configured headers, which may
+// replace the authentication
+func (p *OpenAIProvider) setHeaders(req *http.Request) {
+	if p.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+p.apiKey)
+	}
+	for key, value := range p.headers {
+		req.Header.Set(key, value)
+	}
+}
+
 // Close releases resources
 func (p *OpenAIProvider) Close() error {
 	return nil
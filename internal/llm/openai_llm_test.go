@@ -0,0 +1,244 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/anastreamer/ana/internal/config"
+)
+
+// newTestPrompts returns the built-in prompts, with no prompts directory
+func newTestPrompts(t *testing.T) *Prompts {
+	t.Helper()
+	cfg := config.DefaultConfig()
+	cfg.General.Language = "es"
+	cfg.LLM.PromptsDir = t.TempDir()
+	prompts, err := NewPrompts(cfg)
+	if err != nil {
+		t.Fatalf("NewPrompts: %v", err)
+	}
+	return prompts
+}
+
+// fakeLLMServer is an OpenAI-compatible server (and llama.cpp's /health)
+// that always answers content
+type fakeLLMServer struct {
+	*httptest.Server
+	content string
+	healthy bool
+
+	mu       sync.Mutex
+	requests []OpenAIChatRequest
+	headers  []http.Header
+}
+
+func newFakeLLMServer(t *testing.T, content string, healthy bool) *fakeLLMServer {
+	t.Helper()
+	s := &fakeLLMServer{content: content, healthy: healthy}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+		if !s.healthy {
+			http.Error(w, `{"error":{"message":"Loading model"}}`, http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprint(w, `{"status":"ok"}`)
+	})
+	mux.HandleFunc("/v1/models", func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.headers = append(s.headers, r.Header.Clone())
+		s.mu.Unlock()
+		if !s.healthy {
+			http.Error(w, "down", http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprint(w, `{"data":[{"id":"test-model"}]}`)
+	})
+	mux.HandleFunc("/v1/chat/completions", s.chat)
+	s.Server = httptest.NewServer(mux)
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *fakeLLMServer) chat(w http.ResponseWriter, r *http.Request) {
+	var req OpenAIChatRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.mu.Lock()
+	s.requests = append(s.requests, req)
+	s.headers = append(s.headers, r.Header.Clone())
+	s.mu.Unlock()
+
+	if !req.Stream {
+		resp := map[string]interface{}{
+			"choices": []map[string]interface{}{
+				{"message": map[string]string{"role": "assistant", "content": s.content}},
+			},
+		}
+		json.NewEncoder(w).Encode(resp)
+		return
+	}
+
+	// A few characters per event, as servers send tokens
+	content := []rune(s.content)
+	for i := 0; i < len(content); i += 5 {
+		end := min(i+5, len(content))
+		chunk := map[string]interface{}{
+			"choices": []map[string]interface{}{
+				{"delta": map[string]string{"content": string(content[i:end])}},
+			},
+		}
+		data, _ := json.Marshal(chunk)
+		fmt.Fprintf(w, "data: %s\n\n", data)
+	}
+	fmt.Fprint(w, "data: [DONE]\n\n")
+}
+
+// lastRequest returns the last chat request and its headers
+func (s *fakeLLMServer) lastRequest(t *testing.T) (OpenAIChatRequest, http.Header) {
+	t.Helper()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.requests) == 0 {
+		t.Fatal("the server got no chat request")
+	}
+	return s.requests[len(s.requests)-1], s.headers[len(s.headers)-1]
+}
+
+// chats returns how many chat requests the server got
+func (s *fakeLLMServer) chats() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.requests)
+}
+
+const testAction = `{"action": "music.next", "params": {}, "reply": "Siguiente canción. Que la disfrutes."}`
+
+func TestEndpointProvider(t *testing.T) {
+	server := newFakeLLMServer(t, testAction, true)
+	p, err := NewEndpointProvider("lmstudio", config.OpenAILLMConfig{
+		BaseURL: server.URL + "/v1/",
+		APIKey:  "secret",
+		Model:   "local-model",
+		Headers: map[string]string{"X-Team": "ana"},
+	}, newTestPrompts(t))
+	if err != nil {
+		t.Fatalf("NewEndpointProvider: %v", err)
+	}
+
+	if p.Name() != "lmstudio" {
+		t.Errorf("Name() = %q, want lmstudio", p.Name())
+	}
+	if !p.IsAvailable(context.Background()) {
+		t.Error("IsAvailable() = false with the server up")
+	}
+
+	action, err := p.Complete(context.Background(), "siguiente canción")
+	if err != nil {
+		t.Fatalf("Complete: %v", err)
+	}
+	if action.Action != "music.next" || action.Reply != "Siguiente canción. Que la disfrutes." {
+		t.Errorf("Complete() = %+v", action)
+	}
+
+	req, header := server.lastRequest(t)
+	if req.Model != "local-model" {
+		t.Errorf("model = %q, want local-model", req.Model)
+	}
+	if len(req.Messages) != 2 || req.Messages[0].Role != "system" || req.Messages[1].Content != "siguiente canción" {
+		t.Errorf("messages = %+v", req.Messages)
+	}
+	if req.ResponseFormat == nil || req.ResponseFormat.Type != "json_object" || req.Grammar != "" {
+		t.Errorf("response_format = %+v, grammar = %q, want JSON mode", req.ResponseFormat, req.Grammar)
+	}
+	if got := header.Get("Authorization"); got != "Bearer secret" {
+		t.Errorf("Authorization = %q", got)
+	}
+	if got := header.Get("X-Team"); got != "ana" {
+		t.Errorf("X-Team = %q", got)
+	}
+}
+
+func TestEndpointProviderHeadersReplaceAuth(t *testing.T) {
+	server := newFakeLLMServer(t, testAction, true)
+	p, err := NewEndpointProvider("proxy", config.OpenAILLMConfig{
+		BaseURL: server.URL + "/v1",
+		APIKey:  "secret",
+		Headers: map[string]string{"Authorization": "Token other"},
+	}, newTestPrompts(t))
+	if err != nil {
+		t.Fatalf("NewEndpointProvider: %v", err)
+	}
+
+	if _, err := p.Complete(context.Background(), "hola"); err != nil {
+		t.Fatalf("Complete: %v", err)
+	}
+	if _, header := server.lastRequest(t); header.Get("Authorization") != "Token other" {
+		t.Errorf("Authorization = %q, want the configured header", header.Get("Authorization"))
+	}
+}
+
+func TestEndpointProviderStream(t *testing.T) {
+	server := newFakeLLMServer(t, testAction, true)
+	p, err := NewEndpointProvider("vllm", config.OpenAILLMConfig{BaseURL: server.URL + "/v1"}, newTestPrompts(t))
+	if err != nil {
+		t.Fatalf("NewEndpointProvider: %v", err)
+	}
+
+	var actions []string
+	var sentences []string
+	action, err := p.CompleteStream(context.Background(), "siguiente canción", StreamHandler{
+		OnAction:   func(a Action) { actions = append(actions, a.Action) },
+		OnSentence: func(s string) { sentences = append(sentences, s) },
+	})
+	if err != nil {
+		t.Fatalf("CompleteStream: %v", err)
+	}
+
+	if action.Action != "music.next" {
+		t.Errorf("CompleteStream() = %+v", action)
+	}
+	if len(actions) != 1 || actions[0] != "music.next" {
+		t.Errorf("OnAction calls = %v, want one music.next", actions)
+	}
+	if strings.Join(sentences, " ") != "Siguiente canción. Que la disfrutes." {
+		t.Errorf("sentences = %q", sentences)
+	}
+	if req, header := server.lastRequest(t); !req.Stream || header.Get("Authorization") != "" {
+		t.Errorf("stream = %v, Authorization = %q", req.Stream, header.Get("Authorization"))
+	}
+}
+
+func TestEndpointProviderErrors(t *testing.T) {
+	if _, err := NewEndpointProvider("empty", config.OpenAILLMConfig{}, newTestPrompts(t)); err == nil {
+		t.Error("NewEndpointProvider without a base URL succeeded")
+	}
+	if _, err := NewOpenAIProvider(config.OpenAILLMConfig{}, newTestPrompts(t)); err == nil {
+		t.Error("NewOpenAIProvider without an API key or base URL succeeded")
+	}
+
+	server := newFakeLLMServer(t, "no es JSON", false)
+	p, err := NewEndpointProvider("broken", config.OpenAILLMConfig{BaseURL: server.URL + "/v1"}, newTestPrompts(t))
+	if err != nil {
+		t.Fatalf("NewEndpointProvider: %v", err)
+	}
+	if p.IsAvailable(context.Background()) {
+		t.Error("IsAvailable() = true with /models failing")
+	}
+
+	// Answers that aren't an action become an apology
+	action, err := p.Complete(context.Background(), "hola")
+	if err != nil {
+		t.Fatalf("Complete: %v", err)
+	}
+	if action.Action != "none" || action.Reply == "" {
+		t.Errorf("Complete() = %+v, want the fallback", action)
+	}
+}
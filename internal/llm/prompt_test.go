@@ -0,0 +1,78 @@
+package llm
+
+import "testing"
+
+func TestIsAnaDeactivated(t *testing.T) {
+	tests := []struct {
+		input string
+		want  bool
+	}{
+		{"adiós", true},
+		{"Adiós, Ana.", true},
+		{"vale, gracias, adiós", true},
+		{"Ana, eso es todo", true},
+		{"fin de la sesión", true},
+		{"detente", true},
+		{"¡Silencio!", true},
+		{"cállate ana", true},
+		{"Ana, para", true},
+		{"para, Ana", true},
+		{"termina", true},
+		{"stop", true},
+		{"no más", true},
+		{"goodbye", true},
+		{"Okay, thanks, bye Ana.", true},
+		{"Ana, that's all", true},
+		{"see you later", true},
+		{"be quiet", true},
+
+		// Commands for the brain that use the same words
+		{"termina la grabación", false},
+		{"Ana, termina la grabación", false},
+		{"deja de grabar", false},
+		{"Ana, deja de grabar", false},
+		{"para la grabación", false},
+		{"para la música", false},
+		{"pon música para ana", false},
+		{"stop the music", false},
+		{"say goodbye to the chat", false},
+		{"that's all for the clip, make it", false},
+		{"adiós a la escena de inicio y pon la de juego", false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		if got := IsAnaDeactivated(tt.input); got != tt.want {
+			t.Errorf("IsAnaDeactivated(%q) = %v, want %v", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestIsAnaInterrupted(t *testing.T) {
+	tests := []struct {
+		input string
+		want  bool
+	}{
+		{"para", true},
+		{"Ana, cállate", true},
+		{"¡Basta!", true},
+		{"espera un momento", true},
+		{"stop", true},
+		{"Ana, be quiet", true},
+		{"shut up", true},
+		{"wait, wait", true},
+		{"hold on", true},
+
+		// Her own reply picked up by the mic, or a longer command
+		{"he puesto música para ti", false},
+		{"para la música y pon la escena de juego", false},
+		{"I stopped the music", false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		if got := IsAnaInterrupted(tt.input); got != tt.want {
+			t.Errorf("IsAnaInterrupted(%q) = %v, want %v", tt.input, got, tt.want)
+		}
+	}
+}
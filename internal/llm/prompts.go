@@ -0,0 +1,384 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/anastreamer/ana/internal/config"
+	"github.com/anastreamer/ana/pkg/logger"
+	"github.com/rs/zerolog"
+)
+
+// builtinPrompts are the system prompt templates shipped with Ana, one
+// <lang>.tmpl per language
+//
+//go:embed prompts/*.tmpl
+var builtinPrompts embed.FS
+
+// reloadInterval is how often the prompts directory is checked for changes
+const reloadInterval = 2 * time.Second
+
+// builtinActions are handled by the brain itself, with no executor
+var builtinActions = []string{"calc", "system.status", "system.help", "none"}
+
+// PromptData are the variables of a prompt template
+type PromptData struct {
+	StreamerName string
+	Language     string
+	Actions      []string          // Actions Ana can run, nil if unknown
+	Macros       map[string]string // Snippets from llm.macros
+	Scene        string            // Current OBS scene, "" if unknown
+	Personas     []string          // Personas system.persona can switch to
+	Persona      string            // Personality of the active persona, "" for none
+}
+
+// Enabled tells whether Ana can run actions of a group like "obs" or
+// "spotify", so templates can leave out the others. It's true for every
+// group while the actions are unknown.
+func (d PromptData) Enabled(group string) bool {
+	if d.Actions == nil {
+		return true
+	}
+	for _, action := range d.Actions {
+		if strings.HasPrefix(action, group+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// Prompts holds the system prompt template of each language Ana speaks.
+// The built-in ones can be replaced or extended with <lang>.tmpl files in
+// the prompts directory, which are reloaded when they change.
+type Prompts struct {
+	dir          string
+	streamerName string
+	language     string // Used when a command has no language
+	macros       map[string]string
+	log          zerolog.Logger
+
+	mu        sync.RWMutex
+	personas  map[string]*template.Template // Personality of each persona
+	builtin   map[string]*template.Template
+	templates map[string]*template.Template // Built-in and from dir
+	files     map[string]time.Time          // Templates in dir when loaded
+	actions   func() []string
+	scene     func(ctx context.Context) (string, error)
+	persona   string
+}
+
+// NewPrompts loads the built-in templates and the ones in
+// cfg.LLM.PromptsDir
+func NewPrompts(cfg *config.Config) (*Prompts, error) {
+	p := &Prompts{
+		dir:          cfg.LLM.PromptsDir,
+		streamerName: cfg.General.StreamerName,
+		language:     cfg.General.Language,
+		macros:       cfg.LLM.Macros,
+		log:          logger.Component("prompts"),
+		builtin:      map[string]*template.Template{},
+		personas:     map[string]*template.Template{},
+	}
+	if p.streamerName == "" {
+		p.streamerName = "Streamer"
+	}
+
+	entries, err := builtinPrompts.ReadDir("prompts")
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		data, err := builtinPrompts.ReadFile("prompts/" + e.Name())
+		if err != nil {
+			return nil, err
+		}
+		tmpl, err := p.parse(e.Name(), string(data))
+		if err != nil {
+			return nil, err
+		}
+		p.builtin[strings.TrimSuffix(e.Name(), ".tmpl")] = tmpl
+	}
+
+	for name, persona := range cfg.Personas.Profiles {
+		tmpl, err := p.parse("persona "+name, persona.Prompt)
+		if err != nil {
+			return nil, err
+		}
+		p.personas[name] = tmpl
+	}
+
+	if err := p.load(); err != nil {
+		return nil, err
+	}
+
+	if _, ok := p.templates[p.language]; !ok {
+		return nil, fmt.Errorf("no system prompt for the main language %s (add %s.tmpl to %s)", p.language, p.language, p.dir)
+	}
+	for _, lang := range cfg.General.Languages {
+		if _, ok := p.templates[lang]; !ok {
+			p.log.Warn().Str("language", lang).Msg("No system prompt for language, Ana will answer it with the main one")
+		}
+	}
+
+	return p, nil
+}
+
+// SetActions sets the function listing the actions the executors can run
+func (p *Prompts) SetActions(fn func() []string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.actions = fn
+}
+
+// SetScene sets the function returning the current OBS scene
+func (p *Prompts) SetScene(fn func(ctx context.Context) (string, error)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.scene = fn
+}
+
+// SetPersona sets the persona whose personality goes into the prompts, ""
+// for none
+func (p *Prompts) SetPersona(name string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.persona = name
+}
+
+// System returns the system prompt for the language of the command in ctx,
+// or for the main language if it has none
+func (p *Prompts) System(ctx context.Context) string {
+	data := p.Data(ctx, LanguageFromContext(ctx))
+	prompt, err := p.Render(data)
+	if err != nil {
+		// A template from the directory that parsed but fails to run
+		p.log.Error().Err(err).Str("language", data.Language).Msg("Cannot render system prompt, using the built-in one")
+		prompt, _ = p.render(p.builtin, data)
+	}
+	return prompt
+}
+
+// Data returns the template variables for a command in lang
+func (p *Prompts) Data(ctx context.Context, lang string) PromptData {
+	p.mu.RLock()
+	actions, scene, persona := p.actions, p.scene, p.persona
+	personas := make([]string, 0, len(p.personas))
+	for name := range p.personas {
+		personas = append(personas, name)
+	}
+	personaTmpl := p.personas[persona]
+	p.mu.RUnlock()
+
+	if lang == "" {
+		lang = p.language
+	}
+	data := PromptData{
+		StreamerName: p.streamerName,
+		Language:     lang,
+		Macros:       p.macros,
+	}
+	if len(personas) > 0 {
+		data.Personas = personas
+		sort.Strings(data.Personas)
+	}
+	data.Actions = listActions(actions, len(personas) > 0)
+	if scene != nil {
+		if name, err := scene(ctx); err == nil {
+			data.Scene = name
+		}
+	}
+	if personaTmpl != nil {
+		var out bytes.Buffer
+		if err := personaTmpl.Execute(&out, data); err != nil {
+			p.log.Error().Err(err).Str("persona", persona).Msg("Cannot render persona prompt")
+		}
+		data.Persona = strings.TrimSpace(out.String())
+	}
+	return data
+}
+
+// Actions returns every action Ana can run, nil if the executors are unknown
+func (p *Prompts) Actions() []string {
+	p.mu.RLock()
+	actions, hasPersonas := p.actions, len(p.personas) > 0
+	p.mu.RUnlock()
+	return listActions(actions, hasPersonas)
+}
+
+// listActions returns the built-in actions and the ones of the executors,
+// with system.persona if there are personas to switch to. It's called
+// without p.mu, as actions may take other locks.
+func listActions(actions func() []string, hasPersonas bool) []string {
+	if actions == nil {
+		return nil
+	}
+	list := append(append([]string{}, builtinActions...), actions()...)
+	if hasPersonas {
+		list = append(list, "system.persona")
+	}
+	sort.Strings(list)
+	return list
+}
+
+// Render runs the template of data.Language, or of the main language if
+// it has none
+func (p *Prompts) Render(data PromptData) (string, error) {
+	p.mu.RLock()
+	templates := p.templates
+	p.mu.RUnlock()
+	return p.render(templates, data)
+}
+
+func (p *Prompts) render(templates map[string]*template.Template, data PromptData) (string, error) {
+	tmpl, ok := templates[data.Language]
+	if !ok {
+		tmpl, ok = templates[p.language]
+	}
+	if !ok {
+		return "", fmt.Errorf("no system prompt for %s", data.Language)
+	}
+
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, data); err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
+
+// Languages returns the languages that have a system prompt
+func (p *Prompts) Languages() []string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	langs := make([]string, 0, len(p.templates))
+	for lang := range p.templates {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	return langs
+}
+
+// Watch reloads the templates in the background whenever a file in the
+// prompts directory changes, until ctx is done. A template with errors is
+// reported and the previous one kept.
+func (p *Prompts) Watch(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(reloadInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if !p.changed() {
+					continue
+				}
+				if err := p.load(); err != nil {
+					p.log.Error().Err(err).Msg("Cannot reload prompts, keeping the previous ones")
+					continue
+				}
+				p.log.Info().Strs("languages", p.Languages()).Msg("Prompts reloaded")
+			}
+		}
+	}()
+}
+
+// load parses the templates in the directory over the built-in ones. Each
+// one is tried with sample data so mistakes show up now rather than when
+// Ana is asked something.
+func (p *Prompts) load() error {
+	files := p.scan()
+	templates := make(map[string]*template.Template, len(p.builtin)+len(files))
+	for lang, tmpl := range p.builtin {
+		templates[lang] = tmpl
+	}
+
+	for file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return fmt.Errorf("failed to read prompt %s: %w", file, err)
+		}
+		tmpl, err := p.parse(filepath.Base(file), string(data))
+		if err != nil {
+			return err
+		}
+
+		lang := strings.TrimSuffix(filepath.Base(file), ".tmpl")
+		sample := PromptData{StreamerName: p.streamerName, Language: lang, Macros: p.macros}
+		if err := tmpl.Execute(&bytes.Buffer{}, sample); err != nil {
+			return fmt.Errorf("prompt %s: %w", file, err)
+		}
+		templates[lang] = tmpl
+		p.log.Debug().Str("file", file).Str("language", lang).Msg("Loaded system prompt")
+	}
+
+	p.mu.Lock()
+	p.templates = templates
+	p.files = files
+	p.mu.Unlock()
+	return nil
+}
+
+// parse parses a prompt template. Unknown macros render empty.
+func (p *Prompts) parse(name, text string) (*template.Template, error) {
+	tmpl, err := template.New(name).Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid prompt template: %w", err)
+	}
+	return tmpl, nil
+}
+
+// scan lists the templates in the directory with their modification time
+func (p *Prompts) scan() map[string]time.Time {
+	files := map[string]time.Time{}
+	matches, _ := filepath.Glob(filepath.Join(p.dir, "*.tmpl"))
+	for _, file := range matches {
+		if info, err := os.Stat(file); err == nil {
+			files[file] = info.ModTime()
+		}
+	}
+	return files
+}
+
+// changed tells whether templates were added, removed or modified since
+// they were loaded
+func (p *Prompts) changed() bool {
+	files := p.scan()
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if len(files) != len(p.files) {
+		return true
+	}
+	for file, modified := range files {
+		if loaded, ok := p.files[file]; !ok || !loaded.Equal(modified) {
+			return true
+		}
+	}
+	return false
+}
+
+// languageKey is the context key of a command's language
+type languageKey struct{}
+
+// WithLanguage tells the providers that the command in ctx was said in lang,
+// so the reply is in lang too
+func WithLanguage(ctx context.Context, lang string) context.Context {
+	return context.WithValue(ctx, languageKey{}, lang)
+}
+
+// LanguageFromContext returns the language set by WithLanguage, or ""
+func LanguageFromContext(ctx context.Context) string {
+	lang, _ := ctx.Value(languageKey{}).(string)
+	return lang
+}
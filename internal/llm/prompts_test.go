@@ -0,0 +1,250 @@
+package llm
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/anastreamer/ana/internal/config"
+)
+
+// promptsConfig returns a config with prompts in dir, a persona and macros
+func promptsConfig(dir string) *config.Config {
+	cfg := config.DefaultConfig()
+	cfg.General.Language = "es"
+	cfg.General.StreamerName = "Lucía"
+	cfg.LLM.PromptsDir = dir
+	cfg.LLM.Macros = map[string]string{"horario": "De lunes a viernes a las 18:00"}
+	cfg.Personas.Profiles = map[string]config.PersonaConfig{
+		"pirata": {Prompt: "Hablas como un pirata con {{.StreamerName}}."},
+	}
+	return cfg
+}
+
+func TestBuiltinPromptsRender(t *testing.T) {
+	p, err := NewPrompts(promptsConfig(t.TempDir()))
+	if err != nil {
+		t.Fatalf("NewPrompts: %v", err)
+	}
+
+	entries, err := builtinPrompts.ReadDir("prompts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	headers := map[string]string{"es": "ACCIONES DISPONIBLES", "en": "AVAILABLE ACTIONS"}
+
+	for _, e := range entries {
+		lang := strings.TrimSuffix(e.Name(), ".tmpl")
+		header, ok := headers[lang]
+		if !ok {
+			t.Errorf("shipped prompt %s has no test", e.Name())
+			continue
+		}
+
+		t.Run(lang, func(t *testing.T) {
+			ctx := WithLanguage(context.Background(), lang)
+
+			// Every integration while the actions are unknown
+			p.SetActions(nil)
+			p.SetScene(nil)
+			p.SetPersona("")
+			prompt := p.System(ctx)
+			for _, want := range []string{header, "Lucía", "- twitch.clip:", "- obs.scene:", "== SPOTIFY ==", "- system.persona:", `"pirata"`, "De lunes a viernes"} {
+				if !strings.Contains(prompt, want) {
+					t.Errorf("prompt has no %q", want)
+				}
+			}
+
+			// Only the enabled ones, with the scene and the persona
+			p.SetActions(func() []string { return []string{"music.play", "music.next"} })
+			p.SetScene(func(ctx context.Context) (string, error) { return "Charla", nil })
+			p.SetPersona("pirata")
+			prompt = p.System(ctx)
+			for _, want := range []string{header, "- music.next:", `"Charla"`, "Hablas como un pirata con Lucía."} {
+				if !strings.Contains(prompt, want) {
+					t.Errorf("prompt has no %q", want)
+				}
+			}
+			// The examples may still mention them, not the action list
+			for _, unwanted := range []string{"- twitch.clip:", "- obs.scene:", "== SPOTIFY =="} {
+				if strings.Contains(prompt, unwanted) {
+					t.Errorf("prompt has %q with its integration disabled", unwanted)
+				}
+			}
+
+			if strings.Contains(prompt, "<no value>") {
+				t.Error("prompt has an empty variable")
+			}
+		})
+	}
+}
+
+func TestPromptsLanguageFallback(t *testing.T) {
+	p, err := NewPrompts(promptsConfig(t.TempDir()))
+	if err != nil {
+		t.Fatalf("NewPrompts: %v", err)
+	}
+
+	main := p.System(context.Background())
+	if got := p.System(WithLanguage(context.Background(), "fr")); got != main {
+		t.Error("a language without a prompt does not use the main one")
+	}
+	if !strings.Contains(main, "ACCIONES DISPONIBLES") {
+		t.Error("the main language prompt is not the Spanish one")
+	}
+
+	cfg := promptsConfig(t.TempDir())
+	cfg.General.Language = "fr"
+	if _, err := NewPrompts(cfg); err == nil {
+		t.Error("NewPrompts succeeded with no prompt for the main language")
+	}
+}
+
+// writePrompt writes a template to dir with the given modification time,
+// so reloads don't depend on the file system's time resolution
+func writePrompt(t *testing.T, dir, name, text string, modified time.Time) {
+	t.Helper()
+	file := filepath.Join(dir, name)
+	if err := os.WriteFile(file, []byte(text), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(file, modified, modified); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPromptsReload(t *testing.T) {
+	dir := t.TempDir()
+	start := time.Now().Add(-time.Hour)
+	writePrompt(t, dir, "es.tmpl", "Hola {{.StreamerName}}, v1", start)
+
+	p, err := NewPrompts(promptsConfig(dir))
+	if err != nil {
+		t.Fatalf("NewPrompts: %v", err)
+	}
+	ctx := context.Background()
+	if got := p.System(ctx); got != "Hola Lucía, v1" {
+		t.Fatalf("System() = %q, want the template from the directory", got)
+	}
+	if p.changed() {
+		t.Error("changed() = true right after loading")
+	}
+
+	// A changed file is reloaded
+	writePrompt(t, dir, "es.tmpl", "Hola {{.StreamerName}}, v2 {{.Macros.horario}}", start.Add(time.Minute))
+	if !p.changed() {
+		t.Fatal("changed() = false after editing a template")
+	}
+	if err := p.load(); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if got := p.System(ctx); got != "Hola Lucía, v2 De lunes a viernes a las 18:00" {
+		t.Errorf("System() = %q after the reload", got)
+	}
+
+	// A new language is added
+	writePrompt(t, dir, "fr.tmpl", "Bonjour {{.StreamerName}}", start.Add(2*time.Minute))
+	if !p.changed() {
+		t.Fatal("changed() = false after adding a template")
+	}
+	if err := p.load(); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if got := strings.Join(p.Languages(), ","); got != "en,es,fr" {
+		t.Errorf("Languages() = %s, want en,es,fr", got)
+	}
+	if got := p.System(WithLanguage(ctx, "fr")); got != "Bonjour Lucía" {
+		t.Errorf("System(fr) = %q", got)
+	}
+
+	// A broken one keeps the previous templates
+	writePrompt(t, dir, "es.tmpl", "Hola {{.StreamerName", start.Add(3*time.Minute))
+	if err := p.load(); err == nil {
+		t.Error("load succeeded with a broken template")
+	}
+	if got := p.System(ctx); got != "Hola Lucía, v2 De lunes a viernes a las 18:00" {
+		t.Errorf("System() = %q after a failed reload, want the previous one", got)
+	}
+	writePrompt(t, dir, "es.tmpl", "{{.Nope}}", start.Add(4*time.Minute))
+	if err := p.load(); err == nil {
+		t.Error("load succeeded with a template that fails to run")
+	}
+
+	// Removing the file goes back to the built-in one
+	if err := os.Remove(filepath.Join(dir, "es.tmpl")); err != nil {
+		t.Fatal(err)
+	}
+	if !p.changed() {
+		t.Fatal("changed() = false after removing a template")
+	}
+	if err := p.load(); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if got := p.System(ctx); !strings.Contains(got, "ACCIONES DISPONIBLES") {
+		t.Errorf("System() = %q, want the built-in prompt", got)
+	}
+}
+
+func TestPromptsWatch(t *testing.T) {
+	dir := t.TempDir()
+	start := time.Now().Add(-time.Hour)
+	writePrompt(t, dir, "en.tmpl", "v1", start)
+
+	p, err := NewPrompts(promptsConfig(dir))
+	if err != nil {
+		t.Fatalf("NewPrompts: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	p.Watch(ctx)
+
+	writePrompt(t, dir, "en.tmpl", "v2", start.Add(time.Minute))
+	en := WithLanguage(context.Background(), "en")
+	deadline := time.Now().Add(3 * reloadInterval)
+	for p.System(en) != "v2" {
+		if time.Now().After(deadline) {
+			t.Fatalf("System(en) = %q, the change was not reloaded", p.System(en))
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func TestPromptsConcurrentReload(t *testing.T) {
+	dir := t.TempDir()
+	writePrompt(t, dir, "es.tmpl", "{{.StreamerName}} {{len .Actions}} {{.Persona}}", time.Now())
+
+	p, err := NewPrompts(promptsConfig(dir))
+	if err != nil {
+		t.Fatalf("NewPrompts: %v", err)
+	}
+	p.SetActions(func() []string { return []string{"music.next"} })
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				switch i {
+				case 0:
+					if err := p.load(); err != nil {
+						t.Error(err)
+					}
+				case 1:
+					p.SetPersona([]string{"", "pirata"}[j%2])
+				case 2:
+					if actions := p.Actions(); len(actions) == 0 {
+						t.Error("Actions() is empty")
+					}
+				default:
+					p.System(context.Background())
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,254 @@
+package llm
+
+import (
+	"encoding/json"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// StreamHandler receives a completion while the LLM writes it
+type StreamHandler struct {
+	// OnAction is called once the action and its params are complete,
+	// usually before the reply is
+	OnAction func(action Action)
+
+	// OnSentence is called with each sentence of the reply as soon as it's
+	// complete
+	OnSentence func(sentence string)
+}
+
+// actionParser reads the JSON of an Action as it arrives, reporting its
+// fields to a StreamHandler before the whole object is there. Only the top
+// level of the object is tracked; nested values are taken whole.
+type actionParser struct {
+	handler StreamHandler
+
+	buf      []byte
+	pos      int  // Next byte to scan
+	depth    int  // 0 before the object, 1 inside it
+	inString bool // Inside a string at any depth
+	escape   bool // After a backslash in a string
+	strStart int  // Opening quote of the current string
+	expKey   bool // A key comes next at depth 1
+	key      string
+	valStart int // Start of the current top-level value, -1 if none
+
+	action     Action
+	hasAction  bool
+	hasParams  bool
+	dispatched bool
+	done       bool // The object is closed
+
+	sent int // Bytes of the decoded reply already reported as sentences
+}
+
+// newActionParser creates a parser reporting to h
+func newActionParser(h StreamHandler) *actionParser {
+	return &actionParser{handler: h, valStart: -1}
+}
+
+// Write adds the next piece of the LLM's output
+func (p *actionParser) Write(chunk string) {
+	p.buf = append(p.buf, chunk...)
+
+	for ; p.pos < len(p.buf) && !p.done; p.pos++ {
+		c := p.buf[p.pos]
+
+		if p.inString {
+			switch {
+			case p.escape:
+				p.escape = false
+			case c == '\\':
+				p.escape = true
+			case c == '"':
+				p.inString = false
+				if p.depth == 1 && p.expKey {
+					json.Unmarshal(p.buf[p.strStart:p.pos+1], &p.key)
+					p.expKey = false
+				} else if p.depth == 1 && p.valStart == p.strStart {
+					p.field(p.buf[p.valStart : p.pos+1])
+				}
+			}
+			continue
+		}
+
+		switch {
+		case c == '"':
+			p.inString = true
+			p.strStart = p.pos
+			if p.depth == 1 && !p.expKey && p.valStart < 0 {
+				p.valStart = p.pos
+			}
+
+		case c == '{' && p.depth == 0:
+			p.depth = 1
+			p.expKey = true
+
+		case c == '{' || c == '[':
+			if p.depth == 1 && p.valStart < 0 {
+				p.valStart = p.pos
+			}
+			p.depth++
+
+		case c == '}' || c == ']':
+			if p.depth == 1 {
+				// A number, bool or null ends with the object
+				if p.valStart >= 0 {
+					p.field(p.buf[p.valStart:p.pos])
+				}
+				p.depth = 0
+				p.done = true
+				p.dispatch()
+				continue
+			}
+			p.depth--
+			if p.depth == 1 && p.valStart >= 0 {
+				p.field(p.buf[p.valStart : p.pos+1])
+			}
+
+		case c == ',' && p.depth == 1:
+			if p.valStart >= 0 {
+				p.field(p.buf[p.valStart:p.pos])
+			}
+			p.expKey = true
+
+		case p.depth == 1 && !p.expKey && p.valStart < 0 && c != ':' && !unicode.IsSpace(rune(c)):
+			p.valStart = p.pos
+		}
+	}
+
+	p.streamReply()
+}
+
+// Close reports the end of the reply if the output stopped before the
+// object was closed
+func (p *actionParser) Close() {
+	if p.done {
+		return
+	}
+	p.dispatch()
+	p.streamReply()
+	if p.inReply() {
+		p.sentences(p.partialReply(), true)
+	}
+}
+
+// field stores a complete top-level value
+func (p *actionParser) field(raw []byte) {
+	p.valStart = -1
+
+	switch p.key {
+	case "action":
+		if json.Unmarshal(raw, &p.action.Action) == nil {
+			p.hasAction = true
+		}
+	case "params":
+		p.hasParams = json.Unmarshal(raw, &p.action.Params) == nil
+	case "reply":
+		if json.Unmarshal(raw, &p.action.Reply) == nil {
+			p.sentences(p.action.Reply, true)
+		}
+	}
+
+	if p.hasAction && p.hasParams {
+		p.dispatch()
+	}
+}
+
+// dispatch reports the action once it's known
+func (p *actionParser) dispatch() {
+	if p.dispatched || !p.hasAction {
+		return
+	}
+	p.dispatched = true
+
+	if p.action.Params == nil {
+		p.action.Params = map[string]interface{}{}
+	}
+	if p.handler.OnAction != nil {
+		p.handler.OnAction(p.action)
+	}
+}
+
+// inReply tells whether the reply string is being written
+func (p *actionParser) inReply() bool {
+	return p.inString && p.depth == 1 && p.key == "reply" && p.valStart == p.strStart
+}
+
+// streamReply reports the sentences the reply has so far
+func (p *actionParser) streamReply() {
+	if p.inReply() {
+		p.sentences(p.partialReply(), false)
+	}
+}
+
+// partialReply decodes the reply written so far, leaving out an escape
+// sequence or character that isn't complete yet
+func (p *actionParser) partialReply() string {
+	raw := p.buf[p.valStart+1 : p.pos]
+	for cut := 0; cut <= 6 && cut <= len(raw); cut++ {
+		var reply string
+		if json.Unmarshal([]byte(`"`+string(raw[:len(raw)-cut])+`"`), &reply) == nil {
+			if !strings.HasSuffix(reply, string(utf8.RuneError)) {
+				return reply
+			}
+		}
+	}
+	return ""
+}
+
+// sentences reports the complete sentences of reply not reported yet. When
+// the reply is final, whatever is left is the last sentence.
+func (p *actionParser) sentences(reply string, final bool) {
+	if p.sent > len(reply) {
+		return
+	}
+
+	for {
+		rest := reply[p.sent:]
+		end := sentenceEnd(rest)
+		if end < 0 {
+			break
+		}
+		p.emit(rest[:end])
+		p.sent += end
+	}
+
+	if final {
+		p.emit(reply[p.sent:])
+		p.sent = len(reply)
+	}
+}
+
+// emit reports a sentence, if it has anything to say
+func (p *actionParser) emit(sentence string) {
+	sentence = strings.TrimSpace(sentence)
+	if sentence != "" && p.handler.OnSentence != nil {
+		p.handler.OnSentence(sentence)
+	}
+}
+
+// sentenceEnd returns where the first sentence of s ends, after its
+// punctuation and the space that follows, or -1 if it isn't complete yet.
+// The space avoids cutting numbers like "2.5".
+func sentenceEnd(s string) int {
+	for i, r := range s {
+		if !strings.ContainsRune(".!?…", r) {
+			continue
+		}
+
+		j := i + utf8.RuneLen(r)
+		for j < len(s) {
+			next, size := utf8.DecodeRuneInString(s[j:])
+			if !strings.ContainsRune(".!?…\"'»)", next) {
+				break
+			}
+			j += size
+		}
+		if j < len(s) && (s[j] == ' ' || s[j] == '\n') {
+			return j + 1
+		}
+	}
+	return -1
+}
@@ -0,0 +1,244 @@
+package llm
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// parseRecorder records what an actionParser reports
+type parseRecorder struct {
+	actions   []Action
+	sentences []string
+}
+
+func (r *parseRecorder) handler() StreamHandler {
+	return StreamHandler{
+		OnAction:   func(a Action) { r.actions = append(r.actions, a) },
+		OnSentence: func(s string) { r.sentences = append(r.sentences, s) },
+	}
+}
+
+// parseChunks feeds chunks to a new parser and closes it
+func parseChunks(chunks ...string) *parseRecorder {
+	r := &parseRecorder{}
+	p := newActionParser(r.handler())
+	for _, chunk := range chunks {
+		p.Write(chunk)
+	}
+	p.Close()
+	return r
+}
+
+var streamCases = []struct {
+	name   string
+	json   string
+	action string
+	params map[string]interface{}
+	reply  string
+}{
+	{
+		name:   "plain",
+		json:   `{"action": "music.next", "params": {}, "reply": "Siguiente canción. Que la disfrutes."}`,
+		action: "music.next",
+		params: map[string]interface{}{},
+		reply:  "Siguiente canción. Que la disfrutes.",
+	},
+	{
+		name:   "escaped quotes",
+		json:   `{"action": "obs.scene", "params": {"scene": "Juego \"2\" {beta}"}, "reply": "Dijo \"hola\" y se fue. ¿Vale?"}`,
+		action: "obs.scene",
+		params: map[string]interface{}{"scene": `Juego "2" {beta}`},
+		reply:  `Dijo "hola" y se fue. ¿Vale?`,
+	},
+	{
+		name:   "unicode escapes",
+		json:   `{"action":"n\u006fne","params":{"song":"Canci\u00f3n \ud83c\udfb5"},"reply":"Canci\u00f3n lista \ud83c\udfb5. \u00a1Dale!"}`,
+		action: "none",
+		params: map[string]interface{}{"song": "Canción 🎵"},
+		reply:  "Canción lista 🎵. ¡Dale!",
+	},
+	{
+		name:   "multibyte",
+		json:   `{"action": "none", "params": {}, "reply": "Ñandú, acción… ¿Qué tal? 🎶 ¡Olé!"}`,
+		action: "none",
+		params: map[string]interface{}{},
+		reply:  "Ñandú, acción… ¿Qué tal? 🎶 ¡Olé!",
+	},
+	{
+		name:   "params after the action and nested keys",
+		json:   "{\n  \"params\": {\"action\": \"fake\", \"list\": [1, {\"a\": [2]}], \"volume\": 0.5},\n  \"action\": \"music.volume\",\n  \"reply\": \"Volumen al 0.5 por uno. Hecho.\"\n}",
+		action: "music.volume",
+		params: map[string]interface{}{"action": "fake", "list": []interface{}{1.0, map[string]interface{}{"a": []interface{}{2.0}}}, "volume": 0.5},
+		reply:  "Volumen al 0.5 por uno. Hecho.",
+	},
+	{
+		name:   "no params",
+		json:   `{"action": "system.status", "reply": "Todo bien."}`,
+		action: "system.status",
+		params: map[string]interface{}{},
+		reply:  "Todo bien.",
+	},
+	{
+		name:   "text around the object",
+		json:   "Claro: {\"action\": \"twitch.clip\", \"params\": {\"duration\": 30, \"live\": true, \"title\": null}, \"reply\": \"Clip hecho.\"} ¿Algo más?",
+		action: "twitch.clip",
+		params: map[string]interface{}{"duration": 30.0, "live": true, "title": nil},
+		reply:  "Clip hecho.",
+	},
+}
+
+// checkParse returns how what r saw differs from the action of the case
+// reported exactly once and the whole reply
+func checkParse(r *parseRecorder, action string, params map[string]interface{}, reply string) []string {
+	if len(r.actions) != 1 {
+		return []string{fmt.Sprintf("OnAction called %d times, want once", len(r.actions))}
+	}
+
+	var problems []string
+	got := r.actions[0]
+	if got.Action != action {
+		problems = append(problems, fmt.Sprintf("action = %q, want %q", got.Action, action))
+	}
+	if !reflect.DeepEqual(got.Params, params) {
+		problems = append(problems, fmt.Sprintf("params = %#v, want %#v", got.Params, params))
+	}
+	if said := strings.Join(r.sentences, " "); said != reply {
+		problems = append(problems, fmt.Sprintf("sentences = %q, want %q", r.sentences, reply))
+	}
+	return problems
+}
+
+func TestActionParserSplits(t *testing.T) {
+	for _, tt := range streamCases {
+		t.Run(tt.name, func(t *testing.T) {
+			for i := 0; i <= len(tt.json); i++ {
+				r := parseChunks(tt.json[:i], tt.json[i:])
+				if problems := checkParse(r, tt.action, tt.params, tt.reply); len(problems) > 0 {
+					t.Fatalf("split at byte %d (%q | %q): %s", i, tt.json[:i], tt.json[i:], strings.Join(problems, "; "))
+				}
+			}
+		})
+	}
+}
+
+func TestActionParserByteByByte(t *testing.T) {
+	for _, tt := range streamCases {
+		t.Run(tt.name, func(t *testing.T) {
+			chunks := make([]string, len(tt.json))
+			for i := 0; i < len(tt.json); i++ {
+				chunks[i] = tt.json[i : i+1]
+			}
+			r := parseChunks(chunks...)
+			if problems := checkParse(r, tt.action, tt.params, tt.reply); len(problems) > 0 {
+				t.Error(strings.Join(problems, "; "))
+			}
+		})
+	}
+}
+
+func TestActionParserSentencesWhileStreaming(t *testing.T) {
+	r := &parseRecorder{}
+	p := newActionParser(r.handler())
+
+	p.Write(`{"action": "none", "params": {}, "reply": "Hola. Esto va`)
+	if len(r.actions) != 1 {
+		t.Errorf("OnAction called %d times before the reply, want once", len(r.actions))
+	}
+	if !reflect.DeepEqual(r.sentences, []string{"Hola."}) {
+		t.Errorf("sentences = %q, want the first one only", r.sentences)
+	}
+
+	p.Write(` por 2.5 euros. Adi`)
+	if !reflect.DeepEqual(r.sentences, []string{"Hola.", "Esto va por 2.5 euros."}) {
+		t.Errorf("sentences = %q", r.sentences)
+	}
+
+	p.Write(`ós."}`)
+	p.Close()
+	if !reflect.DeepEqual(r.sentences, []string{"Hola.", "Esto va por 2.5 euros.", "Adiós."}) {
+		t.Errorf("sentences = %q", r.sentences)
+	}
+	if len(r.actions) != 1 {
+		t.Errorf("OnAction called %d times, want once", len(r.actions))
+	}
+}
+
+// actionValueEnd returns where the string value of the top-level action
+// key of s ends, after its closing quote
+func actionValueEnd(s string) int {
+	i := strings.LastIndex(s, `"action":`) + len(`"action":`)
+	i += strings.Index(s[i:], `"`) + 1
+	for ; s[i] != '"'; i++ {
+		if s[i] == '\\' {
+			i++
+		}
+	}
+	return i + 1
+}
+
+func TestActionParserTruncated(t *testing.T) {
+	for _, tt := range streamCases {
+		t.Run(tt.name, func(t *testing.T) {
+			actionEnd := actionValueEnd(tt.json)
+
+			for i := 0; i < len(tt.json); i++ {
+				r := parseChunks(tt.json[:i])
+
+				want := 0
+				if i >= actionEnd {
+					want = 1
+				}
+				if len(r.actions) != want {
+					t.Fatalf("cut at byte %d (%q): OnAction called %d times, want %d", i, tt.json[:i], len(r.actions), want)
+				}
+				if want == 1 && r.actions[0].Action != tt.action {
+					t.Errorf("cut at byte %d: action = %q, want %q", i, r.actions[0].Action, tt.action)
+				}
+				if said := strings.Join(r.sentences, " "); !strings.HasPrefix(tt.reply, said) {
+					t.Errorf("cut at byte %d: sentences %q are not the start of the reply", i, r.sentences)
+				}
+			}
+		})
+	}
+}
+
+func TestActionParserMalformed(t *testing.T) {
+	tests := []struct {
+		name   string
+		json   string
+		action string // "" if none should be reported
+	}{
+		{"not JSON", "Lo siento, no puedo ayudarte con eso.", ""},
+		{"empty", "", ""},
+		{"no action", `{"params": {}, "reply": "Hola."}`, ""},
+		{"action is a number", `{"action": 12, "params": {}, "reply": "Hola."}`, ""},
+		{"params is a string", `{"action": "none", "params": "x", "reply": "Hola."}`, "none"},
+		{"unterminated params", `{"action": "obs.scene", "params": {"scene": "Juego"`, "obs.scene"},
+		{"bad escape", `{"action": "none", "params": {}, "reply": "Hola \x. Adiós."}`, "none"},
+		{"two objects", `{"action": "music.next", "params": {}} {"action": "music.pause", "params": {}}`, "music.next"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			whole := parseChunks(tt.json)
+			chunks := make([]string, len(tt.json))
+			for i := 0; i < len(tt.json); i++ {
+				chunks[i] = tt.json[i : i+1]
+			}
+			bytewise := parseChunks(chunks...)
+
+			for _, r := range []*parseRecorder{whole, bytewise} {
+				switch {
+				case tt.action == "" && len(r.actions) != 0:
+					t.Errorf("OnAction called with %+v, want no call", r.actions)
+				case tt.action != "" && len(r.actions) != 1:
+					t.Errorf("OnAction called %d times, want once", len(r.actions))
+				case tt.action != "" && r.actions[0].Action != tt.action:
+					t.Errorf("action = %q, want %q", r.actions[0].Action, tt.action)
+				}
+			}
+		})
+	}
+}
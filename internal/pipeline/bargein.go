@@ -0,0 +1,159 @@
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/anastreamer/ana/internal/llm"
+	"github.com/anastreamer/ana/internal/stt"
+	"github.com/anastreamer/ana/internal/vad"
+	"github.com/anastreamer/ana/pkg/utils"
+)
+
+// Barge-in probes: speech heard while Ana thinks or speaks is transcribed in
+// short pieces to look for "Ana ..." or "para"
+const (
+	probeSilenceTime = 400 * time.Millisecond
+	maxProbeTime     = 4 * time.Second
+)
+
+// monitorBargeIn listens for interruptions while Ana thinks or speaks. The
+// wake word detector, if any, interrupts right away unless the mic is gated
+// by echo suppression; other speech is sent to STT and handled by
+// handleProbe, whose echo check copes with hearing Ana.
+func (p *Pipeline) monitorBargeIn(ctx context.Context, audio []byte, voice vad.Result, gated bool) {
+	if p.wakeDetector != nil && !gated {
+		if detection, ok := p.wakeDetector.Process(utils.BytesToInt16(audio)); ok {
+			p.log.Info().Str("word", detection.Word).Float64("score", detection.Score).Msg("Barge-in: wake word")
+			p.bargeIn()
+			p.startWakeRecording(ctx, audio)
+			return
+		}
+	}
+
+	// One probe at a time; speech heard while one is transcribed is lost,
+	// which is fine for short interruptions
+	if !p.cfg.Audio.VAD.Enabled || p.probeBusy {
+		return
+	}
+
+	now := p.clock.Now()
+	if !p.probing {
+		if !voice.Speech {
+			return
+		}
+		p.probing = true
+		p.probe.Reset()
+		p.probe.Write(p.preRoll.Last(p.preRollBytes))
+		p.probeStart = now
+		p.probeSilence = time.Time{}
+	}
+
+	p.probe.Write(audio)
+	if voice.Speech {
+		p.probeSilence = time.Time{}
+	} else if p.probeSilence.IsZero() {
+		p.probeSilence = now
+	}
+
+	ended := !p.probeSilence.IsZero() && now.Sub(p.probeSilence) >= probeSilenceTime
+	if ended || now.Sub(p.probeStart) >= maxProbeTime {
+		p.sendProbe()
+	}
+}
+
+// sendProbe transcribes the collected probe in a worker
+func (p *Pipeline) sendProbe() {
+	audio := bytes.Clone(p.probe.Bytes())
+	p.probe.Reset()
+	p.probing = false
+	p.probeBusy = true
+
+	p.transcribe(result{state: p.GetState(), probe: true}, func(ctx context.Context) (*stt.TranscriptionResult, error) {
+		return p.sttProvider.Transcribe(ctx, audio)
+	})
+}
+
+// handleProbe acts on the transcription of speech heard while Ana was busy
+func (p *Pipeline) handleProbe(ctx context.Context, res result) {
+	p.probeBusy = false
+
+	if state := p.GetState(); state != StateThinking && state != StateSpeaking {
+		return
+	}
+	if res.err != nil {
+		p.log.Debug().Err(res.err).Msg("Barge-in transcription failed")
+		return
+	}
+
+	text := res.text
+	switch {
+	case text == "":
+		return
+
+	case p.isEcho(text):
+		p.log.Debug().Str("text", text).Msg("Ignoring Ana's own voice")
+
+	case llm.IsAnaInterrupted(text):
+		p.log.Info().Str("text", text).Msg("Barge-in: interrupted")
+		p.bargeIn()
+		p.finishCommand()
+
+	case llm.IsAnaActivated(text):
+		p.log.Info().Str("text", text).Msg("Barge-in: new command")
+		p.bargeIn()
+		p.startTurn(ctx)
+		p.source = sourceSpeech
+		p.handleCommand(text, res.confidence, res.language)
+
+	default:
+		p.log.Debug().Str("text", text).Msg("Ignoring speech while busy")
+	}
+}
+
+// bargeIn stops the reply being spoken and cancels the LLM request and the
+// rest of the current turn
+func (p *Pipeline) bargeIn() {
+	if p.GetState() == StateSpeaking {
+		p.brain.StopSpeaking()
+	}
+	p.cancelTurn()
+	p.replyText("", nil)
+}
+
+// isEcho tells whether heard is most likely the mic picking up the reply
+// being spoken rather than the streamer: most of its words are in the reply.
+// Very short utterances are never treated as echo, so "para" still works.
+func (p *Pipeline) isEcho(heard string) bool {
+	words := strings.Fields(normalizeWords(heard))
+	if len(words) < 3 || p.speakingText == "" {
+		return false
+	}
+
+	spoken := make(map[string]bool)
+	for _, w := range strings.Fields(normalizeWords(p.speakingText)) {
+		spoken[w] = true
+	}
+
+	var matches int
+	for _, w := range words {
+		if spoken[w] {
+			matches++
+		}
+	}
+	return float64(matches) >= 0.6*float64(len(words))
+}
+
+// normalizeWords lowercases s and turns everything but letters and digits
+// into spaces
+func normalizeWords(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return ' '
+	}, s)
+}
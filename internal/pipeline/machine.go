@@ -0,0 +1,647 @@
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/anastreamer/ana/internal/brain"
+	"github.com/anastreamer/ana/internal/llm"
+	"github.com/anastreamer/ana/internal/stt"
+	"github.com/anastreamer/ana/internal/vad"
+	"github.com/anastreamer/ana/pkg/utils"
+)
+
+// Transitions:
+//
+//	Idle ──wake word──▶ WakeDetected ──▶ Recording
+//	Idle ──speech / hotkey──▶ Recording
+//	FollowUp ──speech / hotkey / wake word──▶ Recording
+//	Recording ──silence / release / limit──▶ Transcribing ──▶ Thinking ──▶ Speaking
+//	Recording ──silence, already in a partial transcript──▶ Thinking (streaming STT)
+//	Speaking ──done──▶ FollowUp (session) or Idle
+//	Thinking / Speaking ──wake word──▶ WakeDetected (barge-in)
+//	Thinking / Speaking ──"Ana ..." / "para"──▶ Thinking / FollowUp or Idle (barge-in)
+//	FollowUp ──window timeout / deactivation──▶ Idle
+//
+// Any step that has nothing to do (no audio, empty transcription, no "Ana",
+// empty reply, error) ends the turn early and goes back to FollowUp or Idle.
+// A deactivation phrase ends the session.
+
+// goodbye is shown when a deactivation phrase ends the session, translated
+// with brain.Localize
+const goodbye = "Adiós! Estoy aquí si me necesitas."
+
+// handleWakeWord starts recording a command after a manual wake word trigger
+func (p *Pipeline) handleWakeWord(ctx context.Context) {
+	if state := p.GetState(); state != StateIdle && state != StateFollowUp {
+		p.log.Debug().Str("state", state.String()).Msg("Ignoring wake word - busy")
+		return
+	}
+
+	p.setState(StateWakeDetected)
+	p.startRecording(ctx, sourceWakeWord, p.preRollBytes)
+}
+
+// handleHotkeyDown starts a push-to-talk recording, or takes over the one in
+// progress so it ends on release
+func (p *Pipeline) handleHotkeyDown(ctx context.Context) {
+	switch p.GetState() {
+	case StateIdle, StateFollowUp:
+		p.startRecording(ctx, sourceHotkey, p.preRollBytes)
+	case StateRecording:
+		p.source = sourceHotkey
+	default:
+		p.log.Debug().Str("state", p.GetState().String()).Msg("Ignoring hotkey - busy")
+	}
+}
+
+// handleHotkeyRelease handles when the hotkey is released
+func (p *Pipeline) handleHotkeyRelease(ctx context.Context) {
+	if p.GetState() != StateRecording || p.source != sourceHotkey {
+		return
+	}
+
+	p.finishRecording(ctx)
+}
+
+// handleAudio handles incoming audio chunks
+func (p *Pipeline) handleAudio(ctx context.Context, audio []byte) {
+	// Remove Ana's own voice before anything listens to the chunk. Without
+	// a reference to remove it, the mic is gated: what it hears can't start
+	// anything.
+	var gated bool
+	if p.echo != nil {
+		res := p.echo.Process(utils.BytesToInt16(audio))
+		if res.Active && !res.Gated {
+			audio = utils.Int16ToBytes(res.Samples)
+		}
+		gated = res.Gated
+	}
+
+	// The chunk joins the pre-roll only afterwards, so a recording started
+	// by this chunk doesn't get it twice
+	defer p.preRoll.Write(audio)
+
+	// Every chunk goes through the VAD once, in any state, so its noise
+	// estimate keeps following the room
+	var voice vad.Result
+	if p.cfg.Audio.VAD.Enabled {
+		voice = p.vad.Process(utils.BytesToInt16(audio))
+		if p.onSpeechActivity != nil {
+			p.reportSpeechActivity(voice.Speech)
+		}
+	}
+
+	switch p.GetState() {
+	case StateIdle:
+		// With a detector, only its detection starts a recording; the audio
+		// it heard becomes the start of the recording
+		if p.wakeDetector != nil {
+			if gated {
+				return
+			}
+			detection, ok := p.wakeDetector.Process(utils.BytesToInt16(audio))
+			if !ok {
+				return
+			}
+
+			p.log.Info().Str("word", detection.Word).Float64("score", detection.Score).Msg("Wake word detected")
+			p.startWakeRecording(ctx, audio)
+			return
+		}
+
+		// Without one, record any speech and look for "Ana" in the text
+		if !p.cfg.Audio.VAD.Enabled || !voice.Speech || gated {
+			return
+		}
+		p.log.Debug().
+			Float64("level_db", voice.LevelDB).
+			Float64("noise_db", voice.NoiseDB).
+			Float64("probability", voice.Probability).
+			Msg("Voice detected in Idle state, checking for wake word")
+		p.startRecording(ctx, sourceSpeech, p.preRollBytes)
+
+	case StateFollowUp:
+		if !p.cfg.Audio.VAD.Enabled || !voice.Speech || gated {
+			return
+		}
+		p.log.Debug().Msg("Voice detected during session")
+		p.startRecording(ctx, sourceFollowUp, p.preRollBytes)
+
+	case StateRecording:
+		// Keep recording
+
+	case StateThinking, StateSpeaking:
+		if p.cfg.Session.BargeIn {
+			p.monitorBargeIn(ctx, audio, voice, gated)
+		}
+		return
+
+	default:
+		return
+	}
+
+	p.record(audio)
+	if p.cfg.Audio.VAD.Enabled {
+		p.analyzeVAD(voice)
+	}
+	p.checkRecording(ctx)
+}
+
+// startWakeRecording starts recording a command after the detector heard
+// the wake word in chunk. The recording includes the word itself.
+func (p *Pipeline) startWakeRecording(ctx context.Context, chunk []byte) {
+	p.wakeDetector.Reset()
+	p.setState(StateWakeDetected)
+	p.startRecording(ctx, sourceWakeWord, p.wakePreRollBytes)
+	p.record(chunk)
+
+	// The wake word itself counts as speech for the silence timer
+	p.hasSpeech = true
+	p.speechStart = p.clock.Now()
+}
+
+// reportSpeechActivity notifies voice activity changes
+func (p *Pipeline) reportSpeechActivity(speaking bool) {
+	if speaking != p.speaking {
+		p.speaking = speaking
+		p.onSpeechActivity(speaking)
+	}
+}
+
+// startRecording starts the turn of a new command by recording audio,
+// beginning with the last n bytes of audio heard before this moment
+func (p *Pipeline) startRecording(ctx context.Context, source recordingSource, n int) {
+	p.startTurn(ctx)
+	p.audioBuffer.Reset()
+	p.audioBuffer.Write(p.preRoll.Last(n))
+
+	p.source = source
+	p.recordStart = p.clock.Now()
+	p.hasSpeech = false
+	p.silenceStart = time.Time{}
+	p.speechStart = time.Time{}
+
+	p.setState(StateRecording)
+	p.startStream()
+}
+
+// analyzeVAD tracks speech start and silence start from the VAD decision
+func (p *Pipeline) analyzeVAD(voice vad.Result) {
+	now := p.clock.Now()
+
+	if voice.Speech {
+		if !p.hasSpeech {
+			p.speechStart = now
+			p.hasSpeech = true
+			p.log.Debug().Float64("level_db", voice.LevelDB).Float64("probability", voice.Probability).Msg("Speech detected")
+		}
+		p.silenceStart = time.Time{} // Reset silence timer
+	} else {
+		if p.hasSpeech && p.silenceStart.IsZero() {
+			p.silenceStart = now
+			p.silenceBytes = p.audioBuffer.Len()
+			p.log.Debug().Float64("level_db", voice.LevelDB).Msg("Silence detected")
+		}
+	}
+}
+
+// checkRecording ends the recording once silence follows speech, or a time
+// limit is reached. Hotkey recordings only end on release (or the hard limit).
+func (p *Pipeline) checkRecording(ctx context.Context) {
+	if p.GetState() != StateRecording {
+		return
+	}
+
+	now := p.clock.Now()
+	elapsed := now.Sub(p.recordStart)
+
+	if elapsed >= maxRecordTime {
+		p.log.Warn().Msg("Recording timeout reached")
+		p.finishRecording(ctx)
+		return
+	}
+
+	if p.source == sourceHotkey {
+		return
+	}
+
+	// Without the VAD there's no way to tell speech from silence, so only
+	// the hard limit applies
+	if !p.cfg.Audio.VAD.Enabled {
+		return
+	}
+
+	if !p.hasSpeech {
+		if elapsed >= noSpeechTimeout {
+			p.log.Info().Msg("No command after the wake word, giving up")
+			p.audioBuffer.Reset()
+			p.endTurn()
+		}
+		return
+	}
+
+	if elapsed >= autoProcessTime {
+		p.log.Info().Dur("elapsed", elapsed).Msg("Auto-processing long speech")
+		p.finishRecording(ctx)
+		return
+	}
+
+	if p.silenceStart.IsZero() {
+		return
+	}
+
+	silence := now.Sub(p.silenceStart)
+	silenceThreshold := time.Duration(p.cfg.Audio.VAD.SilenceThresholdMs) * time.Millisecond
+	if silence >= silenceThreshold {
+		p.log.Info().Dur("silence", silence).Msg("Silence threshold reached")
+		p.finishRecording(ctx)
+		return
+	}
+
+	// After a couple of seconds of recording, accept shorter silences
+	if elapsed > longSpeechTime && silence >= shortSilenceTime {
+		p.log.Info().Msg("Short silence after speech detected, processing")
+		p.finishRecording(ctx)
+	}
+}
+
+// finishRecording stops recording and sends the audio to STT
+func (p *Pipeline) finishRecording(ctx context.Context) {
+	audio := bytes.Clone(p.audioBuffer.Bytes())
+	p.audioBuffer.Reset()
+
+	if len(audio) == 0 {
+		p.log.Warn().Msg("No audio recorded")
+		p.endTurn()
+		return
+	}
+
+	// Check minimum speech duration
+	minSpeech := time.Duration(p.cfg.Audio.VAD.MinSpeechMs) * time.Millisecond
+	if p.hasSpeech && !p.speechStart.IsZero() {
+		speechDuration := p.clock.Now().Sub(p.speechStart)
+		if speechDuration < minSpeech {
+			p.log.Debug().
+				Dur("duration", speechDuration).
+				Dur("minimum", minSpeech).
+				Msg("Speech too short, ignoring")
+			p.endTurn()
+			return
+		}
+	}
+
+	p.log.Info().Int("bytes", len(audio)).Msg("Processing recorded audio")
+
+	// A partial made after the silence began already has the whole command
+	if text, ok := p.partialFinal(); ok && p.stream != nil {
+		p.stream.Close()
+		p.stream = nil
+		p.log.Info().Str("text", text).Msg("Transcribed (from partial)")
+		p.handleCommand(text, p.partial.Confidence, p.partial.Language)
+		return
+	}
+
+	stream := p.stream
+	p.stream = nil
+	p.setState(StateTranscribing)
+	p.transcribe(result{state: StateTranscribing}, func(ctx context.Context) (*stt.TranscriptionResult, error) {
+		if stream != nil {
+			return stream.Finish(ctx)
+		}
+		return p.sttProvider.Transcribe(ctx, audio)
+	})
+}
+
+// handleText starts a turn for a typed command
+func (p *Pipeline) handleText(ctx context.Context, req textRequest) {
+	if state := p.GetState(); state != StateIdle && state != StateFollowUp {
+		req.reply <- textReply{err: ErrBusy}
+		return
+	}
+
+	p.startTurn(ctx)
+	p.source = sourceText
+	p.pendingText = &req
+	p.handleCommand(req.text, 1, "")
+}
+
+// handleResult moves the turn forward with a worker's result
+func (p *Pipeline) handleResult(ctx context.Context, res result) {
+	if res.partial != nil {
+		if res.turn == p.turn && p.GetState() == StateRecording {
+			p.handlePartial(*res.partial)
+		}
+		return
+	}
+	if res.turn == p.turn && res.probe {
+		p.handleProbe(ctx, res)
+		return
+	}
+	if res.turn == p.turn && res.sentence != "" {
+		p.handleSentence(res.sentence)
+		return
+	}
+	if res.turn == p.turn && p.streamingReply {
+		// Ana is already speaking the reply
+		switch res.state {
+		case StateThinking:
+			p.finishReply(res)
+		case StateSpeaking:
+			if res.err != nil {
+				p.log.Error().Err(res.err).Msg("TTS failed")
+			}
+			p.talking = false
+			p.speakNext()
+		}
+		return
+	}
+	if res.turn != p.turn || res.state != p.GetState() {
+		p.log.Debug().Str("state", res.state.String()).Msg("Dropping stale result")
+		return
+	}
+
+	switch res.state {
+	case StateTranscribing:
+		if res.err != nil {
+			p.log.Error().Err(res.err).Msg("Transcription failed")
+			if p.onError != nil {
+				p.onError(fmt.Errorf("transcription failed: %w", res.err))
+			}
+			p.endTurn()
+			return
+		}
+		if res.text == "" {
+			p.log.Debug().Msg("Empty transcription")
+			p.endTurn()
+			return
+		}
+
+		p.log.Info().Str("text", res.text).Float64("confidence", res.confidence).Msg("Transcribed")
+		p.handleCommand(res.text, res.confidence, res.language)
+
+	case StateThinking:
+		if res.err != nil {
+			p.log.Error().Err(res.err).Msg("Command processing failed")
+			if p.onError != nil {
+				p.onError(fmt.Errorf("command processing failed: %w", res.err))
+			}
+			p.replyText("", res.err)
+			p.finishCommand()
+			return
+		}
+
+		response := res.text
+		p.replyText(response, nil)
+		if response == "" {
+			p.finishCommand()
+			return
+		}
+
+		p.log.Info().Str("response", response).Msg("Response")
+		if p.onResponse != nil {
+			p.onResponse(response)
+		}
+
+		p.speakingText = response
+		p.setState(StateSpeaking)
+		lang := p.language
+		p.work(result{state: StateSpeaking}, func(ctx context.Context) (string, error) {
+			return "", p.brain.Speak(llm.WithLanguage(ctx, lang), response)
+		})
+
+	case StateSpeaking:
+		if res.err != nil {
+			p.log.Error().Err(res.err).Msg("TTS failed")
+		}
+		p.finishCommand()
+	}
+}
+
+// handleCommand checks that a transcribed or typed command is meant for Ana
+// and sends it to the brain with the transcription's confidence and
+// language ("" if unknown)
+func (p *Pipeline) handleCommand(text string, confidence float64, language string) {
+	if p.needsName() && !llm.IsAnaActivated(text) {
+		p.log.Debug().Str("text", text).Msg("Ignoring input - Ana name not mentioned")
+		p.endTurn()
+		return
+	}
+
+	if lang := p.commandLanguage(language); lang != p.language {
+		p.log.Info().Str("from", p.language).Str("to", lang).Msg("Switching language")
+		p.language = lang
+	}
+	lang := p.language
+
+	if llm.IsAnaDeactivated(text) {
+		p.log.Info().Str("text", text).Msg("Deactivation word detected - ending session")
+		bye := brain.Localize(lang, goodbye)
+		if p.onResponse != nil {
+			p.onResponse(bye)
+		}
+		p.replyText(bye, nil)
+		p.setSession(false)
+		p.endTurn()
+		return
+	}
+
+	p.log.Info().Str("text", text).Msg("Ana detected - processing command")
+
+	if p.onTranscript != nil {
+		p.onTranscript(text)
+	}
+
+	spec := p.takeSpeculation(text)
+	p.setState(StateThinking)
+	p.think(lang, func(ctx context.Context, speak func(string)) (string, string, error) {
+		var action *llm.Action
+		if spec != nil {
+			if interpreted, ok := spec.wait(ctx); ok {
+				p.log.Debug().Msg("Using the intent interpreted from the partial transcript")
+				action = &interpreted
+			}
+		}
+		return p.brain.ProcessTranscriptStream(ctx, text, confidence, action, speak)
+	})
+}
+
+// commandLanguage returns the language to answer a command in: the
+// detected one if Ana speaks it, otherwise the last one. Short utterances
+// are easily detected as some other language.
+func (p *Pipeline) commandLanguage(detected string) string {
+	if slices.Contains(p.languages, detected) {
+		return detected
+	}
+	return p.language
+}
+
+// needsName tells whether the command must mention Ana. Speech picked up in
+// Idle must name her; the wake word, the hotkey and an active session
+// already tell us the user is talking to her.
+func (p *Pipeline) needsName() bool {
+	return p.source == sourceSpeech || (p.source == sourceText && !p.sessionActive)
+}
+
+// startTurn begins a new command, cancelling whatever the previous one left
+func (p *Pipeline) startTurn(ctx context.Context) {
+	p.cancelTurn()
+	p.turn++
+	p.turnCtx, p.turnCancel = context.WithCancel(ctx)
+
+	// The detector listens for barge-in during the turn; don't let it match
+	// across the audio it didn't hear while recording
+	if p.wakeDetector != nil {
+		p.wakeDetector.Reset()
+	}
+}
+
+// cancelTurn cancels the workers of the current turn
+func (p *Pipeline) cancelTurn() {
+	if p.turnCancel != nil {
+		p.turnCancel()
+		p.turnCancel = nil
+	}
+
+	p.probe.Reset()
+	p.probing = false
+	p.probeBusy = false
+	p.speakingText = ""
+	p.streamingReply = false
+	p.sentences = nil
+	p.talking = false
+	p.replyDone = false
+	p.closeStream()
+}
+
+// finishCommand ends the turn of a command Ana handled. Unless the session
+// mode is single, she keeps listening without the wake word: for the next
+// FollowUpSeconds in follow_up mode, or until deactivated in persistent mode.
+func (p *Pipeline) finishCommand() {
+	switch p.cfg.Session.Mode {
+	case "follow_up":
+		window := time.Duration(p.cfg.Session.FollowUpSeconds) * time.Second
+		p.followUpUntil = p.clock.Now().Add(window)
+		p.setSession(true)
+	case "persistent":
+		p.setSession(true)
+	}
+
+	p.endTurn()
+}
+
+// setSession starts or ends the session, notifying onFollowUp on changes
+func (p *Pipeline) setSession(active bool) {
+	if !active {
+		p.followUpUntil = time.Time{}
+	}
+	if active == p.sessionActive {
+		return
+	}
+
+	p.sessionActive = active
+	p.log.Info().Bool("active", active).Str("mode", p.cfg.Session.Mode).Msg("Session changed")
+	if p.onFollowUp != nil {
+		p.onFollowUp(active)
+	}
+}
+
+// checkFollowUp closes the follow-up window once it times out. A recording
+// started inside the window is let finish; the window is checked again when
+// the pipeline is back in FollowUp.
+func (p *Pipeline) checkFollowUp() {
+	if p.GetState() != StateFollowUp || p.followUpUntil.IsZero() {
+		return
+	}
+	if p.clock.Now().Before(p.followUpUntil) {
+		return
+	}
+
+	p.log.Info().Msg("Follow-up window closed")
+	p.setSession(false)
+	p.rest()
+}
+
+// endTurn finishes the current command and goes back to waiting
+func (p *Pipeline) endTurn() {
+	p.cancelTurn()
+	p.replyText("", nil)
+	p.rest()
+}
+
+// rest goes back to FollowUp during a persistent session, Idle otherwise
+func (p *Pipeline) rest() {
+	if p.sessionActive {
+		p.setState(StateFollowUp)
+		return
+	}
+
+	if p.wakeDetector != nil {
+		p.wakeDetector.Reset()
+	}
+	p.setState(StateIdle)
+}
+
+// work runs fn in a worker goroutine bound to the current turn and reports
+// its outcome as res to the run goroutine, unless the turn is cancelled first
+func (p *Pipeline) work(res result, fn func(ctx context.Context) (string, error)) {
+	res.turn = p.turn
+	ctx := p.turnCtx
+
+	go func() {
+		res.text, res.err = fn(ctx)
+		select {
+		case p.results <- res:
+		case <-ctx.Done():
+		}
+	}()
+}
+
+// transcribe runs an STT request in a worker like work, reporting the
+// cleaned up text and its confidence
+func (p *Pipeline) transcribe(res result, fn func(ctx context.Context) (*stt.TranscriptionResult, error)) {
+	res.turn = p.turn
+	ctx := p.turnCtx
+
+	go func() {
+		transcription, err := fn(ctx)
+		if err != nil {
+			res.err = err
+		} else {
+			p.cleanTranscription(transcription)
+			res.text, res.confidence = transcription.Text, transcription.Confidence
+			res.language = transcription.Language
+		}
+
+		select {
+		case p.results <- res:
+		case <-ctx.Done():
+		}
+	}()
+}
+
+// cleanTranscription removes from a transcription what Whisper likely made
+// up from silence or noise, and fixes misheard known terms
+func (p *Pipeline) cleanTranscription(res *stt.TranscriptionResult) {
+	cfg := p.cfg.STT.Confidence
+	if cfg.FilterHallucinations {
+		if dropped := stt.DropHallucinations(res, cfg.NoSpeechThreshold, cfg.LogProbThreshold); len(dropped) > 0 {
+			p.log.Info().Strs("dropped", dropped).Msg("Dropped hallucinated transcription")
+		}
+	}
+
+	if p.vocabulary != nil && p.cfg.STT.Vocabulary.Correct {
+		res.Text = p.vocabulary.Correct(res.Text)
+	}
+}
+
+// replyText answers a pending ProcessText call
+func (p *Pipeline) replyText(response string, err error) {
+	if p.pendingText != nil {
+		p.pendingText.reply <- textReply{response: response, err: err}
+		p.pendingText = nil
+	}
+}
@@ -4,16 +4,18 @@ package pipeline
 import (
 	"bytes"
 	"context"
-	"fmt"
+	"errors"
 	"sync"
 	"time"
 
 	"github.com/anastreamer/ana/internal/brain"
 	"github.com/anastreamer/ana/internal/config"
-	"github.com/anastreamer/ana/internal/llm"
+	"github.com/anastreamer/ana/internal/echo"
 	"github.com/anastreamer/ana/internal/stt"
+	"github.com/anastreamer/ana/internal/vad"
+	"github.com/anastreamer/ana/internal/vocab"
+	"github.com/anastreamer/ana/internal/wakeword"
 	"github.com/anastreamer/ana/pkg/logger"
-	"github.com/anastreamer/ana/pkg/utils"
 	"github.com/rs/zerolog"
 )
 
@@ -21,78 +23,245 @@ import (
 type State int
 
 const (
+	// StateIdle waits for the wake word, speech or the hotkey
 	StateIdle State = iota
-	StateListening
+	// StateWakeDetected is passed through when the wake word is heard,
+	// right before recording the command
+	StateWakeDetected
+	// StateRecording captures a command until silence or hotkey release
 	StateRecording
-	StateProcessing
+	// StateTranscribing waits for STT
+	StateTranscribing
+	// StateThinking waits for the LLM and the executors
+	StateThinking
+	// StateSpeaking waits for TTS to say the reply
+	StateSpeaking
+	// StateFollowUp listens for another command without the wake word
+	// (follow-up window or persistent session)
+	StateFollowUp
 )
 
 func (s State) String() string {
 	switch s {
 	case StateIdle:
 		return "idle"
-	case StateListening:
-		return "listening"
+	case StateWakeDetected:
+		return "wake_detected"
 	case StateRecording:
 		return "recording"
-	case StateProcessing:
-		return "processing"
+	case StateTranscribing:
+		return "transcribing"
+	case StateThinking:
+		return "thinking"
+	case StateSpeaking:
+		return "speaking"
+	case StateFollowUp:
+		return "follow_up"
 	default:
 		return "unknown"
 	}
 }
 
-// Pipeline is the main audio processing pipeline
+// ErrBusy is returned by ProcessText while another command is in progress
+var ErrBusy = errors.New("pipeline is busy with another command")
+
+// Recording limits
+const (
+	tickInterval     = 100 * time.Millisecond
+	maxRecordTime    = 30 * time.Second // Hard limit for any recording
+	autoProcessTime  = 6 * time.Second  // Process after this long if there was speech
+	noSpeechTimeout  = 8 * time.Second  // Give up on a wake word not followed by a command
+	longSpeechTime   = 2 * time.Second  // After this long recording...
+	shortSilenceTime = 300 * time.Millisecond
+)
+
+// recordingSource is what started a recording. It decides how the recording
+// ends and whether the transcription has to mention Ana.
+type recordingSource int
+
+const (
+	sourceSpeech   recordingSource = iota // Speech in Idle, must mention Ana
+	sourceWakeWord                        // Wake word detector or TriggerWakeWord
+	sourceHotkey                          // Push-to-talk, ends on release
+	sourceFollowUp                        // Speech during a session
+	sourceText                            // ProcessText
+)
+
+// Pipeline is the main audio processing pipeline.
+//
+// Everything it tracks is owned by the run goroutine: audio, hotkeys, typed
+// commands and worker results all arrive as events on channels, so no field
+// is shared without synchronization. STT, LLM and TTS calls run in worker
+// goroutines bound to the current turn and report back on results.
 type Pipeline struct {
 	cfg         *config.Config
 	sttProvider stt.Provider
 	brain       *brain.Brain
 	log         zerolog.Logger
+	clock       Clock
 
+	// state is only written by the run goroutine; the mutex lets GetState
+	// be called from anywhere
 	state   State
 	stateMu sync.RWMutex
 
-	// Audio buffer for recording
-	audioBuffer *bytes.Buffer
-	bufferMu    sync.Mutex
-
 	// Channels for events
 	wakeWordChan chan struct{}
 	hotkeyDown   chan struct{}
 	hotkeyUp     chan struct{}
 	audioChan    chan []byte
+	textChan     chan textRequest
+	results      chan result
 	stopChan     chan struct{}
+	stopOnce     sync.Once
 
-	// VAD settings
+	// Current recording
+	audioBuffer  bytes.Buffer
+	source       recordingSource
+	recordStart  time.Time
 	silenceStart time.Time
 	speechStart  time.Time
 	hasSpeech    bool
+	silenceBytes int // Recorded bytes when the current silence started
+
+	// Streaming STT: with a provider that can stream, the recording is
+	// transcribed while it's recorded and a partial that covers the whole
+	// command is interpreted (spec) before the recording ends
+	streamer stt.StreamingProvider
+	stream   stt.Stream
+	partial  stt.Hypothesis
+	spec     *speculation
+
+	// Current turn (one command from recording to reply). Results from
+	// workers of an older turn are dropped.
+	turn        int
+	turnCtx     context.Context
+	turnCancel  context.CancelFunc
+	pendingText *textRequest
+
+	// sessionActive is set after a command unless the session mode is
+	// single. In follow_up mode it ends at followUpUntil.
+	sessionActive bool
+	followUpUntil time.Time
+
+	// language is the one of the last command, kept while the STT detects
+	// one outside languages (or none, as for typed commands)
+	languages []string
+	language  string
+
+	// Barge-in: speech heard while Ana thinks or speaks is collected into
+	// probe and transcribed to look for an interruption
+	probe        bytes.Buffer
+	probing      bool // Collecting speech into probe
+	probeBusy    bool // Waiting for the probe transcription
+	probeStart   time.Time
+	probeSilence time.Time
+	speakingText string // Reply being spoken, to recognize its echo
+
+	// Streaming LLM: the first sentences of a reply are spoken while the
+	// rest is written (see reply.go)
+	streamingReply bool     // Speaking a reply that isn't complete
+	sentences      []string // Waiting to be spoken
+	talking        bool     // A sentence is being spoken
+	replyDone      bool     // The reply is complete
+
+	vad vad.VAD
+
+	// echo removes Ana's own voice from the mic, or gates the mic while she
+	// speaks when it can't
+	echo *echo.Canceller
+
+	// vocabulary snaps misheard names in transcriptions to known terms
+	vocabulary *vocab.Vocabulary
+
+	// speaking tracks per-chunk voice activity for onSpeechActivity
+	speaking bool
+
+	// preRoll always holds the latest audio so recordings can start a bit
+	// before the moment they were triggered and keep the first syllables
+	preRoll      *ringBuffer
+	preRollBytes int
+
+	// On-device wake word detection. A detection starts the recording with
+	// wakePreRollBytes of audio so it includes the wake word itself.
+	wakeDetector     wakeword.Detector
+	wakePreRollBytes int
 
 	// Callbacks
 	onStateChange func(State)
 	onTranscript  func(string)
 	onResponse    func(string)
 	onError       func(error)
+
+	onSpeechActivity func(active bool)
+	onFollowUp       func(open bool)
+}
+
+// result is what a worker reports back to the run goroutine
+type result struct {
+	turn    int
+	state   State           // State the worker was started for
+	probe   bool            // Barge-in probe transcription
+	partial *stt.Hypothesis // Partial transcript from the recording's STT stream
+	text    string
+	err     error
+
+	sentence string // Of a reply still being written
+	rest     string // Of a reply, not reported as sentences
+
+	confidence float64 // Of a transcription
+	language   string  // Detected in a transcription
+}
+
+// textRequest is a typed command waiting for its response
+type textRequest struct {
+	text  string
+	reply chan textReply
+}
+
+type textReply struct {
+	response string
+	err      error
 }
 
 // NewPipeline creates a new processing pipeline
 func NewPipeline(cfg *config.Config, sttProvider stt.Provider, brn *brain.Brain) *Pipeline {
+	log := logger.Component("pipeline")
+
+	detector, err := vad.New(cfg.Audio.VAD, cfg.Audio.SampleRate)
+	if err != nil {
+		log.Warn().Err(err).Msg("Falling back to energy VAD")
+		detector = vad.NewEnergyVAD(cfg.Audio.VAD, cfg.Audio.SampleRate)
+	}
+
+	preRollBytes := msToBytes(cfg.Audio.VAD.PreRollMs, cfg.Audio.SampleRate)
+	streamer, _ := sttProvider.(stt.StreamingProvider)
+
 	return &Pipeline{
 		cfg:          cfg,
 		sttProvider:  sttProvider,
+		streamer:     streamer,
 		brain:        brn,
-		log:          logger.Component("pipeline"),
+		log:          log,
+		clock:        realClock{},
+		vad:          detector,
+		preRoll:      newRingBuffer(preRollBytes),
+		preRollBytes: preRollBytes,
 		state:        StateIdle,
-		audioBuffer:  bytes.NewBuffer(nil),
+		languages:    cfg.General.Languages,
+		language:     cfg.General.Language,
 		wakeWordChan: make(chan struct{}, 1),
 		hotkeyDown:   make(chan struct{}, 1),
 		hotkeyUp:     make(chan struct{}, 1),
 		audioChan:    make(chan []byte, 100),
+		textChan:     make(chan textRequest),
+		results:      make(chan result),
 		stopChan:     make(chan struct{}),
 	}
 }
 
-// SetCallbacks sets the callback functions
+// SetCallbacks sets the callback functions. They run on the pipeline
+// goroutine and must not block.
 func (p *Pipeline) SetCallbacks(
 	onStateChange func(State),
 	onTranscript func(string),
@@ -105,6 +274,55 @@ func (p *Pipeline) SetCallbacks(
 	p.onError = onError
 }
 
+// SetSpeechActivityCallback sets a callback invoked when the VAD starts or
+// stops hearing voice, in any state (e.g. to duck music)
+func (p *Pipeline) SetSpeechActivityCallback(fn func(active bool)) {
+	p.onSpeechActivity = fn
+}
+
+// SetFollowUpCallback sets a callback invoked when Ana starts or stops
+// listening without the wake word (e.g. to play a cue)
+func (p *Pipeline) SetFollowUpCallback(fn func(open bool)) {
+	p.onFollowUp = fn
+}
+
+// SetWakeWordDetector replaces the idle "transcribe everything and look for
+// Ana" behavior with an on-device detector. Only audio after a detection is
+// sent to STT.
+func (p *Pipeline) SetWakeWordDetector(d wakeword.Detector) {
+	p.wakeDetector = d
+
+	// Two seconds covers the wake word plus a short lead-in
+	p.wakePreRollBytes = max(msToBytes(2000, p.cfg.Audio.SampleRate), p.preRollBytes)
+	if p.wakePreRollBytes > p.preRoll.Size() {
+		p.preRoll = newRingBuffer(p.wakePreRollBytes)
+	}
+}
+
+// SetEchoCanceller removes Ana's own voice from the mic before the VAD, the
+// wake word detector and STT hear it. The TTS provider must report its
+// playback to the canceller.
+func (p *Pipeline) SetEchoCanceller(c *echo.Canceller) {
+	p.echo = c
+}
+
+// SetVocabulary corrects transcriptions that misspell the streamer's known
+// terms (Ana, scenes, chatters...)
+func (p *Pipeline) SetVocabulary(v *vocab.Vocabulary) {
+	p.vocabulary = v
+}
+
+// SetClock replaces the system clock, e.g. with a fake clock to drive the
+// silence and timeout logic in tests. Call it before Start.
+func (p *Pipeline) SetClock(c Clock) {
+	p.clock = c
+}
+
+// msToBytes converts a duration in ms to a number of 16-bit mono PCM bytes
+func msToBytes(ms, sampleRate int) int {
+	return max(ms, 0) * sampleRate / 1000 * 2
+}
+
 // setState updates the pipeline state
 func (p *Pipeline) setState(state State) {
 	p.stateMu.Lock()
@@ -133,8 +351,7 @@ func (p *Pipeline) GetState() State {
 
 // Start starts the pipeline
 func (p *Pipeline) Start(ctx context.Context) error {
-	p.log.Info().Msg("Starting pipeline")
-	p.setState(StateIdle)
+	p.log.Info().Str("session", p.cfg.Session.Mode).Msg("Starting pipeline")
 
 	go p.run(ctx)
 	return nil
@@ -143,7 +360,7 @@ func (p *Pipeline) Start(ctx context.Context) error {
 // Stop stops the pipeline
 func (p *Pipeline) Stop() {
 	p.log.Info().Msg("Stopping pipeline")
-	close(p.stopChan)
+	p.stopOnce.Do(func() { close(p.stopChan) })
 }
 
 // TriggerWakeWord simulates a wake word detection
@@ -179,37 +396,36 @@ func (p *Pipeline) FeedAudio(audio []byte) {
 	}
 }
 
-// ProcessText directly processes a text command (for testing)
+// ProcessText runs a typed command through the same path as a transcribed
+// one and returns the response. It fails with ErrBusy while another command
+// is in progress.
 func (p *Pipeline) ProcessText(ctx context.Context, text string) (string, error) {
-	p.setState(StateProcessing)
-	defer p.setState(StateIdle)
+	req := textRequest{text: text, reply: make(chan textReply, 1)}
 
-	// Check if Ana name is mentioned
-	if !llm.IsAnaActivated(text) {
-		p.log.Debug().Str("text", text).Msg("Ignoring input - Ana name not mentioned")
-		return "", nil
-	}
-
-	if p.onTranscript != nil {
-		p.onTranscript(text)
+	select {
+	case p.textChan <- req:
+	case <-ctx.Done():
+		return "", ctx.Err()
+	case <-p.stopChan:
+		return "", errors.New("pipeline stopped")
 	}
 
-	response, err := p.brain.ProcessCommand(ctx, text)
-	if err == nil && response != "" {
-		if p.onResponse != nil {
-			p.onResponse(response)
-		}
-		// Try to speak but don't fail if it doesn't work
-		_ = p.brain.ProcessAndSpeak(ctx, text)
+	select {
+	case r := <-req.reply:
+		return r.response, r.err
+	case <-ctx.Done():
+		return "", ctx.Err()
 	}
-
-	return response, err
 }
 
-// run is the main pipeline loop
+// run is the main pipeline loop and the only goroutine touching its state
 func (p *Pipeline) run(ctx context.Context) {
 	p.log.Debug().Msg("Pipeline loop started")
 
+	ticker := p.clock.NewTicker(tickInterval)
+	defer ticker.Stop()
+	defer p.cancelTurn()
+
 	for {
 		select {
 		case <-ctx.Done():
@@ -221,12 +437,12 @@ func (p *Pipeline) run(ctx context.Context) {
 			return
 
 		case <-p.wakeWordChan:
-			p.log.Info().Msg("Wake word detected")
+			p.log.Info().Msg("Wake word triggered")
 			p.handleWakeWord(ctx)
 
 		case <-p.hotkeyDown:
 			p.log.Debug().Msg("Hotkey pressed")
-			p.startRecording()
+			p.handleHotkeyDown(ctx)
 
 		case <-p.hotkeyUp:
 			p.log.Debug().Msg("Hotkey released")
@@ -234,324 +450,16 @@ func (p *Pipeline) run(ctx context.Context) {
 
 		case audio := <-p.audioChan:
 			p.handleAudio(ctx, audio)
-		}
-	}
-}
 
-// handleWakeWord handles wake word detection
-func (p *Pipeline) handleWakeWord(ctx context.Context) {
-	p.log.Info().Msg("Ana wake word detected - starting one-time recording")
-	// Simple: just record once, process, and go back to idle
-	p.startRecording()
-	go func() {
-		p.recordUntilSilence(ctx)
-		p.processRecordedAudio(ctx)
-	}()
-}
+		case req := <-p.textChan:
+			p.handleText(ctx, req)
 
-// persistentListeningSession handles continuous listening after wake word detection
-func (p *Pipeline) persistentListeningSession(ctx context.Context) {
-	p.log.Info().Msg("🔴 PERSISTENT SESSION STARTED")
-	// Keep listening until deactivation or context cancellation
-	for {
-		select {
-		case <-ctx.Done():
-			p.log.Info().Msg("Persistent session: context done")
-			return
-		case <-p.stopChan:
-			p.log.Info().Msg("Persistent session: stop signal")
-			return
-		default:
-		}
+		case res := <-p.results:
+			p.handleResult(ctx, res)
 
-		// Check if still in listening state
-		currentState := p.GetState()
-		p.log.Info().Str("state", currentState.String()).Msg("Persistent session: checking state")
-		if currentState != StateListening {
-			p.log.Info().Str("state", currentState.String()).Msg("Persistent session: exiting - not in listening state")
-			return
+		case <-ticker.C():
+			p.checkRecording(ctx)
+			p.checkFollowUp()
 		}
-
-		// Record until silence - this is blocking and waits for audio
-		// Once audio ends with silence, it returns
-		p.log.Info().Msg("Persistent session: starting recording loop")
-		p.startRecording()
-		p.log.Info().Msg("Persistent session: calling recordUntilSilence")
-		p.recordUntilSilence(ctx)
-		p.log.Info().Msg("Persistent session: recordUntilSilence returned")
-
-		// Process the recorded audio
-		// This will handle deactivation check and state management
-		p.log.Info().Msg("Persistent session: calling processRecordedAudio")
-		p.processRecordedAudio(ctx)
-		p.log.Info().Msg("Persistent session: processRecordedAudio returned")
-
-		// Loop back to record next command
-		// If deactivation was detected, GetState() will be Idle and loop will exit
 	}
 }
-
-// handleHotkeyRelease handles when the hotkey is released
-func (p *Pipeline) handleHotkeyRelease(ctx context.Context) {
-	if p.GetState() != StateRecording {
-		return
-	}
-
-	p.processRecordedAudio(ctx)
-}
-
-// startRecording starts recording audio
-func (p *Pipeline) startRecording() {
-	p.bufferMu.Lock()
-	p.audioBuffer.Reset()
-	p.bufferMu.Unlock()
-
-	p.setState(StateRecording)
-	p.hasSpeech = false
-	p.silenceStart = time.Time{}
-	p.speechStart = time.Time{}
-}
-
-// handleAudio handles incoming audio chunks
-func (p *Pipeline) handleAudio(ctx context.Context, audio []byte) {
-	state := p.GetState()
-
-	// In Idle state, detect speech and transcribe to look for wake word "Ana"
-	// This allows activation by saying "Ana" without pressing F4
-	if state == StateIdle && p.cfg.Audio.VAD.Enabled {
-		samples := utils.BytesToInt16(audio)
-		rms := utils.CalculateRMS(samples)
-		threshold := float64(500) * (1.0 - p.cfg.Audio.VAD.Sensitivity)
-
-		if rms > threshold {
-			p.log.Debug().Float64("rms", rms).Float64("threshold", threshold).Msg("Voice detected in Idle state, checking for wake word")
-			// Start recording to check for wake word
-			p.startRecording()
-
-			// Start wake word detection in background
-			go func() {
-				p.recordUntilSilence(ctx)
-				p.processRecordedAudio(ctx)
-			}()
-			// Fall through to record this chunk
-		}
-	}
-
-	// Process audio when in Listening or Recording state
-	currentState := p.GetState()
-	if currentState == StateListening || currentState == StateRecording {
-		p.bufferMu.Lock()
-		p.audioBuffer.Write(audio)
-		p.bufferMu.Unlock()
-
-		// Check for speech/silence
-		if p.cfg.Audio.VAD.Enabled {
-			p.analyzeVAD(audio)
-		}
-	}
-}
-
-// analyzeVAD analyzes audio for Voice Activity Detection
-func (p *Pipeline) analyzeVAD(audio []byte) {
-	// Convert bytes to samples
-	samples := utils.BytesToInt16(audio)
-
-	// Calculate RMS energy
-	rms := utils.CalculateRMS(samples)
-
-	// Threshold based on sensitivity
-	// Higher sensitivity = lower threshold = more sensitive to quiet sounds
-	threshold := float64(500) * (1.0 - p.cfg.Audio.VAD.Sensitivity)
-
-	isSpeech := rms > threshold
-
-	now := time.Now()
-
-	if isSpeech {
-		if !p.hasSpeech {
-			p.speechStart = now
-			p.hasSpeech = true
-			p.log.Debug().Float64("rms", rms).Msg("Speech detected")
-		}
-		p.silenceStart = time.Time{} // Reset silence timer
-	} else {
-		if p.hasSpeech && p.silenceStart.IsZero() {
-			p.silenceStart = now
-			p.log.Debug().Float64("rms", rms).Msg("Silence detected")
-		}
-	}
-}
-
-// recordUntilSilence records until silence is detected OR max speaking time reached
-func (p *Pipeline) recordUntilSilence(ctx context.Context) {
-	silenceThreshold := time.Duration(p.cfg.Audio.VAD.SilenceThresholdMs) * time.Millisecond
-	maxRecordTime := 30 * time.Second // Maximum recording time
-	autoProcessTime := 6 * time.Second // Auto-process after 6 seconds if still speaking
-
-	timeout := time.NewTimer(maxRecordTime)
-	defer timeout.Stop()
-
-	autoProcess := time.NewTimer(autoProcessTime)
-	defer autoProcess.Stop()
-
-	checkInterval := 100 * time.Millisecond
-	ticker := time.NewTicker(checkInterval)
-	defer ticker.Stop()
-
-	p.log.Info().Msg("recordUntilSilence: waiting for speech and silence")
-	startTime := time.Now()
-
-	for {
-		select {
-		case <-ctx.Done():
-			p.log.Info().Msg("recordUntilSilence: context done")
-			return
-		case <-p.stopChan:
-			p.log.Info().Msg("recordUntilSilence: stop signal")
-			return
-		case <-timeout.C:
-			p.log.Warn().Msg("Recording timeout reached")
-			return
-		case <-autoProcess.C:
-			// Auto-process after 6 seconds if we have speech
-			if p.hasSpeech {
-				p.log.Info().Msg("Auto-processing after 6 seconds of speech")
-				return
-			}
-		case <-ticker.C:
-			// If we've detected speech and now have silence
-			if p.hasSpeech && !p.silenceStart.IsZero() {
-				silenceDuration := time.Since(p.silenceStart)
-
-				if silenceDuration >= silenceThreshold {
-					p.log.Info().
-						Dur("silence", silenceDuration).
-						Msg("Silence threshold reached")
-					return
-				}
-			}
-
-			// If we have speech for more than 2 seconds, start considering shorter silences
-			if p.hasSpeech && time.Since(startTime) > 2*time.Second {
-				if !p.silenceStart.IsZero() {
-					// After 2 seconds of speech, accept shorter silences (300ms)
-					if time.Since(p.silenceStart) >= 300*time.Millisecond {
-						p.log.Info().Msg("Short silence after speech detected, processing")
-						return
-					}
-				}
-			}
-		}
-	}
-}
-
-// processRecordedAudio processes the recorded audio buffer
-func (p *Pipeline) processRecordedAudio(ctx context.Context) {
-	// Capture state BEFORE changing to StateProcessing
-	stateBeforeProcessing := p.GetState()
-	p.log.Info().Str("stateBeforeProcessing", stateBeforeProcessing.String()).Msg("DEBUG: State captured before processing")
-
-	p.setState(StateProcessing)
-	// Don't immediately return to Idle - check for deactivation first
-	defer func() {
-		// Only return to Idle if not in a persistent session or if deactivated
-		if p.GetState() == StateProcessing {
-			p.setState(StateIdle)
-		}
-	}()
-
-	p.bufferMu.Lock()
-	audio := p.audioBuffer.Bytes()
-	p.audioBuffer.Reset()
-	p.bufferMu.Unlock()
-
-	if len(audio) == 0 {
-		p.log.Warn().Msg("No audio recorded")
-		return
-	}
-
-	// Check minimum speech duration
-	minSpeech := time.Duration(p.cfg.Audio.VAD.MinSpeechMs) * time.Millisecond
-	if p.hasSpeech && !p.speechStart.IsZero() {
-		speechDuration := time.Since(p.speechStart)
-		if speechDuration < minSpeech {
-			p.log.Debug().
-				Dur("duration", speechDuration).
-				Dur("minimum", minSpeech).
-				Msg("Speech too short, ignoring")
-			return
-		}
-	}
-
-	p.log.Info().Int("bytes", len(audio)).Msg("Processing recorded audio")
-
-	// Transcribe
-	result, err := p.sttProvider.Transcribe(ctx, audio)
-	if err != nil {
-		p.log.Error().Err(err).Msg("Transcription failed")
-		if p.onError != nil {
-			p.onError(fmt.Errorf("transcription failed: %w", err))
-		}
-		return
-	}
-
-	text := result.Text
-	if text == "" {
-		p.log.Debug().Msg("Empty transcription")
-		return
-	}
-
-	p.log.Info().Str("text", text).Msg("Transcribed")
-
-	// Check for deactivation word first - if detected, exit the session
-	if llm.IsAnaDeactivated(text) {
-		p.log.Info().Str("text", text).Msg("Deactivation word detected - ending session")
-		if p.onResponse != nil {
-			p.onResponse("Adiós! Estoy aquí si me necesitas.")
-		}
-		p.setState(StateIdle)
-		return
-	}
-
-	// SIMPLIFIED: Always require "Ana" keyword for every command
-	// Check if Ana is activated
-	if !llm.IsAnaActivated(text) {
-		p.log.Debug().Str("text", text).Msg("Ignoring transcription - Ana name not mentioned")
-		return
-	}
-
-	p.log.Info().Str("text", text).Msg("Ana detected - processing command")
-
-	if p.onTranscript != nil {
-		p.onTranscript(text)
-	}
-
-	// Process command
-	response, err := p.brain.ProcessCommand(ctx, text)
-	if err != nil {
-		p.log.Error().Err(err).Msg("Command processing failed")
-		if p.onError != nil {
-			p.onError(fmt.Errorf("command processing failed: %w", err))
-		}
-		// Keep session active after error
-		p.setState(StateListening)
-		return
-	}
-
-	if response != "" {
-		p.log.Info().Str("response", response).Msg("Response")
-
-		if p.onResponse != nil {
-			p.onResponse(response)
-		}
-	}
-
-	// Speak response
-	if err := p.brain.ProcessAndSpeak(ctx, text); err != nil {
-		p.log.Error().Err(err).Msg("TTS failed")
-	}
-
-	// SIMPLIFIED: Always return to Idle after processing
-	// User must say "Ana" again for next command
-	p.setState(StateIdle)
-}
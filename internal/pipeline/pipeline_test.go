@@ -0,0 +1,619 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/anastreamer/ana/internal/brain"
+	"github.com/anastreamer/ana/internal/config"
+	"github.com/anastreamer/ana/internal/llm"
+	"github.com/anastreamer/ana/internal/stt"
+	"github.com/anastreamer/ana/internal/vad"
+	"github.com/anastreamer/ana/internal/wakeword"
+	"github.com/anastreamer/ana/pkg/utils"
+)
+
+// Test audio: 100 ms chunks at 16 kHz whose samples are all the same level
+const (
+	chunkDuration = 100 * time.Millisecond
+	chunkBytes    = 3200
+	speechLevel   = 2000
+	wakeLevel     = 3000 // Speech the fake detector takes for the wake word
+)
+
+// fakeClock is a manually advanced Clock
+type fakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	tickers []*fakeTicker
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) NewTicker(d time.Duration) Ticker {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	t := &fakeTicker{
+		clock:  c,
+		period: d,
+		next:   c.now.Add(d),
+		ch:     make(chan time.Time, 1),
+	}
+	c.tickers = append(c.tickers, t)
+	return t
+}
+
+// Advance moves the clock forward, firing tickers that come due. Like
+// time.Ticker, a ticker whose previous tick wasn't read drops the new one.
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+	for _, t := range c.tickers {
+		for !t.next.After(c.now) {
+			select {
+			case t.ch <- t.next:
+			default:
+			}
+			t.next = t.next.Add(t.period)
+		}
+	}
+}
+
+type fakeTicker struct {
+	clock  *fakeClock
+	period time.Duration
+	next   time.Time
+	ch     chan time.Time
+}
+
+func (t *fakeTicker) C() <-chan time.Time { return t.ch }
+
+func (t *fakeTicker) Stop() {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+
+	for i, other := range t.clock.tickers {
+		if other == t {
+			t.clock.tickers = append(t.clock.tickers[:i], t.clock.tickers[i+1:]...)
+			break
+		}
+	}
+}
+
+// fakeSTT returns the queued transcriptions in order, then empty ones
+type fakeSTT struct {
+	mu       sync.Mutex
+	texts    []string
+	language string // Detected language, "es" if empty
+	audios   [][]byte
+}
+
+func (s *fakeSTT) Name() string                     { return "fake" }
+func (s *fakeSTT) SetLanguage(lang string)          {}
+func (s *fakeSTT) SetPrompt(prompt string)          {}
+func (s *fakeSTT) IsAvailable(context.Context) bool { return true }
+func (s *fakeSTT) Close() error                     { return nil }
+
+func (s *fakeSTT) Transcribe(ctx context.Context, audio []byte) (*stt.TranscriptionResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.audios = append(s.audios, audio)
+
+	res := &stt.TranscriptionResult{Language: s.language, Confidence: 1}
+	if res.Language == "" {
+		res.Language = "es"
+	}
+	if len(s.texts) > 0 {
+		res.Text = s.texts[0]
+		s.texts = s.texts[1:]
+	}
+	return res, nil
+}
+
+func (s *fakeSTT) TranscribeFile(ctx context.Context, path string) (*stt.TranscriptionResult, error) {
+	return nil, errors.New("not supported")
+}
+
+// transcribed returns the audio of every transcription so far
+func (s *fakeSTT) transcribed() [][]byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([][]byte(nil), s.audios...)
+}
+
+// fakeLLM answers every command with the same reply and no action
+type fakeLLM struct {
+	reply string
+}
+
+func (l *fakeLLM) Name() string                     { return "fake" }
+func (l *fakeLLM) IsAvailable(context.Context) bool { return true }
+func (l *fakeLLM) Close() error                     { return nil }
+
+func (l *fakeLLM) Complete(ctx context.Context, prompt string) (llm.Action, error) {
+	return llm.Action{Action: "none", Params: map[string]interface{}{}, Reply: l.reply}, nil
+}
+
+func (l *fakeLLM) CompleteStream(ctx context.Context, prompt string, h llm.StreamHandler) (llm.Action, error) {
+	action, _ := l.Complete(ctx, prompt)
+	if h.OnAction != nil {
+		h.OnAction(action)
+	}
+	if h.OnSentence != nil {
+		h.OnSentence(action.Reply)
+	}
+	return action, nil
+}
+
+func (l *fakeLLM) CompleteRaw(ctx context.Context, prompt string) (string, error) {
+	return l.reply, nil
+}
+
+// fakeTTS "plays" each reply until the test finishes it or it's stopped
+type fakeTTS struct {
+	playing chan string   // Each reply as it starts
+	finish  chan struct{} // Ends the reply being played
+	stop    chan struct{}
+
+	mu      sync.Mutex
+	stopped int
+}
+
+func newFakeTTS() *fakeTTS {
+	return &fakeTTS{
+		playing: make(chan string, 10),
+		finish:  make(chan struct{}),
+		stop:    make(chan struct{}, 1),
+	}
+}
+
+func (t *fakeTTS) Name() string                     { return "fake" }
+func (t *fakeTTS) SetVoice(voice string) error      { return nil }
+func (t *fakeTTS) SetLanguage(lang string)          {}
+func (t *fakeTTS) SetSpeed(speed float64)           {}
+func (t *fakeTTS) IsAvailable(context.Context) bool { return true }
+func (t *fakeTTS) Close() error                     { return nil }
+
+func (t *fakeTTS) Synthesize(ctx context.Context, text string) ([]byte, error) {
+	return nil, errors.New("not supported")
+}
+
+func (t *fakeTTS) Speak(ctx context.Context, text string) error {
+	t.playing <- text
+	select {
+	case <-t.finish:
+		return nil
+	case <-t.stop:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (t *fakeTTS) Stop() {
+	t.mu.Lock()
+	t.stopped++
+	t.mu.Unlock()
+
+	select {
+	case t.stop <- struct{}{}:
+	default:
+	}
+}
+
+// stops returns how many times playback was stopped
+func (t *fakeTTS) stops() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.stopped
+}
+
+// fakeVAD hears speech in any chunk that isn't silent
+type fakeVAD struct{}
+
+func (fakeVAD) Process(samples []int16) vad.Result {
+	if len(samples) > 0 && samples[0] != 0 {
+		return vad.Result{Probability: 1, Speech: true, LevelDB: -20, NoiseDB: -60}
+	}
+	return vad.Result{LevelDB: -60, NoiseDB: -60}
+}
+
+func (fakeVAD) Reset() {}
+
+// fakeDetector hears the wake word in chunks at wakeLevel
+type fakeDetector struct{}
+
+func (fakeDetector) Process(samples []int16) (wakeword.Detection, bool) {
+	if len(samples) > 0 && samples[0] == wakeLevel {
+		return wakeword.Detection{Word: "ana", Score: 0.9}, true
+	}
+	return wakeword.Detection{Word: "ana"}, false
+}
+
+func (fakeDetector) Reset() {}
+
+// harness runs a pipeline with fakes on a fake clock and records its
+// state changes
+type harness struct {
+	t         *testing.T
+	p         *Pipeline
+	clock     *fakeClock
+	stt       *fakeSTT
+	tts       *fakeTTS
+	states    chan State
+	followUp  chan bool
+	responses chan string
+}
+
+func newHarness(t *testing.T, configure func(cfg *config.Config), transcripts ...string) *harness {
+	t.Helper()
+	cfg := config.DefaultConfig()
+	cfg.General.Language = "es"
+	cfg.General.Languages = []string{"es"}
+	cfg.LLM.Streaming = false
+	if configure != nil {
+		configure(cfg)
+	}
+
+	h := &harness{
+		t:         t,
+		clock:     &fakeClock{now: time.Date(2026, 5, 1, 18, 0, 0, 0, time.UTC)},
+		stt:       &fakeSTT{texts: transcripts},
+		tts:       newFakeTTS(),
+		states:    make(chan State, 100),
+		followUp:  make(chan bool, 10),
+		responses: make(chan string, 10),
+	}
+
+	h.p = NewPipeline(cfg, h.stt, brain.New(&fakeLLM{reply: "Hecho."}, h.tts))
+	h.p.vad = fakeVAD{}
+	h.p.SetClock(h.clock)
+	h.p.SetCallbacks(func(s State) { h.states <- s }, nil, func(r string) { h.responses <- r }, nil)
+	h.p.SetFollowUpCallback(func(open bool) { h.followUp <- open })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(func() {
+		h.p.Stop()
+		cancel()
+	})
+	h.p.Start(ctx)
+
+	// Ticks only reach the loop once it made its ticker
+	for !h.started() {
+		time.Sleep(time.Millisecond)
+	}
+	return h
+}
+
+func (h *harness) started() bool {
+	h.clock.mu.Lock()
+	defer h.clock.mu.Unlock()
+	return len(h.clock.tickers) > 0
+}
+
+// settle waits until the pipeline handled every event sent so far
+func (h *harness) settle() {
+	h.t.Helper()
+	for len(h.p.audioChan) > 0 || len(h.p.wakeWordChan) > 0 || len(h.p.hotkeyDown) > 0 || len(h.p.hotkeyUp) > 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// The loop handles one event at a time, so once it takes this stale
+	// result (a no-op) it's done with the events before it
+	select {
+	case h.p.results <- result{turn: -1}:
+	case <-time.After(2 * time.Second):
+		h.t.Fatal("the pipeline loop is stuck")
+	}
+}
+
+// advance moves the clock forward one tick at a time, letting the
+// pipeline handle each tick
+func (h *harness) advance(d time.Duration) {
+	h.t.Helper()
+	for ; d > 0; d -= tickInterval {
+		h.clock.Advance(tickInterval)
+		for h.pendingTicks() > 0 {
+			time.Sleep(time.Millisecond)
+		}
+		h.settle()
+	}
+}
+
+// pendingTicks returns the ticks the pipeline hasn't read yet
+func (h *harness) pendingTicks() int {
+	h.clock.mu.Lock()
+	defer h.clock.mu.Unlock()
+	return len(h.clock.tickers[0].ch)
+}
+
+// feed sends d of audio at level in chunks, as the mic does in real time
+func (h *harness) feed(level int16, d time.Duration) {
+	h.t.Helper()
+	for ; d > 0; d -= chunkDuration {
+		samples := make([]int16, chunkBytes/2)
+		for i := range samples {
+			samples[i] = level
+		}
+		h.p.FeedAudio(utils.Int16ToBytes(samples))
+		h.settle()
+		h.advance(chunkDuration)
+	}
+}
+
+// expect waits for the next state changes to be want
+func (h *harness) expect(want ...State) {
+	h.t.Helper()
+	for _, w := range want {
+		select {
+		case got := <-h.states:
+			if got != w {
+				h.t.Fatalf("state changed to %s, want %s", got, w)
+			}
+		case <-time.After(2 * time.Second):
+			h.t.Fatalf("state stayed %s, want %s", h.p.GetState(), w)
+		}
+	}
+}
+
+// expectNoChange checks that the state hasn't changed since the last expect
+func (h *harness) expectNoChange() {
+	h.t.Helper()
+	select {
+	case got := <-h.states:
+		h.t.Fatalf("state changed to %s, want it to stay", got)
+	default:
+	}
+}
+
+// expectFollowUp waits for the follow-up window to open or close
+func (h *harness) expectFollowUp(open bool) {
+	h.t.Helper()
+	select {
+	case got := <-h.followUp:
+		if got != open {
+			h.t.Fatalf("follow-up open = %v, want %v", got, open)
+		}
+	case <-time.After(2 * time.Second):
+		h.t.Fatalf("follow-up never changed to open = %v", open)
+	}
+}
+
+// expectSpoken waits for Ana to start saying text
+func (h *harness) expectSpoken(text string) {
+	h.t.Helper()
+	select {
+	case got := <-h.tts.playing:
+		if got != text {
+			h.t.Fatalf("Ana says %q, want %q", got, text)
+		}
+	case <-time.After(2 * time.Second):
+		h.t.Fatalf("Ana never said %q", text)
+	}
+}
+
+// command says a command after the wake word and waits until Ana speaks
+// the reply
+func (h *harness) command() {
+	h.t.Helper()
+	h.p.TriggerWakeWord()
+	h.settle()
+	h.expect(StateWakeDetected, StateRecording)
+
+	h.feed(speechLevel, 500*time.Millisecond)
+	h.feed(0, 1500*time.Millisecond)
+	h.expect(StateTranscribing, StateThinking, StateSpeaking)
+	h.expectSpoken("Hecho.")
+}
+
+func TestPipelineCommand(t *testing.T) {
+	h := newHarness(t, nil, "pon música")
+
+	h.p.TriggerWakeWord()
+	h.settle()
+	h.expect(StateWakeDetected, StateRecording)
+
+	h.feed(speechLevel, 500*time.Millisecond)
+
+	// The recording ends once the silence lasts the threshold
+	h.feed(0, 1400*time.Millisecond)
+	h.expectNoChange()
+	h.feed(0, 100*time.Millisecond)
+	h.expect(StateTranscribing, StateThinking, StateSpeaking)
+
+	// STT got everything since the wake word
+	if audios := h.stt.transcribed(); len(audios) != 1 || len(audios[0]) != 20*chunkBytes {
+		t.Errorf("transcribed %d recordings, want one of 2 s", len(audios))
+	}
+
+	h.expectSpoken("Hecho.")
+	h.tts.finish <- struct{}{}
+	h.expect(StateFollowUp)
+	h.expectFollowUp(true)
+
+	// Without another command the follow-up window closes
+	h.advance(7900 * time.Millisecond)
+	h.expectNoChange()
+	h.advance(100 * time.Millisecond)
+	h.expect(StateIdle)
+	h.expectFollowUp(false)
+}
+
+func TestPipelineNoCommandAfterWakeWord(t *testing.T) {
+	h := newHarness(t, nil)
+
+	h.p.TriggerWakeWord()
+	h.settle()
+	h.expect(StateWakeDetected, StateRecording)
+
+	// Nobody talks: give up after noSpeechTimeout without calling STT
+	h.feed(0, noSpeechTimeout-chunkDuration)
+	h.expectNoChange()
+	h.feed(0, chunkDuration)
+	h.expect(StateIdle)
+
+	if audios := h.stt.transcribed(); len(audios) != 0 {
+		t.Errorf("transcribed %d recordings of silence", len(audios))
+	}
+}
+
+func TestPipelineSpeechNeedsName(t *testing.T) {
+	h := newHarness(t, nil, "pon música", "Ana, pon música")
+
+	// Without the wake word, only speech naming Ana is a command
+	h.feed(speechLevel, 500*time.Millisecond)
+	h.feed(0, 1500*time.Millisecond)
+	h.expect(StateRecording, StateTranscribing, StateIdle)
+
+	h.feed(speechLevel, 500*time.Millisecond)
+	h.feed(0, 1500*time.Millisecond)
+	h.expect(StateRecording, StateTranscribing, StateThinking, StateSpeaking)
+	h.expectSpoken("Hecho.")
+}
+
+func TestPipelineFollowUpCommand(t *testing.T) {
+	h := newHarness(t, nil, "pon música", "sube el volumen", "adiós")
+	h.command()
+	h.tts.finish <- struct{}{}
+	h.expect(StateFollowUp)
+	h.expectFollowUp(true)
+
+	// Speech in the window is a command, without the wake word or "Ana"
+	h.advance(3 * time.Second)
+	h.feed(speechLevel, 500*time.Millisecond)
+	h.expect(StateRecording)
+	h.feed(0, 1500*time.Millisecond)
+	h.expect(StateTranscribing, StateThinking, StateSpeaking)
+	h.expectSpoken("Hecho.")
+	h.tts.finish <- struct{}{}
+	h.expect(StateFollowUp)
+
+	// A deactivation phrase ends the session
+	h.feed(speechLevel, 500*time.Millisecond)
+	h.feed(0, 1500*time.Millisecond)
+	h.expect(StateRecording, StateTranscribing, StateIdle)
+	h.expectFollowUp(false)
+}
+
+func TestPipelineGoodbyeInEnglish(t *testing.T) {
+	h := newHarness(t, func(cfg *config.Config) { cfg.General.Languages = []string{"es", "en"} }, "goodbye")
+	h.stt.language = "en"
+
+	h.p.TriggerWakeWord()
+	h.settle()
+	h.feed(speechLevel, 500*time.Millisecond)
+	h.feed(0, 1500*time.Millisecond)
+	h.expect(StateWakeDetected, StateRecording, StateTranscribing, StateIdle)
+
+	select {
+	case got := <-h.responses:
+		if want := "Bye! I'm here if you need me."; got != want {
+			t.Errorf("goodbye = %q, want %q", got, want)
+		}
+	default:
+		t.Error("no goodbye")
+	}
+}
+
+func TestPipelineSessionModes(t *testing.T) {
+	// Single: back to Idle after every reply
+	h := newHarness(t, func(cfg *config.Config) { cfg.Session.Mode = "single" }, "pon música")
+	h.command()
+	h.tts.finish <- struct{}{}
+	h.expect(StateIdle)
+
+	// Persistent: the session doesn't time out
+	h = newHarness(t, func(cfg *config.Config) { cfg.Session.Mode = "persistent" }, "pon música")
+	h.command()
+	h.tts.finish <- struct{}{}
+	h.expect(StateFollowUp)
+	h.expectFollowUp(true)
+	h.advance(time.Minute)
+	h.expectNoChange()
+}
+
+func TestPipelineBargeInStop(t *testing.T) {
+	h := newHarness(t, nil, "pon música", "para")
+	h.command()
+
+	// The streamer talks over Ana; the probe is transcribed once they stop
+	h.feed(speechLevel, 300*time.Millisecond)
+	h.feed(0, probeSilenceTime+chunkDuration)
+	h.expect(StateFollowUp)
+	h.expectFollowUp(true)
+
+	if h.tts.stops() != 1 {
+		t.Errorf("playback stopped %d times, want once", h.tts.stops())
+	}
+	if audios := h.stt.transcribed(); len(audios) != 2 {
+		t.Errorf("transcribed %d recordings, want the command and the probe", len(audios))
+	}
+}
+
+func TestPipelineBargeInCommand(t *testing.T) {
+	h := newHarness(t, nil, "pon música", "Ana, sube el volumen")
+	h.command()
+
+	// A new command replaces the reply
+	h.feed(speechLevel, 300*time.Millisecond)
+	h.feed(0, probeSilenceTime+chunkDuration)
+	h.expect(StateThinking, StateSpeaking)
+	h.expectSpoken("Hecho.")
+	if h.tts.stops() != 1 {
+		t.Errorf("playback stopped %d times, want once", h.tts.stops())
+	}
+
+	h.tts.finish <- struct{}{}
+	h.expect(StateFollowUp)
+}
+
+func TestPipelineBargeInIgnoresOtherSpeech(t *testing.T) {
+	h := newHarness(t, nil, "pon música", "qué buena partida")
+	h.command()
+
+	h.feed(speechLevel, 300*time.Millisecond)
+	h.feed(0, probeSilenceTime+chunkDuration)
+	h.advance(time.Second)
+
+	// Ana keeps talking
+	h.expectNoChange()
+	if h.tts.stops() != 0 {
+		t.Errorf("playback stopped %d times for speech not meant for Ana", h.tts.stops())
+	}
+	h.tts.finish <- struct{}{}
+	h.expect(StateFollowUp)
+}
+
+func TestPipelineWakeWordDetector(t *testing.T) {
+	h := newHarness(t, nil, "pon música", "sube el volumen")
+	h.p.SetWakeWordDetector(fakeDetector{})
+
+	// Speech alone doesn't start anything in Idle
+	h.feed(speechLevel, time.Second)
+	h.feed(0, time.Second)
+	h.expectNoChange()
+
+	h.feed(wakeLevel, chunkDuration)
+	h.expect(StateWakeDetected, StateRecording)
+	h.feed(speechLevel, 400*time.Millisecond)
+	h.feed(0, 1500*time.Millisecond)
+	h.expect(StateTranscribing, StateThinking, StateSpeaking)
+	h.expectSpoken("Hecho.")
+
+	// The wake word interrupts Ana at once
+	h.feed(wakeLevel, chunkDuration)
+	h.expect(StateWakeDetected, StateRecording)
+	if h.tts.stops() != 1 {
+		t.Errorf("playback stopped %d times, want once", h.tts.stops())
+	}
+}
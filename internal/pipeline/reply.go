@@ -0,0 +1,102 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/anastreamer/ana/internal/llm"
+)
+
+// think processes a command in a worker like work. With llm.streaming, the
+// sentences of the reply are reported as the LLM writes them, so Ana starts
+// speaking before the reply is complete; the final result carries the part
+// not reported yet in rest.
+func (p *Pipeline) think(lang string, fn func(ctx context.Context, speak func(sentence string)) (string, string, error)) {
+	res := result{turn: p.turn, state: StateThinking}
+	ctx := p.turnCtx
+
+	var speak func(string)
+	if p.cfg.LLM.Streaming {
+		speak = func(sentence string) {
+			select {
+			case p.results <- result{turn: res.turn, state: StateThinking, sentence: sentence}:
+			case <-ctx.Done():
+			}
+		}
+	}
+
+	go func() {
+		res.text, res.rest, res.err = fn(llm.WithLanguage(ctx, lang), speak)
+		select {
+		case p.results <- res:
+		case <-ctx.Done():
+		}
+	}()
+}
+
+// handleSentence speaks a sentence of a reply still being written, or
+// queues it behind the one being spoken
+func (p *Pipeline) handleSentence(sentence string) {
+	if state := p.GetState(); state != StateThinking && state != StateSpeaking {
+		return
+	}
+
+	if !p.streamingReply {
+		p.log.Debug().Str("sentence", sentence).Msg("Speaking before the reply is complete")
+		p.streamingReply = true
+		p.setState(StateSpeaking)
+	}
+	p.sentences = append(p.sentences, sentence)
+	if !p.talking {
+		p.speakNext()
+	}
+}
+
+// finishReply handles the end of a reply whose first sentences are being
+// spoken: the rest is queued and the turn ends once everything is said
+func (p *Pipeline) finishReply(res result) {
+	p.replyDone = true
+
+	if res.err != nil {
+		p.log.Error().Err(res.err).Msg("Command processing failed")
+		if p.onError != nil {
+			p.onError(fmt.Errorf("command processing failed: %w", res.err))
+		}
+		p.replyText("", res.err)
+	} else {
+		p.log.Info().Str("response", res.text).Msg("Response")
+		p.replyText(res.text, nil)
+		if p.onResponse != nil {
+			p.onResponse(res.text)
+		}
+		if res.rest != "" {
+			p.sentences = append(p.sentences, res.rest)
+		}
+	}
+
+	if !p.talking {
+		p.speakNext()
+	}
+}
+
+// speakNext speaks the next queued sentence, or ends the turn if the reply
+// is complete and everything was said
+func (p *Pipeline) speakNext() {
+	if len(p.sentences) == 0 {
+		if p.replyDone {
+			p.finishCommand()
+		}
+		return
+	}
+
+	sentence := p.sentences[0]
+	p.sentences = p.sentences[1:]
+	p.talking = true
+	p.speakingText = strings.TrimSpace(p.speakingText + " " + sentence)
+
+	lang := p.language
+	p.work(result{state: StateSpeaking}, func(ctx context.Context) (string, error) {
+		return "", p.brain.Speak(llm.WithLanguage(ctx, lang), sentence)
+	})
+}
@@ -0,0 +1,60 @@
+package pipeline
+
+// ringBuffer keeps the most recent bytes written to it, up to its capacity
+type ringBuffer struct {
+	data []byte
+	pos  int
+	full bool
+}
+
+// newRingBuffer creates a ring buffer holding up to size bytes
+func newRingBuffer(size int) *ringBuffer {
+	return &ringBuffer{data: make([]byte, size)}
+}
+
+// Write appends p, overwriting the oldest bytes once full
+func (r *ringBuffer) Write(p []byte) {
+	if len(r.data) == 0 {
+		return
+	}
+	if len(p) >= len(r.data) {
+		copy(r.data, p[len(p)-len(r.data):])
+		r.pos = 0
+		r.full = true
+		return
+	}
+
+	n := copy(r.data[r.pos:], p)
+	if n < len(p) {
+		copy(r.data, p[n:])
+		r.full = true
+	}
+	r.pos = (r.pos + len(p)) % len(r.data)
+	if r.pos == 0 && len(p) > 0 {
+		r.full = true
+	}
+}
+
+// Bytes returns a copy of the buffered bytes, oldest first
+func (r *ringBuffer) Bytes() []byte {
+	if !r.full {
+		return append([]byte(nil), r.data[:r.pos]...)
+	}
+	out := make([]byte, 0, len(r.data))
+	out = append(out, r.data[r.pos:]...)
+	return append(out, r.data[:r.pos]...)
+}
+
+// Last returns a copy of the newest n buffered bytes, or all of them if fewer
+func (r *ringBuffer) Last(n int) []byte {
+	b := r.Bytes()
+	if n < len(b) {
+		b = b[len(b)-n:]
+	}
+	return b
+}
+
+// Size returns the capacity in bytes
+func (r *ringBuffer) Size() int {
+	return len(r.data)
+}
@@ -0,0 +1,127 @@
+package pipeline
+
+import (
+	"context"
+	"strings"
+
+	"github.com/anastreamer/ana/internal/llm"
+	"github.com/anastreamer/ana/internal/stt"
+)
+
+// speculation is the intent of a partial transcript, parsed by the LLM while
+// the streamer may still be talking. It's used if the final transcript turns
+// out to be the same text.
+type speculation struct {
+	text   string
+	done   chan struct{}
+	action llm.Action
+	err    error
+}
+
+// wait returns the speculated action once the LLM has answered
+func (s *speculation) wait(ctx context.Context) (llm.Action, bool) {
+	select {
+	case <-s.done:
+		return s.action, s.err == nil
+	case <-ctx.Done():
+		return llm.Action{}, false
+	}
+}
+
+// startStream opens an STT stream for the recording that just started, if
+// the provider can stream, and feeds it what was recorded so far
+func (p *Pipeline) startStream() {
+	if p.streamer == nil {
+		return
+	}
+
+	stream, err := p.streamer.StartStream(p.turnCtx)
+	if err != nil {
+		p.log.Warn().Err(err).Msg("Cannot start STT stream")
+		return
+	}
+	p.stream = stream
+	stream.Write(p.audioBuffer.Bytes())
+
+	// Forward partials to the run goroutine as results of this turn
+	turn, ctx := p.turn, p.turnCtx
+	go func() {
+		for h := range stream.Partials() {
+			select {
+			case p.results <- result{turn: turn, state: StateRecording, partial: &h}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// record adds audio to the recording and its STT stream
+func (p *Pipeline) record(audio []byte) {
+	p.audioBuffer.Write(audio)
+	if p.stream != nil {
+		p.stream.Write(audio)
+	}
+}
+
+// closeStream abandons the recording's STT stream and speculation
+func (p *Pipeline) closeStream() {
+	if p.stream != nil {
+		p.stream.Close()
+		p.stream = nil
+	}
+	p.partial = stt.Hypothesis{}
+	p.spec = nil
+}
+
+// handlePartial acts on a partial transcript of the recording in progress.
+// Hearing "Ana" early confirms a speech recording is meant for her; once the
+// streamer has gone quiet, the command is sent to the LLM before the
+// recording even ends.
+func (p *Pipeline) handlePartial(h stt.Hypothesis) {
+	p.cleanTranscription(&h.TranscriptionResult)
+	p.partial = h
+	p.log.Debug().Str("text", h.Text).Msg("Partial transcript")
+
+	if p.source == sourceSpeech && llm.IsAnaActivated(h.Text) {
+		p.log.Info().Msg("Ana heard in partial transcript")
+		p.source = sourceWakeWord
+	}
+
+	text, ok := p.partialFinal()
+	if !ok || (p.spec != nil && p.spec.text == text) {
+		return
+	}
+	if p.needsName() && !llm.IsAnaActivated(text) || llm.IsAnaDeactivated(text) {
+		return
+	}
+
+	p.log.Debug().Str("text", text).Msg("Interpreting partial transcript ahead of time")
+	spec := &speculation{text: text, done: make(chan struct{})}
+	p.spec = spec
+	ctx := llm.WithLanguage(p.turnCtx, p.commandLanguage(h.Language))
+	go func() {
+		defer close(spec.done)
+		spec.action, spec.err = p.brain.Interpret(ctx, text)
+	}()
+}
+
+// partialFinal returns the latest partial transcript if it already covers
+// all the speech of the recording: it was made after the silence began
+func (p *Pipeline) partialFinal() (string, bool) {
+	text := strings.TrimSpace(p.partial.Text)
+	if text == "" || !p.hasSpeech || p.silenceStart.IsZero() {
+		return "", false
+	}
+	return text, p.partial.Bytes >= p.silenceBytes
+}
+
+// takeSpeculation returns the speculation made for text, if any
+func (p *Pipeline) takeSpeculation(text string) *speculation {
+	spec := p.spec
+	p.spec = nil
+	if spec == nil || spec.text != strings.TrimSpace(text) {
+		return nil
+	}
+	return spec
+}
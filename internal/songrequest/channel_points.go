@@ -0,0 +1,230 @@
+package songrequest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/anastreamer/ana/internal/config"
+	"github.com/anastreamer/ana/internal/executor/music"
+	"github.com/anastreamer/ana/pkg/logger"
+	"github.com/gorilla/websocket"
+	"github.com/rs/zerolog"
+)
+
+const (
+	helixURL             = "https://api.twitch.tv/helix"
+	redemptionEventType  = "channel.channel_points_custom_reward_redemption.add"
+	eventSubWelcome      = "session_welcome"
+	eventSubKeepalive    = "session_keepalive"
+	eventSubReconnect    = "session_reconnect"
+	eventSubNotification = "notification"
+	eventSubRevocation   = "revocation"
+)
+
+// ChannelPointsSource turns channel point redemptions of a reward into song
+// requests, using the redemption's text input as the query. It listens on
+// Twitch EventSub over WebSocket, so no public URL is needed.
+type ChannelPointsSource struct {
+	reward        string
+	wsURL         string
+	apiURL        string
+	clientID      string
+	token         string
+	broadcasterID string
+	client        *http.Client
+	log           zerolog.Logger
+}
+
+// NewChannelPointsSource creates a channel point redemption source
+func NewChannelPointsSource(cfg config.SongRequestChannelPointsConfig, twitch config.TwitchConfig) *ChannelPointsSource {
+	return &ChannelPointsSource{
+		reward:        cfg.Reward,
+		wsURL:         cfg.URL,
+		apiURL:        helixURL,
+		clientID:      os.ExpandEnv(twitch.ClientID),
+		token:         strings.TrimPrefix(os.ExpandEnv(twitch.AccessToken), "oauth:"),
+		broadcasterID: os.ExpandEnv(twitch.BroadcasterID),
+		client: &http.Client{
+			Timeout: 10 * time.Second,
+		},
+		log: logger.Component("songrequest-points"),
+	}
+}
+
+// Name returns the source name
+func (s *ChannelPointsSource) Name() string {
+	return "channel_points"
+}
+
+// eventSubMessage is the envelope of every EventSub WebSocket message
+type eventSubMessage struct {
+	Metadata struct {
+		MessageType      string `json:"message_type"`
+		SubscriptionType string `json:"subscription_type"`
+	} `json:"metadata"`
+	Payload struct {
+		Session struct {
+			ID                      string `json:"id"`
+			KeepaliveTimeoutSeconds int    `json:"keepalive_timeout_seconds"`
+			ReconnectURL            string `json:"reconnect_url"`
+		} `json:"session"`
+		Event struct {
+			UserName  string `json:"user_name"`
+			UserInput string `json:"user_input"`
+			Reward    struct {
+				Title string `json:"title"`
+			} `json:"reward"`
+		} `json:"event"`
+	} `json:"payload"`
+}
+
+// Start connects to EventSub and keeps reconnecting until ctx is done
+func (s *ChannelPointsSource) Start(ctx context.Context, handle music.RequestHandler) error {
+	if s.token == "" || s.clientID == "" || s.broadcasterID == "" {
+		return fmt.Errorf("Twitch client_id, access_token and broadcaster_id are required for channel point requests")
+	}
+
+	go func() {
+		backoff := time.Second
+		for {
+			start := time.Now()
+			err := s.run(ctx, handle)
+			if ctx.Err() != nil {
+				return
+			}
+
+			if time.Since(start) > time.Minute {
+				backoff = time.Second
+			}
+			s.log.Warn().Err(err).Dur("retry_in", backoff).Msg("EventSub disconnected")
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(backoff):
+			}
+			backoff = min(backoff*2, 2*time.Minute)
+		}
+	}()
+
+	return nil
+}
+
+// run handles one EventSub session, following reconnect messages
+func (s *ChannelPointsSource) run(ctx context.Context, handle music.RequestHandler) error {
+	url := s.wsURL
+	subscribed := false
+
+	for {
+		conn, _, err := websocket.DefaultDialer.DialContext(ctx, url, nil)
+		if err != nil {
+			return fmt.Errorf("failed to connect to EventSub: %w", err)
+		}
+
+		next, err := s.session(ctx, conn, handle, &subscribed)
+		conn.Close()
+		if err != nil {
+			return err
+		}
+		// Twitch asked us to move to a new URL; subscriptions carry over
+		url = next
+	}
+}
+
+// session reads messages until the connection fails or Twitch asks to
+// reconnect, in which case it returns the new URL
+func (s *ChannelPointsSource) session(ctx context.Context, conn *websocket.Conn, handle music.RequestHandler, subscribed *bool) (string, error) {
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+
+	keepalive := 30 * time.Second
+	for {
+		conn.SetReadDeadline(time.Now().Add(keepalive + 10*time.Second))
+
+		var msg eventSubMessage
+		if err := conn.ReadJSON(&msg); err != nil {
+			return "", err
+		}
+
+		switch msg.Metadata.MessageType {
+		case eventSubWelcome:
+			if t := msg.Payload.Session.KeepaliveTimeoutSeconds; t > 0 {
+				keepalive = time.Duration(t) * time.Second
+			}
+			if !*subscribed {
+				if err := s.subscribe(ctx, msg.Payload.Session.ID); err != nil {
+					return "", err
+				}
+				*subscribed = true
+				s.log.Info().Str("reward", s.reward).Msg("Listening for channel point song requests")
+			}
+
+		case eventSubKeepalive:
+
+		case eventSubReconnect:
+			return msg.Payload.Session.ReconnectURL, nil
+
+		case eventSubRevocation:
+			*subscribed = false
+			return "", fmt.Errorf("EventSub subscription revoked")
+
+		case eventSubNotification:
+			if msg.Metadata.SubscriptionType != redemptionEventType {
+				continue
+			}
+			event := msg.Payload.Event
+			if !strings.EqualFold(event.Reward.Title, s.reward) || strings.TrimSpace(event.UserInput) == "" {
+				continue
+			}
+
+			req := music.Request{User: event.UserName, Query: event.UserInput, Source: s.Name()}
+			if _, _, err := handle(ctx, req); err != nil {
+				s.log.Info().Err(err).Str("user", req.User).Str("query", req.Query).Msg("Channel point request rejected")
+			}
+		}
+	}
+}
+
+// subscribe creates the redemption subscription for this WebSocket session
+func (s *ChannelPointsSource) subscribe(ctx context.Context, sessionID string) error {
+	body, _ := json.Marshal(map[string]interface{}{
+		"type":    redemptionEventType,
+		"version": "1",
+		"condition": map[string]string{
+			"broadcaster_user_id": s.broadcasterID,
+		},
+		"transport": map[string]string{
+			"method":     "websocket",
+			"session_id": sessionID,
+		},
+	})
+
+	req, err := http.NewRequestWithContext(ctx, "POST", s.apiURL+"/eventsub/subscriptions", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+s.token)
+	req.Header.Set("Client-Id", s.clientID)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("subscription request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		respBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("failed to subscribe to redemptions (status %d): %s", resp.StatusCode, string(respBody))
+	}
+	return nil
+}
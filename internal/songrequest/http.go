@@ -0,0 +1,122 @@
+// Package songrequest provides the sources viewers use to request songs
+package songrequest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/anastreamer/ana/internal/executor/music"
+	"github.com/anastreamer/ana/pkg/logger"
+	"github.com/rs/zerolog"
+)
+
+// HTTPSource accepts requests on a local HTTP endpoint, e.g. from a bot or
+// a Streamer.bot action:
+//
+//	POST /request {"user": "viewer", "query": "song"}
+//	GET  /request?user=viewer&query=song
+type HTTPSource struct {
+	addr string
+	log  zerolog.Logger
+}
+
+// NewHTTPSource creates an HTTP request source listening on addr
+func NewHTTPSource(addr string) *HTTPSource {
+	return &HTTPSource{
+		addr: addr,
+		log:  logger.Component("songrequest-http"),
+	}
+}
+
+// Name returns the source name
+func (s *HTTPSource) Name() string {
+	return "http"
+}
+
+// Start listens on the address and serves requests until ctx is done
+func (s *HTTPSource) Start(ctx context.Context, handle music.RequestHandler) error {
+	listener, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", s.addr, err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/request", func(w http.ResponseWriter, r *http.Request) {
+		s.serveRequest(w, r, handle)
+	})
+
+	server := &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+		server.Close()
+	}()
+
+	go func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			s.log.Error().Err(err).Msg("Song request server stopped")
+		}
+	}()
+
+	s.log.Info().Str("addr", listener.Addr().String()).Msg("Listening for song requests")
+	return nil
+}
+
+// serveRequest handles one /request call
+func (s *HTTPSource) serveRequest(w http.ResponseWriter, r *http.Request, handle music.RequestHandler) {
+	var body struct {
+		User  string `json:"user"`
+		Query string `json:"query"`
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		body.User = r.URL.Query().Get("user")
+		body.Query = r.URL.Query().Get("query")
+	case http.MethodPost:
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, "invalid JSON body", http.StatusBadRequest)
+			return
+		}
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if strings.TrimSpace(body.Query) == "" {
+		http.Error(w, "query is required", http.StatusBadRequest)
+		return
+	}
+	if body.User == "" {
+		body.User = "anónimo"
+	}
+
+	req := music.Request{User: body.User, Query: body.Query, Source: s.Name()}
+	track, position, err := handle(r.Context(), req)
+
+	resp := map[string]interface{}{
+		"ok":      err == nil,
+		"message": music.FormatRequestReply(req, track, position, err),
+	}
+	status := http.StatusOK
+	if err != nil {
+		resp["error"] = err.Error()
+		status = http.StatusUnprocessableEntity
+	} else {
+		resp["track"] = track
+		resp["position"] = position
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resp)
+}
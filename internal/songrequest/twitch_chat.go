@@ -0,0 +1,208 @@
+package songrequest
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/anastreamer/ana/internal/config"
+	"github.com/anastreamer/ana/internal/executor/music"
+	"github.com/anastreamer/ana/pkg/logger"
+	"github.com/rs/zerolog"
+)
+
+// TwitchChatSource reads song request commands (e.g. "!sr bohemian rhapsody")
+// from Twitch chat over IRC and answers in chat
+type TwitchChatSource struct {
+	server   string
+	channel  string
+	username string
+	token    string
+	command  string
+	log      zerolog.Logger
+
+	// onChatter is told who writes in chat, not only song requests
+	onChatter func(user string)
+}
+
+// NewTwitchChatSource creates a chat command source
+func NewTwitchChatSource(cfg config.SongRequestChatConfig) *TwitchChatSource {
+	return &TwitchChatSource{
+		server:   cfg.Server,
+		channel:  strings.ToLower(strings.TrimPrefix(cfg.Channel, "#")),
+		username: strings.ToLower(cfg.Username),
+		token:    strings.TrimPrefix(cfg.Token, "oauth:"),
+		command:  strings.ToLower(cfg.Command),
+		log:      logger.Component("songrequest-chat"),
+	}
+}
+
+// Name returns the source name
+func (s *TwitchChatSource) Name() string {
+	return "chat"
+}
+
+// SetChatterCallback sets a function called with the name of every chat user
+// who writes a message (e.g. to teach the names to STT)
+func (s *TwitchChatSource) SetChatterCallback(fn func(user string)) {
+	s.onChatter = fn
+}
+
+// Start connects to chat and keeps reconnecting until ctx is done
+func (s *TwitchChatSource) Start(ctx context.Context, handle music.RequestHandler) error {
+	if s.token == "" {
+		return fmt.Errorf("Twitch chat token is required")
+	}
+
+	go func() {
+		backoff := time.Second
+		for {
+			start := time.Now()
+			err := s.run(ctx, handle)
+			if ctx.Err() != nil {
+				return
+			}
+
+			if time.Since(start) > time.Minute {
+				backoff = time.Second
+			}
+			s.log.Warn().Err(err).Dur("retry_in", backoff).Msg("Twitch chat disconnected")
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(backoff):
+			}
+			backoff = min(backoff*2, 2*time.Minute)
+		}
+	}()
+
+	return nil
+}
+
+// run handles one chat connection
+func (s *TwitchChatSource) run(ctx context.Context, handle music.RequestHandler) error {
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	conn, err := tls.DialWithDialer(dialer, "tcp", s.server, nil)
+	if err != nil {
+		return fmt.Errorf("failed to connect to chat: %w", err)
+	}
+	defer conn.Close()
+
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+
+	send := func(line string) error {
+		conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+		_, err := fmt.Fprintf(conn, "%s\r\n", line)
+		return err
+	}
+
+	for _, line := range []string{
+		"PASS oauth:" + s.token,
+		"NICK " + s.username,
+		"CAP REQ :twitch.tv/tags",
+		"JOIN #" + s.channel,
+	} {
+		if err := send(line); err != nil {
+			return err
+		}
+	}
+	s.log.Info().Str("channel", s.channel).Msg("Joined Twitch chat for song requests")
+
+	reader := bufio.NewReader(conn)
+	for {
+		conn.SetReadDeadline(time.Now().Add(6 * time.Minute)) // Twitch pings every ~5 minutes
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return err
+		}
+		line = strings.TrimRight(line, "\r\n")
+
+		msg := parseIRC(line)
+		switch msg.command {
+		case "PING":
+			if err := send("PONG :" + msg.trailing); err != nil {
+				return err
+			}
+		case "NOTICE":
+			if strings.Contains(msg.trailing, "authentication failed") {
+				return fmt.Errorf("chat authentication failed")
+			}
+		case "PRIVMSG":
+			user := msg.tags["display-name"]
+			if user == "" {
+				user = msg.nick
+			}
+			if s.onChatter != nil {
+				s.onChatter(user)
+			}
+
+			query, ok := s.parseCommand(msg.trailing)
+			if !ok {
+				continue
+			}
+
+			req := music.Request{User: user, Query: query, Source: s.Name()}
+			track, position, err := handle(ctx, req)
+			if err != nil {
+				s.log.Debug().Err(err).Str("user", user).Str("query", query).Msg("Song request rejected")
+			}
+
+			reply := music.FormatRequestReply(req, track, position, err)
+			if err := send(fmt.Sprintf("PRIVMSG #%s :%s", s.channel, reply)); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// parseCommand returns the query if the message is a song request command
+func (s *TwitchChatSource) parseCommand(text string) (string, bool) {
+	text = strings.TrimSpace(text)
+	cmd, query, _ := strings.Cut(text, " ")
+	if strings.ToLower(cmd) != s.command {
+		return "", false
+	}
+	query = strings.TrimSpace(query)
+	return query, query != ""
+}
+
+// ircMessage is a parsed IRC line
+type ircMessage struct {
+	tags     map[string]string
+	nick     string
+	command  string
+	trailing string
+}
+
+// parseIRC parses "@tags :nick!user@host COMMAND params :trailing"
+func parseIRC(line string) ircMessage {
+	msg := ircMessage{tags: map[string]string{}}
+
+	if strings.HasPrefix(line, "@") {
+		var tags string
+		tags, line, _ = strings.Cut(line[1:], " ")
+		for _, tag := range strings.Split(tags, ";") {
+			k, v, _ := strings.Cut(tag, "=")
+			msg.tags[k] = v
+		}
+	}
+
+	if strings.HasPrefix(line, ":") {
+		var prefix string
+		prefix, line, _ = strings.Cut(line[1:], " ")
+		msg.nick, _, _ = strings.Cut(prefix, "!")
+	}
+
+	var params string
+	params, msg.trailing, _ = strings.Cut(line, " :")
+	msg.command, _, _ = strings.Cut(params, " ")
+	return msg
+}
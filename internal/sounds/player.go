@@ -0,0 +1,85 @@
+// Package sounds plays the short system cues configured in SoundsConfig
+package sounds
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"time"
+
+	"github.com/anastreamer/ana/internal/config"
+	"github.com/anastreamer/ana/pkg/logger"
+	"github.com/anastreamer/ana/pkg/utils"
+	"github.com/rs/zerolog"
+)
+
+// maxCueDuration bounds how long a cue may play
+const maxCueDuration = 5 * time.Second
+
+// Player plays WAV cues in the background with the system audio player
+type Player struct {
+	cfg config.SoundsConfig
+	log zerolog.Logger
+}
+
+// NewPlayer creates a cue player
+func NewPlayer(cfg config.SoundsConfig) *Player {
+	return &Player{
+		cfg: cfg,
+		log: logger.Component("sounds"),
+	}
+}
+
+// FollowUpStart plays the cue for a follow-up window opening
+func (p *Player) FollowUpStart() {
+	p.Play(p.cfg.FollowUpStart)
+}
+
+// FollowUpEnd plays the cue for a follow-up window closing
+func (p *Player) FollowUpEnd() {
+	p.Play(p.cfg.FollowUpEnd)
+}
+
+// Play plays a WAV file without blocking. Cues are optional: when sounds are
+// disabled, the file doesn't exist or there's no player, nothing happens.
+func (p *Player) Play(path string) {
+	if !p.cfg.Enabled || path == "" {
+		return
+	}
+	if !utils.FileExists(path) {
+		p.log.Debug().Str("file", path).Msg("Sound file not found, skipping")
+		return
+	}
+
+	cmd, err := playCommand(path)
+	if err != nil {
+		p.log.Debug().Err(err).Msg("Cannot play sound")
+		return
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), maxCueDuration)
+		defer cancel()
+
+		if err := exec.CommandContext(ctx, cmd[0], cmd[1:]...).Run(); err != nil {
+			p.log.Debug().Err(err).Str("file", path).Msg("Failed to play sound")
+		}
+	}()
+}
+
+// playCommand returns the command line of the first available WAV player
+func playCommand(path string) ([]string, error) {
+	players := [][]string{
+		{"aplay", "-q", path}, // Linux ALSA
+		{"paplay", path},      // Linux PulseAudio
+		{"afplay", path},      // macOS
+		{"powershell", "-c", fmt.Sprintf(`(New-Object Media.SoundPlayer '%s').PlaySync()`, path)}, // Windows
+	}
+
+	for _, player := range players {
+		if _, err := exec.LookPath(player[0]); err == nil {
+			return player, nil
+		}
+	}
+	return nil, fmt.Errorf("no audio player found")
+}
@@ -0,0 +1,310 @@
+package stt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/anastreamer/ana/internal/config"
+	"github.com/anastreamer/ana/pkg/logger"
+	"github.com/rs/zerolog"
+)
+
+// defaultBackendTimeout bounds a provider with no timeout configured
+const defaultBackendTimeout = 15 * time.Second
+
+// AutoProvider tries a chain of STT providers in order. Each one gets its
+// own timeout, and one that fails too many times in a row is skipped for a
+// cool-down. Optionally, when the first provider is slow the next one is
+// started too and the first answer wins.
+type AutoProvider struct {
+	backends  []*backend
+	threshold int
+	cooldown  time.Duration
+	raceAfter time.Duration
+	log       zerolog.Logger
+}
+
+// backend is a provider of the chain with its circuit breaker
+type backend struct {
+	provider Provider
+	timeout  time.Duration
+
+	mu        sync.Mutex
+	failures  int       // Failures in a row
+	openUntil time.Time // Skipped until then
+}
+
+// attempt is the outcome of one backend's transcription
+type attempt struct {
+	backend *backend
+	result  *TranscriptionResult
+	err     error
+}
+
+// NewAutoProvider creates the providers of cfg.STT.Auto.Order. Those that
+// cannot be created are left out of the chain.
+func NewAutoProvider(cfg *config.Config) (*AutoProvider, error) {
+	auto := cfg.STT.Auto
+	a := &AutoProvider{
+		threshold: auto.FailureThreshold,
+		cooldown:  time.Duration(auto.CooldownSeconds) * time.Second,
+		raceAfter: time.Duration(auto.RaceAfterMs) * time.Millisecond,
+		log:       logger.Component("stt-auto"),
+	}
+
+	var errs []string
+	for _, name := range auto.Order {
+		p, err := newBackend(name, cfg)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", name, err))
+			continue
+		}
+
+		timeout := time.Duration(auto.TimeoutsMs[name]) * time.Millisecond
+		if timeout <= 0 {
+			timeout = defaultBackendTimeout
+		}
+		a.backends = append(a.backends, &backend{provider: p, timeout: timeout})
+	}
+
+	if len(a.backends) == 0 {
+		msg := "auto STT provider requires at least one valid backend"
+		if len(errs) > 0 {
+			msg = fmt.Sprintf("%s (%s)", msg, strings.Join(errs, "; "))
+		}
+		return nil, errors.New(msg)
+	}
+	for _, e := range errs {
+		a.log.Warn().Str("error", e).Msg("STT provider left out of the chain")
+	}
+
+	return a, nil
+}
+
+// Name returns the provider name
+func (a *AutoProvider) Name() string {
+	names := make([]string, len(a.backends))
+	for i, b := range a.backends {
+		names[i] = b.provider.Name()
+	}
+	return fmt.Sprintf("auto(%s)", strings.Join(names, "+"))
+}
+
+// Transcribe converts audio bytes to text with the first provider that
+// manages to
+func (a *AutoProvider) Transcribe(ctx context.Context, audio []byte) (*TranscriptionResult, error) {
+	return a.run(ctx, func(ctx context.Context, p Provider) (*TranscriptionResult, error) {
+		return p.Transcribe(ctx, audio)
+	})
+}
+
+// TranscribeFile transcribes an audio file with the first provider that
+// manages to
+func (a *AutoProvider) TranscribeFile(ctx context.Context, filePath string) (*TranscriptionResult, error) {
+	return a.run(ctx, func(ctx context.Context, p Provider) (*TranscriptionResult, error) {
+		return p.TranscribeFile(ctx, filePath)
+	})
+}
+
+// run calls transcribe on the chain: the next provider starts when one
+// fails or, if racing, when the running one takes longer than raceAfter.
+// The first result wins and cancels the rest.
+func (a *AutoProvider) run(ctx context.Context, transcribe func(context.Context, Provider) (*TranscriptionResult, error)) (*TranscriptionResult, error) {
+	parent := ctx
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	queue := a.queue()
+	attempts := make(chan attempt, len(queue))
+	running := 0
+	start := func() bool {
+		if len(queue) == 0 {
+			return false
+		}
+		b := queue[0]
+		queue = queue[1:]
+		running++
+
+		go func() {
+			ctx, cancel := context.WithTimeout(ctx, b.timeout)
+			defer cancel()
+			result, err := transcribe(ctx, b.provider)
+			if err == nil && result == nil {
+				err = errors.New("no result")
+			}
+			attempts <- attempt{backend: b, result: result, err: err}
+		}()
+		return true
+	}
+
+	var race <-chan time.Time
+	if a.raceAfter > 0 {
+		timer := time.NewTimer(a.raceAfter)
+		defer timer.Stop()
+		race = timer.C
+	}
+
+	var errs []string
+	start()
+	for running > 0 {
+		select {
+		case <-race:
+			race = nil
+			if start() {
+				a.log.Debug().Dur("after", a.raceAfter).Msg("STT provider slow, racing the next one")
+			}
+
+		case at := <-attempts:
+			running--
+			name := at.backend.provider.Name()
+			if at.err == nil {
+				at.backend.succeed()
+				a.log.Debug().Str("provider", name).Msg("Transcribed")
+				return at.result, nil
+			}
+
+			// Giving up because the caller did isn't the provider's fault
+			if parent.Err() != nil {
+				return nil, parent.Err()
+			}
+
+			if at.backend.fail(a.threshold, a.cooldown) {
+				a.log.Warn().Err(at.err).Str("provider", name).Dur("cooldown", a.cooldown).Msg("STT provider keeps failing, pausing it")
+			} else {
+				a.log.Warn().Err(at.err).Str("provider", name).Msg("STT provider failed")
+			}
+			errs = append(errs, fmt.Sprintf("%s: %v", name, at.err))
+
+			// The chain goes on one provider at a time; racing was only
+			// for the first one being slow
+			if running == 0 {
+				start()
+				race = nil
+			}
+		}
+	}
+
+	return nil, fmt.Errorf("all STT providers failed: %s", strings.Join(errs, "; "))
+}
+
+// queue returns the backends to try, in order, skipping the paused ones.
+// If all of them are paused, the one that resumes first is tried anyway so
+// an utterance is never dropped unheard.
+func (a *AutoProvider) queue() []*backend {
+	var queue []*backend
+	now := time.Now()
+	for _, b := range a.backends {
+		if !b.paused(now) {
+			queue = append(queue, b)
+		}
+	}
+	if len(queue) > 0 {
+		return queue
+	}
+
+	first := a.backends[0]
+	for _, b := range a.backends[1:] {
+		if b.resumesAt().Before(first.resumesAt()) {
+			first = b
+		}
+	}
+	return []*backend{first}
+}
+
+// SetLanguage sets the language of every provider
+func (a *AutoProvider) SetLanguage(lang string) {
+	for _, b := range a.backends {
+		b.provider.SetLanguage(lang)
+	}
+}
+
+// SetPrompt sets the biasing prompt of every provider
+func (a *AutoProvider) SetPrompt(prompt string) {
+	for _, b := range a.backends {
+		b.provider.SetPrompt(prompt)
+	}
+}
+
+// IsAvailable checks if any provider is available
+func (a *AutoProvider) IsAvailable(ctx context.Context) bool {
+	for _, b := range a.backends {
+		if b.provider.IsAvailable(ctx) {
+			return true
+		}
+	}
+	return false
+}
+
+// GetStatus describes every provider of the chain, in Spanish
+func (a *AutoProvider) GetStatus() string {
+	var parts []string
+	now := time.Now()
+	for _, b := range a.backends {
+		name := b.provider.Name()
+		switch sp, ok := b.provider.(StatusProvider); {
+		case b.paused(now):
+			wait := time.Until(b.resumesAt()).Round(time.Second)
+			parts = append(parts, fmt.Sprintf("%s: en pausa %s tras varios fallos", name, wait))
+		case ok:
+			parts = append(parts, sp.GetStatus())
+		case b.provider.IsAvailable(context.Background()):
+			parts = append(parts, name+": activo")
+		default:
+			parts = append(parts, name+": no disponible")
+		}
+	}
+	return "STT auto: " + strings.Join(parts, ", ")
+}
+
+// Close releases every provider
+func (a *AutoProvider) Close() error {
+	var errs []string
+	for _, b := range a.backends {
+		if err := b.provider.Close(); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("errors closing STT providers: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// paused tells whether the breaker is open at now. Once the cool-down
+// passes the backend gets one more try; failing it pauses it again.
+func (b *backend) paused(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return now.Before(b.openUntil)
+}
+
+// resumesAt is when the breaker closes again
+func (b *backend) resumesAt() time.Time {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.openUntil
+}
+
+// succeed closes the breaker
+func (b *backend) succeed() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures = 0
+	b.openUntil = time.Time{}
+}
+
+// fail records a failure and tells whether it paused the backend
+func (b *backend) fail(threshold int, cooldown time.Duration) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures++
+	if b.failures < threshold {
+		return false
+	}
+	b.openUntil = time.Now().Add(cooldown)
+	return true
+}
@@ -0,0 +1,208 @@
+package stt
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/anastreamer/ana/pkg/logger"
+)
+
+// fakeProvider answers with its name after delay, or fails with err
+type fakeProvider struct {
+	name  string
+	delay time.Duration
+	err   error
+
+	mu    sync.Mutex
+	calls int
+}
+
+func (p *fakeProvider) Name() string                     { return p.name }
+func (p *fakeProvider) SetLanguage(lang string)          {}
+func (p *fakeProvider) SetPrompt(prompt string)          {}
+func (p *fakeProvider) IsAvailable(context.Context) bool { return true }
+func (p *fakeProvider) Close() error                     { return nil }
+
+func (p *fakeProvider) Transcribe(ctx context.Context, audio []byte) (*TranscriptionResult, error) {
+	p.mu.Lock()
+	p.calls++
+	p.mu.Unlock()
+
+	select {
+	case <-time.After(p.delay):
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	if p.err != nil {
+		return nil, p.err
+	}
+	return &TranscriptionResult{Text: p.name, Confidence: 1}, nil
+}
+
+func (p *fakeProvider) TranscribeFile(ctx context.Context, path string) (*TranscriptionResult, error) {
+	return p.Transcribe(ctx, nil)
+}
+
+func (p *fakeProvider) callCount() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.calls
+}
+
+// newTestAuto chains providers, each with a timeout of 500 ms
+func newTestAuto(threshold int, cooldown, raceAfter time.Duration, providers ...*fakeProvider) *AutoProvider {
+	a := &AutoProvider{
+		threshold: threshold,
+		cooldown:  cooldown,
+		raceAfter: raceAfter,
+		log:       logger.Component("stt-auto"),
+	}
+	for _, p := range providers {
+		a.backends = append(a.backends, &backend{provider: p, timeout: 500 * time.Millisecond})
+	}
+	return a
+}
+
+func TestAutoProviderChain(t *testing.T) {
+	failed := errors.New("failed")
+	ms := time.Millisecond
+
+	tests := []struct {
+		name      string
+		providers []*fakeProvider
+		raceAfter time.Duration
+		want      string // Winner, "" for an error
+		calls     []int
+	}{
+		{
+			name:      "first answers",
+			providers: []*fakeProvider{{name: "a"}, {name: "b"}},
+			want:      "a",
+			calls:     []int{1, 0},
+		},
+		{
+			name:      "failure goes to the next",
+			providers: []*fakeProvider{{name: "a", err: failed}, {name: "b"}},
+			want:      "b",
+			calls:     []int{1, 1},
+		},
+		{
+			name:      "timeout goes to the next",
+			providers: []*fakeProvider{{name: "a", delay: time.Second}, {name: "b"}},
+			want:      "b",
+			calls:     []int{1, 1},
+		},
+		{
+			name:      "all fail",
+			providers: []*fakeProvider{{name: "a", err: failed}, {name: "b", err: failed}},
+			calls:     []int{1, 1},
+		},
+		{
+			name:      "slow first races the next",
+			providers: []*fakeProvider{{name: "a", delay: 300 * ms}, {name: "b", delay: 10 * ms}, {name: "c"}},
+			raceAfter: 20 * ms,
+			want:      "b",
+			calls:     []int{1, 1, 0},
+		},
+		{
+			name:      "slow first still wins",
+			providers: []*fakeProvider{{name: "a", delay: 40 * ms}, {name: "b", delay: 300 * ms}},
+			raceAfter: 20 * ms,
+			want:      "a",
+			calls:     []int{1, 1},
+		},
+		{
+			name:      "no race after a quick failure",
+			providers: []*fakeProvider{{name: "a", err: failed}, {name: "b", delay: 100 * ms}, {name: "c"}},
+			raceAfter: 30 * ms,
+			want:      "b",
+			calls:     []int{1, 1, 0},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newTestAuto(3, time.Minute, tt.raceAfter, tt.providers...)
+			result, err := a.Transcribe(context.Background(), nil)
+
+			if tt.want == "" {
+				if err == nil || !strings.Contains(err.Error(), "a: failed") || !strings.Contains(err.Error(), "b: failed") {
+					t.Errorf("Transcribe() = %v, %v, want every failure", result, err)
+				}
+			} else if err != nil || result.Text != tt.want {
+				t.Errorf("Transcribe() = %v, %v, want %s", result, err, tt.want)
+			}
+			for i, p := range tt.providers {
+				if got := p.callCount(); got != tt.calls[i] {
+					t.Errorf("%s called %d times, want %d", p.name, got, tt.calls[i])
+				}
+			}
+		})
+	}
+}
+
+func TestAutoProviderCircuitBreaker(t *testing.T) {
+	a1 := &fakeProvider{name: "a", err: errors.New("down")}
+	b := &fakeProvider{name: "b"}
+	a := newTestAuto(2, time.Minute, 0, a1, b)
+
+	// Two failures in a row pause the first provider
+	for i := 0; i < 3; i++ {
+		if result, err := a.Transcribe(context.Background(), nil); err != nil || result.Text != "b" {
+			t.Fatalf("Transcribe() = %v, %v", result, err)
+		}
+	}
+	if a1.callCount() != 2 || b.callCount() != 3 {
+		t.Errorf("called a %d and b %d times, want 2 and 3", a1.callCount(), b.callCount())
+	}
+	if !strings.Contains(a.GetStatus(), "a: en pausa") {
+		t.Errorf("status %q doesn't show the pause", a.GetStatus())
+	}
+
+	// Once the cool-down passes it gets another try, and a success resets it
+	a.backends[0].openUntil = time.Now().Add(-time.Second)
+	a1.mu.Lock()
+	a1.err = nil
+	a1.mu.Unlock()
+	if result, _ := a.Transcribe(context.Background(), nil); result == nil || result.Text != "a" {
+		t.Fatalf("Transcribe() = %v after the cool-down, want a", result)
+	}
+	if a.backends[0].failures != 0 {
+		t.Errorf("%d failures left after a success", a.backends[0].failures)
+	}
+}
+
+func TestAutoProviderAllPaused(t *testing.T) {
+	a1 := &fakeProvider{name: "a"}
+	b := &fakeProvider{name: "b"}
+	a := newTestAuto(1, time.Minute, 0, a1, b)
+
+	// Both paused: the one that resumes first is tried anyway
+	a.backends[0].openUntil = time.Now().Add(time.Minute)
+	a.backends[1].openUntil = time.Now().Add(time.Second)
+	if result, err := a.Transcribe(context.Background(), nil); err != nil || result.Text != "b" {
+		t.Fatalf("Transcribe() = %v, %v, want b", result, err)
+	}
+	if a1.callCount() != 0 {
+		t.Errorf("a called %d times while paused longer", a1.callCount())
+	}
+}
+
+func TestAutoProviderCanceled(t *testing.T) {
+	a1 := &fakeProvider{name: "a", delay: time.Second}
+	a := newTestAuto(1, time.Minute, 0, a1, &fakeProvider{name: "b"})
+
+	// Giving up doesn't count against the provider
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := a.Transcribe(ctx, nil); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Transcribe() error = %v, want the caller's deadline", err)
+	}
+	if a.backends[0].paused(time.Now()) {
+		t.Error("the provider was paused because the caller gave up")
+	}
+}
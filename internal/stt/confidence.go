@@ -0,0 +1,119 @@
+package stt
+
+import (
+	"math"
+	"strings"
+	"unicode"
+)
+
+// knownHallucinations are phrases Whisper tends to make up from silence or
+// noise, learned from video subtitles. A segment that is only one of these
+// is never a command.
+var knownHallucinations = []string{
+	"gracias por ver el video",
+	"gracias por ver el vídeo",
+	"gracias por ver",
+	"gracias por vernos",
+	"gracias por su atención",
+	"suscríbete",
+	"suscríbete al canal",
+	"no olvides suscribirte",
+	"subtítulos realizados por la comunidad de amara.org",
+	"subtítulos por la comunidad de amara.org",
+	"subtitulado por la comunidad de amara.org",
+	"amara.org",
+	"thanks for watching",
+	"thank you for watching",
+	"[música]",
+	"(música)",
+}
+
+// scoreSegments sets the result's Confidence from the average
+// log-probability of its segments, weighted by their length. Without
+// segments it's left alone.
+func scoreSegments(res *TranscriptionResult) {
+	if len(res.Segments) == 0 {
+		return
+	}
+
+	var sum, weights float64
+	for _, s := range res.Segments {
+		weight := (s.End - s.Start).Seconds()
+		if weight <= 0 {
+			weight = 1
+		}
+		sum += s.AvgLogProb * weight
+		weights += weight
+	}
+	res.Confidence = math.Exp(sum / weights)
+}
+
+// tokenLogProb is the mean log-probability of the text tokens of a segment,
+// skipping whisper.cpp's special tokens like [_BEG_]
+func tokenLogProb(tokens []Token) float64 {
+	var sum float64
+	var n int
+	for _, t := range tokens {
+		if strings.HasPrefix(t.Text, "[_") || t.Probability <= 0 {
+			continue
+		}
+		sum += math.Log(t.Probability)
+		n++
+	}
+	if n == 0 {
+		return 0
+	}
+	return sum / float64(n)
+}
+
+// DropHallucinations removes the segments Whisper most likely made up: the
+// ones it found more likely than noSpeechThreshold to be silence while being
+// unsure of them (average log-probability below logProbThreshold), and known
+// subtitle phrases. Text and Confidence are updated from what's left; it
+// returns the dropped text.
+func DropHallucinations(res *TranscriptionResult, noSpeechThreshold, logProbThreshold float64) []string {
+	if len(res.Segments) == 0 {
+		if res.Text != "" && isKnownHallucination(res.Text) {
+			dropped := []string{res.Text}
+			res.Text = ""
+			return dropped
+		}
+		return nil
+	}
+
+	var dropped []string
+	var kept []Segment
+	var texts []string
+	for _, s := range res.Segments {
+		silent := s.NoSpeechProb > noSpeechThreshold && s.AvgLogProb < logProbThreshold
+		if silent || isKnownHallucination(s.Text) {
+			dropped = append(dropped, s.Text)
+			continue
+		}
+		kept = append(kept, s)
+		if text := strings.TrimSpace(s.Text); text != "" {
+			texts = append(texts, text)
+		}
+	}
+	if len(dropped) == 0 {
+		return nil
+	}
+
+	res.Segments = kept
+	res.Text = strings.Join(texts, " ")
+	scoreSegments(res)
+	return dropped
+}
+
+// isKnownHallucination tells whether text is just one of knownHallucinations
+func isKnownHallucination(text string) bool {
+	text = strings.ToLower(strings.TrimFunc(text, func(r rune) bool {
+		return unicode.IsSpace(r) || (unicode.IsPunct(r) && r != '[' && r != ']' && r != '(' && r != ')')
+	}))
+	for _, phrase := range knownHallucinations {
+		if text == phrase {
+			return true
+		}
+	}
+	return false
+}
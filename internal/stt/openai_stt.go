@@ -9,6 +9,7 @@ import (
 	"mime/multipart"
 	"net/http"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/anastreamer/ana/internal/config"
@@ -21,18 +22,29 @@ const openAISTTURL = "https://api.openai.com/v1/audio/transcriptions"
 
 // OpenAISTTProvider implements STT using OpenAI's Whisper API
 type OpenAISTTProvider struct {
-	apiKey   string
-	model    string
-	language string
-	client   *http.Client
-	log      zerolog.Logger
+	promptHolder
+	languageHolder
+	apiKey string
+	model  string
+	client *http.Client
+	log    zerolog.Logger
 }
 
 // OpenAITranscriptionResponse represents the API response
 type OpenAITranscriptionResponse struct {
-	Text     string  `json:"text"`
-	Language string  `json:"language,omitempty"`
-	Duration float64 `json:"duration,omitempty"`
+	Text     string                  `json:"text"`
+	Language string                  `json:"language,omitempty"`
+	Duration float64                 `json:"duration,omitempty"`
+	Segments []OpenAISegmentResponse `json:"segments,omitempty"` // verbose_json only
+}
+
+// OpenAISegmentResponse is a segment of a verbose_json response
+type OpenAISegmentResponse struct {
+	Start        float64 `json:"start"`
+	End          float64 `json:"end"`
+	Text         string  `json:"text"`
+	AvgLogProb   float64 `json:"avg_logprob"`
+	NoSpeechProb float64 `json:"no_speech_prob"`
 }
 
 // NewOpenAIProvider creates a new OpenAI STT provider
@@ -46,15 +58,16 @@ func NewOpenAIProvider(cfg config.OpenAISTTConfig) (*OpenAISTTProvider, error) {
 		model = "whisper-1"
 	}
 
-	return &OpenAISTTProvider{
-		apiKey:   cfg.APIKey,
-		model:    model,
-		language: "es", // Default language
+	p := &OpenAISTTProvider{
+		apiKey: cfg.APIKey,
+		model:  model,
 		client: &http.Client{
 			Timeout: 60 * time.Second,
 		},
 		log: logger.Component("openai-stt"),
-	}, nil
+	}
+	p.SetLanguage("es") // Default language
+	return p, nil
 }
 
 // Name returns the provider name
@@ -101,10 +114,11 @@ func (p *OpenAISTTProvider) Transcribe(ctx context.Context, audio []byte) (*Tran
 // TranscribeFile transcribes an audio file
 func (p *OpenAISTTProvider) TranscribeFile(ctx context.Context, filePath string) (*TranscriptionResult, error) {
 	start := time.Now()
+	language := p.language()
 
 	p.log.Debug().
 		Str("file", filePath).
-		Str("language", p.language).
+		Str("language", language).
 		Msg("Starting OpenAI transcription")
 
 	// Open audio file
@@ -132,15 +146,27 @@ func (p *OpenAISTTProvider) TranscribeFile(ctx context.Context, filePath string)
 		return nil, fmt.Errorf("failed to write model field: %w", err)
 	}
 
-	// Add language
-	if p.language != "" {
-		if err := writer.WriteField("language", p.language); err != nil {
+	// Add language, or let OpenAI detect it
+	if language != "" && language != "auto" {
+		if err := writer.WriteField("language", language); err != nil {
 			return nil, fmt.Errorf("failed to write language field: %w", err)
 		}
 	}
 
-	// Add response format
-	if err := writer.WriteField("response_format", "json"); err != nil {
+	// Add the biasing prompt
+	if prompt := p.prompt(); prompt != "" {
+		if err := writer.WriteField("prompt", prompt); err != nil {
+			return nil, fmt.Errorf("failed to write prompt field: %w", err)
+		}
+	}
+
+	// Add response format. Only whisper-1 has verbose_json, with the
+	// segment scores; the newer models answer json.
+	format := "json"
+	if p.model == "whisper-1" {
+		format = "verbose_json"
+	}
+	if err := writer.WriteField("response_format", format); err != nil {
 		return nil, fmt.Errorf("failed to write response_format field: %w", err)
 	}
 
@@ -179,22 +205,54 @@ func (p *OpenAISTTProvider) TranscribeFile(ctx context.Context, filePath string)
 		return nil, fmt.Errorf("failed to parse response: %w", err)
 	}
 
+	result := &TranscriptionResult{
+		Text:       transcriptionResp.Text,
+		Language:   languageCode(transcriptionResp.Language),
+		Confidence: 1.0, // Without segments there's no score
+		Duration:   time.Since(start).Seconds(),
+	}
+	for _, s := range transcriptionResp.Segments {
+		result.Segments = append(result.Segments, Segment{
+			Start:        time.Duration(s.Start * float64(time.Second)),
+			End:          time.Duration(s.End * float64(time.Second)),
+			Text:         strings.TrimSpace(s.Text),
+			AvgLogProb:   s.AvgLogProb,
+			NoSpeechProb: s.NoSpeechProb,
+		})
+	}
+	scoreSegments(result)
+
 	p.log.Debug().
-		Str("text", transcriptionResp.Text).
+		Str("text", result.Text).
+		Float64("confidence", result.Confidence).
 		Dur("duration", time.Since(start)).
 		Msg("Transcription complete")
 
-	return &TranscriptionResult{
-		Text:       transcriptionResp.Text,
-		Language:   transcriptionResp.Language,
-		Confidence: 1.0, // OpenAI doesn't provide confidence
-		Duration:   time.Since(start).Seconds(),
-	}, nil
+	return result, nil
+}
+
+// openAILanguages maps the language names of verbose_json responses to
+// ISO 639-1 codes
+var openAILanguages = map[string]string{
+	"spanish":    "es",
+	"english":    "en",
+	"portuguese": "pt",
+	"french":     "fr",
+	"italian":    "it",
+	"german":     "de",
+	"catalan":    "ca",
+	"galician":   "gl",
+	"basque":     "eu",
 }
 
-// SetLanguage sets the language for transcription
-func (p *OpenAISTTProvider) SetLanguage(lang string) {
-	p.language = lang
+// languageCode returns the ISO 639-1 code of a language OpenAI reports by
+// name ("spanish"), or the code itself if it already is one
+func languageCode(lang string) string {
+	lang = strings.ToLower(lang)
+	if code, ok := openAILanguages[lang]; ok {
+		return code
+	}
+	return lang
 }
 
 // IsAvailable checks if OpenAI STT is available
@@ -0,0 +1,201 @@
+package stt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/anastreamer/ana/pkg/logger"
+	"github.com/rs/zerolog"
+)
+
+// Hypothesis is a partial transcription of the audio streamed so far
+type Hypothesis struct {
+	TranscriptionResult
+	Bytes int // PCM bytes of the stream it covers
+}
+
+// StreamingProvider is implemented by providers that can transcribe audio
+// while it's still being recorded
+type StreamingProvider interface {
+	Provider
+
+	// StartStream begins transcribing one utterance of 16kHz mono 16-bit PCM.
+	// The stream ends when ctx is done.
+	StartStream(ctx context.Context) (Stream, error)
+}
+
+// Stream is one utterance being transcribed as it's recorded
+type Stream interface {
+	// Write appends PCM audio; it doesn't wait for transcription
+	Write(pcm []byte) error
+
+	// Partials delivers hypotheses of the audio so far. Only the latest
+	// matters: a slow reader misses older ones. It's closed by Finish/Close.
+	Partials() <-chan Hypothesis
+
+	// Finish ends the audio and returns the final transcription
+	Finish(ctx context.Context) (*TranscriptionResult, error)
+
+	// Close abandons the stream without a final transcription
+	Close()
+}
+
+// ErrStreamClosed is returned when writing to a finished or closed stream
+var ErrStreamClosed = errors.New("stream is closed")
+
+// pcmBytesPerSecond is the size of one second of 16kHz mono 16-bit PCM
+const pcmBytesPerSecond = 16000 * 2
+
+// maxWindowBytes stops partials of a windowed stream past 30 seconds of
+// audio: each one re-transcribes the whole recording, and a command that
+// long is better left to the final transcription
+const maxWindowBytes = 30 * pcmBytesPerSecond
+
+// windowedProvider streams with any provider by transcribing everything
+// recorded so far again whenever interval of new audio has arrived
+type windowedProvider struct {
+	Provider
+	interval time.Duration
+	log      zerolog.Logger
+}
+
+// NewStreamingProvider makes p stream by re-transcribing the growing
+// recording every interval of new audio, one request at a time. It suits
+// fast backends like a whisper.cpp server or the OpenAI API; whisper-cli is
+// refused, since every partial would reload the model.
+func NewStreamingProvider(p Provider, interval time.Duration) (StreamingProvider, error) {
+	if sp, ok := p.(StreamingProvider); ok {
+		return sp, nil
+	}
+	if _, ok := p.(*WhisperProvider); ok {
+		return nil, fmt.Errorf("%s reloads the model on every call, too slow for streaming", p.Name())
+	}
+	return &windowedProvider{
+		Provider: p,
+		interval: interval,
+		log:      logger.Component("stt-stream"),
+	}, nil
+}
+
+// StartStream implements StreamingProvider
+func (w *windowedProvider) StartStream(ctx context.Context) (Stream, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	return &windowedStream{
+		provider: w.Provider,
+		every:    int(w.interval.Seconds() * pcmBytesPerSecond),
+		log:      w.log,
+		ctx:      ctx,
+		cancel:   cancel,
+		partials: make(chan Hypothesis, 1),
+	}, nil
+}
+
+// windowedStream is a Stream of a windowedProvider
+type windowedStream struct {
+	provider Provider
+	every    int // Bytes of new audio between partials
+	log      zerolog.Logger
+
+	// ctx bounds the partial transcriptions
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	partials  chan Hypothesis
+	wg        sync.WaitGroup
+	closeOnce sync.Once
+
+	mu     sync.Mutex
+	audio  []byte
+	sentAt int  // Audio length of the last partial request
+	busy   bool // A partial transcription is running
+	closed bool
+}
+
+// Write implements Stream
+func (s *windowedStream) Write(pcm []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return ErrStreamClosed
+	}
+	s.audio = append(s.audio, pcm...)
+
+	if s.busy || len(s.audio)-s.sentAt < s.every || len(s.audio) > maxWindowBytes {
+		return nil
+	}
+	s.busy = true
+	s.sentAt = len(s.audio)
+	snapshot := append([]byte(nil), s.audio...)
+
+	s.wg.Add(1)
+	go s.transcribePartial(snapshot)
+	return nil
+}
+
+// transcribePartial transcribes the audio so far and publishes the result
+func (s *windowedStream) transcribePartial(audio []byte) {
+	defer s.wg.Done()
+
+	res, err := s.provider.Transcribe(s.ctx, audio)
+	if err != nil {
+		if s.ctx.Err() == nil {
+			s.log.Debug().Err(err).Msg("Partial transcription failed")
+		}
+	} else if s.ctx.Err() == nil {
+		s.publish(Hypothesis{TranscriptionResult: *res, Bytes: len(audio)})
+	}
+
+	s.mu.Lock()
+	s.busy = false
+	s.mu.Unlock()
+}
+
+// publish replaces any unread partial with h. Only one partial runs at a
+// time, so there's a single sender.
+func (s *windowedStream) publish(h Hypothesis) {
+	select {
+	case <-s.partials:
+	default:
+	}
+	s.partials <- h
+}
+
+// Partials implements Stream
+func (s *windowedStream) Partials() <-chan Hypothesis {
+	return s.partials
+}
+
+// Finish implements Stream
+func (s *windowedStream) Finish(ctx context.Context) (*TranscriptionResult, error) {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil, ErrStreamClosed
+	}
+	audio := s.audio
+	s.mu.Unlock()
+
+	// The final transcription covers everything; a partial still running
+	// would only compete with it
+	s.Close()
+	return s.provider.Transcribe(ctx, audio)
+}
+
+// Close implements Stream
+func (s *windowedStream) Close() {
+	s.mu.Lock()
+	s.closed = true
+	s.mu.Unlock()
+
+	s.cancel()
+	s.closeOnce.Do(func() {
+		go func() {
+			s.wg.Wait()
+			close(s.partials)
+		}()
+	})
+}
@@ -0,0 +1,32 @@
+package stt
+
+import (
+	"context"
+	"testing"
+)
+
+func TestNewStreamingProviderRefusesWhisperCLI(t *testing.T) {
+	if _, err := NewStreamingProvider(&WhisperProvider{}, 0); err == nil {
+		t.Error("whisper-cli was accepted for streaming")
+	}
+	if _, err := NewStreamingProvider(&fakeProvider{name: "a"}, 0); err != nil {
+		t.Errorf("NewStreamingProvider() error = %v", err)
+	}
+}
+
+func TestWindowedStreamStopsPartialsPastMaxWindow(t *testing.T) {
+	p := &fakeProvider{name: "a"}
+	sp, _ := NewStreamingProvider(p, 0)
+	stream, _ := sp.StartStream(context.Background())
+	defer stream.Close()
+
+	stream.Write(make([]byte, maxWindowBytes+1))
+	if p.callCount() != 0 {
+		t.Errorf("transcribed %d partials of a recording past the window", p.callCount())
+	}
+
+	result, err := stream.Finish(context.Background())
+	if err != nil || result.Text != "a" {
+		t.Errorf("Finish() = %v, %v", result, err)
+	}
+}
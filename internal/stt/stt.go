@@ -4,16 +4,39 @@ package stt
 import (
 	"context"
 	"fmt"
+	"sync/atomic"
+	"time"
 
 	"github.com/anastreamer/ana/internal/config"
 )
 
 // TranscriptionResult holds the result of a transcription
 type TranscriptionResult struct {
-	Text       string  // Transcribed text
-	Language   string  // Detected language
-	Confidence float64 // Confidence score (0-1)
-	Duration   float64 // Audio duration in seconds
+	Text       string    // Transcribed text
+	Language   string    // Detected language
+	Confidence float64   // Confidence score (0-1), 1 if the provider has no scores
+	Duration   float64   // Audio duration in seconds
+	Segments   []Segment // Timed segments, when the provider reports them
+}
+
+// Segment is a timed piece of a transcription
+type Segment struct {
+	Start  time.Duration
+	End    time.Duration
+	Text   string
+	Tokens []Token
+
+	// AvgLogProb is the mean log-probability of the segment's tokens, and
+	// NoSpeechProb how likely the model found it was silence or noise (zero
+	// if the provider doesn't report it)
+	AvgLogProb   float64
+	NoSpeechProb float64
+}
+
+// Token is a word or subword of a segment with the model's probability for it
+type Token struct {
+	Text        string
+	Probability float64
 }
 
 // Provider is the interface for STT providers
@@ -28,9 +51,14 @@ type Provider interface {
 	// TranscribeFile transcribes an audio file
 	TranscribeFile(ctx context.Context, filePath string) (*TranscriptionResult, error)
 
-	// SetLanguage sets the language for transcription
+	// SetLanguage sets the language for transcription, or "auto" to detect
+	// it in each one (reported in TranscriptionResult.Language)
 	SetLanguage(lang string)
 
+	// SetPrompt sets text that biases the transcription towards its words
+	// (names, scenes...). It may be called while transcriptions run.
+	SetPrompt(prompt string)
+
 	// IsAvailable checks if the provider is available
 	IsAvailable(ctx context.Context) bool
 
@@ -38,14 +66,67 @@ type Provider interface {
 	Close() error
 }
 
+// promptHolder keeps a provider's biasing prompt. Embedding it implements
+// SetPrompt.
+type promptHolder struct {
+	value atomic.Value // string
+}
+
+// SetPrompt implements Provider
+func (h *promptHolder) SetPrompt(prompt string) {
+	h.value.Store(prompt)
+}
+
+// prompt returns the current biasing prompt
+func (h *promptHolder) prompt() string {
+	prompt, _ := h.value.Load().(string)
+	return prompt
+}
+
+// languageHolder keeps a provider's transcription language, which the
+// pipeline changes while workers transcribe. Embedding it implements
+// SetLanguage.
+type languageHolder struct {
+	value atomic.Value // string
+}
+
+// SetLanguage implements Provider
+func (h *languageHolder) SetLanguage(lang string) {
+	h.value.Store(lang)
+}
+
+// language returns the current transcription language
+func (h *languageHolder) language() string {
+	lang, _ := h.value.Load().(string)
+	return lang
+}
+
+// StatusProvider is an optional interface for providers that report more
+// than whether they're available
+type StatusProvider interface {
+	// GetStatus returns detailed status information
+	GetStatus() string
+}
+
 // New creates a new STT provider based on configuration
 func New(cfg *config.Config) (Provider, error) {
-	switch cfg.STT.Provider {
+	if cfg.STT.Provider == "auto" {
+		return NewAutoProvider(cfg)
+	}
+	return newBackend(cfg.STT.Provider, cfg)
+}
+
+// newBackend creates the provider called name: whisper or openai
+func newBackend(name string, cfg *config.Config) (Provider, error) {
+	switch name {
 	case "whisper":
+		if cfg.STT.Whisper.Mode == "server" {
+			return NewWhisperServerProvider(cfg.STT.Whisper)
+		}
 		return NewWhisperProvider(cfg.STT.Whisper)
 	case "openai":
 		return NewOpenAIProvider(cfg.STT.OpenAI)
 	default:
-		return nil, fmt.Errorf("unknown STT provider: %s", cfg.STT.Provider)
+		return nil, fmt.Errorf("unknown STT provider: %s", name)
 	}
 }
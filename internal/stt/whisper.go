@@ -2,6 +2,7 @@ package stt
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -16,9 +17,10 @@ import (
 
 // WhisperProvider implements STT using local whisper.cpp
 type WhisperProvider struct {
+	promptHolder
+	languageHolder
 	binaryPath string
 	modelPath  string
-	language   string
 	log        zerolog.Logger
 }
 
@@ -32,12 +34,13 @@ func NewWhisperProvider(cfg config.WhisperConfig) (*WhisperProvider, error) {
 		return nil, fmt.Errorf("failed to resolve model path %s: %w", cfg.ModelPath, err)
 	}
 
-	return &WhisperProvider{
+	p := &WhisperProvider{
 		binaryPath: binaryPath,
 		modelPath:  modelPath,
-		language:   cfg.Language,
 		log:        logger.Component("whisper"),
-	}, nil
+	}
+	p.SetLanguage(cfg.Language)
+	return p, nil
 }
 
 // Name returns the provider name
@@ -84,6 +87,7 @@ func (p *WhisperProvider) Transcribe(ctx context.Context, audio []byte) (*Transc
 // TranscribeFile transcribes an audio file
 func (p *WhisperProvider) TranscribeFile(ctx context.Context, filePath string) (*TranscriptionResult, error) {
 	start := time.Now()
+	language := p.language()
 
 	// Get file size for debugging
 	fileInfo, _ := os.Stat(filePath)
@@ -96,7 +100,7 @@ func (p *WhisperProvider) TranscribeFile(ctx context.Context, filePath string) (
 		Str("file", filePath).
 		Int64("file_size", fileSize).
 		Str("model", p.modelPath).
-		Str("language", p.language).
+		Str("language", language).
 		Msg("Starting transcription")
 
 	// Build command arguments for whisper-cli
@@ -106,12 +110,15 @@ func (p *WhisperProvider) TranscribeFile(ctx context.Context, filePath string) (
 	}
 
 	args := []string{
-		"-m", p.modelPath,           // model path
-		"-l", p.language,            // language
-		"-otxt",                     // output as text file
-		"-of", outputFile,           // output file (without extension)
-		"-nt",                       // no timestamps
-		"-f", filePath,              // input file
+		"-m", p.modelPath, // model path
+		"-l", language, // language
+		"-ojf",            // output as JSON with token probabilities
+		"-of", outputFile, // output file (without extension)
+		"-nt",          // no timestamps
+		"-f", filePath, // input file
+	}
+	if prompt := p.prompt(); prompt != "" {
+		args = append(args, "--prompt", prompt)
 	}
 
 	// Run whisper
@@ -134,57 +141,90 @@ func (p *WhisperProvider) TranscribeFile(ctx context.Context, filePath string) (
 		return nil, fmt.Errorf("whisper returned non-zero exit code: %d, stderr: %s", result.ExitCode, result.Stderr)
 	}
 
-	// Parse output - whisper outputs to stdout
-	text := strings.TrimSpace(result.Stdout)
-	p.log.Debug().
-		Str("stdout_content", result.Stdout).
-		Str("trimmed_text", text).
-		Msg("Whisper stdout content")
+	transcription := &TranscriptionResult{
+		Language:   language,
+		Confidence: 1.0,
+		Duration:   result.Duration.Seconds(),
+	}
 
-	// Also check for output file specified via -of flag
-	outputPath := filePath
-	if len(filePath) > 4 && filePath[len(filePath)-4:] == ".wav" {
-		outputPath = filePath[:len(filePath)-4]
-	}
-	txtFile := outputPath + ".txt"
-	p.log.Debug().Str("expected_txt_file", txtFile).Msg("Looking for output file")
-
-	if _, err := os.Stat(txtFile); err == nil {
-		content, err := os.ReadFile(txtFile)
-		if err == nil {
-			fileText := strings.TrimSpace(string(content))
-			p.log.Debug().
-				Int("file_size", len(content)).
-				Str("file_content", fileText).
-				Msg("Read text from output file")
-			text = fileText
-		} else {
-			p.log.Error().Err(err).Str("file", txtFile).Msg("Failed to read output file")
+	// The JSON output file has the segments and token probabilities; whisper
+	// also prints the text to stdout, used if the file is missing
+	jsonFile := outputFile + ".json"
+	p.log.Debug().Str("expected_json_file", jsonFile).Msg("Looking for output file")
+
+	if content, err := os.ReadFile(jsonFile); err == nil {
+		os.Remove(jsonFile) // Clean up
+		if err := parseWhisperJSON(content, transcription); err != nil {
+			p.log.Error().Err(err).Str("file", jsonFile).Msg("Failed to parse output file")
+			transcription.Text = cleanTranscription(result.Stdout)
 		}
-		os.Remove(txtFile) // Clean up
 	} else {
-		p.log.Debug().Err(err).Str("file", txtFile).Msg("Output file not found")
+		p.log.Debug().Err(err).Str("file", jsonFile).Msg("Output file not found")
+		transcription.Text = cleanTranscription(result.Stdout)
 	}
 
-	// Clean up the text
-	text = cleanTranscription(text)
-
 	p.log.Debug().
-		Str("text", text).
+		Str("text", transcription.Text).
+		Float64("confidence", transcription.Confidence).
 		Dur("duration", time.Since(start)).
 		Msg("Transcription complete")
 
-	return &TranscriptionResult{
-		Text:       text,
-		Language:   p.language,
-		Confidence: 1.0, // Whisper doesn't provide confidence
-		Duration:   result.Duration.Seconds(),
-	}, nil
+	return transcription, nil
+}
+
+// whisperJSON is the full JSON output of whisper-cli (-ojf)
+type whisperJSON struct {
+	Result struct {
+		Language string `json:"language"`
+	} `json:"result"`
+	Transcription []struct {
+		Offsets struct {
+			From int64 `json:"from"` // Milliseconds
+			To   int64 `json:"to"`
+		} `json:"offsets"`
+		Text         string  `json:"text"`
+		NoSpeechProb float64 `json:"no_speech_prob"`
+		Tokens       []struct {
+			Text string  `json:"text"`
+			P    float64 `json:"p"`
+		} `json:"tokens"`
+	} `json:"transcription"`
 }
 
-// SetLanguage sets the language for transcription
-func (p *WhisperProvider) SetLanguage(lang string) {
-	p.language = lang
+// parseWhisperJSON fills res with the segments and text of whisper-cli's
+// JSON output
+func parseWhisperJSON(data []byte, res *TranscriptionResult) error {
+	var out whisperJSON
+	if err := json.Unmarshal(data, &out); err != nil {
+		return err
+	}
+
+	if out.Result.Language != "" {
+		res.Language = out.Result.Language
+	}
+
+	var texts []string
+	for _, t := range out.Transcription {
+		segment := Segment{
+			Start:        time.Duration(t.Offsets.From) * time.Millisecond,
+			End:          time.Duration(t.Offsets.To) * time.Millisecond,
+			Text:         cleanTranscription(t.Text),
+			NoSpeechProb: t.NoSpeechProb,
+		}
+		for _, token := range t.Tokens {
+			segment.Tokens = append(segment.Tokens, Token{Text: token.Text, Probability: token.P})
+		}
+		segment.AvgLogProb = tokenLogProb(segment.Tokens)
+
+		res.Segments = append(res.Segments, segment)
+		if segment.Text != "" {
+			texts = append(texts, segment.Text)
+		}
+	}
+
+	res.Text = strings.Join(texts, " ")
+	scoreSegments(res)
+	return nil
 }
 
 // IsAvailable checks if Whisper is available
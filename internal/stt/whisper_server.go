@@ -0,0 +1,383 @@
+package stt
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os/exec"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/anastreamer/ana/internal/config"
+	"github.com/anastreamer/ana/pkg/logger"
+	"github.com/anastreamer/ana/pkg/utils"
+	"github.com/rs/zerolog"
+)
+
+// Supervision of the whisper.cpp server
+const (
+	healthInterval    = 5 * time.Second
+	startupInterval   = 250 * time.Millisecond // Health polling while the model loads
+	startupTimeout    = 2 * time.Minute        // Loading longer than this is a hung start
+	maxHealthFailures = 3                      // Consecutive failures before a restart
+)
+
+// WhisperServerProvider implements STT with a long-running whisper.cpp
+// server that Ana launches and supervises, so the model is loaded once.
+// While the server is starting or down, utterances go to whisper-cli.
+type WhisperServerProvider struct {
+	promptHolder
+	languageHolder
+	binaryPath string
+	modelPath  string
+	baseURL    string
+	port       int
+	client     *http.Client
+	fallback   *WhisperProvider
+	log        zerolog.Logger
+
+	ready  atomic.Bool
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// whisperServerResponse is the verbose_json response of /inference
+type whisperServerResponse struct {
+	Language string  `json:"language"`
+	Duration float64 `json:"duration"`
+	Text     string  `json:"text"`
+	Segments []struct {
+		Start        float64  `json:"start"`
+		End          float64  `json:"end"`
+		Text         string   `json:"text"`
+		AvgLogProb   *float64 `json:"avg_logprob"`
+		NoSpeechProb float64  `json:"no_speech_prob"`
+		Words        []struct {
+			Word        string  `json:"word"`
+			Probability float64 `json:"probability"`
+		} `json:"words"`
+	} `json:"segments"`
+}
+
+// NewWhisperServerProvider starts the whisper.cpp server on cfg.Port and
+// keeps it running until Close
+func NewWhisperServerProvider(cfg config.WhisperConfig) (*WhisperServerProvider, error) {
+	fallback, err := NewWhisperProvider(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &WhisperServerProvider{
+		binaryPath: utils.GetBinaryPath(cfg.ServerPath),
+		modelPath:  fallback.modelPath,
+		baseURL:    fmt.Sprintf("http://127.0.0.1:%d", cfg.Port),
+		port:       cfg.Port,
+		client:     &http.Client{Timeout: 60 * time.Second},
+		fallback:   fallback,
+		log:        logger.Component("whisper-server"),
+	}
+
+	if !utils.BinaryExists(p.binaryPath) {
+		return nil, fmt.Errorf("whisper server not found at %s", p.binaryPath)
+	}
+	// Set before supervise reads it
+	p.languageHolder.SetLanguage(cfg.Language)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	p.cancel = cancel
+	p.wg.Add(1)
+	go p.supervise(ctx)
+
+	return p, nil
+}
+
+// Name returns the provider name
+func (p *WhisperServerProvider) Name() string {
+	return "whisper-server"
+}
+
+// supervise runs the server and restarts it when it exits or stops
+// answering, until ctx is done
+func (p *WhisperServerProvider) supervise(ctx context.Context) {
+	defer p.wg.Done()
+
+	backoff := time.Second
+	for {
+		start := time.Now()
+		err := p.run(ctx)
+		wasReady := p.ready.Swap(false)
+		if ctx.Err() != nil {
+			return
+		}
+
+		// A server that never got ready keeps backing off however long it
+		// took to give up on it
+		if wasReady && time.Since(start) > time.Minute {
+			backoff = time.Second
+		}
+		p.log.Warn().Err(err).Dur("retry_in", backoff).Msg("Whisper server stopped, using whisper-cli meanwhile")
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, 2*time.Minute)
+	}
+}
+
+// run starts the server and health-checks it until it exits or fails
+// maxHealthFailures checks in a row. A server that fails them or isn't
+// ready within startupTimeout is killed.
+func (p *WhisperServerProvider) run(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, p.binaryPath,
+		"-m", p.modelPath,
+		"-l", p.language(),
+		"--host", "127.0.0.1",
+		"--port", strconv.Itoa(p.port),
+	)
+	output, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	cmd.Stderr = cmd.Stdout
+
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("start whisper server: %w", err)
+	}
+	p.log.Info().Int("port", p.port).Str("model", p.modelPath).Msg("Whisper server starting")
+
+	// The server logs a lot; keep it at debug level
+	go func() {
+		scanner := bufio.NewScanner(output)
+		for scanner.Scan() {
+			p.log.Debug().Str("output", scanner.Text()).Msg("Whisper server")
+		}
+	}()
+
+	exited := make(chan error, 1)
+	go func() {
+		exited <- cmd.Wait()
+	}()
+
+	interval := startupInterval
+	deadline := time.Now().Add(startupTimeout)
+	failures := 0
+	for {
+		select {
+		case err := <-exited:
+			return fmt.Errorf("whisper server exited: %w", err)
+		case <-time.After(interval):
+		}
+
+		if err := p.checkHealth(ctx); err != nil {
+			// A loading model is not a failure, unless it never ends
+			if !p.ready.Load() {
+				if time.Now().Before(deadline) {
+					continue
+				}
+				cancel()
+				<-exited
+				return fmt.Errorf("whisper server not ready after %s: %w", startupTimeout, err)
+			}
+			failures++
+			p.log.Debug().Err(err).Int("failures", failures).Msg("Whisper server health check failed")
+			if failures >= maxHealthFailures {
+				cancel()
+				<-exited
+				return fmt.Errorf("whisper server not responding: %w", err)
+			}
+			continue
+		}
+
+		failures = 0
+		if !p.ready.Load() {
+			p.ready.Store(true)
+			p.log.Info().Msg("Whisper server ready")
+			interval = healthInterval
+		}
+	}
+}
+
+// checkHealth asks the server whether the model is loaded
+func (p *WhisperServerProvider) checkHealth(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.baseURL+"/health", nil)
+	if err != nil {
+		return err
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// Transcribe converts audio bytes to text. PCM is wrapped as WAV in memory
+// and posted to the server.
+func (p *WhisperServerProvider) Transcribe(ctx context.Context, audio []byte) (*TranscriptionResult, error) {
+	if !p.ready.Load() {
+		p.log.Debug().Msg("Whisper server not ready, using whisper-cli")
+		return p.fallback.Transcribe(ctx, audio)
+	}
+
+	wav := audio
+	if len(audio) <= 4 || string(audio[0:4]) != "RIFF" {
+		var err error
+		if wav, err = utils.PCMToWAV(audio, 16000, 1, 16); err != nil {
+			return nil, err
+		}
+	}
+	return p.inference(ctx, wav)
+}
+
+// TranscribeFile transcribes an audio file
+func (p *WhisperServerProvider) TranscribeFile(ctx context.Context, filePath string) (*TranscriptionResult, error) {
+	if !p.ready.Load() {
+		return p.fallback.TranscribeFile(ctx, filePath)
+	}
+
+	samples, rate, err := utils.ReadWAV(filePath)
+	if err != nil {
+		return nil, err
+	}
+	samples = utils.Resample(samples, rate, 16000)
+	return p.Transcribe(ctx, utils.Int16ToBytes(samples))
+}
+
+// inference posts a WAV file to the server
+func (p *WhisperServerProvider) inference(ctx context.Context, wav []byte) (*TranscriptionResult, error) {
+	start := time.Now()
+	language := p.language()
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, err := writer.CreateFormFile("file", "audio.wav")
+	if err != nil {
+		return nil, err
+	}
+	part.Write(wav)
+	writer.WriteField("response_format", "verbose_json")
+	writer.WriteField("language", language)
+	writer.WriteField("temperature", "0.0")
+	if prompt := p.prompt(); prompt != "" {
+		writer.WriteField("prompt", prompt)
+	}
+	writer.Close()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.baseURL+"/inference", &body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("whisper server request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read whisper server response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("whisper server error (status %d): %s", resp.StatusCode, string(data))
+	}
+
+	var res whisperServerResponse
+	if err := json.Unmarshal(data, &res); err != nil {
+		return nil, fmt.Errorf("failed to parse whisper server response: %w", err)
+	}
+
+	result := &TranscriptionResult{
+		Text:       cleanTranscription(res.Text),
+		Language:   res.Language,
+		Confidence: 1.0,
+		Duration:   res.Duration,
+	}
+	if result.Language == "" {
+		result.Language = language
+	}
+
+	for _, s := range res.Segments {
+		segment := Segment{
+			Start:        time.Duration(s.Start * float64(time.Second)),
+			End:          time.Duration(s.End * float64(time.Second)),
+			Text:         cleanTranscription(s.Text),
+			NoSpeechProb: s.NoSpeechProb,
+		}
+		for _, w := range s.Words {
+			segment.Tokens = append(segment.Tokens, Token{Text: w.Word, Probability: w.Probability})
+		}
+
+		// Older servers only report word probabilities
+		if s.AvgLogProb != nil {
+			segment.AvgLogProb = *s.AvgLogProb
+		} else {
+			segment.AvgLogProb = tokenLogProb(segment.Tokens)
+		}
+		result.Segments = append(result.Segments, segment)
+	}
+	scoreSegments(result)
+
+	p.log.Debug().
+		Str("text", result.Text).
+		Int("segments", len(result.Segments)).
+		Float64("confidence", result.Confidence).
+		Dur("duration", time.Since(start)).
+		Msg("Transcription complete")
+
+	return result, nil
+}
+
+// SetLanguage sets the language for transcription. The running server keeps
+// its -l; each request sends the language.
+func (p *WhisperServerProvider) SetLanguage(lang string) {
+	p.languageHolder.SetLanguage(lang)
+	p.fallback.SetLanguage(lang)
+}
+
+// SetPrompt sets the biasing prompt of the server and whisper-cli
+func (p *WhisperServerProvider) SetPrompt(prompt string) {
+	p.promptHolder.SetPrompt(prompt)
+	p.fallback.SetPrompt(prompt)
+}
+
+// IsAvailable checks if the server is up or whisper-cli can stand in
+func (p *WhisperServerProvider) IsAvailable(ctx context.Context) bool {
+	return p.ready.Load() || p.fallback.IsAvailable(ctx)
+}
+
+// GetStatus tells whether the server is up, in Spanish
+func (p *WhisperServerProvider) GetStatus() string {
+	if p.ready.Load() {
+		return p.Name() + ": activo"
+	}
+	return p.Name() + ": arrancando, usando whisper-cli"
+}
+
+// Close stops the server
+func (p *WhisperServerProvider) Close() error {
+	p.cancel()
+	p.wg.Wait()
+	return nil
+}
@@ -72,16 +72,30 @@ func (a *autoProvider) Synthesize(ctx context.Context, text string) ([]byte, err
 	return p.Synthesize(ctx, text)
 }
 
+// SetVoice sets the voice of the providers that have it: a Piper model
+// means nothing to OpenAI and vice versa. It fails if none does.
 func (a *autoProvider) SetVoice(voice string) error {
 	var lastErr error
+	accepted := false
 	for _, p := range a.providers {
 		if err := p.SetVoice(voice); err != nil {
 			lastErr = err
+		} else {
+			accepted = true
 		}
 	}
+	if accepted {
+		return nil
+	}
 	return lastErr
 }
 
+func (a *autoProvider) SetLanguage(lang string) {
+	for _, p := range a.providers {
+		p.SetLanguage(lang)
+	}
+}
+
 func (a *autoProvider) SetSpeed(speed float64) {
 	for _, p := range a.providers {
 		p.SetSpeed(speed)
@@ -94,6 +108,16 @@ func (a *autoProvider) Stop() {
 	}
 }
 
+// AddPlaybackListener implements PlaybackNotifier for the vendors that can
+// report their audio
+func (a *autoProvider) AddPlaybackListener(fn func(wav []byte)) {
+	for _, p := range a.providers {
+		if n, ok := p.(PlaybackNotifier); ok {
+			n.AddPlaybackListener(fn)
+		}
+	}
+}
+
 func (a *autoProvider) IsAvailable(ctx context.Context) bool {
 	for _, p := range a.providers {
 		if p.IsAvailable(ctx) {
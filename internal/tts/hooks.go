@@ -0,0 +1,86 @@
+package tts
+
+import (
+	"context"
+	"sync"
+)
+
+// Hooks are called around speech playback, e.g. to duck music while Ana talks
+type Hooks struct {
+	OnStart func()
+	OnStop  func()
+	// OnPlay receives the exact WAV audio right before it starts playing,
+	// e.g. as the echo canceller reference. Only providers implementing
+	// PlaybackNotifier call it.
+	OnPlay func(wav []byte)
+}
+
+// PlaybackNotifier is implemented by providers that can report the audio
+// they are about to play
+type PlaybackNotifier interface {
+	AddPlaybackListener(fn func(wav []byte))
+}
+
+// hookedProvider wraps a provider and runs hooks around each Speak call
+type hookedProvider struct {
+	Provider
+	hooks Hooks
+}
+
+// WithHooks wraps a provider so the hooks run before and after it speaks.
+// Wrapping a nil provider returns nil.
+func WithHooks(p Provider, hooks Hooks) Provider {
+	if p == nil {
+		return nil
+	}
+	if hooks.OnPlay != nil {
+		if n, ok := p.(PlaybackNotifier); ok {
+			n.AddPlaybackListener(hooks.OnPlay)
+		}
+	}
+	return &hookedProvider{Provider: p, hooks: hooks}
+}
+
+// AddPlaybackListener implements PlaybackNotifier when the wrapped provider
+// does, so wrappers can be nested
+func (h *hookedProvider) AddPlaybackListener(fn func(wav []byte)) {
+	if n, ok := h.Provider.(PlaybackNotifier); ok {
+		n.AddPlaybackListener(fn)
+	}
+}
+
+// Speak runs OnStart, speaks, then runs OnStop (also on error or cancel)
+func (h *hookedProvider) Speak(ctx context.Context, text string) error {
+	if h.hooks.OnStart != nil {
+		h.hooks.OnStart()
+	}
+	if h.hooks.OnStop != nil {
+		defer h.hooks.OnStop()
+	}
+	return h.Provider.Speak(ctx, text)
+}
+
+// playbackListeners implements PlaybackNotifier for providers that play WAV
+// audio they synthesized themselves
+type playbackListeners struct {
+	mu  sync.Mutex
+	fns []func(wav []byte)
+}
+
+// AddPlaybackListener implements PlaybackNotifier
+func (l *playbackListeners) AddPlaybackListener(fn func(wav []byte)) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.fns = append(l.fns, fn)
+}
+
+// notifyPlayback reports audio that is about to play
+func (l *playbackListeners) notifyPlayback(wav []byte) {
+	l.mu.Lock()
+	fns := l.fns
+	l.mu.Unlock()
+
+	for _, fn := range fns {
+		fn(wav)
+	}
+}
@@ -29,9 +29,15 @@ type OpenAITTSProvider struct {
 	client *http.Client
 	log    zerolog.Logger
 
+	defaultVoice string            // Voice for languages without their own
+	voices       map[string]string // Language -> voice
+	configVoice  string            // voice, restored by SetVoice("")
+
 	mu         sync.Mutex
 	currentCmd *exec.Cmd
 	isPlaying  bool
+
+	playbackListeners
 }
 
 // OpenAITTSRequest represents the API request
@@ -67,7 +73,10 @@ func NewOpenAIProvider(cfg config.OpenAITTSConfig) (*OpenAITTSProvider, error) {
 		client: &http.Client{
 			Timeout: 60 * time.Second,
 		},
-		log: logger.Component("openai-tts"),
+		log:          logger.Component("openai-tts"),
+		defaultVoice: voice,
+		voices:       cfg.Voices,
+		configVoice:  voice,
 	}, nil
 }
 
@@ -106,19 +115,23 @@ func (p *OpenAITTSProvider) Speak(ctx context.Context, text string) error {
 	return p.playAudio(ctx, audio)
 }
 
-// Synthesize converts text to audio bytes (MP3 format from OpenAI)
+// Synthesize converts text to audio bytes (WAV format)
 func (p *OpenAITTSProvider) Synthesize(ctx context.Context, text string) ([]byte, error) {
 	if text == "" {
 		return nil, fmt.Errorf("empty text")
 	}
 
+	p.mu.Lock()
+	voice, speed := p.voice, p.speed
+	p.mu.Unlock()
+
 	// Create request
 	reqBody := OpenAITTSRequest{
 		Model:          p.model,
 		Input:          text,
-		Voice:          p.voice,
-		ResponseFormat: "mp3", // OpenAI returns MP3 by default
-		Speed:          p.speed,
+		Voice:          voice,
+		ResponseFormat: "wav", // WAV so the echo canceller can use it as reference
+		Speed:          speed,
 	}
 
 	jsonBody, err := json.Marshal(reqBody)
@@ -155,10 +168,10 @@ func (p *OpenAITTSProvider) Synthesize(ctx context.Context, text string) ([]byte
 	return audio, nil
 }
 
-// playAudio plays audio data (MP3 format)
+// playAudio plays audio data (WAV format)
 func (p *OpenAITTSProvider) playAudio(ctx context.Context, audio []byte) error {
 	// Create temp file for playback
-	tempFile := utils.GetTempFilePath("ana_play", ".mp3")
+	tempFile := utils.GetTempFilePath("ana_play", ".wav")
 	defer os.Remove(tempFile)
 
 	if err := os.WriteFile(tempFile, audio, 0644); err != nil {
@@ -187,13 +200,14 @@ func (p *OpenAITTSProvider) playAudio(ctx context.Context, audio []byte) error {
 	}
 
 	if cmd == nil {
-		return fmt.Errorf("no MP3 audio player found (install mpv or ffplay)")
+		return fmt.Errorf("no audio player found (install mpv or ffplay)")
 	}
 
 	p.mu.Lock()
 	p.currentCmd = cmd
 	p.mu.Unlock()
 
+	p.notifyPlayback(audio)
 	if err := cmd.Run(); err != nil {
 		if ctx.Err() != nil {
 			return ctx.Err()
@@ -206,24 +220,51 @@ func (p *OpenAITTSProvider) playAudio(ctx context.Context, audio []byte) error {
 
 // SetVoice sets the voice to use
 func (p *OpenAITTSProvider) SetVoice(voice string) error {
+	if voice == "" {
+		voice = p.configVoice
+	}
 	validVoices := []string{"alloy", "echo", "fable", "onyx", "nova", "shimmer"}
 	for _, v := range validVoices {
 		if v == voice {
+			p.mu.Lock()
+			defer p.mu.Unlock()
 			p.voice = voice
+			p.defaultVoice = voice
 			return nil
 		}
 	}
 	return fmt.Errorf("invalid voice: %s (valid: alloy, echo, fable, onyx, nova, shimmer)", voice)
 }
 
+// SetLanguage switches to the voice configured for lang
+func (p *OpenAITTSProvider) SetLanguage(lang string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	voice, ok := p.voices[lang]
+	if !ok {
+		voice = p.defaultVoice
+	}
+	if voice != p.voice {
+		p.log.Debug().Str("language", lang).Str("voice", voice).Msg("Switching voice")
+		p.voice = voice
+	}
+}
+
 // SetSpeed sets the speech speed
 func (p *OpenAITTSProvider) SetSpeed(speed float64) {
+	if speed == 0 {
+		speed = 1.0
+	}
 	if speed < 0.25 {
 		speed = 0.25
 	}
 	if speed > 4.0 {
 		speed = 4.0
 	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
 	p.speed = speed
 }
 
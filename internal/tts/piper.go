@@ -22,9 +22,16 @@ type PiperProvider struct {
 	speed      float64
 	log        zerolog.Logger
 
+	defaultModel string            // Model for languages without their own
+	voices       map[string]string // Language -> model
+	configModel  string            // model_path, restored by SetVoice("")
+	configSpeed  float64
+
 	mu         sync.Mutex
 	currentCmd *exec.Cmd
 	isPlaying  bool
+
+	playbackListeners
 }
 
 // NewPiperProvider creates a new Piper TTS provider
@@ -52,11 +59,23 @@ func NewPiperProvider(cfg config.PiperConfig) (*PiperProvider, error) {
 		speed = 1.0
 	}
 
+	voices := make(map[string]string, len(cfg.Voices))
+	for lang, model := range cfg.Voices {
+		if absPath, err := filepath.Abs(model); err == nil {
+			model = absPath
+		}
+		voices[lang] = model
+	}
+
 	return &PiperProvider{
-		binaryPath: binaryPath,
-		modelPath:  modelPath,
-		speed:      speed,
-		log:        logger.Component("piper"),
+		binaryPath:   binaryPath,
+		modelPath:    modelPath,
+		speed:        speed,
+		log:          logger.Component("piper"),
+		defaultModel: modelPath,
+		voices:       voices,
+		configModel:  modelPath,
+		configSpeed:  speed,
 	}, nil
 }
 
@@ -100,10 +119,11 @@ func (p *PiperProvider) Synthesize(ctx context.Context, text string) ([]byte, er
 	if text == "" {
 		return nil, fmt.Errorf("empty text")
 	}
+	model, speed := p.settings()
 
 	p.log.Debug().
 		Str("binary", p.binaryPath).
-		Str("model", p.modelPath).
+		Str("model", model).
 		Msg("Piper synthesize starting")
 
 	// Create temp output file
@@ -118,13 +138,13 @@ func (p *PiperProvider) Synthesize(ctx context.Context, text string) ([]byte, er
 
 	// Build command arguments
 	args := []string{
-		"--model", p.modelPath,
+		"--model", model,
 		"--output_file", tempFile,
 	}
 
 	// Add speed/length scale if not default
-	if p.speed != 1.0 {
-		args = append(args, "--length-scale", fmt.Sprintf("%.2f", 1.0/p.speed))
+	if speed != 1.0 {
+		args = append(args, "--length-scale", fmt.Sprintf("%.2f", 1.0/speed))
 	}
 
 	p.log.Debug().
@@ -236,6 +256,7 @@ func (p *PiperProvider) playAudio(ctx context.Context, audio []byte) error {
 	p.currentCmd = cmd
 	p.mu.Unlock()
 
+	p.notifyPlayback(audio)
 	if err := cmd.Run(); err != nil {
 		// Check if it was cancelled
 		if ctx.Err() != nil {
@@ -249,21 +270,54 @@ func (p *PiperProvider) playAudio(ctx context.Context, audio []byte) error {
 
 // SetVoice sets the voice model to use
 func (p *PiperProvider) SetVoice(voice string) error {
+	if voice == "" {
+		voice = p.configModel
+	}
 	if !utils.FileExists(voice) {
 		return fmt.Errorf("voice model not found: %s", voice)
 	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
 	p.modelPath = voice
+	p.defaultModel = voice
 	return nil
 }
 
+// SetLanguage switches to the model configured for lang
+func (p *PiperProvider) SetLanguage(lang string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	model, ok := p.voices[lang]
+	if !ok {
+		model = p.defaultModel
+	}
+	if model != p.modelPath {
+		p.log.Debug().Str("language", lang).Str("model", model).Msg("Switching voice")
+		p.modelPath = model
+	}
+}
+
 // SetSpeed sets the speech speed
 func (p *PiperProvider) SetSpeed(speed float64) {
 	if speed <= 0 {
-		speed = 1.0
+		speed = p.configSpeed
 	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
 	p.speed = speed
 }
 
+// settings returns the model and speed to synthesize with, which the
+// setters change while Ana speaks
+func (p *PiperProvider) settings() (string, float64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.modelPath, p.speed
+}
+
 // Stop stops any current playback
 func (p *PiperProvider) Stop() {
 	p.mu.Lock()
@@ -284,8 +338,8 @@ func (p *PiperProvider) IsAvailable(ctx context.Context) bool {
 	}
 
 	// Check model exists
-	if !utils.FileExists(p.modelPath) {
-		p.log.Warn().Str("path", p.modelPath).Msg("Piper model not found")
+	if model, _ := p.settings(); !utils.FileExists(model) {
+		p.log.Warn().Str("path", model).Msg("Piper model not found")
 		return false
 	}
 
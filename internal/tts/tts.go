@@ -19,10 +19,16 @@ type Provider interface {
 	// Synthesize converts text to audio bytes (WAV format)
 	Synthesize(ctx context.Context, text string) ([]byte, error)
 
-	// SetVoice sets the voice to use
+	// SetVoice sets the voice to use, or back to the configured one if
+	// voice is empty
 	SetVoice(voice string) error
 
-	// SetSpeed sets the speech speed (1.0 = normal)
+	// SetLanguage switches to the voice configured for lang, or back to the
+	// default voice if there's none
+	SetLanguage(lang string)
+
+	// SetSpeed sets the speech speed (1.0 = normal), or back to the
+	// configured one if speed is 0
 	SetSpeed(speed float64)
 
 	// Stop stops any current playback
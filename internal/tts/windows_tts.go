@@ -14,11 +14,11 @@ import (
 
 // WindowsTTSProvider implements TTS using Windows built-in SAPI
 type WindowsTTSProvider struct {
-	speed      float64
-	voice      string
-	log        zerolog.Logger
-	mu         sync.Mutex
-	isPlaying  bool
+	speed     float64
+	voice     string
+	log       zerolog.Logger
+	mu        sync.Mutex
+	isPlaying bool
 }
 
 // NewWindowsTTSProvider creates a new Windows TTS provider
@@ -115,6 +115,9 @@ func (w *WindowsTTSProvider) SetVoice(voice string) error {
 	return nil
 }
 
+// SetLanguage does nothing, SAPI speaks with the system voice
+func (w *WindowsTTSProvider) SetLanguage(lang string) {}
+
 // SetSpeed sets the speech speed
 func (w *WindowsTTSProvider) SetSpeed(speed float64) {
 	if speed <= 0 {
@@ -0,0 +1,108 @@
+package vad
+
+import (
+	"math"
+	"sort"
+
+	"github.com/anastreamer/ana/internal/config"
+)
+
+// Calibration summarizes a room noise recording (and optionally a speech
+// recording) and the settings suggested for it
+type Calibration struct {
+	NoiseFloorDB  float64 // Median noise level
+	NoisePeakDB   float64 // 95th percentile of the noise level
+	SpeechDB      float64 // Median level of the speech recording (0 if none)
+	Engine        string  // Suggested engine
+	Sensitivity   float64 // Suggested sensitivity
+	SpeechHitRate float64 // Share of loud speech frames detected with the suggestion
+	FalseRate     float64 // Share of noise frames detected as speech with the suggestion
+}
+
+// Calibrate measures a noise recording and, if given, a speech recording
+// made with the same mic, and suggests VAD settings for them. Audio is split
+// into chunkSize frames like the pipeline does.
+func Calibrate(cfg config.VADConfig, noise, speech []int16, sampleRate, chunkSize int) Calibration {
+	noiseLevels := levels(noise, chunkSize)
+	c := Calibration{
+		NoiseFloorDB: percentile(noiseLevels, 0.5),
+		NoisePeakDB:  percentile(noiseLevels, 0.95),
+	}
+
+	// Fluctuating noise (keyboard, game audio) trips an energy threshold;
+	// the spectral engine handles it better
+	c.Engine = "energy"
+	if c.NoisePeakDB-c.NoiseFloorDB > 6 {
+		c.Engine = "spectral"
+	}
+
+	// Put the threshold halfway between the noise peaks and typical speech,
+	// or a few dB over the noise peaks without a speech sample
+	margin := c.NoisePeakDB - c.NoiseFloorDB + 6
+	if len(speech) > 0 {
+		speechLevels := levels(speech, chunkSize)
+		c.SpeechDB = percentile(speechLevels, 0.5)
+		if c.SpeechDB > c.NoisePeakDB {
+			margin = (c.NoisePeakDB+c.SpeechDB)/2 - c.NoiseFloorDB
+		}
+	}
+	c.Sensitivity = math.Round(SensitivityForMargin(margin)*100) / 100
+
+	// Replay the recordings through the suggested settings
+	cfg.Engine = c.Engine
+	cfg.Sensitivity = c.Sensitivity
+	cfg.NoiseFloorDB = math.Round(c.NoiseFloorDB)
+	cfg.HangoverMs = 0
+
+	if v, err := New(cfg, sampleRate); err == nil {
+		c.FalseRate = speechRate(v, noise, chunkSize, math.Inf(-1))
+		if len(speech) > 0 {
+			// Only count the loud half of the speech sample, pauses between
+			// words are not supposed to be detected
+			v.Reset()
+			speechRate(v, noise, chunkSize, math.Inf(-1)) // Learn the noise first
+			c.SpeechHitRate = speechRate(v, speech, chunkSize, c.SpeechDB)
+		}
+	}
+
+	return c
+}
+
+// levels returns the level of every chunk in dBFS
+func levels(samples []int16, chunkSize int) []float64 {
+	var out []float64
+	for start := 0; start+chunkSize <= len(samples); start += chunkSize {
+		out = append(out, levelDB(samples[start:start+chunkSize]))
+	}
+	return out
+}
+
+// speechRate returns the share of chunks at or above minLevel detected as speech
+func speechRate(v VAD, samples []int16, chunkSize int, minLevel float64) float64 {
+	var total, hits int
+	for start := 0; start+chunkSize <= len(samples); start += chunkSize {
+		chunk := samples[start : start+chunkSize]
+		res := v.Process(chunk)
+		if res.LevelDB < minLevel {
+			continue
+		}
+		total++
+		if res.Speech {
+			hits++
+		}
+	}
+	if total == 0 {
+		return 0
+	}
+	return float64(hits) / float64(total)
+}
+
+// percentile returns the p-th percentile (0..1) of values
+func percentile(values []float64, p float64) float64 {
+	if len(values) == 0 {
+		return minDB
+	}
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	return sorted[int(p*float64(len(sorted)-1))]
+}
@@ -0,0 +1,52 @@
+package vad
+
+import (
+	"time"
+
+	"github.com/anastreamer/ana/internal/config"
+)
+
+// EnergyVAD compares each frame's level with a running noise floor estimate.
+// Cheap and good enough for a quiet room with a close mic.
+type EnergyVAD struct {
+	sampleRate int
+	margin     float64
+	noise      noiseTracker
+	hangover   hangover
+	speech     bool
+}
+
+// NewEnergyVAD creates an adaptive energy VAD
+func NewEnergyVAD(cfg config.VADConfig, sampleRate int) *EnergyVAD {
+	return &EnergyVAD{
+		sampleRate: sampleRate,
+		margin:     MarginDB(cfg.Sensitivity),
+		noise:      newNoiseTracker(cfg.NoiseFloorDB),
+		hangover:   hangover{hold: time.Duration(cfg.HangoverMs) * time.Millisecond},
+	}
+}
+
+// Process implements VAD
+func (v *EnergyVAD) Process(samples []int16) Result {
+	level := levelDB(samples)
+	floor := v.noise.update(level, v.speech)
+
+	// 2 dB per unit of log-odds: the probability goes from about 0.1 to 0.9
+	// over ±4 dB around the margin
+	p := logistic((level - floor - v.margin) / 2)
+	v.speech = v.hangover.update(p >= 0.5, frameDuration(len(samples), v.sampleRate))
+
+	return Result{
+		Probability: p,
+		Speech:      v.speech,
+		LevelDB:     level,
+		NoiseDB:     floor,
+	}
+}
+
+// Reset implements VAD
+func (v *EnergyVAD) Reset() {
+	v.noise.reset()
+	v.hangover.reset()
+	v.speech = false
+}
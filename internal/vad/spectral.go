@@ -0,0 +1,162 @@
+package vad
+
+import (
+	"math"
+	"math/cmplx"
+	"time"
+
+	"github.com/anastreamer/ana/internal/config"
+	"github.com/anastreamer/ana/pkg/utils"
+)
+
+// band is a frequency band analyzed by SpectralVAD, with how much it counts
+type band struct {
+	low, high float64
+	weight    float64
+}
+
+// Sub-bands in the style of the WebRTC VAD; the ones holding the first
+// formants of speech weigh most, the lowest one (hum, rumble) least
+var bands = []band{
+	{80, 250, 0.4},
+	{250, 500, 1},
+	{500, 1000, 1.2},
+	{1000, 2000, 1.2},
+	{2000, 3000, 1},
+	{3000, 4000, 0.6},
+}
+
+// SpectralVAD splits each frame into sub-bands, tracks the noise of every
+// band separately and combines the per-band signal-to-noise ratios with the
+// spectral flatness (speech is peaky, fans and hiss are flat). It copes with
+// steady noise (fans, hum, background music) much better than EnergyVAD.
+type SpectralVAD struct {
+	sampleRate int
+	margin     float64
+
+	noise    []noiseTracker
+	level    noiseTracker // Only to report the overall noise floor
+	hangover hangover
+	speech   bool
+
+	buf    []complex128
+	window []float64
+}
+
+// NewSpectralVAD creates a sub-band spectral VAD
+func NewSpectralVAD(cfg config.VADConfig, sampleRate int) *SpectralVAD {
+	v := &SpectralVAD{
+		sampleRate: sampleRate,
+		margin:     MarginDB(cfg.Sensitivity),
+		noise:      make([]noiseTracker, len(bands)),
+		level:      newNoiseTracker(cfg.NoiseFloorDB),
+		hangover:   hangover{hold: time.Duration(cfg.HangoverMs) * time.Millisecond},
+	}
+	for i := range v.noise {
+		v.noise[i] = newNoiseTracker(0)
+	}
+	return v
+}
+
+// Process implements VAD
+func (v *SpectralVAD) Process(samples []int16) Result {
+	level := levelDB(samples)
+	floor := v.level.update(level, v.speech)
+	if len(samples) == 0 {
+		return Result{LevelDB: level, NoiseDB: floor, Speech: v.speech}
+	}
+
+	spectrum := v.powerSpectrum(samples)
+	binHz := float64(v.sampleRate) / float64(len(v.buf))
+
+	// Weighted average of the positive per-band SNRs
+	var snr, weights float64
+	for i, b := range bands {
+		if b.low >= float64(v.sampleRate)/2 {
+			break
+		}
+		lo := int(b.low / binHz)
+		hi := min(int(b.high/binHz), len(spectrum)-1)
+
+		var e float64
+		for k := lo; k <= hi; k++ {
+			e += spectrum[k]
+		}
+		e = math.Max(minDB, 10*math.Log10(e/float64(hi-lo+1)+1e-12))
+
+		bandFloor := v.noise[i].update(e, v.speech)
+		snr += b.weight * math.Max(0, e-bandFloor)
+		weights += b.weight
+	}
+	snr /= weights
+
+	// A flat spectrum (flatness near 1) is noise, a peaky one (near 0) is
+	// voiced speech; it shifts the decision by up to about ±1.5 in log-odds
+	flat := flatness(spectrum, int(250/binHz), min(int(4000/binHz), len(spectrum)-1))
+
+	p := logistic((snr-v.margin)/2 + 3*(0.5-flat))
+	v.speech = v.hangover.update(p >= 0.5, frameDuration(len(samples), v.sampleRate))
+
+	return Result{
+		Probability: p,
+		Speech:      v.speech,
+		LevelDB:     level,
+		NoiseDB:     floor,
+	}
+}
+
+// Reset implements VAD
+func (v *SpectralVAD) Reset() {
+	for i := range v.noise {
+		v.noise[i].reset()
+	}
+	v.level.reset()
+	v.hangover.reset()
+	v.speech = false
+}
+
+// powerSpectrum returns the Hann-windowed power spectrum of the frame
+func (v *SpectralVAD) powerSpectrum(samples []int16) []float64 {
+	n := utils.NextPowerOfTwo(len(samples))
+	if len(v.buf) != n {
+		v.buf = make([]complex128, n)
+	}
+	if len(v.window) != len(samples) {
+		v.window = make([]float64, len(samples))
+		for i := range v.window {
+			v.window[i] = 0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/float64(max(len(samples)-1, 1)))
+		}
+	}
+
+	for i := range v.buf {
+		if i < len(samples) {
+			v.buf[i] = complex(float64(samples[i])/32768*v.window[i], 0)
+		} else {
+			v.buf[i] = 0
+		}
+	}
+	utils.FFT(v.buf)
+
+	spectrum := make([]float64, n/2+1)
+	for i := range spectrum {
+		m := cmplx.Abs(v.buf[i])
+		spectrum[i] = m * m
+	}
+	return spectrum
+}
+
+// flatness returns the spectral flatness (geometric over arithmetic mean)
+// of spectrum[lo..hi], between 0 and 1
+func flatness(spectrum []float64, lo, hi int) float64 {
+	if hi <= lo {
+		return 1
+	}
+	var logSum, sum float64
+	for k := lo; k <= hi; k++ {
+		p := spectrum[k] + 1e-12
+		logSum += math.Log(p)
+		sum += p
+	}
+	n := float64(hi - lo + 1)
+	return math.Exp(logSum/n) / (sum / n)
+}
@@ -0,0 +1,138 @@
+// Package vad decides whether audio frames contain speech. Implementations
+// adapt to the room's background noise instead of using a fixed threshold.
+package vad
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/anastreamer/ana/internal/config"
+)
+
+// Result is the analysis of one frame
+type Result struct {
+	Probability float64 // Speech probability of this frame (0..1)
+	Speech      bool    // Decision, including the hangover after speech ends
+	LevelDB     float64 // Frame level in dBFS
+	NoiseDB     float64 // Current noise floor estimate in dBFS
+}
+
+// VAD analyzes 16-bit mono PCM frames. Frames may be any length (the
+// pipeline feeds whole capture chunks). Implementations keep state between
+// calls and are not safe for concurrent use.
+type VAD interface {
+	Process(samples []int16) Result
+	Reset()
+}
+
+// New creates the VAD selected by cfg.Engine
+func New(cfg config.VADConfig, sampleRate int) (VAD, error) {
+	switch cfg.Engine {
+	case "energy":
+		return NewEnergyVAD(cfg, sampleRate), nil
+	case "spectral":
+		return NewSpectralVAD(cfg, sampleRate), nil
+	default:
+		return nil, fmt.Errorf("unknown VAD engine: %s", cfg.Engine)
+	}
+}
+
+// MarginDB converts the sensitivity setting into how far above the noise
+// floor a frame has to be to count as speech: 18 dB at 0, 3 dB at 1
+func MarginDB(sensitivity float64) float64 {
+	return 3 + 15*(1-sensitivity)
+}
+
+// SensitivityForMargin is the inverse of MarginDB, clamped to 0..1
+func SensitivityForMargin(margin float64) float64 {
+	return math.Max(0, math.Min(1, 1-(margin-3)/15))
+}
+
+// levelDB returns the frame level in dBFS
+func levelDB(samples []int16) float64 {
+	if len(samples) == 0 {
+		return minDB
+	}
+	var sum float64
+	for _, s := range samples {
+		v := float64(s) / 32768
+		sum += v * v
+	}
+	return math.Max(minDB, 10*math.Log10(sum/float64(len(samples))+1e-12))
+}
+
+// minDB is the quietest level tracked; digital silence sits here
+const minDB = -90
+
+// logistic maps a log-odds value to 0..1
+func logistic(x float64) float64 {
+	return 1 / (1 + math.Exp(-x))
+}
+
+// noiseTracker follows the background level of a signal in dB: it falls
+// quickly to quieter frames and rises slowly, and much more slowly during
+// speech, so talking barely moves it but a new fan or game audio does
+type noiseTracker struct {
+	floor   float64
+	initial float64
+	primed  bool
+}
+
+func newNoiseTracker(initial float64) noiseTracker {
+	return noiseTracker{initial: initial}
+}
+
+func (n *noiseTracker) update(level float64, speech bool) float64 {
+	if !n.primed {
+		n.primed = true
+		n.floor = level
+		if n.initial != 0 {
+			n.floor = n.initial
+		}
+		return n.floor
+	}
+
+	switch {
+	case level < n.floor:
+		n.floor += (level - n.floor) * 0.2
+	case speech:
+		n.floor += (level - n.floor) * 0.001
+	default:
+		n.floor += (level - n.floor) * 0.02
+	}
+	n.floor = math.Max(n.floor, minDB)
+	return n.floor
+}
+
+func (n *noiseTracker) reset() {
+	n.primed = false
+}
+
+// hangover keeps the speech decision on for a while after the last speech
+// frame so short pauses between words don't end an utterance
+type hangover struct {
+	hold      time.Duration
+	remaining time.Duration
+}
+
+func (h *hangover) update(speech bool, frame time.Duration) bool {
+	if speech {
+		h.remaining = h.hold
+		return true
+	}
+	if h.remaining > 0 {
+		h.remaining -= frame
+		return true
+	}
+	return false
+}
+
+func (h *hangover) reset() {
+	h.remaining = 0
+}
+
+// frameDuration returns how long a frame of n samples lasts
+func frameDuration(n, sampleRate int) time.Duration {
+	return time.Duration(n) * time.Second / time.Duration(sampleRate)
+}
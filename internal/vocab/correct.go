@@ -0,0 +1,240 @@
+package vocab
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// minKeyLen is the shortest sound key of a term that is corrected; shorter
+// ones match too many common words
+const minKeyLen = 3
+
+// minSplitKeyLen is the shortest sound key of a term that may be heard as
+// one word more than it has, like "MrQuesadilla" as "Mr Kesadilla"
+const minSplitKeyLen = 6
+
+// entry is a known term for corrections
+type entry struct {
+	term  string
+	words int    // Number of words in term
+	key   string // Sound of the term, see soundKey
+}
+
+// buildIndex prepares terms for Correct, longest first so "Just Chatting"
+// wins over "Chatting"
+func buildIndex(terms []string) []entry {
+	var index []entry
+	for _, term := range terms {
+		words := strings.Fields(term)
+		key := soundKey(strings.Join(words, ""))
+		if len(key) < minKeyLen {
+			continue
+		}
+		index = append(index, entry{term: term, words: len(words), key: key})
+	}
+
+	sort.SliceStable(index, func(i, j int) bool {
+		return len(index[i].key) > len(index[j].key)
+	})
+	return index
+}
+
+// Correct replaces words of text that sound like a known term but are
+// spelled differently, like "Hanna" for "Ana" or "just chating" for
+// "Just Chatting"
+func (v *Vocabulary) Correct(text string) string {
+	v.mu.RLock()
+	index := v.index
+	v.mu.RUnlock()
+
+	words := strings.Fields(text)
+	if len(words) == 0 || len(index) == 0 {
+		return text
+	}
+
+	var out []string
+	changed := false
+	for i := 0; i < len(words); {
+		e, n, ok := matchAt(words, i, index)
+		if !ok {
+			out = append(out, words[i])
+			i++
+			continue
+		}
+
+		window := words[i : i+n]
+		lead, _, _ := splitPunct(window[0])
+		_, _, trail := splitPunct(window[len(window)-1])
+		replaced := lead + e.term + trail
+		if replaced != strings.Join(window, " ") {
+			v.log.Debug().Str("heard", strings.Join(window, " ")).Str("term", e.term).Msg("Corrected transcription")
+			changed = true
+		}
+		out = append(out, replaced)
+		i += n
+	}
+
+	if !changed {
+		return text
+	}
+	return strings.Join(out, " ")
+}
+
+// matchAt finds the term that the words starting at i sound like, and how
+// many words it takes
+func matchAt(words []string, i int, index []entry) (entry, int, bool) {
+	for _, e := range index {
+		sizes := []int{e.words}
+		if len(e.key) >= minSplitKeyLen {
+			sizes = append(sizes, e.words+1)
+		}
+
+		for _, n := range sizes {
+			if i+n > len(words) {
+				continue
+			}
+
+			var key strings.Builder
+			for _, w := range words[i : i+n] {
+				_, core, _ := splitPunct(w)
+				key.WriteString(soundKey(core))
+			}
+			if soundsLike(key.String(), e.key) {
+				return e, n, true
+			}
+		}
+	}
+	return entry{}, 0, false
+}
+
+// soundsLike compares two sound keys. Longer keys may differ in one or two
+// letters, but never in the first one.
+func soundsLike(heard, term string) bool {
+	if heard == "" || heard[0] != term[0] {
+		return false
+	}
+
+	allowed := 0
+	switch {
+	case len(term) >= 9:
+		allowed = 2
+	case len(term) >= 5:
+		allowed = 1
+	}
+	return distance(heard, term, allowed) <= allowed
+}
+
+// splitPunct separates the punctuation around a word: "¿Hanna," gives "¿",
+// "Hanna" and ","
+func splitPunct(word string) (lead, core, trail string) {
+	isPunct := func(r rune) bool { return unicode.IsPunct(r) || unicode.IsSymbol(r) }
+
+	core = strings.TrimLeftFunc(word, isPunct)
+	lead = word[:len(word)-len(core)]
+	trimmed := strings.TrimRightFunc(core, isPunct)
+	trail = core[len(trimmed):]
+	return lead, trimmed, trail
+}
+
+// soundKey approximates how a word sounds in Spanish, so spellings of the
+// same sound compare equal: it drops accents and silent h, merges b/v,
+// soft c/z/s, hard c/k/q, ll/y and g/j before e and i, and doubled letters
+func soundKey(word string) string {
+	var letters []rune
+	for _, r := range strings.ToLower(word) {
+		switch r {
+		case 'á', 'à', 'ä':
+			r = 'a'
+		case 'é', 'è', 'ë':
+			r = 'e'
+		case 'í', 'ì', 'ï':
+			r = 'i'
+		case 'ó', 'ò', 'ö':
+			r = 'o'
+		case 'ú', 'ù', 'ü':
+			r = 'u'
+		case 'ñ':
+			r = 'n'
+		}
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			letters = append(letters, r)
+		}
+	}
+
+	var key []rune
+	for i := 0; i < len(letters); i++ {
+		r := letters[i]
+		next := rune(0)
+		if i+1 < len(letters) {
+			next = letters[i+1]
+		}
+		soft := next == 'e' || next == 'i'
+
+		switch {
+		case r == 'c' && next == 'h':
+			r = 'x'
+			i++
+		case r == 'h':
+			continue
+		case r == 'q' && next == 'u':
+			r = 'k'
+			i++
+		case r == 'c' && soft, r == 'z':
+			r = 's'
+		case r == 'c', r == 'q':
+			r = 'k'
+		case r == 'g' && soft:
+			r = 'j'
+		case r == 'l' && next == 'l':
+			r = 'y'
+			i++
+		case r == 'v', r == 'w':
+			r = 'b'
+		case r == 'y' && (next == 0 || !isVowel(next)):
+			r = 'i'
+		}
+
+		if len(key) > 0 && key[len(key)-1] == r {
+			continue
+		}
+		key = append(key, r)
+	}
+	return string(key)
+}
+
+func isVowel(r rune) bool {
+	return strings.ContainsRune("aeiou", r)
+}
+
+// distance is the Levenshtein distance between a and b, or more than limit
+// once it's known to exceed it
+func distance(a, b string, limit int) int {
+	ra, rb := []rune(a), []rune(b)
+	if d := len(ra) - len(rb); d > limit || -d > limit {
+		return limit + 1
+	}
+
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		best := cur[0]
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+			best = min(best, cur[j])
+		}
+		if best > limit {
+			return limit + 1
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)]
+}
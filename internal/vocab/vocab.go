@@ -0,0 +1,219 @@
+// Package vocab collects the words a streamer says that a generic STT model
+// gets wrong: Ana's name, the wake word, OBS scene and input names, chat
+// usernames and music artists. They bias the transcription (as a Whisper
+// prompt) and correct it afterwards.
+package vocab
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/anastreamer/ana/internal/config"
+	"github.com/anastreamer/ana/pkg/logger"
+	"github.com/rs/zerolog"
+)
+
+// SourceFunc lists the current terms of something that changes over time,
+// like the OBS scenes
+type SourceFunc func(ctx context.Context) ([]string, error)
+
+// source is a SourceFunc with the terms it listed last
+type source struct {
+	name  string
+	list  SourceFunc
+	terms []string
+}
+
+// Vocabulary is the set of known terms. Sources are read every refresh
+// interval once started; chatters are added as they write.
+type Vocabulary struct {
+	cfg config.STTVocabularyConfig
+	log zerolog.Logger
+
+	mu       sync.RWMutex
+	static   []string
+	sources  []*source
+	chatters []string // Most recent first
+	index    []entry  // Terms for Correct, longest first
+	onChange func(prompt string)
+	prompt   string
+}
+
+// New creates a vocabulary with Ana's name, the wake word and the
+// configured terms
+func New(cfg config.STTVocabularyConfig, wakeWord string) *Vocabulary {
+	v := &Vocabulary{
+		cfg: cfg,
+		log: logger.Component("vocab"),
+	}
+
+	v.static = appendTerms(v.static, "Ana")
+	if wakeWord != "" {
+		// Wake words are configured lowercase; names read better capitalized
+		v.static = appendTerms(v.static, strings.ToUpper(wakeWord[:1])+wakeWord[1:])
+	}
+	v.static = appendTerms(v.static, cfg.Terms...)
+	v.rebuild()
+	return v
+}
+
+// AddSource registers a source of terms, read on the next refresh. Terms of
+// earlier sources come first in the prompt.
+func (v *Vocabulary) AddSource(name string, list SourceFunc) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.sources = append(v.sources, &source{name: name, list: list})
+}
+
+// OnChange sets a callback invoked with the new prompt whenever it changes
+// (e.g. stt.Provider.SetPrompt), and once right away
+func (v *Vocabulary) OnChange(fn func(prompt string)) {
+	v.mu.Lock()
+	v.onChange = fn
+	prompt := v.prompt
+	v.mu.Unlock()
+
+	fn(prompt)
+}
+
+// AddChatter records a chat user as the most recent one
+func (v *Vocabulary) AddChatter(name string) {
+	name = strings.TrimSpace(name)
+	if name == "" || v.cfg.MaxChatters == 0 {
+		return
+	}
+
+	v.mu.Lock()
+	if len(v.chatters) > 0 && strings.EqualFold(v.chatters[0], name) {
+		v.mu.Unlock()
+		return
+	}
+	v.chatters = slices.DeleteFunc(v.chatters, func(c string) bool {
+		return strings.EqualFold(c, name)
+	})
+	v.chatters = append([]string{name}, v.chatters...)
+	if len(v.chatters) > v.cfg.MaxChatters {
+		v.chatters = v.chatters[:v.cfg.MaxChatters]
+	}
+	v.mu.Unlock()
+
+	v.rebuild()
+}
+
+// Start reads the sources in the background, now and every refresh
+// interval until ctx is done
+func (v *Vocabulary) Start(ctx context.Context) {
+	go func() {
+		v.refresh(ctx)
+
+		ticker := time.NewTicker(time.Duration(v.cfg.RefreshSeconds) * time.Second)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				v.refresh(ctx)
+			}
+		}
+	}()
+}
+
+// refresh reads every source. A source that fails keeps its last terms.
+func (v *Vocabulary) refresh(ctx context.Context) {
+	v.mu.RLock()
+	sources := slices.Clone(v.sources)
+	v.mu.RUnlock()
+
+	for _, src := range sources {
+		ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		terms, err := src.list(ctx)
+		cancel()
+		if err != nil {
+			v.log.Debug().Err(err).Str("source", src.name).Msg("Cannot read vocabulary source")
+			continue
+		}
+
+		v.mu.Lock()
+		src.terms = appendTerms(nil, terms...)
+		v.mu.Unlock()
+	}
+
+	v.rebuild()
+}
+
+// Terms returns every known term in prompt order: Ana and the configured
+// terms, recent chatters, then the sources in the order they were added
+func (v *Vocabulary) Terms() []string {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	return v.termsLocked()
+}
+
+func (v *Vocabulary) termsLocked() []string {
+	terms := appendTerms(nil, v.static...)
+	terms = appendTerms(terms, v.chatters...)
+	for _, src := range v.sources {
+		terms = appendTerms(terms, src.terms...)
+	}
+	return terms
+}
+
+// Prompt returns the terms as a Whisper prompt, cut to MaxPromptChars
+func (v *Vocabulary) Prompt() string {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	return v.prompt
+}
+
+// rebuild updates the prompt and the correction index after a change and
+// reports a new prompt
+func (v *Vocabulary) rebuild() {
+	v.mu.Lock()
+	terms := v.termsLocked()
+
+	var prompt strings.Builder
+	for _, term := range terms {
+		if prompt.Len()+len(term)+2 > v.cfg.MaxPromptChars {
+			break
+		}
+		if prompt.Len() > 0 {
+			prompt.WriteString(", ")
+		}
+		prompt.WriteString(term)
+	}
+	if prompt.Len() > 0 {
+		prompt.WriteString(".")
+	}
+
+	v.index = buildIndex(terms)
+	changed := prompt.String() != v.prompt
+	v.prompt = prompt.String()
+	onChange := v.onChange
+	v.mu.Unlock()
+
+	if changed {
+		v.log.Debug().Int("terms", len(terms)).Str("prompt", prompt.String()).Msg("Vocabulary updated")
+		if onChange != nil {
+			onChange(prompt.String())
+		}
+	}
+}
+
+// appendTerms adds the terms not in list yet, ignoring case
+func appendTerms(list []string, terms ...string) []string {
+	for _, term := range terms {
+		term = strings.TrimSpace(term)
+		if term == "" {
+			continue
+		}
+		if !slices.ContainsFunc(list, func(t string) bool { return strings.EqualFold(t, term) }) {
+			list = append(list, term)
+		}
+	}
+	return list
+}
@@ -0,0 +1,52 @@
+// Package wakeword spots the wake word directly in PCM audio so the pipeline
+// only starts a recording and transcription once it has been heard
+package wakeword
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/anastreamer/ana/pkg/utils"
+)
+
+// Detection describes a wake word match
+type Detection struct {
+	Word   string
+	Score  float64       // Similarity between 0 and 1
+	Offset time.Duration // Stream position where the match ended, since the last Reset
+}
+
+// Detector consumes 16-bit mono PCM frames and reports when the wake word has
+// just been spoken. Implementations are not safe for concurrent use.
+type Detector interface {
+	// Process feeds samples and returns the best match in them. The bool is
+	// true when the score reached the configured threshold.
+	Process(samples []int16) (Detection, bool)
+
+	// Reset forgets buffered audio, e.g. after a detection or a recording
+	Reset()
+}
+
+// ScanWAV runs a detector over a WAV file, feeding it in chunks the size the
+// microphone delivers. It returns every detection plus the best score seen,
+// which helps tune the threshold against recorded clips.
+func ScanWAV(d Detector, path string, sampleRate, chunkSize int) ([]Detection, float64, error) {
+	samples, rate, err := utils.ReadWAV(path)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	samples = utils.Resample(samples, rate, sampleRate)
+
+	d.Reset()
+	var detections []Detection
+	best := 0.0
+	for start := 0; start < len(samples); start += chunkSize {
+		end := min(start+chunkSize, len(samples))
+		det, ok := d.Process(samples[start:end])
+		best = max(best, det.Score)
+		if ok {
+			detections = append(detections, det)
+		}
+	}
+	return detections, best, nil
+}
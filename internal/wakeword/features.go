@@ -0,0 +1,159 @@
+package wakeword
+
+import (
+	"math"
+	"math/cmplx"
+
+	"github.com/anastreamer/ana/pkg/utils"
+)
+
+const (
+	numMelFilters = 26
+	numCepstra    = 12 // c1..c12; c0 is left out so gain doesn't matter
+	preEmphasis   = 0.97
+)
+
+// frame holds the features of one 25 ms analysis window
+type frame struct {
+	mfcc   []float64
+	energy float64 // Natural log of the frame power
+}
+
+// featureExtractor turns a PCM stream into MFCC frames (25 ms windows every
+// 10 ms), keeping leftover samples between calls
+type featureExtractor struct {
+	frameLen int
+	hop      int
+	nfft     int
+
+	window  []float64
+	filters [][]float64 // Mel filterbank over the power spectrum bins
+	dct     [][]float64
+
+	pending []float64
+	buf     []complex128
+}
+
+// newFeatureExtractor creates an extractor for the given sample rate
+func newFeatureExtractor(sampleRate int) *featureExtractor {
+	frameLen := sampleRate * 25 / 1000
+	nfft := utils.NextPowerOfTwo(frameLen)
+
+	f := &featureExtractor{
+		frameLen: frameLen,
+		hop:      sampleRate / 100,
+		nfft:     nfft,
+		window:   make([]float64, frameLen),
+		buf:      make([]complex128, nfft),
+	}
+
+	for i := range f.window {
+		f.window[i] = 0.54 - 0.46*math.Cos(2*math.Pi*float64(i)/float64(frameLen-1))
+	}
+	f.filters = melFilterbank(sampleRate, nfft)
+
+	f.dct = make([][]float64, numCepstra)
+	for k := range f.dct {
+		f.dct[k] = make([]float64, numMelFilters)
+		for n := range f.dct[k] {
+			f.dct[k][n] = math.Cos(math.Pi * float64(k+1) * (float64(n) + 0.5) / numMelFilters)
+		}
+	}
+
+	return f
+}
+
+// push adds samples and returns the frames that became complete
+func (f *featureExtractor) push(samples []int16) []frame {
+	for _, s := range samples {
+		f.pending = append(f.pending, float64(s)/32768)
+	}
+
+	var frames []frame
+	for len(f.pending) >= f.frameLen {
+		frames = append(frames, f.compute(f.pending[:f.frameLen]))
+		f.pending = f.pending[f.hop:]
+	}
+
+	// Compact so the backing array doesn't grow forever
+	f.pending = append(f.pending[:0:0], f.pending...)
+	return frames
+}
+
+// reset drops buffered samples
+func (f *featureExtractor) reset() {
+	f.pending = nil
+}
+
+// compute extracts the features of one window
+func (f *featureExtractor) compute(samples []float64) frame {
+	var power float64
+	for i := range f.buf {
+		if i >= len(samples) {
+			f.buf[i] = 0
+			continue
+		}
+		s := samples[i]
+		if i > 0 {
+			s -= preEmphasis * samples[i-1]
+		}
+		power += samples[i] * samples[i]
+		f.buf[i] = complex(s*f.window[i], 0)
+	}
+	utils.FFT(f.buf)
+
+	spectrum := make([]float64, f.nfft/2+1)
+	for i := range spectrum {
+		m := cmplx.Abs(f.buf[i])
+		spectrum[i] = m * m
+	}
+
+	logMel := make([]float64, numMelFilters)
+	for i, filter := range f.filters {
+		var e float64
+		for bin, w := range filter {
+			e += w * spectrum[bin]
+		}
+		logMel[i] = math.Log(math.Max(e, 1e-10))
+	}
+
+	mfcc := make([]float64, numCepstra)
+	for k, basis := range f.dct {
+		for n, v := range logMel {
+			mfcc[k] += basis[n] * v
+		}
+	}
+
+	return frame{
+		mfcc:   mfcc,
+		energy: math.Log(math.Max(power/float64(len(samples)), 1e-10)),
+	}
+}
+
+// melFilterbank builds triangular filters spaced evenly on the mel scale
+func melFilterbank(sampleRate, nfft int) [][]float64 {
+	toMel := func(hz float64) float64 { return 2595 * math.Log10(1+hz/700) }
+	toHz := func(mel float64) float64 { return 700 * (math.Pow(10, mel/2595) - 1) }
+
+	low := toMel(64)
+	high := toMel(math.Min(float64(sampleRate)/2, 8000))
+
+	bins := make([]int, numMelFilters+2)
+	for i := range bins {
+		hz := toHz(low + (high-low)*float64(i)/float64(numMelFilters+1))
+		bins[i] = int(math.Floor(float64(nfft+1) * hz / float64(sampleRate)))
+	}
+
+	filters := make([][]float64, numMelFilters)
+	for m := range filters {
+		filters[m] = make([]float64, nfft/2+1)
+		left, center, right := bins[m], bins[m+1], bins[m+2]
+		for k := left; k < center; k++ {
+			filters[m][k] = float64(k-left) / float64(max(center-left, 1))
+		}
+		for k := center; k < right && k < len(filters[m]); k++ {
+			filters[m][k] = float64(right-k) / float64(max(right-center, 1))
+		}
+	}
+	return filters
+}
@@ -0,0 +1,289 @@
+package wakeword
+
+import (
+	"fmt"
+	"math"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/anastreamer/ana/internal/config"
+	"github.com/anastreamer/ana/pkg/logger"
+	"github.com/anastreamer/ana/pkg/utils"
+	"github.com/rs/zerolog"
+)
+
+const (
+	evalEvery      = 3   // Frames between evaluations (30 ms)
+	refractory     = 100 // Frames to ignore after a detection (1 s)
+	minTemplateLen = 15  // Frames (150 ms)
+	speechMargin   = 2.3 // Frame log power above the noise floor (10 dB) counted as speech
+	trimMargin     = 6.9 // Template frames quieter than the peak by this (30 dB) are trimmed
+)
+
+// TemplateDetector spots the wake word by comparing the incoming audio with
+// a few recordings of the streamer saying it. Each clip becomes a sequence of
+// MFCC frames; the most recent audio is aligned to every sequence with
+// dynamic time warping and the best similarity is compared to the threshold.
+// It needs no model download and runs in well under a millisecond per chunk.
+//
+// Templates are WAV files in <templates_dir>/<word>/, ideally 3 to 5 short
+// clips recorded with the streaming mic.
+type TemplateDetector struct {
+	word       string
+	threshold  float64
+	sampleRate int
+
+	features  *featureExtractor
+	templates [][][]float64
+	maxLen    int
+
+	history    []frame
+	noiseFloor float64
+	frames     int // Frames processed since Reset
+	lastHit    int
+
+	log zerolog.Logger
+}
+
+// NewTemplateDetector loads the templates for cfg.Word
+func NewTemplateDetector(cfg config.WakeWordConfig, sampleRate int) (*TemplateDetector, error) {
+	dir := filepath.Join(cfg.TemplatesDir, strings.ToLower(cfg.Word))
+	files, err := filepath.Glob(filepath.Join(dir, "*.wav"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+
+	d := &TemplateDetector{
+		word:       cfg.Word,
+		threshold:  cfg.Threshold,
+		sampleRate: sampleRate,
+		features:   newFeatureExtractor(sampleRate),
+		log:        logger.Component("wakeword"),
+	}
+
+	for _, file := range files {
+		tmpl, err := d.loadTemplate(file)
+		if err != nil {
+			d.log.Warn().Err(err).Str("file", file).Msg("Skipping wake word template")
+			continue
+		}
+		d.templates = append(d.templates, tmpl)
+		d.maxLen = max(d.maxLen, len(tmpl))
+	}
+
+	if len(d.templates) == 0 {
+		return nil, fmt.Errorf("no wake word templates for %q in %s (record a few WAV clips of the word)", cfg.Word, dir)
+	}
+
+	d.log.Info().
+		Str("word", cfg.Word).
+		Int("templates", len(d.templates)).
+		Float64("threshold", cfg.Threshold).
+		Msg("Wake word detector ready")
+
+	d.Reset()
+	return d, nil
+}
+
+// loadTemplate extracts the normalized features of the voiced part of a clip
+func (d *TemplateDetector) loadTemplate(path string) ([][]float64, error) {
+	samples, rate, err := utils.ReadWAV(path)
+	if err != nil {
+		return nil, err
+	}
+	samples = utils.Resample(samples, rate, d.sampleRate)
+
+	frames := newFeatureExtractor(d.sampleRate).push(samples)
+	if len(frames) == 0 {
+		return nil, fmt.Errorf("clip is too short")
+	}
+
+	// Trim leading and trailing silence
+	peak := math.Inf(-1)
+	for _, f := range frames {
+		peak = math.Max(peak, f.energy)
+	}
+	start, end := 0, len(frames)
+	for start < end && frames[start].energy < peak-trimMargin {
+		start++
+	}
+	for end > start && frames[end-1].energy < peak-trimMargin {
+		end--
+	}
+	frames = frames[start:end]
+
+	if len(frames) < minTemplateLen {
+		return nil, fmt.Errorf("voiced part is too short (%d ms)", len(frames)*10)
+	}
+
+	return normalize(frames, len(frames)), nil
+}
+
+// Process implements Detector
+func (d *TemplateDetector) Process(samples []int16) (Detection, bool) {
+	best := Detection{Word: d.word}
+
+	for _, f := range d.features.push(samples) {
+		d.frames++
+		d.trackNoise(f.energy)
+
+		d.history = append(d.history, f)
+		if limit := d.maxLen * 3 / 2; len(d.history) > limit {
+			d.history = d.history[len(d.history)-limit:]
+		}
+
+		if d.frames%evalEvery != 0 || d.frames-d.lastHit < refractory {
+			continue
+		}
+
+		score := d.evaluate()
+		if score <= best.Score {
+			continue
+		}
+		best.Score = score
+		best.Offset = time.Duration(d.frames) * 10 * time.Millisecond
+
+		if score >= d.threshold {
+			d.log.Debug().Float64("score", score).Msg("Wake word matched")
+			d.lastHit = d.frames
+			d.history = d.history[:0]
+			return best, true
+		}
+	}
+
+	return best, false
+}
+
+// Reset implements Detector
+func (d *TemplateDetector) Reset() {
+	d.features.reset()
+	d.history = d.history[:0]
+	d.frames = 0
+	d.lastHit = -refractory
+	d.noiseFloor = math.Inf(1)
+}
+
+// trackNoise follows the background level: it drops at once to quieter
+// frames and rises slowly, so speech barely moves it
+func (d *TemplateDetector) trackNoise(energy float64) {
+	if energy < d.noiseFloor {
+		d.noiseFloor = energy
+		return
+	}
+	d.noiseFloor += (energy - d.noiseFloor) * 0.002
+}
+
+// evaluate returns the best similarity between the recent audio and any template
+func (d *TemplateDetector) evaluate() float64 {
+	best := 0.0
+	for _, tmpl := range d.templates {
+		n := len(tmpl)
+		if len(d.history) < n*2/3 {
+			continue
+		}
+
+		// The word must have just ended: the tail has to be speech, not
+		// a match found in audio that is mostly silence
+		tail := d.history[max(len(d.history)-n, 0):]
+		var loud int
+		for _, f := range tail {
+			if f.energy > d.noiseFloor+speechMargin {
+				loud++
+			}
+		}
+		if loud < len(tail)/2 {
+			continue
+		}
+
+		window := d.history[max(len(d.history)-n*3/2, 0):]
+		best = math.Max(best, similarity(tmpl, normalize(window, len(tail))))
+	}
+	return best
+}
+
+// normalize subtracts the cepstral mean of the last n frames, so the same
+// word matches across mics and rooms, and returns the feature vectors
+func normalize(frames []frame, n int) [][]float64 {
+	mean := make([]float64, numCepstra)
+	for _, f := range frames[len(frames)-n:] {
+		for i, v := range f.mfcc {
+			mean[i] += v / float64(n)
+		}
+	}
+
+	out := make([][]float64, len(frames))
+	for i, f := range frames {
+		out[i] = make([]float64, numCepstra)
+		for j, v := range f.mfcc {
+			out[i][j] = v - mean[j]
+		}
+	}
+	return out
+}
+
+// similarity aligns the whole template against a suffix of the window that
+// ends at its last frame (subsequence DTW with a free start) and returns
+// one minus the average cosine distance along the best path
+func similarity(tmpl, window [][]float64) float64 {
+	n, m := len(tmpl), len(window)
+
+	type cell struct {
+		cost  float64
+		steps int
+		start int
+	}
+	prev := make([]cell, m)
+	curr := make([]cell, m)
+
+	for i := 0; i < n; i++ {
+		for j := 0; j < m; j++ {
+			c := cosineDistance(tmpl[i], window[j])
+
+			if i == 0 {
+				// Free start anywhere in the window
+				curr[j] = cell{cost: c, steps: 1, start: j}
+				continue
+			}
+
+			// Predecessors are compared by average cost so long detours
+			// aren't favored over short ones
+			best := prev[j]
+			if j > 0 {
+				for _, cand := range [2]cell{prev[j-1], curr[j-1]} {
+					if cand.cost/float64(cand.steps) < best.cost/float64(best.steps) {
+						best = cand
+					}
+				}
+			}
+			curr[j] = cell{cost: best.cost + c, steps: best.steps + 1, start: best.start}
+		}
+		prev, curr = curr, prev
+	}
+
+	end := prev[m-1]
+
+	// Reject alignments that squeeze or stretch the word too much
+	span := m - end.start
+	if span < n/2 || span > n*2 {
+		return 0
+	}
+
+	return math.Max(0, 1-end.cost/float64(end.steps))
+}
+
+// cosineDistance returns 1 - cos(a, b), between 0 and 2
+func cosineDistance(a, b []float64) float64 {
+	var dot, na, nb float64
+	for i := range a {
+		dot += a[i] * b[i]
+		na += a[i] * a[i]
+		nb += b[i] * b[i]
+	}
+	if na == 0 || nb == 0 {
+		return 1
+	}
+	return 1 - dot/math.Sqrt(na*nb)
+}
@@ -0,0 +1,97 @@
+package wakeword
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/anastreamer/ana/internal/config"
+	"github.com/anastreamer/ana/pkg/utils"
+)
+
+// newTestDetector loads the templates in testdata with the default settings
+func newTestDetector(t *testing.T) (*TemplateDetector, *config.Config) {
+	t.Helper()
+	cfg := config.DefaultConfig()
+	cfg.Audio.WakeWord.TemplatesDir = filepath.Join("testdata", "templates")
+
+	d, err := NewTemplateDetector(cfg.Audio.WakeWord, cfg.Audio.SampleRate)
+	if err != nil {
+		t.Fatalf("NewTemplateDetector: %v", err)
+	}
+	if len(d.templates) != 3 {
+		t.Fatalf("loaded %d templates, want 3", len(d.templates))
+	}
+	return d, cfg
+}
+
+func TestScanWAV(t *testing.T) {
+	d, cfg := newTestDetector(t)
+	threshold := cfg.Audio.WakeWord.Threshold
+
+	// Where the word is said in each clip (see testdata/generate.go)
+	ms := time.Millisecond
+	tests := []struct {
+		file  string
+		words [][2]time.Duration
+	}{
+		{"positive/sentence.wav", [][2]time.Duration{{1260 * ms, 1639 * ms}}},
+		{"positive/48k_quiet.wav", [][2]time.Duration{{1000 * ms, 1343 * ms}}},
+		{"positive/twice.wav", [][2]time.Duration{{500 * ms, 833 * ms}, {2333 * ms, 2725 * ms}}},
+		{"negative/other_words.wav", nil},
+		{"negative/other_voice.wav", nil},
+		{"negative/noise.wav", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			detections, best, err := ScanWAV(d, filepath.Join("testdata", tt.file), cfg.Audio.SampleRate, cfg.Audio.ChunkSize)
+			if err != nil {
+				t.Fatalf("ScanWAV: %v", err)
+			}
+			if len(detections) != len(tt.words) {
+				t.Fatalf("got %d detections %+v (best score %.3f), want %d", len(detections), detections, best, len(tt.words))
+			}
+
+			for i, det := range detections {
+				if det.Word != "ana" || det.Score < threshold {
+					t.Errorf("detection %+v is under the threshold %.2f", det, threshold)
+				}
+				// Reported while the word is said or right after it
+				if start, end := tt.words[i][0], tt.words[i][1]; det.Offset < start || det.Offset > end+300*ms {
+					t.Errorf("detection at %v, the word is said from %v to %v", det.Offset, start, end)
+				}
+			}
+			if len(tt.words) == 0 && best >= threshold {
+				t.Errorf("best score %.3f reaches the threshold %.2f", best, threshold)
+			}
+		})
+	}
+}
+
+func TestNewTemplateDetectorNoTemplates(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Audio.WakeWord.TemplatesDir = t.TempDir()
+	if _, err := NewTemplateDetector(cfg.Audio.WakeWord, cfg.Audio.SampleRate); err == nil {
+		t.Error("NewTemplateDetector succeeded without templates")
+	}
+
+	// A clip shorter than a word is skipped
+	cfg.Audio.WakeWord.TemplatesDir = t.TempDir()
+	dir := filepath.Join(cfg.Audio.WakeWord.TemplatesDir, "ana")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	tone := make([]int16, cfg.Audio.SampleRate/10)
+	for i := range tone {
+		tone[i] = int16(8000 * math.Sin(2*math.Pi*440*float64(i)/float64(cfg.Audio.SampleRate)))
+	}
+	if err := utils.SaveWAV(filepath.Join(dir, "short.wav"), utils.Int16ToBytes(tone), cfg.Audio.SampleRate, 1, 16); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewTemplateDetector(cfg.Audio.WakeWord, cfg.Audio.SampleRate); err == nil {
+		t.Error("NewTemplateDetector succeeded with only a 100 ms clip")
+	}
+}
@@ -0,0 +1,257 @@
+//go:build ignore
+
+// generate.go writes the WAV fixtures of the wake word tests: formant
+// synthesized words, close enough to speech for MFCC matching, over a little
+// background noise. Run it from internal/wakeword with
+//
+//	go run testdata/generate.go
+package main
+
+import (
+	"log"
+	"math"
+	"math/rand"
+	"path/filepath"
+
+	"github.com/anastreamer/ana/pkg/utils"
+)
+
+// phone is a stretch of voiced sound with its first three formants
+type phone struct {
+	ms         float64
+	f1, f2, f3 float64
+	gain       float64
+}
+
+var words = map[string][]phone{
+	"ana": {
+		{120, 750, 1250, 2600, 1},
+		{90, 280, 1650, 2500, 0.35},
+		{150, 780, 1200, 2650, 0.9},
+	},
+	"musica": {
+		{70, 270, 900, 2300, 0.4},
+		{110, 320, 800, 2300, 0.9},
+		{80, 300, 2300, 3000, 0.15},
+		{100, 290, 2250, 3000, 0.8},
+		{70, 400, 1800, 2600, 0.2},
+		{120, 730, 1300, 2600, 0.9},
+	},
+	"hola": {
+		{140, 480, 880, 2500, 1},
+		{70, 360, 1200, 2600, 0.45},
+		{150, 760, 1260, 2600, 0.9},
+	},
+	"siguiente": {
+		{100, 290, 2200, 3000, 0.8},
+		{80, 330, 1000, 2400, 0.3},
+		{90, 300, 2100, 2900, 0.8},
+		{110, 520, 1850, 2600, 0.9},
+		{70, 280, 1600, 2600, 0.35},
+		{90, 480, 1900, 2600, 0.6},
+	},
+}
+
+// voice renders a word with a given pitch, tempo and formant shift
+type voice struct {
+	f0     float64 // Hz
+	tempo  float64 // >1 is faster
+	shift  float64 // Formant scale, for a different speaker
+	rate   int
+	jitter *rand.Rand
+}
+
+func (v voice) say(word string) []float64 {
+	phones := words[word]
+	var out []float64
+
+	// Two-pole resonators per formant, coefficients updated per sample
+	var y1, y2 [3]float64
+	phase := 0.0
+	for p, ph := range phones {
+		n := int(ph.ms / v.tempo * float64(v.rate) / 1000)
+		next := ph
+		if p+1 < len(phones) {
+			next = phones[p+1]
+		}
+		for i := 0; i < n; i++ {
+			// Glide into the next phone over the last 30% of this one
+			t := math.Max(0, (float64(i)/float64(n)-0.7)/0.3)
+			formants := [3]float64{
+				lerp(ph.f1, next.f1, t) * v.shift,
+				lerp(ph.f2, next.f2, t) * v.shift,
+				lerp(ph.f3, next.f3, t) * v.shift,
+			}
+			gain := lerp(ph.gain, next.gain, t)
+
+			// Glottal pulses with some vibrato and jitter
+			f0 := v.f0 * (1 + 0.02*math.Sin(2*math.Pi*5*float64(len(out))/float64(v.rate)))
+			phase += f0 / float64(v.rate) * (1 + 0.01*v.jitter.NormFloat64())
+			x := 0.0
+			if phase >= 1 {
+				phase -= 1
+				x = 1
+			}
+			x += 0.02 * v.jitter.NormFloat64() // Breath
+
+			for k, f := range formants {
+				bw := 60 + 0.06*f
+				r := math.Exp(-math.Pi * bw / float64(v.rate))
+				a1 := 2 * r * math.Cos(2*math.Pi*f/float64(v.rate))
+				a2 := -r * r
+				y := (1-r)*x + a1*y1[k] + a2*y2[k]
+				y2[k], y1[k] = y1[k], y
+				x = y
+			}
+			out = append(out, x*gain)
+		}
+	}
+
+	// Fade in and out to avoid clicks
+	fade := v.rate / 100
+	for i := 0; i < fade && i < len(out); i++ {
+		g := float64(i) / float64(fade)
+		out[i] *= g
+		out[len(out)-1-i] *= g
+	}
+	return normalizePeak(out, 0.5)
+}
+
+// clip lays words (or silence, for "") out after each other over noise
+type clip struct {
+	rate  int
+	noise float64 // Noise amplitude relative to full scale
+	parts []part
+}
+
+type part struct {
+	word    string
+	voice   voice
+	pauseMs float64 // Silence after it
+	level   float64
+}
+
+func (c clip) render(seed int64) []int16 {
+	noise := rand.New(rand.NewSource(seed))
+	var mix []float64
+	for _, p := range c.parts {
+		if p.word != "" {
+			p.voice.rate = c.rate
+			p.voice.jitter = rand.New(rand.NewSource(seed + int64(len(mix))))
+			for _, s := range p.voice.say(p.word) {
+				mix = append(mix, s*p.level)
+			}
+		}
+		mix = append(mix, make([]float64, int(p.pauseMs*float64(c.rate)/1000))...)
+	}
+
+	// Lowpassed noise, like a room
+	out := make([]int16, len(mix))
+	lp := 0.0
+	for i, s := range mix {
+		lp += (noise.NormFloat64() - lp) * 0.3
+		v := (s + lp*c.noise) * 32767
+		out[i] = int16(math.Max(-32768, math.Min(32767, v)))
+	}
+	return out
+}
+
+func lerp(a, b, t float64) float64 { return a + (b-a)*t }
+
+func normalizePeak(samples []float64, peak float64) []float64 {
+	m := 0.0
+	for _, s := range samples {
+		m = math.Max(m, math.Abs(s))
+	}
+	if m == 0 {
+		return samples
+	}
+	for i := range samples {
+		samples[i] *= peak / m
+	}
+	return samples
+}
+
+func write(path string, c clip, seed int64) {
+	samples := c.render(seed)
+	if err := utils.EnsureDir(filepath.Dir(path)); err != nil {
+		log.Fatal(err)
+	}
+	if err := utils.SaveWAV(path, utils.Int16ToBytes(samples), c.rate, 1, 16); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func main() {
+	streamer := voice{f0: 200, tempo: 1, shift: 1}
+	other := voice{f0: 120, tempo: 1.05, shift: 0.9}
+
+	// Templates: the streamer saying the word a few times, a bit differently
+	templates := []voice{
+		{f0: 200, tempo: 1, shift: 1},
+		{f0: 215, tempo: 1.12, shift: 1.02},
+		{f0: 188, tempo: 0.9, shift: 0.98},
+	}
+	for i, v := range templates {
+		write(filepath.Join("testdata", "templates", "ana", []string{"1.wav", "2.wav", "3.wav"}[i]), clip{
+			rate:  16000,
+			noise: 0.002,
+			parts: []part{{pauseMs: 200}, {word: "ana", voice: v, level: 1, pauseMs: 200}},
+		}, int64(i+1))
+	}
+
+	// The word in a sentence, at another rate, pitch and tempo
+	write("testdata/positive/sentence.wav", clip{
+		rate:  16000,
+		noise: 0.006,
+		parts: []part{
+			{pauseMs: 600},
+			{word: "hola", voice: streamer, level: 0.8, pauseMs: 300},
+			{word: "ana", voice: voice{f0: 207, tempo: 0.95, shift: 1.01}, level: 0.9, pauseMs: 250},
+			{word: "siguiente", voice: streamer, level: 0.8, pauseMs: 800},
+		},
+	}, 10)
+	write("testdata/positive/48k_quiet.wav", clip{
+		rate:  48000,
+		noise: 0.004,
+		parts: []part{
+			{pauseMs: 1000},
+			{word: "ana", voice: voice{f0: 195, tempo: 1.05, shift: 0.99}, level: 0.3, pauseMs: 1000},
+		},
+	}, 11)
+	write("testdata/positive/twice.wav", clip{
+		rate:  16000,
+		noise: 0.008,
+		parts: []part{
+			{pauseMs: 500},
+			{word: "ana", voice: voice{f0: 210, tempo: 1.08, shift: 1}, level: 1, pauseMs: 1500},
+			{word: "ana", voice: voice{f0: 192, tempo: 0.92, shift: 1}, level: 1, pauseMs: 500},
+		},
+	}, 12)
+
+	// Other words, other voices and noise alone
+	write("testdata/negative/other_words.wav", clip{
+		rate:  16000,
+		noise: 0.01,
+		parts: []part{
+			{pauseMs: 500},
+			{word: "hola", voice: streamer, level: 0.9, pauseMs: 300},
+			{word: "musica", voice: streamer, level: 0.9, pauseMs: 300},
+			{word: "siguiente", voice: streamer, level: 0.9, pauseMs: 500},
+		},
+	}, 20)
+	write("testdata/negative/other_voice.wav", clip{
+		rate:  16000,
+		noise: 0.01,
+		parts: []part{
+			{pauseMs: 500},
+			{word: "musica", voice: other, level: 0.9, pauseMs: 300},
+			{word: "hola", voice: other, level: 0.9, pauseMs: 500},
+		},
+	}, 21)
+	write("testdata/negative/noise.wav", clip{
+		rate:  16000,
+		noise: 0.05,
+		parts: []part{{pauseMs: 3000}},
+	}, 22)
+}
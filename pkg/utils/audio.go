@@ -192,3 +192,96 @@ func FileExists(path string) bool {
 func GetTempFilePath(prefix, suffix string) string {
 	return filepath.Join(os.TempDir(), fmt.Sprintf("%s_%d%s", prefix, os.Getpid(), suffix))
 }
+
+// ReadWAV reads a 16-bit PCM WAV file and returns its samples mixed down to
+// mono along with the sample rate
+func ReadWAV(filename string) ([]int16, int, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, 0, err
+	}
+	return DecodeWAV(data)
+}
+
+// DecodeWAV decodes 16-bit PCM WAV data, mixing multiple channels down to mono
+func DecodeWAV(data []byte) ([]int16, int, error) {
+	if len(data) < 12 || string(data[0:4]) != "RIFF" || string(data[8:12]) != "WAVE" {
+		return nil, 0, fmt.Errorf("not a WAV file")
+	}
+
+	var channels, bits, format uint16
+	var sampleRate uint32
+	var pcm []byte
+
+	// Walk the chunks; fmt and data are not always the first two
+	for pos := 12; pos+8 <= len(data); {
+		id := string(data[pos : pos+4])
+		size := int(binary.LittleEndian.Uint32(data[pos+4 : pos+8]))
+		body := data[pos+8:]
+		if size > len(body) {
+			size = len(body) // Truncated or streamed file with a bogus size
+		}
+		body = body[:size]
+
+		switch id {
+		case "fmt ":
+			if size < 16 {
+				return nil, 0, fmt.Errorf("invalid fmt chunk")
+			}
+			format = binary.LittleEndian.Uint16(body[0:2])
+			channels = binary.LittleEndian.Uint16(body[2:4])
+			sampleRate = binary.LittleEndian.Uint32(body[4:8])
+			bits = binary.LittleEndian.Uint16(body[14:16])
+		case "data":
+			pcm = body
+		}
+
+		pos += 8 + size + size%2
+	}
+
+	// 0xFFFE is WAVE_FORMAT_EXTENSIBLE, which wraps plain PCM too
+	if (format != 1 && format != 0xFFFE) || bits != 16 || channels == 0 {
+		return nil, 0, fmt.Errorf("unsupported WAV format (need 16-bit PCM, got format %d, %d bits)", format, bits)
+	}
+	if pcm == nil {
+		return nil, 0, fmt.Errorf("WAV file has no data chunk")
+	}
+
+	interleaved := BytesToInt16(pcm)
+	if channels == 1 {
+		return interleaved, int(sampleRate), nil
+	}
+
+	n := int(channels)
+	mono := make([]int16, len(interleaved)/n)
+	for i := range mono {
+		var sum int
+		for c := 0; c < n; c++ {
+			sum += int(interleaved[i*n+c])
+		}
+		mono[i] = int16(sum / n)
+	}
+	return mono, int(sampleRate), nil
+}
+
+// Resample converts mono samples between sample rates with linear interpolation
+func Resample(samples []int16, fromRate, toRate int) []int16 {
+	if fromRate == toRate || fromRate <= 0 || toRate <= 0 || len(samples) == 0 {
+		return samples
+	}
+
+	n := int(int64(len(samples)) * int64(toRate) / int64(fromRate))
+	out := make([]int16, n)
+	step := float64(fromRate) / float64(toRate)
+	for i := range out {
+		pos := float64(i) * step
+		idx := int(pos)
+		if idx >= len(samples)-1 {
+			out[i] = samples[len(samples)-1]
+			continue
+		}
+		frac := pos - float64(idx)
+		out[i] = int16(float64(samples[idx])*(1-frac) + float64(samples[idx+1])*frac)
+	}
+	return out
+}